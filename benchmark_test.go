@@ -36,6 +36,52 @@ func BenchmarkFromYAMLOnly(b *testing.B) {
 	}
 }
 
+func BenchmarkAppendYAML(b *testing.B) {
+	b.ReportAllocs()
+	dst := make([]byte, 0, 4096)
+	for b.Loop() {
+		var err error
+		if dst, err = AppendYAML(dst[:0], frontmatter1YAMLBytes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendTOML(b *testing.B) {
+	b.ReportAllocs()
+	dst := make([]byte, 0, 4096)
+	for b.Loop() {
+		var err error
+		if dst, err = AppendTOML(dst[:0], frontmatter1TOMLBytes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConverterYAML(b *testing.B) {
+	b.ReportAllocs()
+	var c Converter
+	dst := make([]byte, 0, 4096)
+	for b.Loop() {
+		var err error
+		if dst, err = c.AppendYAML(dst[:0], frontmatter1YAMLBytes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConverterTOML(b *testing.B) {
+	b.ReportAllocs()
+	var c Converter
+	dst := make([]byte, 0, 4096)
+	for b.Loop() {
+		var err error
+		if dst, err = c.AppendTOML(dst[:0], frontmatter1TOMLBytes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 const frontmatter1JSON = `{
    "date": "2024-02-02T04:14:54-08:00",
    "draft": false,
@@ -106,7 +152,7 @@ func BenchmarkFromTOMLLineSmall(b *testing.B) {
 func BenchmarkFromTOMLTreeSmall(b *testing.B) {
 	b.ReportAllocs()
 	for b.Loop() {
-		if _, err := tomlConvertTree(frontmatter1TOMLBytes); err != nil {
+		if _, err := fromTOMLTree(frontmatter1TOMLBytes); err != nil {
 			b.Fatal(err)
 		}
 	}
@@ -126,7 +172,54 @@ func BenchmarkFromTOMLLineLarge(b *testing.B) {
 func BenchmarkFromTOMLTreeLarge(b *testing.B) {
 	b.ReportAllocs()
 	for b.Loop() {
-		if _, err := tomlConvertTree(benchTOMLBytes); err != nil {
+		if _, err := fromTOMLTree(benchTOMLBytes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Re-entry benchmarks compare a file whose tables are each written in one
+// place with the same tables split in two, every second half after all the
+// first halves, as in a hand-edited file. Both convert to the same JSON.
+
+var benchTOMLInOrder, benchTOMLReentry = benchTOMLSections(200)
+
+// benchTOMLSections returns n tables [sN.a] and [sN.b], in order and with
+// every [sN.b] after every [sN.a].
+func benchTOMLSections(n int) (inOrder, reentry []byte) {
+	var first, second bytes.Buffer
+	for i := range n {
+		a := fmt.Sprintf("[s%d.a]\nname = 'alpha'\nports = [8000, 8001]\n", i)
+		b := fmt.Sprintf("[s%d.b]\nenabled = true\nweight = 1.5\n", i)
+		inOrder = append(inOrder, a+b...)
+		first.WriteString(a)
+		second.WriteString(b)
+	}
+	return inOrder, append(first.Bytes(), second.Bytes()...)
+}
+
+func BenchmarkFromTOMLInOrder(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := FromTOML(benchTOMLInOrder); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFromTOMLReentry(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := FromTOML(benchTOMLReentry); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFromTOMLTreeReentry(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := fromTOMLTree(benchTOMLReentry); err != nil {
 			b.Fatal(err)
 		}
 	}
@@ -162,7 +255,7 @@ func BenchmarkDecodeFile(b *testing.B) {
 
 	for b.Loop() {
 		dst.Reset()
-		d := decoder{out: &dst}
+		d := decoder{out: &dst, keys: dupKeys{policy: DuplicateKeyLast}} // the sample repeats a key
 		err := d.Translate(data)
 		if err != nil && err != io.EOF {
 			b.Errorf("JsonRx - Decode failed %v", err)
@@ -175,7 +268,7 @@ func BenchmarkJson(b *testing.B) {
 		log.Fatalf("Cant read file - %v", err)
 	}
 	var dst bytes.Buffer
-	d := decoder{out: &dst}
+	d := decoder{out: &dst, keys: dupKeys{policy: DuplicateKeyLast}} // the sample repeats a key
 	err = d.Translate(data)
 	if err != nil && err != io.EOF {
 		b.Errorf("JsonRx - Decode failed %v", err)
@@ -196,7 +289,7 @@ func BenchmarkInt(b *testing.B) {
 	out := &bytes.Buffer{}
 	for b.Loop() {
 		out.Reset()
-		writeInt(out, data)
+		writeInt(out, data, intFormat{})
 	}
 }
 func BenchmarkFloatFast(b *testing.B) {
@@ -220,7 +313,7 @@ func BenchmarkHex(b *testing.B) {
 	out := &bytes.Buffer{}
 	for b.Loop() {
 		out.Reset()
-		writeHex(out, data)
+		writeHex(out, data, intFormat{})
 	}
 }
 func BenchmarkString(b *testing.B) {
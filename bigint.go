@@ -0,0 +1,151 @@
+package tojson
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// appendRadixInt appends to dst the decimal form of the unsigned integer
+// whose digits in base are digits, with a '-' sign if neg. Values beyond
+// uint64 are converted with math/big, so any number of digits is exact. The
+// error is strconv's for digits that are not valid in base.
+func appendRadixInt(dst, digits []byte, base int, neg bool) ([]byte, error) {
+	v, err := strconv.ParseUint(string(digits), base, 64)
+	if err == nil {
+		if neg {
+			dst = append(dst, '-')
+		}
+		return strconv.AppendUint(dst, v, 10), nil
+	}
+	if !errors.Is(err, strconv.ErrRange) {
+		return dst, err
+	}
+	var n big.Int
+	n.SetString(string(digits), base) // valid, as ParseUint found only a range error
+	if neg {
+		n.Neg(&n)
+	}
+	return n.Append(dst, 10), nil
+}
+
+// isBigInt reports whether num, the JSON text of a number, is an integer
+// outside the range of both int64 and uint64.
+func isBigInt(num []byte) bool {
+	digits := bytes.TrimPrefix(num, []byte("-"))
+	if len(digits) < 19 || !isDigits(digits) {
+		return false // shorter than the 19 digits of 2^63, or not an integer
+	}
+	var err error
+	if len(digits) < len(num) {
+		_, err = strconv.ParseInt(string(num), 10, 64)
+	} else {
+		_, err = strconv.ParseUint(string(num), 10, 64)
+	}
+	return err != nil
+}
+
+// isInt64 reports whether num, the JSON text of a number, is not an integer
+// outside the range of int64.
+func isInt64(num []byte) bool {
+	digits := bytes.TrimPrefix(num, []byte("-"))
+	if len(digits) < 19 || !isDigits(digits) {
+		return true // shorter than the 19 digits of 2^63, or not an integer
+	}
+	_, err := strconv.ParseInt(string(num), 10, 64)
+	return err == nil
+}
+
+// UnsafeIntegerPolicy selects what conversion writes for an integer outside
+// the range that a double, and so JavaScript, represents exactly: from
+// -(2^53-1) to 2^53-1. Only numbers written as integers are affected; a
+// float such as 1e20 is left as it is.
+type UnsafeIntegerPolicy int
+
+const (
+	// UnsafeIntegerKeep writes every integer as a JSON number. It is the
+	// default.
+	UnsafeIntegerKeep UnsafeIntegerPolicy = iota
+
+	// UnsafeIntegerString writes an unsafe integer as a JSON string of its
+	// decimal digits, such as "9007199254740993", which a JavaScript reader
+	// can pass to BigInt.
+	UnsafeIntegerString
+
+	// UnsafeIntegerError reports an unsafe integer as a *ParseError at its
+	// position.
+	UnsafeIntegerError
+)
+
+// maxSafeInteger is 2^53-1, the largest integer whose neighbors a double
+// also represents exactly.
+const maxSafeInteger = "9007199254740991"
+
+// isUnsafeInt reports whether num, the JSON text of a number, is an integer
+// beyond maxSafeInteger in magnitude.
+func isUnsafeInt(num []byte) bool {
+	digits := bytes.TrimPrefix(num, []byte("-"))
+	if len(digits) < len(maxSafeInteger) || !isDigits(digits) {
+		return false
+	}
+	return len(digits) > len(maxSafeInteger) || string(digits) > maxSafeInteger
+}
+
+// intFormat is how a converter writes integers, from the UnsafeIntegers
+// option.
+type intFormat struct {
+	unsafe   UnsafeIntegerPolicy
+	within64 bool // reject an integer outside int64, as TOMLOptions.Strict does
+}
+
+func (o YAMLOptions) ints() intFormat        { return intFormat{unsafe: o.UnsafeIntegers} }
+func (o TOMLOptions) ints() intFormat        { return intFormat{o.UnsafeIntegers, o.Strict} }
+func (o JSONVariantOptions) ints() intFormat { return intFormat{unsafe: o.UnsafeIntegers} }
+
+// quote reports whether f writes num, the JSON text of a number, as a
+// string, or an error if f rejects it.
+func (f intFormat) quote(num []byte) (bool, error) {
+	if f.within64 && !isInt64(num) {
+		return false, fmt.Errorf("integer %s is outside the range of a 64-bit integer", num)
+	}
+	if f.unsafe != UnsafeIntegerKeep && isUnsafeInt(num) {
+		if f.unsafe == UnsafeIntegerError {
+			return false, fmt.Errorf("integer %s is outside the safe range of -(2^53-1) to 2^53-1", num)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// append appends num, the JSON text of a number, to dst as f writes it.
+func (f intFormat) append(dst, num []byte) ([]byte, error) {
+	q, err := f.quote(num)
+	switch {
+	case err != nil:
+		return dst, err
+	case q:
+		dst = append(dst, '"')
+		dst = append(dst, num...)
+		return append(dst, '"'), nil
+	}
+	return append(dst, num...), nil
+}
+
+// rewriteTail rewrites the number that out holds from offset start as f
+// writes it. On error the number is left in place.
+func (f intFormat) rewriteTail(out *bytes.Buffer, start int) error {
+	if f == (intFormat{}) {
+		return nil
+	}
+	num := out.Bytes()[start:]
+	q, err := f.quote(num)
+	if q {
+		b := append([]byte{'"'}, num...)
+		out.Truncate(start)
+		out.Write(b)
+		out.WriteByte('"')
+	}
+	return err
+}
@@ -0,0 +1,156 @@
+package tojson
+
+import (
+	"strings"
+	"testing"
+)
+
+// unsafeIntegers returns options with policy for every format.
+func unsafeIntegers(policy UnsafeIntegerPolicy) formatOptions {
+	return formatOptions{
+		YAMLOptions{UnsafeIntegers: policy},
+		TOMLOptions{UnsafeIntegers: policy},
+		JSONVariantOptions{UnsafeIntegers: policy},
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		input   string
+		want    string
+		strings string // output with UnsafeIntegerString
+	}{
+		{"toml decimal", "toml", "id = 340282366920938463463374607431768211455",
+			`{"id":340282366920938463463374607431768211455}`, `{"id":"340282366920938463463374607431768211455"}`},
+		{"toml negative", "toml", "n = -1_000_000_000_000_000_000_000",
+			`{"n":-1000000000000000000000}`, `{"n":"-1000000000000000000000"}`},
+		{"toml plus", "toml", "n = +99999999999999999999",
+			`{"n":99999999999999999999}`, `{"n":"99999999999999999999"}`},
+		{"toml hex", "toml", "id = 0xffff_ffff_ffff_ffff_ffff_ffff_ffff_ffff",
+			`{"id":340282366920938463463374607431768211455}`, `{"id":"340282366920938463463374607431768211455"}`},
+		{"toml octal", "toml", "n = 0o7777777777777777777777",
+			`{"n":73786976294838206463}`, `{"n":"73786976294838206463"}`},
+		{"toml binary", "toml", "n = 0b1" + "0000000000000000000000000000000000000000000000000000000000000000",
+			`{"n":18446744073709551616}`, `{"n":"18446744073709551616"}`},
+		{"toml 64-bit limits", "toml", "a = 18446744073709551615\nb = -9223372036854775808\nc = 0xffffffffffffffff",
+			`{"a":18446744073709551615,"b":-9223372036854775808,"c":18446744073709551615}`,
+			`{"a":"18446744073709551615","b":"-9223372036854775808","c":"18446744073709551615"}`},
+		{"toml just past limits", "toml", "a = 18446744073709551616\nb = -9223372036854775809",
+			`{"a":18446744073709551616,"b":-9223372036854775809}`,
+			`{"a":"18446744073709551616","b":"-9223372036854775809"}`},
+		{"toml floats untouched", "toml", "f = [1e30, 100000000000000000000.5]",
+			`{"f":[1e30,100000000000000000000.5]}`, `{"f":[1e30,100000000000000000000.5]}`},
+		{"toml inline", "toml", "t = {id = 0x1_0000_0000_0000_0000}\na = [0o2000000000000000000000]",
+			`{"t":{"id":18446744073709551616},"a":[18446744073709551616]}`,
+			`{"t":{"id":"18446744073709551616"},"a":["18446744073709551616"]}`},
+		{"toml tree", "toml", "[a]\n[b]\n[a.c]\nid = 0x10000000000000000\n",
+			`{"a":{"c":{"id":18446744073709551616}},"b":{}}`, `{"a":{"c":{"id":"18446744073709551616"}},"b":{}}`},
+		{"json5 hex", "json", "[0x10000000000000000, -0xFFFFFFFFFFFFFFFFFFFF, +0x1F]",
+			`[18446744073709551616,-1208925819614629174706175,31]`,
+			`["18446744073709551616","-1208925819614629174706175",31]`},
+		{"json5 decimal", "json", "{n: 123456789012345678901234567890, m: -00012}",
+			`{"n":123456789012345678901234567890,"m":-12}`, `{"n":"123456789012345678901234567890","m":-12}`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, want := range []struct {
+				policy UnsafeIntegerPolicy
+				out    string
+			}{{UnsafeIntegerKeep, tc.want}, {UnsafeIntegerString, tc.strings}} {
+				got, err := unsafeIntegers(want.policy).convert(tc.format, tc.input)
+				if err != nil {
+					t.Fatalf("policy %d: unexpected error: %v", want.policy, err)
+				}
+				if string(got) != want.out {
+					t.Errorf("policy %d: got %s, want %s", want.policy, got, want.out)
+				}
+			}
+		})
+	}
+}
+
+func TestBigIntegerErrors(t *testing.T) {
+	for _, in := range []string{"n = 0xfg", "n = 0o8", "n = 0b102", "n = 0x", "n = 12a"} {
+		if _, err := FromTOML([]byte(in)); err == nil {
+			t.Errorf("FromTOML(%q): expected error", in)
+		}
+	}
+	// TOML integers are 64-bit, so ToTOML does not write larger ones.
+	if _, err := ToTOML([]byte(`{"n":18446744073709551616}`)); err == nil {
+		t.Error("ToTOML: expected error for an integer beyond uint64")
+	}
+}
+
+func TestUnsafeIntegers(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    string
+		input     string
+		strings   string // output under UnsafeIntegerString
+		line, col int    // of the error under UnsafeIntegerError
+	}{
+		{"yaml", "yaml", "safe: [9007199254740991, -9007199254740991]\nfloat: 1e20\nid: 9007199254740993\nneg: -9007199254740992\n",
+			`{"safe":[9007199254740991,-9007199254740991],"float":1e20,"id":"9007199254740993","neg":"-9007199254740992"}`, 3, 5},
+		{"yaml plus", "yaml", "- +12345678901234567\n",
+			`["12345678901234567"]`, 1, 3},
+		{"toml", "toml", "safe = 9_007_199_254_740_991\nf = 9007199254740993.0\nid = 0x20_0000_0000_0000\n",
+			`{"safe":9007199254740991,"f":9007199254740993.0,"id":"9007199254740992"}`, 3, 6},
+		{"toml fast path", "toml", "a = [1, 90071992547409920]\n",
+			`{"a":[1,"90071992547409920"]}`, 1, 5},
+		{"toml big", "toml", "[t]\nid = 0xffff_ffff_ffff_ffff_ffff\n",
+			`{"t":{"id":"1208925819614629174706175"}}`, 2, 6},
+		{"json", "json", `{"safe": 9007199254740991, "f": 1.5e300, "id": 12345678901234567890}`,
+			`{"safe":9007199254740991,"f":1.5e300,"id":"12345678901234567890"}`, 1, 48},
+		{"json5 hex", "json", "[0x1F, -0x20000000000001]",
+			`[31,"-9007199254740993"]`, 1, 8},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := unsafeIntegers(UnsafeIntegerString).convert(tc.format, tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tc.strings {
+				t.Errorf("got %s, want %s", got, tc.strings)
+			}
+
+			_, err = unsafeIntegers(UnsafeIntegerError).convert(tc.format, tc.input)
+			pe := requireParseError(t, err)
+			if pe.Line != tc.line || pe.Column != tc.col {
+				t.Errorf("got line %d, column %d, want line %d, column %d", pe.Line, pe.Column, tc.line, tc.col)
+			}
+			if !strings.Contains(pe.Message, "outside the safe range") {
+				t.Errorf("got message %q", pe.Message)
+			}
+
+			// The default writes every integer as a number.
+			got, err = unsafeIntegers(UnsafeIntegerKeep).convert(tc.format, tc.input)
+			if err != nil || strings.Contains(string(got), `"9`) || strings.Contains(string(got), `"1`) {
+				t.Errorf("UnsafeIntegerKeep: got %s, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestUnsafeIntegersOptions(t *testing.T) {
+	opts := TOMLOptions{UnsafeIntegers: UnsafeIntegerError}
+	if _, err := FromTOMLWithOptions([]byte("n = 0x1_0000_0000_0000_0000"), opts); err == nil {
+		t.Error("expected error for an integer beyond uint64")
+	}
+
+	c := Converter{YAML: YAMLOptions{UnsafeIntegers: UnsafeIntegerString}}
+	got, err := c.AppendYAML([]byte("x"), []byte("id: 9007199254740993\n"))
+	if err != nil || string(got) != `x{"id":"9007199254740993"}` {
+		t.Errorf("Converter: got %s, %v", got, err)
+	}
+
+	var v struct {
+		ID string `json:"id"`
+	}
+	o := UnmarshalOptions{JSON: JSONVariantOptions{UnsafeIntegers: UnsafeIntegerString}}
+	if err := o.UnmarshalJSONVariant([]byte("{id: 9007199254740993}"), &v); err != nil || v.ID != "9007199254740993" {
+		t.Errorf("UnmarshalJSONVariant: got %q, %v", v.ID, err)
+	}
+}
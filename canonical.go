@@ -0,0 +1,337 @@
+package tojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// appendCanonical appends the RFC 8785 JSON Canonicalization Scheme (JCS)
+// form of the JSON document data to dst: no whitespace, object members
+// sorted by the UTF-16 code units of their keys, numbers formatted as
+// ECMAScript formats a double, and strings with only the escapes JCS
+// requires.
+//
+// data is converter output, which the permissive JSON variant parser may
+// leave invalid, so formatJSON checks it with json.Valid first; data that is
+// still malformed here is reported as an error rather than read past its
+// end. A number that does not fit in a double has no canonical form and is
+// reported as an error.
+func appendCanonical(dst, data []byte) ([]byte, error) {
+	c := canonicalizer{data: data}
+	return c.value(dst)
+}
+
+// errCanonicalSyntax reports data that is not valid JSON.
+var errCanonicalSyntax = errors.New("canonical JSON: invalid JSON")
+
+// canonicalRangeError reports a number that does not fit in a double and so
+// has no canonical form.
+type canonicalRangeError struct {
+	num []byte
+}
+
+func (e *canonicalRangeError) Error() string {
+	return fmt.Sprintf("canonical JSON: number %s is out of range", e.num)
+}
+
+// canonicalError reports err, from laying out the JSON converted from src as
+// canonical JSON, as a *ParseError at the first place in src where the
+// number it names appears, or at the start of src if the number was
+// rewritten in conversion, as 0x10 is.
+func canonicalError(src []byte, err error) error {
+	off := 0
+	if re, ok := err.(*canonicalRangeError); ok {
+		off = max(bytes.Index(src, re.num), 0)
+	}
+	return atOffset(src, off, err)
+}
+
+type canonicalizer struct {
+	data []byte
+	pos  int
+}
+
+// canonicalMember is an object member awaiting sorting.
+type canonicalMember struct {
+	key   string
+	units []uint16 // key as UTF-16, the JCS sort order
+	start int      // offset of the value in data
+}
+
+func (c *canonicalizer) skipSpace() {
+	for c.pos < len(c.data) {
+		switch c.data[c.pos] {
+		case ' ', '\t', '\r', '\n':
+			c.pos++
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the byte at c.pos, or 0 at the end of the data.
+func (c *canonicalizer) peek() byte {
+	if c.pos < len(c.data) {
+		return c.data[c.pos]
+	}
+	return 0
+}
+
+// literal appends the literal lit if it is at c.pos and moves past it.
+func (c *canonicalizer) literal(dst []byte, lit string) ([]byte, error) {
+	if !bytes.HasPrefix(c.data[c.pos:], []byte(lit)) {
+		return dst, errCanonicalSyntax
+	}
+	c.pos += len(lit)
+	return append(dst, lit...), nil
+}
+
+// value appends the canonical form of the value at c.pos and moves past it.
+func (c *canonicalizer) value(dst []byte) ([]byte, error) {
+	c.skipSpace()
+	switch c.peek() {
+	case 0:
+		return dst, errCanonicalSyntax
+	case '{':
+		return c.object(dst)
+	case '[':
+		c.pos++
+		dst = append(dst, '[')
+		for n := 0; ; n++ {
+			c.skipSpace()
+			if c.peek() == ']' {
+				c.pos++
+				return append(dst, ']'), nil
+			}
+			if n > 0 {
+				if c.peek() != ',' {
+					return dst, errCanonicalSyntax
+				}
+				c.pos++
+				dst = append(dst, ',')
+			}
+			var err error
+			if dst, err = c.value(dst); err != nil {
+				return dst, err
+			}
+		}
+	case '"':
+		s, err := c.str()
+		if err != nil {
+			return dst, err
+		}
+		return appendCanonicalString(dst, s), nil
+	case 't':
+		return c.literal(dst, "true")
+	case 'f':
+		return c.literal(dst, "false")
+	case 'n':
+		return c.literal(dst, "null")
+	default:
+		start := c.pos
+		for c.pos < len(c.data) && strings.IndexByte("+-.0123456789eE", c.data[c.pos]) >= 0 {
+			c.pos++
+		}
+		if c.pos == start {
+			return dst, errCanonicalSyntax
+		}
+		return appendCanonicalNumber(dst, c.data[start:c.pos])
+	}
+}
+
+// object appends the canonical form of the object at c.pos. Members are
+// sorted before their values are written, so each value is located first and
+// revisited in sorted order.
+func (c *canonicalizer) object(dst []byte) ([]byte, error) {
+	var members []canonicalMember
+	c.pos++ // '{'
+	for n := 0; ; n++ {
+		c.skipSpace()
+		if c.peek() == '}' {
+			c.pos++
+			break
+		}
+		if n > 0 {
+			if c.peek() != ',' {
+				return dst, errCanonicalSyntax
+			}
+			c.pos++
+			c.skipSpace()
+		}
+		if c.peek() != '"' {
+			return dst, errCanonicalSyntax
+		}
+		key, err := c.str()
+		if err != nil {
+			return dst, err
+		}
+		c.skipSpace()
+		if c.peek() != ':' {
+			return dst, errCanonicalSyntax
+		}
+		c.pos++
+		c.skipSpace()
+		members = append(members, canonicalMember{
+			key:   key,
+			units: utf16.Encode([]rune(key)),
+			start: c.pos,
+		})
+		c.pos = skipJSONValue(c.data, c.pos)
+	}
+	end := c.pos
+
+	slices.SortStableFunc(members, func(a, b canonicalMember) int {
+		return slices.Compare(a.units, b.units)
+	})
+	dst = append(dst, '{')
+	for i, m := range members {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendCanonicalString(dst, m.key)
+		dst = append(dst, ':')
+		c.pos = m.start
+		var err error
+		if dst, err = c.value(dst); err != nil {
+			return dst, err
+		}
+	}
+	c.pos = end
+	return append(dst, '}'), nil
+}
+
+// str decodes the string at c.pos and moves past it.
+func (c *canonicalizer) str() (string, error) {
+	end := skipJSONString(c.data, c.pos)
+	var s string
+	if err := json.Unmarshal(c.data[c.pos:end], &s); err != nil {
+		return "", fmt.Errorf("canonical JSON: invalid string %s", c.data[c.pos:end])
+	}
+	c.pos = end
+	return s, nil
+}
+
+// skipJSONValue returns the offset just past the JSON value at data[off].
+func skipJSONValue(data []byte, off int) int {
+	depth := 0
+	for i := off; i < len(data); i++ {
+		switch data[i] {
+		case '"':
+			i = skipJSONString(data, i) - 1
+			if depth == 0 {
+				return i + 1
+			}
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		default:
+			if depth == 0 {
+				// A number or literal runs to the next delimiter.
+				for i < len(data) && strings.IndexByte(",}] \t\r\n", data[i]) < 0 {
+					i++
+				}
+				return i
+			}
+		}
+	}
+	return len(data)
+}
+
+// appendCanonicalString appends s as a JCS string: only the quote, the
+// backslash, and control characters are escaped, using the two-character
+// forms where JSON has them and lower-case \u00xx otherwise.
+func appendCanonicalString(dst []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	dst = append(dst, '"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			dst = append(dst, '\\', c)
+		case c == '\b':
+			dst = append(dst, `\b`...)
+		case c == '\t':
+			dst = append(dst, `\t`...)
+		case c == '\n':
+			dst = append(dst, `\n`...)
+		case c == '\f':
+			dst = append(dst, `\f`...)
+		case c == '\r':
+			dst = append(dst, `\r`...)
+		case c < 0x20:
+			dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return append(dst, '"')
+}
+
+// appendCanonicalNumber appends the JSON number num as ECMAScript's
+// Number.prototype.toString formats the nearest double, which is what JCS
+// requires. Integers beyond 2^53 therefore lose precision, as they do in
+// JavaScript.
+func appendCanonicalNumber(dst, num []byte) ([]byte, error) {
+	f, err := strconv.ParseFloat(string(num), 64)
+	if err != nil || math.IsInf(f, 0) {
+		return dst, &canonicalRangeError{num: num}
+	}
+	if f == 0 {
+		return append(dst, '0'), nil // also -0
+	}
+	if f < 0 {
+		dst = append(dst, '-')
+		f = -f
+	}
+
+	// Shortest round-trip digits d1.d2...dk and the decimal exponent n such
+	// that f = 0.d1d2...dk × 10^n.
+	var buf [32]byte
+	mant, exp, _ := bytes.Cut(strconv.AppendFloat(buf[:0], f, 'e', -1, 64), []byte{'e'})
+	digits := mant[:1]
+	if len(mant) > 2 {
+		digits = append(digits[:1:1], mant[2:]...) // drop the '.'
+	}
+	n, _ := strconv.Atoi(string(exp))
+	n++
+	k := len(digits)
+
+	switch {
+	case k <= n && n <= 21:
+		dst = append(dst, digits...)
+		for range n - k {
+			dst = append(dst, '0')
+		}
+	case 0 < n && n <= 21:
+		dst = append(dst, digits[:n]...)
+		dst = append(dst, '.')
+		dst = append(dst, digits[n:]...)
+	case -6 < n && n <= 0:
+		dst = append(dst, "0."...)
+		for range -n {
+			dst = append(dst, '0')
+		}
+		dst = append(dst, digits...)
+	default:
+		dst = append(dst, digits[0])
+		if k > 1 {
+			dst = append(dst, '.')
+			dst = append(dst, digits[1:]...)
+		}
+		dst = append(dst, 'e')
+		if n-1 >= 0 {
+			dst = append(dst, '+')
+		}
+		dst = strconv.AppendInt(dst, int64(n-1), 10)
+	}
+	return dst, nil
+}
@@ -0,0 +1,178 @@
+package tojson
+
+import (
+	"testing"
+)
+
+func TestCanonicalNumbers(t *testing.T) {
+	tests := map[string]string{
+		"0":                             "0",
+		"-0":                            "0",
+		"-0.0":                          "0",
+		"1":                             "1",
+		"4.50":                          "4.5",
+		"2e-3":                          "0.002",
+		"1E30":                          "1e+30",
+		"1e21":                          "1e+21",
+		"1e20":                          "100000000000000000000",
+		"123456789012345680000":         "123456789012345680000",
+		"333333333.33333329":            "333333333.3333333",
+		"0.000000000000000000000000001": "1e-27",
+		"0.000001":                      "0.000001",
+		"0.0000001":                     "1e-7",
+		"-1.5e-7":                       "-1.5e-7",
+		"9007199254740993":              "9007199254740992",
+		"1.7976931348623157e308":        "1.7976931348623157e+308",
+		"5e-324":                        "5e-324",
+		"100":                           "100",
+		"12.0":                          "12",
+	}
+	for in, want := range tests {
+		got, err := appendCanonicalNumber(nil, []byte(in))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", in, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s: got %s, want %s", in, got, want)
+		}
+	}
+	if _, err := appendCanonicalNumber(nil, []byte("1e400")); err == nil {
+		t.Error("1e400: expected error")
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		// The examples from RFC 8785, sections 3.2.2 and 3.2.3.
+		{"rfc values",
+			`{"numbers":[333333333.33333329,1E30,4.50,2e-3,0.000000000000000000000000001],` +
+				`"string":"\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/","literals":[null,true,false]}`,
+			`{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],` +
+				"\"string\":\"\u20ac$\\u000f\\nA'B\\\"\\\\\\\\\\\"/\"}"},
+		{"rfc sorting",
+			`{"\u20ac":"Euro Sign","\r":"Carriage Return","\ufb33":"Hebrew Letter Dalet With Dagesh",` +
+				`"1":"One","\ud83d\ude00":"Emoji: Grinning Face","\u0080":"Control","\u00f6":"Latin Small Letter O With Diaeresis"}`,
+			"{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"\u00f6\":\"Latin Small Letter O With Diaeresis\"," +
+				"\"\u20ac\":\"Euro Sign\",\"\U0001F600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}"},
+		{"nested", ` { "b" : [ {"z":1, "a":{}} , [] ], "a" : "x" } `, `{"a":"x","b":[{"a":{},"z":1},[]]}`},
+		{"escapes", `["\u007f <>&\t\b\f\u0001"]`, "[\"\u007f <>&\\t\\b\\f\\u0001\"]"},
+		{"scalar", `"x"`, `"x"`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := appendCanonical(nil, []byte(tc.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("got  %s\nwant %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalAcrossFormats(t *testing.T) {
+	want := `{"name":"web","ports":[80,443],"ratio":0.5,"tls":{"cert":"a\"b","on":true}}`
+	inputs := []struct {
+		name string
+		fn   func(src []byte) ([]byte, error)
+		src  string
+	}{
+		{"yaml", func(src []byte) ([]byte, error) {
+			return FromYAMLWithOptions(src, YAMLOptions{Canonical: true, Indent: "  "})
+		}, "tls:\n  on: true\n  cert: 'a\"b'\nratio: 5e-1\nports: [80, 443.0]\nname: web\n"},
+		{"toml", func(src []byte) ([]byte, error) {
+			return FromTOMLWithOptions(src, TOMLOptions{Canonical: true})
+		}, "name = \"web\"\nports = [80, 4.43e2]\nratio = 0.50\n[tls]\non = true\ncert = \"a\\u0022b\"\n"},
+		{"json", func(src []byte) ([]byte, error) {
+			return FromJSONVariantWithOptions(src, JSONVariantOptions{Canonical: true})
+		}, "{ports: [0x50, 443], ratio: .5, name: 'web', tls: {cert: 'a\"b', on: true}}"},
+		{"front matter", func(src []byte) ([]byte, error) {
+			meta, _, err := FromFrontMatterWithOptions(src, FrontMatterOptions{YAML: YAMLOptions{Canonical: true}})
+			return meta, err
+		}, "---\nratio: 0.5\nname: web\nports: [80, 443]\ntls: {on: true, cert: a\"b}\n---\nbody"},
+	}
+	for _, tc := range inputs {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.fn([]byte(tc.src))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != want {
+				t.Errorf("got  %s\nwant %s", got, want)
+			}
+		})
+	}
+
+	c := Converter{TOML: TOMLOptions{Canonical: true}}
+	got, err := c.AppendTOML([]byte("x"), []byte("b = 1.0\na = 2"))
+	if err != nil || string(got) != `x{"a":2,"b":1}` {
+		t.Errorf("Converter: got %s, %v", got, err)
+	}
+}
+
+func TestCanonicalOutOfRange(t *testing.T) {
+	got, err := FromYAMLWithOptions([]byte("a: 1\nb: 1e400\n"), YAMLOptions{Canonical: true})
+	pe := requireParseError(t, err)
+	if got != nil || pe.Line != 2 || pe.Column != 4 || pe.Message != "canonical JSON: number 1e400 is out of range" {
+		t.Errorf("FromYAMLWithOptions: got %q, %v", got, pe)
+	}
+	c := Converter{JSON: JSONVariantOptions{Canonical: true}}
+	got, err = c.AppendJSONVariant([]byte("x"), []byte("[1, 1e400]"))
+	pe = requireParseError(t, err)
+	if string(got) != "x" || pe.Line != 1 || pe.Column != 5 {
+		t.Errorf("Converter: got %q, %v, want dst unchanged and an error at 1:5", got, pe)
+	}
+}
+
+func TestCanonicalEmpty(t *testing.T) {
+	c := Converter{JSON: JSONVariantOptions{Canonical: true}}
+	for _, src := range []string{"", "  \n", "// comment", "/* a */"} {
+		got, err := FromJSONVariantWithOptions([]byte(src), JSONVariantOptions{Canonical: true})
+		if err != nil || len(got) != 0 {
+			t.Errorf("%q: got %q, %v", src, got, err)
+		}
+		if got, err := c.AppendJSONVariant([]byte("x"), []byte(src)); err != nil || string(got) != "x" {
+			t.Errorf("Converter %q: got %q, %v", src, got, err)
+		}
+	}
+}
+
+func TestCanonicalInvalidInput(t *testing.T) {
+	// The permissive JSON variant parser passes these through unchecked.
+	tests := []struct {
+		src       string
+		line, col int
+		msg       string
+	}{
+		{"{a: [tru]}", 1, 6, "invalid JSON: invalid character ']' in literal true (expecting 'e')"},
+		{"[1_000, 2]", 1, 2, "invalid JSON: invalid character '_' after array element"},
+		{"[0b1]", 1, 2, "invalid JSON: invalid character 'b' after array element"},
+		{`["\u00zz"]`, 1, 2, "invalid JSON: invalid escape sequence `\\u00zz` in string"},
+		{"{b: 1,\n a: [TRUE]}", 2, 6, "invalid JSON: invalid character 'T' looking for beginning of value"},
+	}
+	for _, tc := range tests {
+		got, err := FromJSONVariantWithOptions([]byte(tc.src), JSONVariantOptions{Canonical: true})
+		pe := requireParseError(t, err)
+		if got != nil || pe.Line != tc.line || pe.Column != tc.col || pe.Message != tc.msg {
+			t.Errorf("%q: got %q, %d:%d %q, want %d:%d %q", tc.src, got, pe.Line, pe.Column, pe.Message, tc.line, tc.col, tc.msg)
+		}
+		c := Converter{JSON: JSONVariantOptions{Canonical: true}}
+		if got, err := c.AppendJSONVariant([]byte("x"), []byte(tc.src)); string(got) != "x" || err == nil {
+			t.Errorf("Converter %q: got %q, %v, want dst unchanged and an error", tc.src, got, err)
+		}
+	}
+
+	// appendCanonical itself stops at malformed data instead of reading
+	// past its end or dropping what it cannot decode.
+	for _, data := range []string{`{"a":[tru]}`, `{"a":[t`, `["\u00zz"]`, `[TRUE]`, `{"a"`, `{"a":1 "b":2}`, `[1 2]`} {
+		if got, err := appendCanonical(nil, []byte(data)); err == nil {
+			t.Errorf("appendCanonical(%s) = %s, want an error", data, got)
+		}
+	}
+}
@@ -5,14 +5,23 @@
 //
 //	tojson file.yaml          # format inferred from extension
 //	tojson file.md            # front matter extracted, meta JSON printed
+//	tojson config             # no known extension: format detected from content
 //	cat file.yaml | tojson -f yaml
+//	cat file.yaml | tojson    # format detected from content
 //	tojson -pretty file.yaml  # pretty-printed JSON
 //	tojson -compact file.yaml # explicit compact JSON
 //	tojson -raw file.yaml     # raw output from conversion, no post-processing
+//	tojson -max-errors 20 file.yaml # report up to 20 errors instead of the first
+//	tojson -toml-tagged file.toml   # toml-test tagged JSON, for its test runner
+//	tojson -toml-strict file.toml   # reject all invalid TOML
+//	tojson -yaml-stream array k8s.yaml # each YAML document, as a JSON array
+//	tojson -yaml-stream lines k8s.yaml # each YAML document, as JSON Lines
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -29,16 +38,60 @@ func fatalf(format string, args ...any) {
 	os.Exit(1)
 }
 
-func convert(format string, input []byte) ([]byte, error) {
+// knownFormat reports whether convert accepts format by name.
+func knownFormat(format string) bool {
+	switch format {
+	case "yaml", "yml", "toml", "json5", "json", "jsonc", "hjson", "hson",
+		"md", "markdown", "frontmatter":
+		return true
+	}
+	return false
+}
+
+// options holds the flags that configure conversion.
+type options struct {
+	indent     string // indent for the JSON output; empty for compact
+	maxErrors  int    // errors to report before giving up; see YAMLOptions.MaxErrors
+	tomlTagged bool   // write TOML as toml-test's tagged JSON; see TOMLOptions.Tagged
+	tomlStrict bool   // reject all invalid TOML; see TOMLOptions.Strict
+	yamlStream string // "array" or "lines" to convert each document of a YAML stream
+}
+
+// convert converts input in the named format. An empty format is detected
+// from the content.
+func convert(format string, input []byte, opts options) ([]byte, error) {
+	if format == "" {
+		f, _ := tojson.Detect(input)
+		if f == tojson.FormatFrontMatter && opts.yamlStream != "" {
+			f = tojson.FormatYAML // a stream that starts with --- looks like front matter
+		}
+		if f == tojson.FormatUnknown {
+			return tojson.FromAny(input) // reports the detection failure
+		}
+		format = f.String()
+	}
+	yamlOpts := tojson.YAMLOptions{Indent: opts.indent, MaxErrors: opts.maxErrors}
+	tomlOpts := tojson.TOMLOptions{Indent: opts.indent, MaxErrors: opts.maxErrors, Tagged: opts.tomlTagged, Strict: opts.tomlStrict}
+	jsonOpts := tojson.JSONVariantOptions{Indent: opts.indent, MaxErrors: opts.maxErrors}
+	if opts.yamlStream != "" && format != "yaml" && format != "yml" {
+		return nil, fmt.Errorf("-yaml-stream cannot be used with %s input", format)
+	}
 	switch format {
 	case "yaml", "yml":
-		return tojson.FromYAML(input)
+		if opts.yamlStream != "" {
+			return convertYAMLStream(input, yamlOpts, opts.yamlStream)
+		}
+		return tojson.FromYAMLWithOptions(input, yamlOpts)
 	case "toml":
-		return tojson.FromTOML(input)
+		return tojson.FromTOMLWithOptions(input, tomlOpts)
 	case "json5", "json", "jsonc", "hjson", "hson":
-		return tojson.FromJSONVariant(input)
+		return tojson.FromJSONVariantWithOptions(input, jsonOpts)
 	case "md", "markdown", "frontmatter":
-		meta, _, err := tojson.FromFrontMatter(input)
+		meta, _, err := tojson.FromFrontMatterWithOptions(input, tojson.FrontMatterOptions{
+			YAML: yamlOpts,
+			TOML: tomlOpts,
+			JSON: jsonOpts,
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -51,6 +104,28 @@ func convert(format string, input []byte) ([]byte, error) {
 	}
 }
 
+// convertYAMLStream converts each document of the YAML stream input and
+// writes them as a JSON array or, for mode "lines", as JSON Lines.
+func convertYAMLStream(input []byte, opts tojson.YAMLOptions, mode string) ([]byte, error) {
+	indent := opts.Indent
+	opts.Indent = ""
+	docs, err := tojson.FromYAMLStreamWithOptions(input, opts)
+	if err != nil {
+		return nil, err
+	}
+	if mode == "lines" {
+		return bytes.Join(docs, []byte("\n")), nil
+	}
+	out := append([]byte{'['}, bytes.Join(docs, []byte{','})...)
+	out = append(out, ']')
+	if indent == "" {
+		return out, nil
+	}
+	var buf bytes.Buffer
+	err = json.Indent(&buf, out, "", indent)
+	return buf.Bytes(), err
+}
+
 func writeOutput(w io.Writer, out []byte, raw bool) error {
 	if _, err := w.Write(out); err != nil {
 		return err
@@ -66,7 +141,11 @@ func main() {
 	pretty := flag.Bool("pretty", false, "pretty-print JSON output")
 	compact := flag.Bool("compact", false, "compact JSON output (default)")
 	raw := flag.Bool("raw", false, "raw output from conversion, no post-processing")
-	format := flag.String("f", "", "input format: yaml, toml, json5 (required when reading stdin)")
+	format := flag.String("f", "", "input format: yaml, toml, json5 (default: from the file extension or content)")
+	maxErrors := flag.Int("max-errors", 1, "report up to this many parse errors instead of stopping at the first")
+	tomlTagged := flag.Bool("toml-tagged", false, "write TOML as the tagged JSON of the toml-test suite")
+	tomlStrict := flag.Bool("toml-strict", false, "reject TOML that the specification calls invalid")
+	yamlStream := flag.String("yaml-stream", "", "convert each document of YAML input and write them as a JSON array (array) or as JSON Lines (lines)")
 	version := flag.Bool("version", false, "print version and exit")
 	flag.Parse()
 
@@ -88,6 +167,15 @@ func main() {
 	if modeCount > 1 {
 		fatalf("-pretty, -compact, and -raw are mutually exclusive")
 	}
+	switch *yamlStream {
+	case "", "array":
+	case "lines":
+		if *pretty {
+			fatalf("-pretty cannot be used with -yaml-stream lines")
+		}
+	default:
+		fatalf("-yaml-stream must be array or lines, not %q", *yamlStream)
+	}
 
 	var input []byte
 	var err error
@@ -95,9 +183,6 @@ func main() {
 
 	switch flag.NArg() {
 	case 0:
-		if *format == "" {
-			fatalf("-f <format> is required when reading from stdin")
-		}
 		fmt_ = strings.ToLower(*format)
 		input, err = io.ReadAll(os.Stdin)
 		if err != nil {
@@ -112,27 +197,29 @@ func main() {
 		if *format != "" {
 			fmt_ = strings.ToLower(*format)
 		} else {
-			ext := strings.TrimPrefix(filepath.Ext(filename), ".")
-			fmt_ = strings.ToLower(ext)
+			ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+			if knownFormat(ext) {
+				fmt_ = ext
+			}
 		}
 	default:
-		fatalf("usage: tojson [-pretty|-compact|-raw] [-f format] [file]")
-	}
-
-	out, err := convert(fmt_, input)
-	if err != nil {
-		fatalf("%v", err)
+		fatalf("usage: tojson [-pretty|-compact|-raw] [-f format] [-max-errors n] [-toml-tagged] [-toml-strict] [-yaml-stream array|lines] [file]")
 	}
 
+	opts := options{maxErrors: *maxErrors, tomlTagged: *tomlTagged, tomlStrict: *tomlStrict, yamlStream: *yamlStream}
 	if *pretty {
-		var v any
-		if err := json.Unmarshal(out, &v); err != nil {
-			fatalf("re-marshaling: %v", err)
-		}
-		out, err = json.MarshalIndent(v, "", "  ")
-		if err != nil {
-			fatalf("re-marshaling: %v", err)
+		opts.indent = "  "
+	}
+	out, err := convert(fmt_, input, opts)
+	if err != nil {
+		var errs tojson.ParseErrors
+		if errors.As(err, &errs) {
+			for _, pe := range errs {
+				fmt.Fprintf(os.Stderr, "tojson: %v\n", pe)
+			}
+			os.Exit(1)
 		}
+		fatalf("%v", err)
 	}
 
 	if err := writeOutput(os.Stdout, out, *raw); err != nil {
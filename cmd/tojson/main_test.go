@@ -2,7 +2,10 @@ package main
 
 import (
 	"errors"
+	"strings"
 	"testing"
+
+	"github.com/client9/tojson"
 )
 
 type errWriter struct {
@@ -56,3 +59,139 @@ func TestWriteOutputNewlineError(t *testing.T) {
 		t.Fatalf("writeOutput() error = %v, want %v", err, wantErr)
 	}
 }
+
+func TestConvertDetectsFormat(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"a: 1\n", `{"a":1}`},
+		{"a = 1\n", `{"a":1}`},
+		{"{a: 1}", `{"a":1}`},
+		{"+++\na = 1\n+++\nbody", `{"a":1}`},
+	}
+	for _, tc := range tests {
+		got, err := convert("", []byte(tc.input), options{})
+		if err != nil {
+			t.Errorf("convert(%q) error = %v", tc.input, err)
+			continue
+		}
+		if string(got) != tc.want {
+			t.Errorf("convert(%q) = %s, want %s", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestConvertIndent(t *testing.T) {
+	tests := []struct {
+		format string
+		input  string
+	}{
+		{"toml", "z = 9223372036854775807\na = 1.50\n"},
+		{"yaml", "z: 9223372036854775807\na: 1.50\n"},
+		{"json5", "{z: 9223372036854775807, a: 1.50}"},
+		{"md", "---\nz: 9223372036854775807\na: 1.50\n---\nbody"},
+		{"", "z = 9223372036854775807\na = 1.50\n"},
+	}
+	// Key order and number text survive, unlike a round trip through any.
+	want := "{\n  \"z\": 9223372036854775807,\n  \"a\": 1.50\n}"
+	for _, tc := range tests {
+		got, err := convert(tc.format, []byte(tc.input), options{indent: "  "})
+		if err != nil {
+			t.Errorf("convert(%q, %q) error = %v", tc.format, tc.input, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("convert(%q, %q) = %s, want %s", tc.format, tc.input, got, want)
+		}
+	}
+}
+
+func TestConvertMaxErrors(t *testing.T) {
+	tests := []struct {
+		format string
+		input  string
+	}{
+		{"yaml", "a: \"x\nb: 1\nc: [\n"},
+		{"toml", "a = \nb = 1\nc = \n"},
+		{"json5", "{a: NaN, b: 1, c: Infinity}"},
+		{"md", "---\na: \"x\nb: 1\nc: [\n---\nbody"},
+	}
+	for _, tc := range tests {
+		_, err := convert(tc.format, []byte(tc.input), options{maxErrors: 10})
+		var errs tojson.ParseErrors
+		if !errors.As(err, &errs) || len(errs) != 2 {
+			t.Errorf("convert(%q, %q) error = %v, want 2 errors", tc.format, tc.input, err)
+		}
+	}
+}
+
+func TestConvertTOMLTagged(t *testing.T) {
+	got, err := convert("toml", []byte("n = 1\nf = 1.0\n"), options{tomlTagged: true})
+	want := `{"n":{"type":"integer","value":"1"},"f":{"type":"float","value":"1.0"}}`
+	if err != nil || string(got) != want {
+		t.Errorf("convert = %s, %v, want %s", got, err, want)
+	}
+}
+
+func TestConvertTOMLStrict(t *testing.T) {
+	input := []byte("s = \"\\x41\"\n")
+	if _, err := convert("toml", input, options{}); err != nil {
+		t.Errorf("convert: %v", err)
+	}
+	if got, err := convert("toml", input, options{tomlStrict: true}); err == nil {
+		t.Errorf("convert with tomlStrict = %s, want error", got)
+	}
+}
+
+func TestConvertYAMLStream(t *testing.T) {
+	input := "---\na: 1\n---\nb: [2]\n"
+	tests := []struct {
+		format string
+		opts   options
+		want   string
+	}{
+		{"yaml", options{yamlStream: "array"}, `[{"a":1},{"b":[2]}]`},
+		{"yaml", options{yamlStream: "lines"}, "{\"a\":1}\n{\"b\":[2]}"},
+		{"", options{yamlStream: "lines"}, "{\"a\":1}\n{\"b\":[2]}"},
+		{"yml", options{yamlStream: "array", indent: " "}, "[\n {\n  \"a\": 1\n },\n {\n  \"b\": [\n   2\n  ]\n }\n]"},
+	}
+	for _, tc := range tests {
+		got, err := convert(tc.format, []byte(input), tc.opts)
+		if err != nil || string(got) != tc.want {
+			t.Errorf("convert(%q, %+v) = %q, %v, want %q", tc.format, tc.opts, got, err, tc.want)
+		}
+	}
+	for _, format := range []string{"yaml", ""} {
+		if _, err := convert(format, []byte(input), options{}); err == nil || !strings.Contains(err.Error(), "-yaml-stream") {
+			t.Errorf("convert(%q) without yamlStream: got %v, want an error for the second document", format, err)
+		}
+	}
+	if got, err := convert("", []byte(input+"---\n"), options{yamlStream: "array"}); err != nil || string(got) != `[{"a":1},{"b":[2]},null]` {
+		t.Errorf("convert with a trailing marker = %q, %v", got, err)
+	}
+	for _, format := range []string{"toml", "json5", "md"} {
+		if _, err := convert(format, []byte("a = 1\n"), options{yamlStream: "array"}); err == nil || !strings.Contains(err.Error(), "-yaml-stream") {
+			t.Errorf("convert(%q) with yamlStream: got %v, want an error", format, err)
+		}
+	}
+	if _, err := convert("", []byte("a = 1\n"), options{yamlStream: "lines"}); err == nil {
+		t.Error("convert of detected TOML with yamlStream: want an error")
+	}
+	if _, err := convert("yaml", []byte("a: 1\n---\nb: [\n"), options{yamlStream: "array"}); err == nil {
+		t.Error("convert with an invalid document: want an error")
+	}
+}
+
+func TestKnownFormat(t *testing.T) {
+	for _, f := range []string{"yaml", "yml", "toml", "json", "json5", "md"} {
+		if !knownFormat(f) {
+			t.Errorf("knownFormat(%q) = false", f)
+		}
+	}
+	for _, f := range []string{"", "txt", "conf"} {
+		if knownFormat(f) {
+			t.Errorf("knownFormat(%q) = true", f)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package tojson
+
+import "bytes"
+
+// Converter converts documents like the package-level Append functions but
+// keeps its parser scratch memory (line tables, section stacks, key lists)
+// between calls, so converting many documents in a loop approaches zero
+// allocations per document once the buffers have grown to fit.
+//
+// The zero value is ready to use and applies the same defaults as the
+// package-level functions. A Converter is not safe for concurrent use; give
+// each goroutine its own, or keep them in a sync.Pool.
+//
+//	var c tojson.Converter
+//	for _, doc := range docs {
+//		buf, err = c.AppendYAML(buf[:0], doc)
+//		...
+//	}
+type Converter struct {
+	// YAML holds the options used by AppendYAML and by AppendFrontMatter
+	// for YAML front matter.
+	YAML YAMLOptions
+
+	// TOML holds the options used by AppendTOML and by AppendFrontMatter
+	// for TOML front matter.
+	TOML TOMLOptions
+
+	// JSON holds the options used by AppendJSONVariant and by
+	// AppendFrontMatter for JSON front matter.
+	JSON JSONVariantOptions
+
+	out     bytes.Buffer // wraps the caller's dst for the duration of a call
+	yaml    parser
+	toml    tomlLineParser
+	json    decoder
+	scratch []byte // rebuilt { } front matter
+	compact []byte // compact output awaiting formatting
+}
+
+// AppendYAML is like the package-level AppendYAML but uses c.YAML and
+// reuses c's scratch memory.
+func (c *Converter) AppendYAML(dst, src []byte) ([]byte, error) {
+	out := c.begin(dst)
+	c.yaml.opts = c.YAML
+	err := c.yaml.convert(out, src)
+	c.yaml.release()
+	if err == nil {
+		err = formatTail(src, out, len(dst), c.YAML.output(), &c.compact)
+	}
+	return c.end(dst, err)
+}
+
+// AppendTOML is like the package-level AppendTOML but uses c.TOML and
+// reuses c's scratch memory.
+func (c *Converter) AppendTOML(dst, src []byte) ([]byte, error) {
+	out := c.begin(dst)
+	c.toml.opts = c.TOML
+	err := tomlConvert(&c.toml, out, src)
+	c.toml.reset(nil)
+	if err == nil {
+		err = formatTail(src, out, len(dst), c.TOML.output(), &c.compact)
+	}
+	return c.end(dst, err)
+}
+
+// AppendJSONVariant is like the package-level AppendJSONVariant but uses
+// c.JSON and reuses c's scratch memory.
+func (c *Converter) AppendJSONVariant(dst, src []byte) ([]byte, error) {
+	out := c.begin(dst)
+	c.json.reset(out)
+	c.json.configure(c.JSON)
+	err := c.json.Translate(src)
+	c.json.reset(nil)
+	c.json.tok = tokenizer{}
+	if err == nil {
+		err = formatTail(src, out, len(dst), c.JSON.output(), &c.compact)
+	}
+	return c.end(dst, err)
+}
+
+// AppendFrontMatter is like FromFrontMatter but appends the metadata JSON to
+// dst and returns the extended buffer as meta. As with FromFrontMatter, meta
+// is nil and body is the full input when no front matter is present.
+func (c *Converter) AppendFrontMatter(dst, src []byte) (meta []byte, body []byte, err error) {
+	blk, found, err := splitFrontMatter(src, &c.scratch)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		return nil, src, nil
+	}
+	if len(blk.src) == 0 {
+		opts := FrontMatterOptions{YAML: c.YAML, TOML: c.TOML, JSON: c.JSON}
+		empty, err := formatJSON(nil, []byte("{}"), opts.output(blk.format))
+		if err != nil {
+			return nil, nil, err
+		}
+		return append(dst, empty...), blk.body, nil
+	}
+
+	switch blk.format {
+	case "yaml":
+		meta, err = c.AppendYAML(dst, blk.src)
+	case "toml":
+		meta, err = c.AppendTOML(dst, blk.src)
+	case "json":
+		meta, err = c.AppendJSONVariant(dst, blk.src)
+	}
+	clear(c.scratch)
+	if err != nil {
+		return nil, nil, err
+	}
+	return meta, blk.body, nil
+}
+
+// Reset releases all scratch memory held by c, keeping its options.
+// Use it after converting an unusually large document so the Converter
+// does not pin that memory.
+func (c *Converter) Reset() {
+	*c = Converter{YAML: c.YAML, TOML: c.TOML, JSON: c.JSON}
+}
+
+// begin points c.out at dst and returns it as the conversion destination.
+func (c *Converter) begin(dst []byte) *bytes.Buffer {
+	c.out = *bytes.NewBuffer(dst)
+	return &c.out
+}
+
+// end detaches c.out from the caller's buffer and returns the call result:
+// the extended buffer on success, or dst unextended on error.
+func (c *Converter) end(dst []byte, err error) ([]byte, error) {
+	out := c.out.Bytes()
+	c.out = bytes.Buffer{}
+	if err != nil {
+		return dst, err
+	}
+	return out, nil
+}
@@ -0,0 +1,171 @@
+package tojson
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConverterReuse(t *testing.T) {
+	var c Converter
+	convs := []struct {
+		name string
+		fn   func(dst, src []byte) ([]byte, error)
+	}{
+		{"json", c.AppendJSONVariant},
+		{"yaml", c.AppendYAML},
+		{"toml", c.AppendTOML},
+	}
+	for i, conv := range convs {
+		tc := appendFuncs[i]
+		t.Run(conv.name, func(t *testing.T) {
+			// A failed call must not leave state behind for the next one.
+			for range 3 {
+				got, err := conv.fn(nil, []byte(tc.src))
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if string(got) != tc.want {
+					t.Errorf("got %q, want %q", got, tc.want)
+				}
+				dst := []byte("prefix:")
+				got, err = conv.fn(dst, []byte(tc.bad))
+				requireParseError(t, err)
+				if string(got) != "prefix:" {
+					t.Errorf("got %q, want dst unchanged", got)
+				}
+			}
+		})
+	}
+}
+
+func TestConverterTOMLState(t *testing.T) {
+	// Tables and keys defined by one document must not be seen as
+	// redefinitions in the next.
+	var c Converter
+	src := []byte("a = 1\n[t]\nb = 2\n[t.u]\nc = 3\n[[arr]]\nd = 4\n")
+	want := `{"a":1,"t":{"b":2,"u":{"c":3}},"arr":[{"d":4}]}`
+	for range 3 {
+		got, err := c.AppendTOML(nil, src)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(got) != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+	// Out-of-order tables fall back to the tree parser.
+	got, err := c.AppendTOML(nil, []byte("[a.b]\nx = 1\n[c]\ny = 2\n[a.d]\nz = 3\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := `{"a":{"b":{"x":1},"d":{"z":3}},"c":{"y":2}}`; string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestConverterYAMLOptions(t *testing.T) {
+	c := Converter{YAML: YAMLOptions{BoolAliases: true}}
+	got, err := c.AppendYAML(nil, []byte("a: yes\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := `{"a":true}`; string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	c.Reset()
+	if !c.YAML.BoolAliases {
+		t.Error("Reset cleared YAML options")
+	}
+}
+
+func TestConverterIndent(t *testing.T) {
+	c := Converter{
+		YAML: YAMLOptions{Indent: " "},
+		TOML: TOMLOptions{Indent: " "},
+		JSON: JSONVariantOptions{Indent: " "},
+	}
+	tests := []struct {
+		name string
+		fn   func(dst, src []byte) ([]byte, error)
+		src  string
+	}{
+		{"yaml", c.AppendYAML, "a: [1]\n"},
+		{"toml", c.AppendTOML, "a = [1]\n"},
+		{"json", c.AppendJSONVariant, "{a: [1]}"},
+	}
+	for _, tc := range tests {
+		// Indentation applies to the appended document only.
+		got, err := tc.fn([]byte("x\n"), []byte(tc.src))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if want := "x\n{\n \"a\": [\n  1\n ]\n}"; string(got) != want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, want)
+		}
+	}
+	meta, _, err := c.AppendFrontMatter(nil, []byte("---\na: [1]\n---\n"))
+	if err != nil || string(meta) != "{\n \"a\": [\n  1\n ]\n}" {
+		t.Errorf("front matter: got %q, %v", meta, err)
+	}
+	meta, _, err = c.AppendFrontMatter([]byte("x"), []byte("+++\n+++\n"))
+	if err != nil || string(meta) != "x{}" {
+		t.Errorf("empty front matter: got %q, %v", meta, err)
+	}
+	c.Reset()
+	if c.TOML.Indent != " " || c.JSON.Indent != " " {
+		t.Error("Reset cleared TOML or JSON options")
+	}
+}
+
+func TestConverterFrontMatter(t *testing.T) {
+	var c Converter
+	tests := []struct {
+		in, meta, body string
+	}{
+		{"---\ntitle: A\n---\nbody", `{"title":"A"}`, "body"},
+		{"+++\ntitle = 'B'\n+++\nbody", `{"title":"B"}`, "body"},
+		{"{\n\"title\": \"C\",\n}\nbody", `{"title":"C"}`, "body"},
+		{"---\n---\nbody", `{}`, "body"},
+		{"no front matter", "", "no front matter"},
+	}
+	for _, tc := range tests {
+		meta, body, err := c.AppendFrontMatter(nil, []byte(tc.in))
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tc.in, err)
+		}
+		if string(meta) != tc.meta {
+			t.Errorf("%q: meta got %s, want %s", tc.in, meta, tc.meta)
+		}
+		if strings.TrimSpace(string(body)) != tc.body {
+			t.Errorf("%q: body got %q, want %q", tc.in, body, tc.body)
+		}
+	}
+}
+
+func TestConverterAllocs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("allocation counts are not meaningful in short mode")
+	}
+	var c Converter
+	dst := make([]byte, 0, 4096)
+	tests := []struct {
+		name string
+		fn   func(dst, src []byte) ([]byte, error)
+		src  []byte
+	}{
+		{"yaml", c.AppendYAML, frontmatter1YAMLBytes},
+		{"toml", c.AppendTOML, frontmatter1TOMLBytes},
+		{"json", c.AppendJSONVariant, frontmatter1JSONBytes},
+	}
+	for _, tc := range tests {
+		allocs := testing.AllocsPerRun(100, func() {
+			var err error
+			if dst, err = tc.fn(dst[:0], tc.src); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if allocs > 1 {
+			t.Errorf("%s: %.1f allocs per conversion, want at most 1", tc.name, allocs)
+		}
+	}
+}
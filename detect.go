@@ -0,0 +1,192 @@
+package tojson
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Format identifies an input format.
+type Format int
+
+const (
+	FormatUnknown     Format = iota
+	FormatJSON               // JSON or a JSON variant such as JSON5
+	FormatYAML               // YAML
+	FormatTOML               // TOML
+	FormatFrontMatter        // a document that starts with a front matter block
+)
+
+// String returns the lower-case name of f, as accepted by the tojson command.
+func (f Format) String() string {
+	switch f {
+	case FormatJSON:
+		return "json"
+	case FormatYAML:
+		return "yaml"
+	case FormatTOML:
+		return "toml"
+	case FormatFrontMatter:
+		return "frontmatter"
+	default:
+		return "unknown"
+	}
+}
+
+// detectMaxLines bounds the number of lines Detect examines when telling
+// YAML from TOML.
+const detectMaxLines = 100
+
+// Detect guesses the format of src from its content and reports a confidence
+// between 0 and 1. Confidence 1 means src has a marker only one format uses,
+// such as a +++ front matter sentinel or a valid JSON object; lower values
+// mean the guess rests on the balance of lines that look like YAML or TOML.
+// Detect returns FormatUnknown with confidence 0 when src is empty or
+// matches nothing.
+//
+// A document that both opens and closes with a front matter sentinel is
+// reported as FormatFrontMatter. A --- sentinel is also a YAML document
+// marker, so a --- block counts as front matter only when a body that does
+// not itself read as YAML follows it; otherwise src is a YAML stream.
+func Detect(src []byte) (Format, float64) {
+	if def, rest, found, err := detectFrontMatterFormat(src); err == nil && found && def.open != "{" {
+		if _, body, err := extractFMBlock(rest, def.open, def.close); err == nil {
+			if def.open != "---" {
+				return FormatFrontMatter, 1
+			}
+			if isFrontMatterBody(body) {
+				return FormatFrontMatter, 0.8
+			}
+		}
+	}
+
+	trimmed := bytes.TrimSpace(src)
+	if len(trimmed) == 0 {
+		return FormatUnknown, 0
+	}
+	if json.Valid(trimmed) {
+		return FormatJSON, 1
+	}
+	switch trimmed[0] {
+	case '{':
+		if _, _, found, _ := detectFrontMatterFormat(src); found {
+			if blk, _, err := splitFrontMatter(src, nil); err == nil && len(bytes.TrimSpace(blk.body)) > 0 {
+				return FormatFrontMatter, 0.7
+			}
+		}
+		return FormatJSON, 0.9
+	case '[':
+		first, _, _ := bytes.Cut(trimmed, []byte{'\n'})
+		if !isTOMLHeader(first) {
+			return FormatJSON, 0.9
+		}
+	case '/':
+		if bytes.HasPrefix(trimmed, []byte("//")) || bytes.HasPrefix(trimmed, []byte("/*")) {
+			return FormatJSON, 0.9
+		}
+	}
+
+	return detectLines(src)
+}
+
+// detectLines tells YAML from TOML by counting lines that look like each.
+func detectLines(src []byte) (Format, float64) {
+	var yaml, toml int
+	rest := src
+	for n := 0; len(rest) > 0 && n < detectMaxLines; {
+		var line []byte
+		line, rest, _ = bytes.Cut(rest, []byte{'\n'})
+		line = stripInlineComment(bytes.TrimSpace(line))
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		n++
+		switch {
+		case isTOMLHeader(line), isTOMLKeyValue(line):
+			toml++
+		case bytes.Equal(line, []byte("---")), bytes.Equal(line, []byte("...")),
+			isSeqItem(line), isMapKey(line):
+			yaml++
+		}
+	}
+
+	total := yaml + toml
+	if total == 0 {
+		return FormatUnknown, 0
+	}
+	f, win := FormatYAML, yaml
+	if toml > yaml {
+		f, win = FormatTOML, toml
+	}
+	conf := float64(win) / float64(total)
+	if total < 3 {
+		conf *= 0.8
+	}
+	return f, conf
+}
+
+// isFrontMatterBody reports whether body, what follows a --- front matter
+// block, is a document body rather than more YAML documents: it is not
+// empty, and at most half of its lines that are not blank or comments look
+// like YAML.
+func isFrontMatterBody(body []byte) bool {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return false
+	}
+	var lines, yaml int
+	for n := 0; len(body) > 0 && n < detectMaxLines; n++ {
+		var line []byte
+		line, body, _ = bytes.Cut(body, []byte{'\n'})
+		line = stripInlineComment(bytes.TrimSpace(line))
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		lines++
+		if bytes.Equal(line, []byte("---")) || isSeqItem(line) || isMapKey(line) {
+			yaml++
+		}
+	}
+	return 2*yaml <= lines
+}
+
+// isTOMLHeader reports whether line is a [table] or [[array]] header.
+func isTOMLHeader(line []byte) bool {
+	inner, ok := bytes.CutPrefix(line, []byte("[["))
+	if ok {
+		inner, ok = bytes.CutSuffix(inner, []byte("]]"))
+	} else if inner, ok = bytes.CutPrefix(line, []byte("[")); ok {
+		inner, ok = bytes.CutSuffix(inner, []byte("]"))
+	}
+	if !ok {
+		return false
+	}
+	var pathBuf [4][]byte
+	path, rest, err := parseTOMLKeyPath(inner, pathBuf[:0], &TOMLOptions{})
+	return err == nil && len(path) > 0 && len(bytes.TrimSpace(rest)) == 0
+}
+
+// isTOMLKeyValue reports whether line starts with a TOML key followed by '='.
+func isTOMLKeyValue(line []byte) bool {
+	var pathBuf [4][]byte
+	path, rest, err := parseTOMLKeyPath(line, pathBuf[:0], &TOMLOptions{})
+	return err == nil && len(path) > 0 && len(rest) > 0 && rest[0] == '='
+}
+
+// FromAny converts src to JSON in the format reported by Detect. For a
+// front matter document it returns the converted front matter, or {} when
+// the block is empty, and discards the body; use FromFrontMatter to keep it.
+func FromAny(src []byte) ([]byte, error) {
+	f, _ := Detect(src)
+	switch f {
+	case FormatJSON:
+		return FromJSONVariant(src)
+	case FormatYAML:
+		return FromYAML(src)
+	case FormatTOML:
+		return FromTOML(src)
+	case FormatFrontMatter:
+		meta, _, err := FromFrontMatter(src)
+		return meta, err
+	default:
+		return nil, &ParseError{Line: 1, Column: 1, Message: "cannot detect input format"}
+	}
+}
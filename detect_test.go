@@ -0,0 +1,102 @@
+package tojson
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Format
+		minConf float64
+	}{
+		{"empty", "  \n", FormatUnknown, 0},
+		{"plain text", "just some words\n", FormatUnknown, 0},
+		{"json object", `{"a": [1, 2]}`, FormatJSON, 1},
+		{"json array", "[1, 2, 3]\n", FormatJSON, 1},
+		{"json5", "{a: 1, // comment\n}", FormatJSON, 0.9},
+		{"json5 leading comment", "// settings\n{a: 1}", FormatJSON, 0.9},
+		{"json array of strings", `["a"]`, FormatJSON, 1},
+		{"json number", "42\n", FormatJSON, 1},
+		{"json string", `"s"`, FormatJSON, 1},
+		{"json literal", "true", FormatJSON, 1},
+		{"toml", "title = 'x'\n[server]\nport = 80\n", FormatTOML, 1},
+		{"toml header first", "[server]\nport = 80\n", FormatTOML, 0.8},
+		{"toml array of tables", "[[items]]\nn = 1\n[[items]]\nn = 2\n", FormatTOML, 1},
+		{"toml comments", "# config\nname = \"x: y\" # trailing\n", FormatTOML, 0.8},
+		{"yaml", "name: web\nports:\n  - 80\n  - 443\n", FormatYAML, 1},
+		{"yaml document start", "---\nname: web\n", FormatYAML, 0.8},
+		{"yaml flow value", "tags: [a, b]\nurl: http://x?a=b\n", FormatYAML, 0.8},
+		{"yaml front matter", "---\ntitle: x\n---\n# Heading\n", FormatFrontMatter, 0.8},
+		{"yaml front matter prose", "---\ntitle: x\n---\nSome text.\nNote: more text\nThe end.\n", FormatFrontMatter, 0.8},
+		{"yaml stream", "---\na: 1\n---\nb: 2\nc: [3]\n", FormatYAML, 0.8},
+		{"yaml stream of sequences", "---\n- 1\n---\n- 2\n", FormatYAML, 0.8},
+		{"yaml trailing marker", "---\na: 1\n---\n", FormatYAML, 0.8},
+		{"toml front matter", "+++\ntitle = 'x'\n+++\nbody\n", FormatFrontMatter, 1},
+		{"qualified front matter", "---json\n{\"a\": 1}\n---\nbody\n", FormatFrontMatter, 1},
+		{"brace front matter", "{\n  \"title\": \"x\"\n}\nbody text\n", FormatFrontMatter, 0.7},
+		{"mostly yaml", "a: 1\nb: 2\nc = 3\n", FormatYAML, 0.6},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, conf := Detect([]byte(tc.input))
+			if got != tc.want {
+				t.Fatalf("got %v (%.2f), want %v", got, conf, tc.want)
+			}
+			if conf < tc.minConf || conf > 1 {
+				t.Errorf("got confidence %.2f, want at least %.2f", conf, tc.minConf)
+			}
+			if got == FormatUnknown && conf != 0 {
+				t.Errorf("got confidence %.2f for unknown format", conf)
+			}
+		})
+	}
+}
+
+func TestFromAny(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`{"a": 1}`, `{"a":1}`},
+		{"{a: 1,}", `{"a":1}`},
+		{"a: 1\nb: [x]\n", `{"a":1,"b":["x"]}`},
+		{"a = 1\n[b]\nc = 'x'\n", `{"a":1,"b":{"c":"x"}}`},
+		{"---\ntitle: x\n---\nbody\n", `{"title":"x"}`},
+		{"+++\n+++\nbody\n", `{}`},
+		{"42", `42`},
+		{`"s"`, `"s"`},
+		{"null\n", `null`},
+	}
+	for _, tc := range tests {
+		got, err := FromAny([]byte(tc.input))
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.input, err)
+			continue
+		}
+		if string(got) != tc.want {
+			t.Errorf("%q: got %s, want %s", tc.input, got, tc.want)
+		}
+	}
+
+	_, err := FromAny([]byte("just some words"))
+	requireParseError(t, err)
+
+	// A YAML stream is YAML, not front matter followed by a body, so the
+	// later documents are not silently dropped.
+	_, err = FromAny([]byte("---\na: 1\n---\nb: 2\n"))
+	requireParseError(t, err)
+}
+
+func TestFormatString(t *testing.T) {
+	for f, want := range map[Format]string{
+		FormatUnknown:     "unknown",
+		FormatJSON:        "json",
+		FormatYAML:        "yaml",
+		FormatTOML:        "toml",
+		FormatFrontMatter: "frontmatter",
+	} {
+		if got := f.String(); got != want {
+			t.Errorf("Format(%d).String() = %q, want %q", int(f), got, want)
+		}
+	}
+}
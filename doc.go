@@ -15,7 +15,66 @@
 //	tojson.FromFrontMatter(src []byte) (meta []byte, body []byte, err error)
 //
 // FromYAML intentionally supports a practical YAML subset for config files and
-// front matter, not the full YAML specification.
+// front matter, not the full YAML specification. FromYAMLWithOptions accepts a
+// YAMLOptions value to adjust tab handling, YAML 1.1 boolean aliases, and ~ as
+// null per call. Anchors, aliases, and << merge keys are expanded, and the
+// MaxAliasBytes field bounds how much JSON aliases may copy, so that a small
+// document cannot expand without limit. FromYAMLStream and YAMLDocuments
+// convert a stream of documents separated by --- lines, such as a Kubernetes
+// manifest, one document at a time. Core schema tags such as !!str and !!int
+// coerce the values they mark, and the TagHandler field converts local tags
+// such as CloudFormation's !Ref.
+//
+// The options types YAMLOptions, TOMLOptions, and JSONVariantOptions, used
+// by the WithOptions functions and by Converter, share an Indent field that
+// produces indented JSON in place of compact JSON, and a Canonical field that
+// produces RFC 8785 canonical JSON, which is byte-identical for equivalent
+// documents whatever their input format.
+//
+// FromJSONVariant accepts all of the JSON variants it knows. The Dialect field
+// of JSONVariantOptions restricts the input to one of them, such as strict
+// JSON or JWCC, and reports anything outside it as a *ParseError.
+//
+// A key repeated within a YAML mapping or JSON object is an error by default.
+// The DuplicateKeys field of YAMLOptions and JSONVariantOptions keeps the
+// first or last value instead.
+//
+// JSON has no NaN or infinity, so NaN and Infinity in JSON5 and nan and inf in
+// TOML are errors by default, while .nan and .inf in YAML are strings. The
+// NonFinite field of each options type writes them as null, as strings such
+// as "Infinity", or as JSON text of the caller's choosing instead.
+//
+// Integers convert exactly however large they are, with math/big for TOML
+// hex, octal, and binary integers and JSON5 hex literals beyond 64 bits. For
+// readers that hold numbers in a double, such as JavaScript, the
+// UnsafeIntegers field of every options type writes integers beyond 2^53-1
+// as strings or reports them as errors.
+//
+// TOML dates and times are validated and written as strings by default. The
+// DateTimes field of TOMLOptions normalizes them to RFC 3339 or writes them
+// as objects that record which of TOML's four kinds each one is.
+// The Version field of TOMLOptions accepts TOML 1.1 in place of 1.0.
+// FromTOMLTagged writes the tagged JSON of the toml-test suite, in which
+// every value records its TOML type. The Strict field of TOMLOptions rejects
+// every document that the TOML specification calls invalid.
+//
+// A Converter converts many documents in a row while reusing its parser
+// scratch memory, so that steady-state conversion into a reused buffer does
+// not allocate.
+//
+// UnmarshalYAML, UnmarshalTOML, UnmarshalJSONVariant, and
+// UnmarshalFrontMatter convert and decode in one step. A decoding error, such
+// as a string where a number is expected, is returned as a *ParseError at the
+// key or value in the source that caused it.
+//
+// When the format is not known in advance, Detect guesses it from the content
+// and FromAny converts with the detected format.
+//
+// ToYAML and ToTOML convert in the other direction, from JSON to YAML or TOML
+// that FromYAML or FromTOML reads back to the same JSON.
+//
+// The WithSourceMap variants of the From* functions also return a SourceMap,
+// which gives the input span of each value in the output by JSON Pointer.
 //
 // FromFrontMatter handles documents that embed metadata in a front matter block
 // before the main content, as used by Hugo, Jekyll, and similar static site
@@ -36,4 +95,7 @@
 //	if errors.As(err, &pe) {
 //		fmt.Printf("line %d, column %d: %s\n", pe.Line, pe.Column, pe.Message)
 //	}
+//
+// Setting MaxErrors in the options makes a conversion skip past each error
+// and carry on, returning up to that many errors as a ParseErrors.
 package tojson
@@ -0,0 +1,232 @@
+package tojson
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// DuplicateKeyPolicy selects what YAML and JSON-variant conversion does with
+// an object key that repeats an earlier key of the same object. TOML always
+// reports a repeated key as an error, as the TOML specification requires.
+type DuplicateKeyPolicy int
+
+const (
+	// DuplicateKeyError reports a repeated key as a *ParseError at the
+	// repeat. It is the default, so that a key pasted twice into a large
+	// config is caught instead of silently overriding the first.
+	DuplicateKeyError DuplicateKeyPolicy = iota
+
+	// DuplicateKeyFirst keeps the first value of a repeated key and drops
+	// the later ones.
+	DuplicateKeyFirst
+
+	// DuplicateKeyLast keeps the last value of a repeated key, which is the
+	// value encoding/json would decode, and drops the earlier ones. The
+	// member is written at the position of its last occurrence.
+	DuplicateKeyLast
+)
+
+// dupKeys applies a DuplicateKeyPolicy while a converter writes JSON to its
+// output. Keys are compared as the strings they encode, so a, 'a', "a", and
+// "\u0061" are the same key. As with TOML's markKey, a key is looked up by a linear
+// scan of its object's earlier keys.
+//
+// Dropping a member removes its bytes from the output after the fact, which
+// keeps the converters streaming: a converter calls open, key, and close as
+// it writes each object, and dupKeys rewrites the output in place.
+type dupKeys struct {
+	policy DuplicateKeyPolicy
+
+	// The members of every open object, innermost last, and the open
+	// objects themselves. As with tomlStack, the first of each are held in
+	// arrays inside dupKeys and the rest in the more slices, so that small
+	// documents record their keys without allocating. They are reached by
+	// index rather than through a slice of the arrays, which would point
+	// the converter at itself and move it to the heap.
+	memberBuf   [dupInlineMembers]dupMember
+	moreMembers []dupMember
+	nmembers    int
+	objectBuf   [dupInlineObjects]dupObject
+	moreObjects []dupObject
+	nobjects    int
+}
+
+// dupInlineMembers and dupInlineObjects are the number of members and
+// objects that dupKeys holds before growing onto the heap.
+const (
+	dupInlineMembers = 16
+	dupInlineObjects = 8
+)
+
+// dupMember is the output position of one object member.
+type dupMember struct {
+	start       int  // offset of the member, including any leading comma
+	key, keyEnd int  // span of the encoded key
+	escaped     bool // whether the key has an escape
+}
+
+type dupObject struct {
+	first   int // index in members of the object's first member
+	discard int // offset of a member to drop once its value ends, or -1
+}
+
+// reset forgets any objects left open by an earlier conversion, keeping
+// the policy and the capacity of the spill slices.
+func (k *dupKeys) reset() {
+	k.nmembers = 0
+	k.nobjects = 0
+}
+
+// depth returns the number of open objects.
+func (k *dupKeys) depth() int { return k.nobjects }
+
+// member returns the member at index i.
+func (k *dupKeys) member(i int) *dupMember {
+	if i < len(k.memberBuf) {
+		return &k.memberBuf[i]
+	}
+	return &k.moreMembers[i-len(k.memberBuf)]
+}
+
+// object returns the open object at depth i.
+func (k *dupKeys) object(i int) *dupObject {
+	if i < len(k.objectBuf) {
+		return &k.objectBuf[i]
+	}
+	return &k.moreObjects[i-len(k.objectBuf)]
+}
+
+// addMember records m as the last member of the innermost open object.
+func (k *dupKeys) addMember(m dupMember) {
+	if i := k.nmembers - len(k.memberBuf); i >= 0 {
+		k.moreMembers = append(k.moreMembers[:i], m)
+	} else {
+		k.memberBuf[k.nmembers] = m
+	}
+	k.nmembers++
+}
+
+// open records the start of an object.
+func (k *dupKeys) open() {
+	obj := dupObject{first: k.nmembers, discard: -1}
+	if i := k.nobjects - len(k.objectBuf); i >= 0 {
+		k.moreObjects = append(k.moreObjects[:i], obj)
+	} else {
+		k.objectBuf[k.nobjects] = obj
+	}
+	k.nobjects++
+}
+
+// key records the key just written to out from offset keyStart and applies
+// the policy if it repeats a key of the innermost open object. A member
+// being dropped under DuplicateKeyFirst is removed here or by close, once
+// its value has been written. sm, if non-nil, is kept in step with out.
+func (k *dupKeys) key(out *bytes.Buffer, keyStart int, sm *srcMap) error {
+	if k.nobjects == 0 {
+		return nil
+	}
+	obj := k.object(k.nobjects - 1)
+	start := keyStart
+	if start > 0 && out.Bytes()[start-1] == ',' {
+		start--
+	}
+	if obj.discard >= 0 {
+		k.cut(out, sm, obj.discard, start)
+		keyStart -= start - obj.discard
+		start = obj.discard
+		obj.discard = -1
+	}
+
+	buf := out.Bytes()
+	key := buf[keyStart:]
+	m := dupMember{start: start, key: keyStart, keyEnd: len(buf), escaped: bytes.IndexByte(key, '\\') >= 0}
+	i := k.index(buf, key, m.escaped)
+	if i < 0 {
+		k.addMember(m)
+		return nil
+	}
+	switch k.policy {
+	case DuplicateKeyFirst:
+		obj.discard = start
+	case DuplicateKeyLast:
+		k.addMember(m)
+		i += obj.first
+		from, to := k.member(i).start, k.member(i+1).start
+		if i == obj.first {
+			to++ // the next member's comma, which now leads the object
+		}
+		k.cut(out, sm, from, to)
+		k.member(i + 1).start = to
+		for j := i + 1; j < k.nmembers; j++ {
+			m := k.member(j)
+			m.start -= to - from
+			m.key -= to - from
+			m.keyEnd -= to - from
+			*k.member(j - 1) = *m
+		}
+		k.nmembers--
+	default:
+		return fmt.Errorf("duplicate key %s", key)
+	}
+	return nil
+}
+
+// has reports whether the innermost open object of out has the encoded key.
+func (k *dupKeys) has(out *bytes.Buffer, key []byte) bool {
+	return k.nobjects > 0 && k.index(out.Bytes(), key, bytes.IndexByte(key, '\\') >= 0) >= 0
+}
+
+// index returns the position of the encoded key among the members of the
+// innermost open object, whose output is buf, or -1. escaped reports
+// whether key has an escape.
+func (k *dupKeys) index(buf, key []byte, escaped bool) int {
+	first := k.object(k.nobjects - 1).first
+	for i := first; i < k.nmembers; i++ {
+		m := k.member(i)
+		if sameJSONKey(buf[m.key:m.keyEnd], key, m.escaped || escaped) {
+			return i - first
+		}
+	}
+	return -1
+}
+
+// sameJSONKey reports whether the encoded keys a and b are the same string.
+// Keys with different bytes can still be the same string when either has an
+// escape, as "a" and "\u0061" do; escaped reports whether one does.
+func sameJSONKey(a, b []byte, escaped bool) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	return escaped && jsonStringAt(a, 0) == jsonStringAt(b, 0)
+}
+
+// close records the end of the innermost open object. Call it just before
+// writing the closing brace.
+func (k *dupKeys) close(out *bytes.Buffer, sm *srcMap) {
+	if k.nobjects == 0 {
+		return
+	}
+	obj := k.object(k.nobjects - 1)
+	if obj.discard >= 0 {
+		k.cut(out, sm, obj.discard, out.Len())
+	}
+	k.nmembers = obj.first
+	k.nobjects--
+}
+
+// unwind forgets the objects opened after the first n, which an error
+// left unclosed.
+func (k *dupKeys) unwind(n int) {
+	if n < k.nobjects {
+		k.nmembers = k.object(n).first
+		k.nobjects = n
+	}
+}
+
+// cut removes out[from:to].
+func (k *dupKeys) cut(out *bytes.Buffer, sm *srcMap, from, to int) {
+	buf := out.Bytes()
+	n := copy(buf[from:], buf[to:])
+	out.Truncate(from + n)
+	sm.cut(from, to)
+}
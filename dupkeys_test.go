@@ -0,0 +1,125 @@
+package tojson
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDuplicateKeys(t *testing.T) {
+	// Enough open objects and members to outgrow dupKeys' inline arrays.
+	nest := func(s string) string { return strings.Repeat("{x: ", 9) + s + strings.Repeat("}", 9) }
+	nestJSON := func(s string) string { return strings.Repeat(`{"x":`, 9) + s + strings.Repeat("}", 9) }
+	members := "a: 1, b: 2, c: 3, d: 4, e: 5, f: 6, g: 7, h: 8, i: 9, j: 10, k: 11, l: 12, m: 13, n: 14, o: 15, p: 16"
+	membersJSON := `"b":2,"c":3,"d":4,"e":5,"f":6,"g":7,"h":8,"i":9,"j":10,"k":11,"l":12,"m":13,"n":14,"o":15,"p":16`
+	deep := nest("{" + members + ", a: 17}")
+	deepFirst := nestJSON(`{"a":1,` + membersJSON + "}")
+	deepLast := nestJSON("{" + membersJSON + `,"a":17}`)
+	deepCol := strings.LastIndex(deep, "a:") + 1
+
+	tests := []struct {
+		name        string
+		format      string
+		input       string
+		first, last string
+		line, col   int // of the error under DuplicateKeyError
+	}{
+		{"yaml block", "yaml", "a: 1\nb: 2\na: 3\n",
+			`{"a":1,"b":2}`, `{"b":2,"a":3}`, 3, 1},
+		{"yaml first member", "yaml", "a: 1\na: 2\n",
+			`{"a":1}`, `{"a":2}`, 2, 1},
+		{"yaml nested values", "yaml", "a:\n  x: [1, {y: 2}]\nb: 2\na:\n  z: 3\nc: 4\n",
+			`{"a":{"x":[1,{"y":2}]},"b":2,"c":4}`, `{"b":2,"a":{"z":3},"c":4}`, 4, 1},
+		{"yaml quoting", "yaml", "a: 1\n'a': 2\n\"\\x61\": 3\n",
+			`{"a":1}`, `{"a":3}`, 2, 1},
+		{"yaml nested mapping", "yaml", "t:\n  k: 1\n  k: 2\nu:\n  k: 3\n",
+			`{"t":{"k":1},"u":{"k":3}}`, `{"t":{"k":2},"u":{"k":3}}`, 3, 3},
+		{"yaml sequence item", "yaml", "- a: 1\n  b: 2\n  a: 3\n- a: 4\n",
+			`[{"a":1,"b":2},{"a":4}]`, `[{"b":2,"a":3},{"a":4}]`, 3, 3},
+		{"yaml flow", "yaml", "m: {a: 1, b: 2, a: 3, a: 4}\n",
+			`{"m":{"a":1,"b":2}}`, `{"m":{"b":2,"a":4}}`, 1, 4},
+		{"json5", "json", "{a: 1, 'b': 2, \"a\": {c: 3},}",
+			`{"a":1,"b":2}`, `{"b":2,"a":{"c":3}}`, 1, 16},
+		{"json5 no commas", "json", "{a: 1 a: [2] b: 3}",
+			`{"a":1,"b":3}`, `{"a":[2],"b":3}`, 1, 7},
+		{"json nested", "json", `{"o": {"k": 1, "k": 2}, "k": 3}`,
+			`{"o":{"k":1},"k":3}`, `{"o":{"k":2},"k":3}`, 1, 16},
+		{"json escapes", "json", `{"a":1,"\u0061":2,"b\n":3,"b\u000a":4}`,
+			`{"a":1,"b\n":3}`, `{"\u0061":2,"b\u000a":4}`, 1, 8},
+		{"json deep", "json", deep, deepFirst, deepLast, 1, deepCol},
+		{"yaml deep", "yaml", deep, deepFirst, deepLast, 1, 1},
+	}
+	duplicateKeys := func(policy DuplicateKeyPolicy) formatOptions {
+		return formatOptions{yaml: YAMLOptions{DuplicateKeys: policy}, json: JSONVariantOptions{DuplicateKeys: policy}}
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := duplicateKeys(DuplicateKeyError).convert(tc.format, tc.input)
+			pe := requireParseError(t, err)
+			if pe.Line != tc.line || pe.Column != tc.col {
+				t.Errorf("got line %d, column %d, want line %d, column %d", pe.Line, pe.Column, tc.line, tc.col)
+			}
+			if !strings.Contains(pe.Message, "duplicate key") {
+				t.Errorf("got message %q", pe.Message)
+			}
+			for _, want := range []struct {
+				policy DuplicateKeyPolicy
+				out    string
+			}{{DuplicateKeyFirst, tc.first}, {DuplicateKeyLast, tc.last}} {
+				got, err := duplicateKeys(want.policy).convert(tc.format, tc.input)
+				if err != nil {
+					t.Fatalf("policy %d: unexpected error: %v", want.policy, err)
+				}
+				if string(got) != want.out {
+					t.Errorf("policy %d: got %s, want %s", want.policy, got, want.out)
+				}
+			}
+		})
+	}
+}
+
+func TestDuplicateKeysAllocs(t *testing.T) {
+	// Tracking keys must not cost small documents an allocation per key.
+	tests := []struct {
+		name string
+		fn   func([]byte) ([]byte, error)
+		src  []byte
+		max  float64
+	}{
+		{"json", FromJSONVariant, frontmatter1JSONBytes, 2},
+		{"yaml", FromYAML, frontmatter1YAMLBytes, 5},
+	}
+	for _, tc := range tests {
+		allocs := testing.AllocsPerRun(100, func() {
+			if _, err := tc.fn(tc.src); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if allocs > tc.max {
+			t.Errorf("%s: %.1f allocs per conversion, want at most %v", tc.name, allocs, tc.max)
+		}
+	}
+}
+
+func TestDuplicateKeysPositions(t *testing.T) {
+	// Dropping members must keep later errors pointing at the right place.
+	var v struct {
+		A int `json:"a"`
+		B int `json:"b"`
+	}
+	for _, policy := range []DuplicateKeyPolicy{DuplicateKeyFirst, DuplicateKeyLast} {
+		o := UnmarshalOptions{YAML: YAMLOptions{DuplicateKeys: policy}}
+		err := o.UnmarshalYAML([]byte("a: 1\na: 2\nb: x\n"), &v)
+		pe := requireParseError(t, err)
+		if pe.Line != 3 || pe.Column != 4 {
+			t.Errorf("policy %d: got line %d, column %d, want line 3, column 4", policy, pe.Line, pe.Column)
+		}
+	}
+
+	c := Converter{JSON: JSONVariantOptions{DuplicateKeys: DuplicateKeyLast}}
+	got, err := c.AppendJSONVariant([]byte("x"), []byte("{a: 1, a: 2}"))
+	if err != nil || string(got) != `x{"a":2}` {
+		t.Errorf("Converter: got %s, %v", got, err)
+	}
+	_, err = c.AppendYAML(nil, []byte("a: 1\na: 2\n"))
+	requireParseError(t, err)
+}
@@ -1,6 +1,13 @@
 package tojson
 
-import "fmt"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
 
 // ParseError is returned by all From* functions when the input cannot be parsed.
 // Line and Column are always 1-based.
@@ -8,12 +15,86 @@ type ParseError struct {
 	Line    int    // 1-based line number in the original input
 	Column  int    // 1-based column number
 	Message string // description of the problem
+	Err     error  // underlying error, such as a *json.UnmarshalTypeError, or nil
 }
 
 func (e *ParseError) Error() string {
 	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
 }
 
+// Unwrap returns the underlying error, if any.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseErrors is returned in place of a single *ParseError when a MaxErrors
+// option asks a conversion to continue past errors. It lists every error
+// found, in input order.
+type ParseErrors []*ParseError
+
+// Error returns the errors, one per line.
+func (e ParseErrors) Error() string {
+	var sb strings.Builder
+	for i, pe := range e {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(pe.Error())
+	}
+	return sb.String()
+}
+
+// Unwrap returns the errors, so errors.As finds the first *ParseError.
+func (e ParseErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, pe := range e {
+		errs[i] = pe
+	}
+	return errs
+}
+
+// errorList collects the errors of a conversion that resynchronizes after
+// each one and carries on. A max of 1 or less turns recovery off, so the
+// first error ends the conversion and is returned unchanged.
+type errorList struct {
+	max  int
+	errs ParseErrors
+	last error // the most recently recorded error
+}
+
+// add records err and reports whether the converter should resynchronize
+// and continue. When add returns false the converter returns err to its
+// caller; add returns false again, without recording anything, as the error
+// passes through enclosing levels that also recover.
+func (l *errorList) add(err error) bool {
+	if l.max <= 1 || len(l.errs) >= l.max {
+		return false
+	}
+	pe, ok := err.(*ParseError)
+	if !ok {
+		pe = &ParseError{Message: err.Error(), Err: err}
+	}
+	l.errs = append(l.errs, pe)
+	l.last = err
+	return len(l.errs) < l.max
+}
+
+// result returns the error for a conversion that ended with err: err itself
+// when recovery is off, and otherwise nil or the recorded errors, with err
+// added if it was not already recorded.
+func (l *errorList) result(err error) error {
+	if l.max <= 1 {
+		return err
+	}
+	if err != nil && err != l.last {
+		l.add(err)
+	}
+	if len(l.errs) == 0 {
+		return nil
+	}
+	return l.errs
+}
+
 // atLineCol wraps err with a 1-based line and column unless it is already a ParseError.
 // rawLine is a 0-based index; col is a 0-based column offset.
 func atLineCol(rawLine, col int, err error) error {
@@ -36,3 +117,49 @@ func atToken(t token, err error) error {
 	}
 	return &ParseError{Line: t.row + 1, Column: t.col + 1, Message: err.Error()}
 }
+
+// atOffset wraps err with the 1-based line and column of byte offset off in
+// src unless it is already a ParseError.
+func atOffset(src []byte, off int, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*ParseError); ok {
+		return err
+	}
+	before := src[:min(max(off, 0), len(src))]
+	return &ParseError{
+		Line:    bytes.Count(before, []byte{'\n'}) + 1,
+		Column:  len(before) - bytes.LastIndexByte(before, '\n'),
+		Message: err.Error(),
+	}
+}
+
+// jsonTrailingError reports anything but whitespace in src after off, the
+// end of the top-level value.
+func jsonTrailingError(src []byte, off int64) error {
+	rest := bytes.TrimLeft(src[off:], " \t\r\n")
+	if len(rest) == 0 {
+		return nil
+	}
+	return jsonInputError(src, int64(len(src)-len(rest)), errors.New("invalid character after top-level value"))
+}
+
+// jsonInputError reports err, from decoding JSON input src, as a *ParseError.
+// off is the decoder's input offset, used when err carries none.
+func jsonInputError(src []byte, off int64, err error) error {
+	var se *json.SyntaxError
+	if errors.As(err, &se) {
+		off = se.Offset
+		if !strings.HasPrefix(se.Error(), "unexpected end") {
+			off-- // Offset counts the offending byte
+		}
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = errors.New("unexpected end of JSON input")
+		off = int64(len(src))
+	}
+	pe := atOffset(src, int(off), fmt.Errorf("invalid JSON: %v", err)).(*ParseError)
+	pe.Err = err
+	return pe
+}
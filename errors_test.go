@@ -1,6 +1,11 @@
 package tojson
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
 
 func requireParseError(t *testing.T, err error) *ParseError {
 	t.Helper()
@@ -19,3 +24,136 @@ func requireParseError(t *testing.T, err error) *ParseError {
 	}
 	return pe
 }
+
+// errorPositions returns the "line:column" of each error in err, which must
+// be a ParseErrors.
+func errorPositions(t *testing.T, err error) []string {
+	t.Helper()
+	errs, ok := err.(ParseErrors)
+	if !ok {
+		t.Fatalf("expected ParseErrors, got %T: %v", err, err)
+	}
+	var pos []string
+	for _, pe := range errs {
+		pos = append(pos, fmt.Sprintf("%d:%d", pe.Line, pe.Column))
+	}
+	return pos
+}
+
+// formatOptions holds the options of each input format, for tests that run
+// the same check in several formats.
+type formatOptions struct {
+	yaml YAMLOptions
+	toml TOMLOptions
+	json JSONVariantOptions
+}
+
+// convert converts input in the named format, "yaml", "toml", or "json",
+// with that format's options in o.
+func (o formatOptions) convert(format, input string) ([]byte, error) {
+	switch format {
+	case "yaml":
+		return FromYAMLWithOptions([]byte(input), o.yaml)
+	case "toml":
+		return FromTOMLWithOptions([]byte(input), o.toml)
+	}
+	return FromJSONVariantWithOptions([]byte(input), o.json)
+}
+
+func TestMaxErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  string
+		want   string // positions of the errors found, space separated
+	}{
+		{"yaml", "yaml", "a: \"x\nb: 2\nc: [1\nd: ok\n", "1:4 3:4"},
+		{"yaml nested", "yaml", "a:\n  b: \"x\n  c: 1\nd: \"y\ne: 2\n", "2:6 4:4"},
+		{"yaml sequence", "yaml", "- \"x\n- 2\n- [\n- 3\n", "1:3 3:3"},
+		{"yaml duplicate keys", "yaml", "a: 1\na: 2\nb:\n  c: 1\n  c: 2\n", "2:1 5:3"},
+		{"yaml flow", "yaml", "a: [1, }\nb: 2\nc: \"x\n", "1:4 3:4"},
+		{"toml", "toml", "a = \nb = 2\n[t\nc = 1\nd = x\n", "1:4 3:1 5:5"},
+		{"toml skips to key", "toml", "a = 1\na = 2\n[x]\nb = \"\n[y.z]\nq=1\n", "2:1 4:5"},
+		{"toml tree", "toml", "a.b = 1\n[a]\nc = [\n\nd = 1\n", "2:1 3:5"},
+		{"toml open array", "toml", "a = [1,\n  2\n[t]\nc = x\n", "1:5 4:5"},
+		{"toml array closed late", "toml", "a = [1,\nb = 2\n[t]\nc = 3\nd = ]\n", "2:1 5:5"},
+		{"json5", "json", "{a: 0x, b: NaN, c: 1}", "1:5 1:12"},
+		{"json5 nested", "json", "[{x: 1, x: 2}, [Infinity], 3, -NaN]", "1:9 1:17 1:31"},
+		{"json5 mismatched bracket", "json", "{a: [1, }, b: 2, c: NaN}", "1:9 1:21"},
+		{"json5 trailing", "json", "{a: NaN} 1", "1:5 1:10"},
+	}
+	maxErrors := func(n int) formatOptions {
+		return formatOptions{YAMLOptions{MaxErrors: n}, TOMLOptions{MaxErrors: n}, JSONVariantOptions{MaxErrors: n}}
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			want := strings.Fields(tc.want)
+
+			_, err := maxErrors(10).convert(tc.format, tc.input)
+			if got := errorPositions(t, err); strings.Join(got, " ") != tc.want {
+				t.Errorf("got errors at %v, want %v\n%v", got, want, err)
+			}
+			var pe *ParseError
+			if !errors.As(err, &pe) || fmt.Sprintf("%d:%d", pe.Line, pe.Column) != want[0] {
+				t.Errorf("errors.As: got %v, want the first error", pe)
+			}
+
+			// A cap stops at that many errors.
+			_, err = maxErrors(2).convert(tc.format, tc.input)
+			if got := errorPositions(t, err); strings.Join(got, " ") != strings.Join(want[:2], " ") {
+				t.Errorf("MaxErrors 2: got errors at %v", got)
+			}
+
+			// Recovery off reports the first error alone.
+			for _, n := range []int{0, 1} {
+				_, err = maxErrors(n).convert(tc.format, tc.input)
+				pe := requireParseError(t, err)
+				if got := fmt.Sprintf("%d:%d", pe.Line, pe.Column); got != want[0] {
+					t.Errorf("MaxErrors %d: got error at %s, want %s", n, got, want[0])
+				}
+			}
+		})
+	}
+}
+
+func TestMaxErrorsEOF(t *testing.T) {
+	// The end of the input is reported once, at a real position.
+	for _, src := range []string{"[1,", "[1, # x", "{a: 1, // x\n"} {
+		for _, maxErrors := range []int{0, 10} {
+			_, err := FromJSONVariantWithOptions([]byte(src), JSONVariantOptions{MaxErrors: maxErrors})
+			var pe *ParseError
+			if !errors.As(err, &pe) || pe.Message != "got end of file prematurely" || strings.Count(err.Error(), "\n") != 0 {
+				t.Errorf("%q, MaxErrors %d: got %v", src, maxErrors, err)
+			}
+		}
+	}
+}
+
+func TestMaxErrorsValid(t *testing.T) {
+	got, err := FromYAMLWithOptions([]byte("a: 1\nb: [2, 3]\n"), YAMLOptions{MaxErrors: 10})
+	if err != nil || string(got) != `{"a":1,"b":[2,3]}` {
+		t.Errorf("got %s, %v", got, err)
+	}
+}
+
+func TestMaxErrorsPositions(t *testing.T) {
+	// Errors in front matter are reported at their line in the document.
+	var v map[string]any
+	o := UnmarshalOptions{YAML: YAMLOptions{MaxErrors: 10}}
+	_, err := o.UnmarshalFrontMatter([]byte("---\na: \"x\nb: 1\nc: [\n---\nbody\n"), &v)
+	if got := errorPositions(t, err); strings.Join(got, " ") != "2:4 4:4" {
+		t.Errorf("front matter: got errors at %v", got)
+	}
+
+	c := Converter{TOML: TOMLOptions{MaxErrors: 10}, JSON: JSONVariantOptions{MaxErrors: 10}}
+	for i := 0; i < 2; i++ { // errors do not carry over between calls
+		_, err = c.AppendTOML(nil, []byte("a = \nb = 1\nc = \n"))
+		if got := errorPositions(t, err); strings.Join(got, " ") != "1:4 3:4" {
+			t.Errorf("Converter TOML: got errors at %v", got)
+		}
+		_, err = c.AppendJSONVariant(nil, []byte("[NaN, 1, NaN]"))
+		if got := errorPositions(t, err); strings.Join(got, " ") != "1:2 1:10" {
+			t.Errorf("Converter JSON: got errors at %v", got)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package tojson_test
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -113,3 +114,380 @@ func ExampleParseError() {
 	// Output:
 	// line 1, column 20: unmatched object end, level=2, stack="{["
 }
+
+func ExampleFromYAMLWithOptions() {
+	src := []byte("enabled: yes\nfallback: ~\n")
+
+	raw, err := tojson.FromYAMLWithOptions(src, tojson.YAMLOptions{
+		BoolAliases: true,
+		TildeNull:   true,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(string(raw))
+	// Output:
+	// {"enabled":true,"fallback":null}
+}
+
+func ExampleConverter() {
+	pages := [][]byte{
+		[]byte("---\ntitle: first\n---\nHello"),
+		[]byte("+++\ntitle = 'second'\n+++\nWorld"),
+	}
+
+	var c tojson.Converter
+	var buf []byte
+	for _, page := range pages {
+		meta, body, err := c.AppendFrontMatter(buf[:0], page)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("%s %s\n", meta, body)
+		buf = meta
+	}
+	// Output:
+	// {"title":"first"} Hello
+	// {"title":"second"} World
+}
+
+func ExampleUnmarshalYAML() {
+	var cfg struct {
+		Name string `json:"name"`
+		Port int    `json:"port"`
+	}
+	err := tojson.UnmarshalYAML([]byte("name: web\nport: eighty\n"), &cfg)
+
+	var pe *tojson.ParseError
+	if errors.As(err, &pe) {
+		fmt.Printf("line %d, column %d: %s\n", pe.Line, pe.Column, pe.Message)
+	}
+	// Output:
+	// line 2, column 7: cannot unmarshal string into Go struct field .port of type int
+}
+
+func ExampleFromYAMLWithSourceMap() {
+	src := []byte("server:\n  port: 8080\n  hosts: [a, b]\n")
+
+	_, sm, err := tojson.FromYAMLWithSourceMap(src)
+	if err != nil {
+		panic(err)
+	}
+
+	span, _ := sm.Lookup("/server/hosts/1")
+	fmt.Printf("%d:%d-%d:%d %s\n", span.Start.Line, span.Start.Column,
+		span.End.Line, span.End.Column, src[span.Start.Offset:span.End.Offset])
+	// Output:
+	// 3:14-3:15 b
+}
+
+func ExampleDetect() {
+	src := []byte("[server]\nport = 8080\n")
+
+	format, confidence := tojson.Detect(src)
+	fmt.Println(format, confidence)
+
+	raw, err := tojson.FromAny(src)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(raw))
+	// Output:
+	// toml 0.8
+	// {"server":{"port":8080}}
+}
+
+func ExampleToYAML() {
+	src := []byte(`{"name":"web","ports":[80,443],"debug":"true","note":"line one\nline two\n"}`)
+
+	out, err := tojson.ToYAML(src, tojson.ToYAMLOptions{})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Print(string(out))
+	// Output:
+	// name: web
+	// ports:
+	//   - 80
+	//   - 443
+	// debug: "true"
+	// note: |
+	//   line one
+	//   line two
+}
+
+func ExampleToTOML() {
+	src := []byte(`{"server":{"host":"localhost","port":8080},"title":"demo","items":[{"n":1},{"n":2}]}`)
+
+	out, err := tojson.ToTOML(src)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Print(string(out))
+	// Output:
+	// title = "demo"
+	//
+	// [server]
+	// host = "localhost"
+	// port = 8080
+	//
+	// [[items]]
+	// n = 1
+	//
+	// [[items]]
+	// n = 2
+}
+
+func ExampleFromTOMLWithOptions() {
+	src := []byte("id = 9223372036854775807\nratio = 1.50\n")
+
+	raw, err := tojson.FromTOMLWithOptions(src, tojson.TOMLOptions{Indent: "  "})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(raw))
+	// Output:
+	// {
+	//   "id": 9223372036854775807,
+	//   "ratio": 1.50
+	// }
+}
+
+func ExampleYAMLOptions_canonical() {
+	yaml := []byte("name: web\nratio: 5.0e-1\nports: [80, 443]\n")
+	toml := []byte("ports = [80, 443]\nname = 'web'\nratio = 0.50\n")
+
+	a, err := tojson.FromYAMLWithOptions(yaml, tojson.YAMLOptions{Canonical: true})
+	if err != nil {
+		panic(err)
+	}
+	b, err := tojson.FromTOMLWithOptions(toml, tojson.TOMLOptions{Canonical: true})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(a))
+	fmt.Println(bytes.Equal(a, b))
+	// Output:
+	// {"name":"web","ports":[80,443],"ratio":0.5}
+	// true
+}
+
+func ExampleYAMLOptions_duplicateKeys() {
+	src := []byte("port: 80\nhost: example.com\nport: 8080\n")
+
+	_, err := tojson.FromYAML(src)
+	fmt.Println(err)
+
+	raw, err := tojson.FromYAMLWithOptions(src, tojson.YAMLOptions{DuplicateKeys: tojson.DuplicateKeyLast})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(raw))
+	// Output:
+	// line 3, column 1: duplicate key "port"
+	// {"host":"example.com","port":8080}
+}
+
+func ExampleParseErrors() {
+	src := []byte("name: \"web\nports: [80, 443\ntls: true\n")
+
+	_, err := tojson.FromYAMLWithOptions(src, tojson.YAMLOptions{MaxErrors: 20})
+	var errs tojson.ParseErrors
+	if errors.As(err, &errs) {
+		for _, pe := range errs {
+			fmt.Printf("line %d: %s\n", pe.Line, pe.Message)
+		}
+	}
+	// Output:
+	// line 1: invalid double-quoted string: invalid syntax
+	// line 2: unterminated flow sequence
+}
+
+func ExampleJSONVariantOptions_dialect() {
+	src := []byte("{\n  // comments and trailing commas are JWCC\n  \"port\": 0x1F90,\n}")
+
+	_, err := tojson.FromJSONVariantWithOptions(src, tojson.JSONVariantOptions{Dialect: tojson.DialectJWCC})
+	fmt.Println(err)
+
+	raw, err := tojson.FromJSONVariantWithOptions(src, tojson.JSONVariantOptions{Dialect: tojson.DialectJSON5})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(raw))
+	// Output:
+	// line 3, column 11: hex literal not allowed in JWCC
+	// {"port":8080}
+}
+
+func ExampleNonFinitePolicy() {
+	src := []byte("min = -inf\nmax = inf\nmean = nan\n")
+
+	_, err := tojson.FromTOML(src)
+	fmt.Println(err)
+
+	for _, policy := range []tojson.NonFinitePolicy{tojson.NonFiniteNull, tojson.NonFiniteString} {
+		raw, err := tojson.FromTOMLWithOptions(src, tojson.TOMLOptions{NonFinite: policy})
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(raw))
+	}
+	// Output:
+	// line 1, column 7: -inf is not representable in JSON
+	// {"min":null,"max":null,"mean":null}
+	// {"min":"-Infinity","max":"Infinity","mean":"NaN"}
+}
+
+func ExampleTOMLOptions_unsafeIntegers() {
+	src := []byte("id = 0xffff_ffff_ffff_ffff_ffff_ffff_ffff_ffff\ncount = 18446744073709551615\n")
+
+	raw, err := tojson.FromTOML(src)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(raw))
+
+	raw, err = tojson.FromTOMLWithOptions(src, tojson.TOMLOptions{UnsafeIntegers: tojson.UnsafeIntegerString})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(raw))
+	// Output:
+	// {"id":340282366920938463463374607431768211455,"count":18446744073709551615}
+	// {"id":"340282366920938463463374607431768211455","count":"18446744073709551615"}
+}
+
+func ExampleUnsafeIntegerPolicy() {
+	src := []byte("{count: 42, id: 9007199254740993, ratio: 1e20}")
+
+	raw, err := tojson.FromJSONVariantWithOptions(src, tojson.JSONVariantOptions{UnsafeIntegers: tojson.UnsafeIntegerString})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(raw))
+
+	_, err = tojson.FromJSONVariantWithOptions(src, tojson.JSONVariantOptions{UnsafeIntegers: tojson.UnsafeIntegerError})
+	fmt.Println(err)
+	// Output:
+	// {"count":42,"id":"9007199254740993","ratio":1e20}
+	// line 1, column 17: integer 9007199254740993 is outside the safe range of -(2^53-1) to 2^53-1
+}
+
+func ExampleTOMLDateTimeFormat() {
+	src := []byte("day = 1979-05-27\nat = 1979-05-27 07:32:00Z\n")
+
+	for _, f := range []tojson.TOMLDateTimeFormat{tojson.TOMLDateTimeRaw, tojson.TOMLDateTimeRFC3339, tojson.TOMLDateTimeTagged} {
+		raw, err := tojson.FromTOMLWithOptions(src, tojson.TOMLOptions{DateTimes: f})
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(raw))
+	}
+
+	_, err := tojson.FromTOML([]byte("day = 2026-02-29"))
+	fmt.Println(err)
+	// Output:
+	// {"day":"1979-05-27","at":"1979-05-27 07:32:00Z"}
+	// {"day":"1979-05-27","at":"1979-05-27T07:32:00Z"}
+	// {"day":{"$datetime":"1979-05-27","kind":"local-date"},"at":{"$datetime":"1979-05-27T07:32:00Z","kind":"offset-datetime"}}
+	// line 1, column 7: invalid date-time 2026-02-29: day out of range
+}
+
+func ExampleTOMLVersion() {
+	src := []byte(`
+point = {
+  x = 1, # inline tables may span lines in TOML 1.1
+  y = 2,
+}
+esc = "\e[1m"
+at = 07:32
+`)
+	raw, err := tojson.FromTOMLWithOptions(src, tojson.TOMLOptions{
+		Version:   tojson.TOML11,
+		DateTimes: tojson.TOMLDateTimeRFC3339,
+	})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(raw))
+
+	_, err = tojson.FromTOML(src)
+	fmt.Println(err)
+	// Output:
+	// {"point":{"x":1,"y":2},"esc":"\u001b[1m","at":"07:32:00"}
+	// line 2, column 10: newline not allowed in inline table
+}
+
+func ExampleTOMLOptions_strict() {
+	src := []byte("a = \"\\x41\" # \\x is TOML 1.1 only\nb = 1 2\n")
+	_, err := tojson.FromTOMLWithOptions(src, tojson.TOMLOptions{Strict: true, MaxErrors: 10})
+	fmt.Println(err)
+	// Output:
+	// line 1, column 5: invalid basic string: \x is a TOML 1.1 escape; set TOMLOptions.Version to TOML11 to accept it
+	// line 2, column 5: unexpected content after value: 2
+}
+
+func ExampleYAMLOptions_maxAliasBytes() {
+	src := []byte("defaults: &defaults\n  retries: 3\n  timeout: 10\nprod:\n  <<: *defaults\n  timeout: 30\n")
+
+	raw, err := tojson.FromYAML(src)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(raw))
+
+	_, err = tojson.FromYAMLWithOptions(src, tojson.YAMLOptions{MaxAliasBytes: -1})
+	fmt.Println(err)
+	// Output:
+	// {"defaults":{"retries":3,"timeout":10},"prod":{"timeout":30,"retries":3}}
+	// line 5, column 7: alias *defaults: aliases are not allowed
+}
+
+func ExampleYAMLOptions_tagHandler() {
+	src := []byte("Port: !!str 8080\nBucket: !Ref Logs\nArn: !GetAtt [Logs, Arn]\n")
+
+	// CloudFormation's short form tags become its JSON functions.
+	raw, err := tojson.FromYAMLWithOptions(src, tojson.YAMLOptions{
+		TagHandler: func(tag string, value []byte) ([]byte, error) {
+			if tag == "!Ref" {
+				return fmt.Appendf(nil, `{"Ref":%s}`, value), nil
+			}
+			return fmt.Appendf(nil, `{"Fn::%s":%s}`, tag[1:], value), nil
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(raw))
+	// Output:
+	// {"Port":"8080","Bucket":{"Ref":"Logs"},"Arn":{"Fn::GetAtt":["Logs","Arn"]}}
+}
+
+func ExampleFromYAMLStream() {
+	src := []byte("apiVersion: v1\nkind: Service\n---\napiVersion: apps/v1\nkind: Deployment\n")
+	docs, err := tojson.FromYAMLStream(src)
+	if err != nil {
+		panic(err)
+	}
+	for _, doc := range docs {
+		fmt.Println(string(doc))
+	}
+
+	_, err = tojson.FromYAML(src)
+	fmt.Println(err)
+	// Output:
+	// {"apiVersion":"v1","kind":"Service"}
+	// {"apiVersion":"apps/v1","kind":"Deployment"}
+	// line 3, column 1: a second document starts here; use FromYAMLStream, or tojson -yaml-stream, for a stream of documents
+}
+
+func ExampleFromTOMLTagged() {
+	raw, err := tojson.FromTOMLTagged([]byte("n = 0x2A\nf = 1.0\nday = 1979-05-27\ntags = ['a']\n"))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(raw))
+	// Output:
+	// {"n":{"type":"integer","value":"42"},"f":{"type":"float","value":"1.0"},"day":{"type":"date-local","value":"1979-05-27"},"tags":[{"type":"string","value":"a"}]}
+}
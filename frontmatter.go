@@ -3,6 +3,7 @@ package tojson
 import (
 	"bytes"
 	"fmt"
+	"slices"
 )
 
 // fmDef describes one recognised front matter format.
@@ -61,52 +62,110 @@ var frontMatterFormats = []fmDef{
 // with len(src)-len(body) when an exact position is needed (e.g. to adjust
 // line numbers or byte offsets in downstream error messages).
 func FromFrontMatter(in []byte) (meta []byte, body []byte, err error) {
-	def, rest, found, err := detectFrontMatterFormat(in)
+	return FromFrontMatterWithOptions(in, FrontMatterOptions{})
+}
+
+// FrontMatterOptions holds the options FromFrontMatterWithOptions applies to
+// each front matter format. The zero value selects the defaults.
+type FrontMatterOptions struct {
+	YAML YAMLOptions        // for --- and ---yaml blocks
+	TOML TOMLOptions        // for +++ and ---toml blocks
+	JSON JSONVariantOptions // for {, ---json, and ```json blocks
+}
+
+// FromFrontMatterWithOptions is like FromFrontMatter but converts the front
+// matter according to the options for its format.
+func FromFrontMatterWithOptions(in []byte, opts FrontMatterOptions) (meta []byte, body []byte, err error) {
+	blk, found, err := splitFrontMatter(in, nil)
 	if err != nil {
 		return nil, nil, err
 	}
 	if !found {
 		return nil, in, nil
 	}
+	if len(blk.src) == 0 {
+		meta, err = formatJSON(nil, []byte("{}"), opts.output(blk.format))
+		if err != nil {
+			return nil, nil, err
+		}
+		return meta, blk.body, nil
+	}
 
-	block, tail, err := extractFMBlock(rest, def.open, def.close)
+	switch blk.format {
+	case "yaml":
+		meta, err = FromYAMLWithOptions(blk.src, opts.YAML)
+	case "toml":
+		meta, err = FromTOMLWithOptions(blk.src, opts.TOML)
+	case "json":
+		meta, err = FromJSONVariantWithOptions(blk.src, opts.JSON)
+	}
 	if err != nil {
 		return nil, nil, err
 	}
+	return meta, blk.body, nil
+}
+
+// output returns the output layout of the options for format, "yaml",
+// "toml", or "json".
+func (o FrontMatterOptions) output(format string) outputFormat {
+	switch format {
+	case "toml":
+		return o.TOML.output()
+	case "json":
+		return o.JSON.output()
+	}
+	return o.YAML.output()
+}
+
+// fmBlock is a located front matter block, ready for conversion.
+type fmBlock struct {
+	format string // "yaml", "toml", or "json"
+	src    []byte // bytes to convert; empty when the block has no content
+	base   int    // offset in the input that src[0] corresponds to
+	body   []byte // bytes after the closing sentinel line
+}
+
+// splitFrontMatter locates the front matter in in and returns the block to
+// convert, or found == false when in has no recognised opening sentinel.
+// The { format is rebuilt into a complete JSON object; scratch, when non-nil,
+// supplies reusable memory for that copy and receives the grown slice.
+func splitFrontMatter(in []byte, scratch *[]byte) (blk fmBlock, found bool, err error) {
+	def, rest, found, err := detectFrontMatterFormat(in)
+	if err != nil || !found {
+		return fmBlock{}, false, err
+	}
+
+	block, tail, err := extractFMBlock(rest, def.open, def.close)
+	if err != nil {
+		return fmBlock{}, false, err
+	}
+	blk = fmBlock{format: def.format, base: len(in) - len(rest), body: tail}
+	if len(bytes.TrimSpace(block)) == 0 {
+		return blk, true, nil
+	}
 
 	// The { format uses the opening brace as part of the content: reconstruct
 	// the full JSON object so the converter receives valid input.
-	src := block
+	blk.src = block
 	if def.open == "{" {
-		if len(bytes.TrimSpace(block)) == 0 {
-			return []byte("{}"), tail, nil
+		var buf []byte
+		if scratch != nil {
+			buf = (*scratch)[:0]
 		}
-		buf := make([]byte, 0, len(block)+4)
+		buf = slices.Grow(buf, len(block)+4)
 		buf = append(buf, '{', '\n')
 		buf = append(buf, block...)
-		if len(buf) == 0 || buf[len(buf)-1] != '\n' {
+		if buf[len(buf)-1] != '\n' {
 			buf = append(buf, '\n')
 		}
 		buf = append(buf, '}')
-		src = buf
-	}
-
-	if len(bytes.TrimSpace(src)) == 0 {
-		return []byte("{}"), tail, nil
-	}
-
-	switch def.format {
-	case "yaml":
-		meta, err = FromYAML(src)
-	case "toml":
-		meta, err = FromTOML(src)
-	case "json":
-		meta, err = FromJSONVariant(src)
-	}
-	if err != nil {
-		return nil, nil, err
+		if scratch != nil {
+			*scratch = buf
+		}
+		blk.src = buf
+		blk.base -= 2 // the rebuilt "{\n" stands in for the opening line
 	}
-	return meta, tail, nil
+	return blk, true, nil
 }
 
 // detectFrontMatterFormat inspects the first line of in and returns the
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"strconv"
 	"unicode/utf8"
 )
 
@@ -40,18 +39,78 @@ type decoder struct {
 	next     stateFunction
 	lastRow  int
 	lastCol  int
+	sm       *srcMap // when non-nil, receives the source of each key and value
+	keys     dupKeys
+	errs     errorList
+	cur      token // the token being handled, for resync
+	implied  token // a key read where a comma was missing; see stateImpliedKey
+	dialect  JSONDialect
+	nonFin   nonFinite
+	ints     intFormat
 }
 
 type stateFunction func(d *decoder, t token) error
 
+// reset points d at out and empties its container stack, keeping any stack
+// capacity grown by an earlier call.
+func (d *decoder) reset(out *bytes.Buffer) {
+	d.out = out
+	if cap(d.stack) > len(d.stackbuf) {
+		d.stack = d.stack[:0]
+	} else {
+		d.stack = d.stackbuf[:0]
+	}
+}
+
+// configure applies the options in opts that affect parsing.
+func (d *decoder) configure(opts JSONVariantOptions) {
+	d.dialect = opts.Dialect
+	d.keys.policy = opts.DuplicateKeys
+	d.errs.max = opts.MaxErrors
+	d.nonFin = opts.nonFinite()
+	d.ints = opts.ints()
+}
+
+// writeBareword writes the unquoted value t: true, false, or null as
+// itself, NaN or Infinity under d's non-finite policy, and any other word as
+// a string.
+func (d *decoder) writeBareword(t token) error {
+	if nan := isNaN(t.value); nan || isInfinity(t.value) {
+		if !d.dialect.allows(featJSON5) {
+			return atToken(t, d.dialect.notAllowed(string(t.value)))
+		}
+		if err := d.nonFin.write(d.out, t.value, nan, t.value[0] == '-'); err != nil {
+			return atToken(t, err)
+		}
+		return nil
+	}
+	if err := d.dialect.checkBareword(t); err != nil {
+		return err
+	}
+	bareword(d.out, t.value)
+	return nil
+}
+
 func (d *decoder) Translate(src []byte) error {
-	d.tok = tokenizer{data: src}
+	if d.dialect < DialectPermissive || d.dialect > DialectJSON5 {
+		return fmt.Errorf("unknown JSONDialect %d", int(d.dialect))
+	}
+	d.tok = tokenizer{data: src, dialect: d.dialect}
 	d.next = stateValue
+	d.keys.reset()
+	d.errs = errorList{max: d.errs.max}
+	return d.errs.result(d.translate())
+}
 
+func (d *decoder) translate() error {
+	start := d.out.Len()
 	for {
-		t, err := d.tok.Next()
+		t, err := d.token()
 		if err == io.EOF {
 			if len(d.stack) == 0 {
+				if d.out.Len() == start && d.dialect != DialectPermissive {
+					return &ParseError{Line: d.tok.row + 1, Column: d.tok.col + 1, Message: "expected a value"}
+				}
 				return nil
 			}
 			return &ParseError{Line: d.lastRow + 1, Column: d.lastCol + 1, Message: "got end of file prematurely"}
@@ -60,21 +119,88 @@ func (d *decoder) Translate(src []byte) error {
 			return err
 		}
 
-		// ignore comments
-		if t.kind == 'c' {
-			continue
-		}
 		d.lastRow = t.row
 		d.lastCol = t.col
+		d.cur = t
 		err = d.next(d, t)
 
 		if err != nil {
+			if !d.errs.add(err) {
+				return err
+			}
+			if err := d.resync(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// token returns the next token that is not a comment. A token that the
+// dialect does not accept is returned with its error, unless the error is
+// recorded for a conversion that continues past errors; the token is then
+// handled as though the dialect accepted it.
+func (d *decoder) token() (token, error) {
+	for {
+		t, err := d.tok.Next()
+		if err != nil && t.kind != 0 && d.errs.add(err) {
+			err = nil
+		}
+		if err != nil || t.kind != 'c' {
+			return t, err
+		}
+	}
+}
+
+// resync skips the input from d.cur, the token at which an error occurred,
+// to the ',' or closing bracket that ends the enclosing array element or
+// object member, and continues from there as though the element or member
+// had been well formed. Containers opened while skipping are skipped whole.
+func (d *decoder) resync() error {
+	t := d.cur
+	depth := 0
+	for {
+		switch t.kind {
+		case leftBrace, leftBracket:
+			depth++
+		case ',':
+			if depth == 0 && len(d.stack) > 0 {
+				if d.stack[len(d.stack)-1] == leftBrace {
+					d.next = stateObjectAfterStart
+				} else {
+					d.next = stateArrayAfterStart
+				}
+				return nil
+			}
+		case rightBrace, rightBracket:
+			if depth > 0 {
+				depth--
+				break
+			}
+			if len(d.stack) == 0 {
+				break
+			}
+			// A mismatched bracket is taken as a typo for the right one.
+			t.kind = rightBracket
+			if d.stack[len(d.stack)-1] == leftBrace {
+				t.kind = rightBrace
+				return stateObjectEnd(d, t)
+			}
+			return stateArrayEnd(d, t)
+		}
+		var err error
+		if t, err = d.token(); err != nil {
+			if err == io.EOF {
+				return nil // reported by translate
+			}
 			return err
 		}
 	}
 }
 
+// stateValue handles the top-level value. A scalar ends the document as a
+// closed container does, so stateAfterContainer rejects anything after it.
 func stateValue(d *decoder, t token) error {
+	d.sm.valueOf(d.out, t.value)
 	switch t.kind {
 	case leftBrace:
 		return stateObjectStart(d, t)
@@ -82,28 +208,27 @@ func stateValue(d *decoder, t token) error {
 		return stateArrayStart(d, t)
 	case 's':
 		writeString(d.out, t.value)
-		d.next = stateObjectAfterValue
+		d.next = stateAfterContainer
 	case '0':
-		if err := writeInt(d.out, t.value); err != nil {
+		if err := writeInt(d.out, t.value, d.ints); err != nil {
 			return atToken(t, err)
 		}
-		d.next = stateObjectAfterValue
+		d.next = stateAfterContainer
 	case '1':
 		if err := writeFloat(d.out, t.value); err != nil {
 			return atToken(t, err)
 		}
-		d.next = stateObjectAfterValue
+		d.next = stateAfterContainer
 	case '2':
-		if err := writeHex(d.out, t.value); err != nil {
+		if err := writeHex(d.out, t.value, d.ints); err != nil {
 			return atToken(t, err)
 		}
-		d.next = stateObjectAfterValue
+		d.next = stateAfterContainer
 	case 'w':
-		if isNaN(t.value) || isInfinity(t.value) {
-			return atToken(t, fmt.Errorf("%s is not representable in JSON", t.value))
+		if err := d.writeBareword(t); err != nil {
+			return err
 		}
-		bareword(d.out, t.value)
-		d.next = stateObjectAfterValue
+		d.next = stateAfterContainer
 	default:
 		return atToken(t, fmt.Errorf("unknown token for value"))
 	}
@@ -113,6 +238,7 @@ func stateValue(d *decoder, t token) error {
 func stateObjectStart(d *decoder, t token) error {
 	d.out.WriteByte('{')
 	d.stack = append(d.stack, '{')
+	d.keys.open()
 	d.next = stateObjectAfterStart
 	return nil
 }
@@ -122,6 +248,9 @@ func stateObjectAfterStart(d *decoder, t token) error {
 	case '}':
 		return stateObjectEnd(d, t)
 	case ',': // degenerate case
+		if d.dialect != DialectPermissive {
+			return atToken(t, d.dialect.notAllowed("leading comma"))
+		}
 		// ignore comma and reparse
 		d.next = stateObjectAfterStart
 		return nil
@@ -130,6 +259,11 @@ func stateObjectAfterStart(d *decoder, t token) error {
 }
 
 func stateObjectKey(d *decoder, t token) error {
+	if err := d.dialect.checkKey(t); err != nil {
+		return err
+	}
+	d.sm.keyOf(d.out, t.value)
+	start := d.out.Len()
 	switch t.kind {
 	case 's':
 		writeString(d.out, t.value)
@@ -138,9 +272,14 @@ func stateObjectKey(d *decoder, t token) error {
 		// whatever it is, it's always quoted
 		writeQuoted(d.out, t.value)
 		d.next = stateObjectAfterKey
+	case ',':
+		return atToken(t, fmt.Errorf("expected a key after ','"))
 	default:
 		return atToken(t, fmt.Errorf("invalid token at object key: %s", t))
 	}
+	if err := d.keys.key(d.out, start, d.sm); err != nil {
+		return atToken(t, err)
+	}
 	return nil
 }
 
@@ -154,19 +293,40 @@ func stateObjectAfterKey(d *decoder, t token) error {
 	return atToken(t, fmt.Errorf("invalid token after object key"))
 }
 
+// stateImpliedKey reads t as the key of a member whose leading comma is
+// missing.
+func stateImpliedKey(d *decoder, t token) error {
+	if err := stateObjectKey(d, t); err != nil {
+		return err
+	}
+	d.implied = t
+	d.next = stateObjectAfterImpliedKey
+	return nil
+}
+
+// stateObjectAfterImpliedKey follows a key read by stateImpliedKey. Without
+// a ':' after it, the token was not a key but a second value, as the 2 in
+// {a: 1 2}, and is reported as such.
+func stateObjectAfterImpliedKey(d *decoder, t token) error {
+	if t.kind == ':' {
+		return stateObjectAfterKey(d, t)
+	}
+	return atToken(d.implied, fmt.Errorf("expected ',' or '}' after object value"))
+}
+
 func stateObjectValue(d *decoder, t token) error {
+	d.sm.valueOf(d.out, t.value)
 	switch t.kind {
 	case 's':
 		writeString(d.out, t.value)
 		d.next = stateObjectAfterValue
 	case 'w':
-		if isNaN(t.value) || isInfinity(t.value) {
-			return atToken(t, fmt.Errorf("%s is not representable in JSON", t.value))
+		if err := d.writeBareword(t); err != nil {
+			return err
 		}
-		bareword(d.out, t.value)
 		d.next = stateObjectAfterValue
 	case '0':
-		if err := writeInt(d.out, t.value); err != nil {
+		if err := writeInt(d.out, t.value, d.ints); err != nil {
 			return atToken(t, err)
 		}
 		d.next = stateObjectAfterValue
@@ -176,7 +336,7 @@ func stateObjectValue(d *decoder, t token) error {
 		}
 		d.next = stateObjectAfterValue
 	case '2':
-		if err := writeHex(d.out, t.value); err != nil {
+		if err := writeHex(d.out, t.value, d.ints); err != nil {
 			return atToken(t, err)
 		}
 		d.next = stateObjectAfterValue
@@ -198,8 +358,11 @@ func stateObjectAfterValue(d *decoder, t token) error {
 		// MIDDLE COMMA
 	case 'w', 's', '0', '1', '2':
 		// e.g. { "key": 1 "key2": 2 }  ==> { "key": 1, "key2": 2 }
+		if d.dialect != DialectPermissive {
+			return atToken(t, d.dialect.notAllowed("missing comma"))
+		}
 		d.out.WriteByte(',')
-		return stateObjectKey(d, t)
+		return stateImpliedKey(d, t)
 	default:
 		return atToken(t, fmt.Errorf("unknown token after object value: %s", t))
 	}
@@ -208,20 +371,22 @@ func stateObjectAfterValue(d *decoder, t token) error {
 func stateComma(d *decoder, t token) error {
 	// check if next token is "}"
 
-	t2, err := d.tok.Next()
+	t2, err := d.token()
 	if err != nil {
+		if err == io.EOF {
+			return nil // reported by translate
+		}
 		return err
 	}
-
-	if t2.kind == 'c' {
-		// if comment, reparse
-		return stateComma(d, t)
-	}
-	if t2.kind == '}' {
+	d.cur = t2
+	if t2.kind == '}' || t2.kind == ']' {
+		if !d.dialect.allows(featTrailingCommas) {
+			return atToken(t, d.dialect.notAllowed("trailing comma"))
+		}
 		// Skip writing comma
-		return stateObjectEnd(d, t2)
-	}
-	if t2.kind == ']' {
+		if t2.kind == '}' {
+			return stateObjectEnd(d, t2)
+		}
 		return stateArrayEnd(d, t2)
 	}
 
@@ -240,6 +405,8 @@ func stateObjectEnd(d *decoder, t token) error {
 	if len(d.stack) == 0 || d.stack[len(d.stack)-1] != '{' {
 		return atToken(t, fmt.Errorf("unmatched object end, level=%d, stack=%q", len(d.stack), string(d.stack)))
 	}
+	d.keys.close(d.out, d.sm)
+	d.sm.closeOf(d.out, t.value)
 	d.out.WriteByte('}')
 	d.stack = d.stack[:len(d.stack)-1]
 	d.next = stateAfterContainer
@@ -247,6 +414,9 @@ func stateObjectEnd(d *decoder, t token) error {
 }
 
 func stateAfterContainer(d *decoder, t token) error {
+	if len(d.stack) == 0 {
+		return atToken(t, fmt.Errorf("unknown token after top-level value: %s", t))
+	}
 
 	switch t.kind {
 	case rightBrace:
@@ -258,10 +428,13 @@ func stateAfterContainer(d *decoder, t token) error {
 
 	// MIDDLE COMMA
 	case leftBrace, leftBracket, 'w', 's', '0', '1', '2':
+		if d.dialect != DialectPermissive {
+			return atToken(t, d.dialect.notAllowed("missing comma"))
+		}
 		d.out.WriteByte(',')
 		if d.stack[len(d.stack)-1] == leftBrace {
 			// write comma, and expect a key
-			return stateObjectKey(d, t)
+			return stateImpliedKey(d, t)
 		} else {
 			// it's an array value
 			return stateArrayValue(d, t)
@@ -284,6 +457,9 @@ func stateArrayAfterStart(d *decoder, t token) error {
 	case ']':
 		return stateArrayEnd(d, t)
 	case ',': // degenerate case
+		if d.dialect != DialectPermissive {
+			return atToken(t, d.dialect.notAllowed("leading comma"))
+		}
 		// ignore comma and reparse
 		d.next = stateArrayAfterStart
 		return nil
@@ -295,6 +471,7 @@ func stateArrayEnd(d *decoder, t token) error {
 	if len(d.stack) == 0 || d.stack[len(d.stack)-1] != '[' {
 		return atToken(t, fmt.Errorf("unmatched array end"))
 	}
+	d.sm.closeOf(d.out, t.value)
 	d.out.WriteByte(']')
 	d.stack = d.stack[:len(d.stack)-1]
 	d.next = stateAfterContainer
@@ -302,18 +479,18 @@ func stateArrayEnd(d *decoder, t token) error {
 }
 
 func stateArrayValue(d *decoder, t token) error {
+	d.sm.valueOf(d.out, t.value)
 	switch t.kind {
 	case 's':
 		writeString(d.out, t.value)
 		d.next = stateArrayAfterValue
 	case 'w':
-		if isNaN(t.value) || isInfinity(t.value) {
-			return atToken(t, fmt.Errorf("%s is not representable in JSON", t.value))
+		if err := d.writeBareword(t); err != nil {
+			return err
 		}
-		bareword(d.out, t.value)
 		d.next = stateArrayAfterValue
 	case '0':
-		if err := writeInt(d.out, t.value); err != nil {
+		if err := writeInt(d.out, t.value, d.ints); err != nil {
 			return atToken(t, err)
 		}
 		d.next = stateArrayAfterValue
@@ -323,7 +500,7 @@ func stateArrayValue(d *decoder, t token) error {
 		}
 		d.next = stateArrayAfterValue
 	case '2':
-		if err := writeHex(d.out, t.value); err != nil {
+		if err := writeHex(d.out, t.value, d.ints); err != nil {
 			return atToken(t, err)
 		}
 		d.next = stateArrayAfterValue
@@ -331,6 +508,8 @@ func stateArrayValue(d *decoder, t token) error {
 		return stateObjectStart(d, t)
 	case '[':
 		return stateArrayStart(d, t)
+	case ',':
+		return atToken(t, fmt.Errorf("expected a value after ','"))
 	default:
 		return atToken(t, fmt.Errorf("unknown token for array value: %s", t))
 	}
@@ -348,6 +527,9 @@ func stateArrayAfterValue(d *decoder, t token) error {
 	case leftBrace, leftBracket, 'w', 's', '0', '1', '2':
 		// e.g. [ 1 2 3 ] ==> [ 1,2,3 ]
 		//      [ "foo" "bar" ] ==> [ "foo", "bar" ]
+		if d.dialect != DialectPermissive {
+			return atToken(t, d.dialect.notAllowed("missing comma"))
+		}
 		d.out.WriteByte(',')
 		return stateArrayValue(d, t)
 	}
@@ -383,24 +565,33 @@ func isFalse(b []byte) bool {
 		b[4] == 'e'
 }
 
-func writeInt(out *bytes.Buffer, b []byte) error {
+func writeInt(out *bytes.Buffer, b []byte, f intFormat) error {
 	if len(b) == 0 {
 		return nil
 	}
+	start := out.Len()
 	writeNormalizedNumber(out, b)
-	return nil
+	return f.rewriteTail(out, start)
 }
 
 // Unoptimized since it's a rare feature
-func writeHex(out *bytes.Buffer, b []byte) error {
+func writeHex(out *bytes.Buffer, b []byte, f intFormat) error {
+	// JSON5 allows a sign
+	hex, neg := b, false
+	switch hex[0] {
+	case '-':
+		hex, neg = hex[1:], true
+	case '+':
+		hex = hex[1:]
+	}
 	// slice off "0x" or "0X"
-	s := string(b[2:])
-	num, err := strconv.ParseUint(s, 16, 64)
-	if err == nil {
-		out.WriteString(strconv.FormatUint(num, 10))
-		return nil
+	num, err := appendRadixInt(out.AvailableBuffer(), hex[2:], 16, neg)
+	if err != nil {
+		return fmt.Errorf("invalid hex literal %s", b)
 	}
-	return fmt.Errorf("hex literal %s overflows uint64", b)
+	start := out.Len()
+	out.Write(num)
+	return f.rewriteTail(out, start)
 }
 
 func writeFloat(out *bytes.Buffer, b []byte) error {
@@ -168,12 +168,12 @@ func TestDecodeNumbers(t *testing.T) {
 
 func TestDecodeNumberErrors(t *testing.T) {
 	cases := []string{
-		// hex overflow: 2^64, exceeds uint64
-		"0x10000000000000000",
-		// hex overflow in object value
-		`{"x":0x10000000000000000}`,
-		// hex overflow in array
-		`[0x10000000000000000]`,
+		// hex prefix without digits, in object value
+		`{"x":0x}`,
+		// in array
+		`[0x]`,
+		// nested
+		`{a: [1, 0x]}`,
 	}
 	for _, in := range cases {
 		_, err := FromJSONVariant([]byte(in))
@@ -221,9 +221,22 @@ func TestDecodeStrings(t *testing.T) {
 			"`foo\nbar`",
 			"\"foo\\nbar\"",
 		},
+		{
+			`["a\\", "b"]`,
+			`["a\\","b"]`,
+		},
+		{
+			"'foo\\\nbar'",
+			"\"foobar\"",
+		},
+		{
+			"'foo\\\r\nbar'",
+			"\"foobar\"",
+		},
 	}
 	for _, tt := range cases {
-		out, err := FromJSONVariant([]byte(tt.in))
+		src := []byte(tt.in)
+		out, err := FromJSONVariant(src)
 		if err != nil {
 			t.Errorf("Got error: %v", err)
 		}
@@ -231,6 +244,9 @@ func TestDecodeStrings(t *testing.T) {
 		if tt.out != got {
 			t.Errorf("Expected %s got %s", tt.out, got)
 		}
+		if string(src) != tt.in {
+			t.Errorf("input modified: %q", src)
+		}
 	}
 }
 
@@ -272,12 +288,34 @@ func TestDecodeImplicitComma(t *testing.T) {
 	}
 }
 
+func TestDecodeImplicitCommaErrors(t *testing.T) {
+	// A second value where a member should be is not read as a key.
+	cases := []struct {
+		in     string
+		column int
+	}{
+		{"{a: 1 2}", 7},
+		{`{"a": 1 "b"}`, 9},
+		{"{a: {} 2}", 8},
+		{"{a: [1] 'b' 3}", 9},
+	}
+	for _, tc := range cases {
+		_, err := FromJSONVariant([]byte(tc.in))
+		pe := requireParseError(t, err)
+		if pe.Line != 1 || pe.Column != tc.column || pe.Message != "expected ',' or '}' after object value" {
+			t.Errorf("Decode(%q): got %v, want column %d", tc.in, err, tc.column)
+		}
+	}
+}
+
 func TestDecodeErrors(t *testing.T) {
 	cases := []string{
 		"[}",    // array closed with object brace
 		"{]",    // object closed with array bracket
 		`{"a"}`, // object key without colon
 		"[:]",   // colon in array value position
+		"{} 1",  // value after the top-level value
+		"[], 1", // comma after the top-level value
 	}
 	for _, in := range cases {
 		_, err := FromJSONVariant([]byte(in))
@@ -322,8 +360,8 @@ func TestJSON5ParseError(t *testing.T) {
 		{"unescaped newline line 2", "{\n  \"bad\": \"has\nnewline\"}", 2, 10},
 		// NaN: error at the line containing NaN
 		{"NaN line 2", "[\n  NaN\n]", 2, 3},
-		// hex overflow: error at the line containing the literal
-		{"hex overflow line 2", "[\n  0x10000000000000000\n]", 2, 3},
+		// invalid hex: error at the line containing the literal
+		{"invalid hex line 2", "[\n  0x\n]", 2, 3},
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
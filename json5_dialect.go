@@ -0,0 +1,305 @@
+package tojson
+
+import (
+	"bytes"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// JSONDialect selects the JSON variant that FromJSONVariantWithOptions
+// accepts. Every dialect produces the same JSON for the inputs it accepts;
+// the stricter ones report anything outside their specification as a
+// *ParseError naming the feature and the dialect, such as "hex literal not
+// allowed in JWCC".
+type JSONDialect int
+
+const (
+	// DialectPermissive accepts the union of the JSON variants listed in
+	// the package documentation, along with common mistakes such as missing
+	// or doubled commas. It is the default.
+	DialectPermissive JSONDialect = iota
+
+	// DialectJSON accepts only standard JSON as specified by RFC 8259.
+	DialectJSON
+
+	// DialectJSONC accepts JSON with // and /* */ comments, as in the
+	// JSONC specification at jsonc.org.
+	DialectJSONC
+
+	// DialectJWCC accepts JSON with // and /* */ comments and a trailing
+	// comma after the last element of an array or object. HuJSON is the
+	// same format.
+	DialectJWCC
+
+	// DialectJSON5 accepts JSON5 as specified at spec.json5.org: JWCC plus
+	// identifier keys, single-quoted strings, additional string escapes and
+	// line continuations, hexadecimal numbers, a leading '+', and a leading
+	// or trailing decimal point. NaN and Infinity are converted according
+	// to JSONVariantOptions.NonFinite.
+	DialectJSON5
+)
+
+// String returns the name used for d in error messages.
+func (d JSONDialect) String() string {
+	switch d {
+	case DialectPermissive:
+		return "permissive"
+	case DialectJSON:
+		return "JSON"
+	case DialectJSONC:
+		return "JSONC"
+	case DialectJWCC:
+		return "JWCC"
+	case DialectJSON5:
+		return "JSON5"
+	}
+	return fmt.Sprintf("JSONDialect(%d)", int(d))
+}
+
+// jsonFeature is a set of the extensions to JSON that dialects allow.
+type jsonFeature uint8
+
+const (
+	featComments       jsonFeature = 1 << iota // // and /* */ comments
+	featTrailingCommas                         // one comma before a closing bracket
+	featJSON5                                  // the remaining JSON5 extensions
+)
+
+// allows reports whether d accepts every extension in f. The check
+// functions below return early for DialectPermissive, which accepts more
+// than any set of features describes.
+func (d JSONDialect) allows(f jsonFeature) bool {
+	var have jsonFeature
+	switch d {
+	case DialectPermissive:
+		return true
+	case DialectJSONC:
+		have = featComments
+	case DialectJWCC:
+		have = featComments | featTrailingCommas
+	case DialectJSON5:
+		have = featComments | featTrailingCommas | featJSON5
+	}
+	return have&f == f
+}
+
+// notAllowed reports that d does not accept what.
+func (d JSONDialect) notAllowed(what string) error {
+	return fmt.Errorf("%s not allowed in %s", what, d)
+}
+
+// checkComment reports a comment that tx.dialect does not accept.
+func (tx *tokenizer) checkComment(t token) error {
+	switch d := tx.dialect; {
+	case d == DialectPermissive:
+	case t.value[0] == '#':
+		return atToken(t, d.notAllowed("# comment"))
+	case !d.allows(featComments):
+		return atToken(t, d.notAllowed("comment"))
+	case t.value[1] == aster && (len(t.value) < 4 || !bytes.HasSuffix(t.value, []byte("*/"))):
+		return atToken(t, fmt.Errorf("unterminated comment"))
+	}
+	return nil
+}
+
+// checkString reports a quote style, escape sequence, or raw character in
+// the string token t that tx.dialect does not accept. An escape is reported
+// at its own position.
+func (tx *tokenizer) checkString(t token) error {
+	d := tx.dialect
+	switch {
+	case d == DialectPermissive:
+		return nil
+	case t.value[0] == backQuote:
+		return atToken(t, d.notAllowed("backtick string"))
+	case t.value[0] == singleQuote && !d.allows(featJSON5):
+		return atToken(t, d.notAllowed("single-quoted string"))
+	}
+
+	s := t.value[1 : len(t.value)-1]
+	at := func(i int, err error) error {
+		off := i + 1 // for the opening quote
+		row, col := t.row, t.col+off
+		if nl := bytes.LastIndexByte(t.value[:off], '\n'); nl >= 0 {
+			row += bytes.Count(t.value[:off], []byte{'\n'})
+			col = off - nl - 1
+		}
+		return &ParseError{Line: row + 1, Column: col + 1, Message: err.Error()}
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c < 0x20:
+			if !d.allows(featJSON5) {
+				return at(i, d.notAllowed("unescaped control character"))
+			}
+		case c == backslash:
+			i++
+			switch e := s[i]; e {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+			case '\n', '\r':
+				if !d.allows(featJSON5) {
+					return at(i-1, d.notAllowed("line continuation"))
+				}
+			case 'u':
+				if !isHexDigits(s[i+1:], 4) {
+					return at(i-1, fmt.Errorf("invalid \\u escape"))
+				}
+			case 'x':
+				if !d.allows(featJSON5) {
+					return at(i-1, d.notAllowed(`\x escape`))
+				}
+				if !isHexDigits(s[i+1:], 2) {
+					return at(i-1, fmt.Errorf("invalid \\x escape"))
+				}
+			case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+				return at(i-1, fmt.Errorf("invalid escape \\%c", e))
+			default:
+				if e == '0' && i+1 < len(s) && '0' <= s[i+1] && s[i+1] <= '9' {
+					return at(i-1, fmt.Errorf("invalid escape \\0 followed by a digit"))
+				}
+				if !d.allows(featJSON5) {
+					r, _ := utf8.DecodeRune(s[i:])
+					return at(i-1, d.notAllowed(fmt.Sprintf("escape \\%c", r)))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isHexDigits reports whether s starts with n hexadecimal digits.
+func isHexDigits(s []byte, n int) bool {
+	if len(s) < n {
+		return false
+	}
+	for _, b := range s[:n] {
+		if hexVal(b) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// checkNumber reports a number form in t that tx.dialect does not accept,
+// and corrects the kind of a token the tokenizer left as a bareword, such as
+// 1E+5 or -0x1F, when the dialect accepts it as a number. NaN and Infinity
+// are left for the state functions to reject.
+func (tx *tokenizer) checkNumber(t *token) error {
+	d := tx.dialect
+	if d == DialectPermissive {
+		return nil
+	}
+	s := t.value
+	if s[0] == '+' {
+		if !d.allows(featJSON5) {
+			return atToken(*t, d.notAllowed("leading +"))
+		}
+		s = s[1:]
+	} else if s[0] == '-' {
+		s = s[1:]
+	}
+	if isNaN(s) || isInfinity(s) {
+		return nil
+	}
+	if len(s) == 0 {
+		return atToken(*t, fmt.Errorf("invalid number %s", t.value))
+	}
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		if !d.allows(featJSON5) {
+			return atToken(*t, d.notAllowed("hex literal"))
+		}
+		if !isHexDigits(s[2:], len(s)-2) {
+			return atToken(*t, fmt.Errorf("invalid number %s", t.value))
+		}
+		t.kind = '2'
+		return nil
+	}
+
+	digits := func() int {
+		n := 0
+		for n < len(s) && '0' <= s[n] && s[n] <= '9' {
+			n++
+		}
+		s = s[n:]
+		return n
+	}
+	kind := byte('0')
+	if s[0] == '0' && len(s) > 1 && '0' <= s[1] && s[1] <= '9' {
+		return atToken(*t, d.notAllowed("leading zero"))
+	}
+	intDigits := digits()
+	if len(s) > 0 && s[0] == '.' {
+		kind = '1'
+		s = s[1:]
+		fracDigits := digits()
+		switch {
+		case intDigits == 0 && fracDigits == 0:
+			return atToken(*t, fmt.Errorf("invalid number %s", t.value))
+		case intDigits == 0 && !d.allows(featJSON5):
+			return atToken(*t, d.notAllowed("leading decimal point"))
+		case fracDigits == 0 && !d.allows(featJSON5):
+			return atToken(*t, d.notAllowed("trailing decimal point"))
+		}
+	} else if intDigits == 0 {
+		return atToken(*t, fmt.Errorf("invalid number %s", t.value))
+	}
+	if len(s) > 0 && (s[0] == 'e' || s[0] == 'E') {
+		kind = '1'
+		s = s[1:]
+		if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+			s = s[1:]
+		}
+		if digits() == 0 {
+			return atToken(*t, fmt.Errorf("invalid number %s", t.value))
+		}
+	}
+	if len(s) > 0 {
+		return atToken(*t, fmt.Errorf("invalid number %s", t.value))
+	}
+	t.kind = kind
+	return nil
+}
+
+// checkKey reports an object key that d does not accept.
+func (d JSONDialect) checkKey(t token) error {
+	if t.kind == 's' || d == DialectPermissive || !isKeyKind(t.kind) {
+		return nil
+	}
+	if !d.allows(featJSON5) {
+		return atToken(t, d.notAllowed("unquoted key"))
+	}
+	if !isIdentifier(t.value) {
+		return atToken(t, d.notAllowed(fmt.Sprintf("unquoted key %s", t.value)))
+	}
+	return nil
+}
+
+// isKeyKind reports whether a token of kind could be an unquoted key; any
+// other token is reported by stateObjectKey as out of place.
+func isKeyKind(kind byte) bool {
+	return kind == 'w' || kind == '0' || kind == '1' || kind == '2'
+}
+
+// isIdentifier reports whether s is an ECMAScript IdentifierName without
+// escapes, as JSON5 allows for an unquoted key.
+func isIdentifier(s []byte) bool {
+	for i, r := range string(s) {
+		switch {
+		case r == '$' || r == '_' || unicode.IsLetter(r) || unicode.Is(unicode.Nl, r):
+		case i > 0 && (unicode.IsDigit(r) || unicode.In(r, unicode.Mn, unicode.Mc, unicode.Pc) || r == '\u200c' || r == '\u200d'):
+		default:
+			return false
+		}
+	}
+	return len(s) > 0
+}
+
+// checkBareword reports an unquoted value other than true, false, and null
+// that d does not accept.
+func (d JSONDialect) checkBareword(t token) error {
+	if d == DialectPermissive || isNull(t.value) || isTrue(t.value) || isFalse(t.value) {
+		return nil
+	}
+	return atToken(t, d.notAllowed("unquoted string"))
+}
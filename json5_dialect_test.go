@@ -0,0 +1,188 @@
+package tojson
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+var jsonDialects = []JSONDialect{DialectJSON, DialectJSONC, DialectJWCC, DialectJSON5}
+
+func TestJSONDialects(t *testing.T) {
+	const (
+		json  = 1 << DialectJSON
+		jsonc = 1 << DialectJSONC
+		jwcc  = 1 << DialectJWCC
+		json5 = 1 << DialectJSON5
+		all   = json | jsonc | jwcc | json5
+	)
+	tests := []struct {
+		name   string
+		input  string
+		accept int    // dialects that accept the input
+		want   string // output when accepted
+	}{
+		{"json", `{"a": [1, -0.5e+3, 1E2, true, null, "xé\n\\"]}`, all, `{"a":[1,-0.5e+3,1E2,true,null,"xé\n\\"]}`},
+		{"scalar", ` "x" `, all, `"x"`},
+		{"line comment", "[1, // one\n 2]", jsonc | jwcc | json5, `[1,2]`},
+		{"block comment", "/* c */ [1]", jsonc | jwcc | json5, `[1]`},
+		{"hash comment", "# c\n[1]", 0, ""},
+		{"trailing comma", `{"a": [1,],}`, jwcc | json5, `{"a":[1]}`},
+		{"leading comma", "[,1]", 0, ""},
+		{"missing comma", "[1 2]", 0, ""},
+		{"identifier key", "{a_1$: 1}", json5, `{"a_1$":1}`},
+		{"single quotes", `['a"b', '\'']`, json5, `["a\"b","'"]`},
+		{"backtick", "[`a`]", 0, ""},
+		{"hex", "[0x1F, -0xa, +0XA]", json5, `[31,-10,10]`},
+		{"leading plus", "[+1]", json5, `[1]`},
+		{"decimal points", "[.5, 5., 5.e1]", json5, `[0.5,5.0,5.0e1]`},
+		{"leading zero", "[01]", 0, ""},
+		{"x escape", `["a\x41"]`, json5, `["aA"]`},
+		{"nul escape", `["a\0b"]`, json5, `["a\u0000b"]`},
+		{"line continuation", "[\"a\\\nb\"]", json5, `["ab"]`},
+		{"control character", "[\"a\tb\"]", json5, `["a\tb"]`},
+		{"unquoted string", "[yes]", 0, ""},
+		{"numeric key", "{1: 2}", 0, ""},
+		{"empty", "  \n", 0, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, d := range jsonDialects {
+				got, err := FromJSONVariantWithOptions([]byte(tc.input), JSONVariantOptions{Dialect: d})
+				if tc.accept&(1<<d) == 0 {
+					if err == nil {
+						t.Errorf("%s: got %s, want an error", d, got)
+					}
+				} else if err != nil || string(got) != tc.want {
+					t.Errorf("%s: got %s, %v, want %s", d, got, err, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONDialectErrors(t *testing.T) {
+	tests := []struct {
+		dialect   JSONDialect
+		input     string
+		msg       string
+		line, col int
+	}{
+		{DialectJSON, "[1, // one\n 2]", "comment not allowed in JSON", 1, 5},
+		{DialectJWCC, "# c\n[1]", "# comment not allowed in JWCC", 1, 1},
+		{DialectJSONC, "[1] /* c", "unterminated comment", 1, 5},
+		{DialectJSONC, "{\"a\": [1,\n]}", "trailing comma not allowed in JSONC", 1, 9},
+		{DialectJSON5, "[,1]", "leading comma not allowed in JSON5", 1, 2},
+		{DialectJSON5, "{a: 1\nb: 2}", "missing comma not allowed in JSON5", 2, 1},
+		{DialectJWCC, "{a: 1}", "unquoted key not allowed in JWCC", 1, 2},
+		{DialectJSON5, "{a-b: 1}", "unquoted key a-b not allowed in JSON5", 1, 2},
+		{DialectJSON5, "{1: 2}", "unquoted key 1 not allowed in JSON5", 1, 2},
+		{DialectJSON, "['a']", "single-quoted string not allowed in JSON", 1, 2},
+		{DialectJSON5, "[`a`]", "backtick string not allowed in JSON5", 1, 2},
+		{DialectJWCC, "[1, 0x1F]", "hex literal not allowed in JWCC", 1, 5},
+		{DialectJSON, "[+1]", "leading + not allowed in JSON", 1, 2},
+		{DialectJSONC, "[.5]", "leading decimal point not allowed in JSONC", 1, 2},
+		{DialectJSON, "[5.]", "trailing decimal point not allowed in JSON", 1, 2},
+		{DialectJSON5, "[-01]", "leading zero not allowed in JSON5", 1, 2},
+		{DialectJSON5, "[1.5e]", "invalid number 1.5e", 1, 2},
+		{DialectJSON5, "[0x1G]", "invalid number 0x1G", 1, 2},
+		{DialectJSON, `["ab\x41"]`, `\x escape not allowed in JSON`, 1, 5},
+		{DialectJSON, `["ab\'"]`, `escape \' not allowed in JSON`, 1, 5},
+		{DialectJSON5, `["\1"]`, `invalid escape \1`, 1, 3},
+		{DialectJSON5, `["\u12"]`, `invalid \u escape`, 1, 3},
+		{DialectJSON5, "[\"a\",\n \"b\\x4\"]", `invalid \x escape`, 2, 4},
+		{DialectJWCC, "[\"a\\\nb\"]", "line continuation not allowed in JWCC", 1, 4},
+		{DialectJSON, "[\"a\tb\"]", "unescaped control character not allowed in JSON", 1, 4},
+		{DialectJSON5, "{a: 1,, b: 2}", "expected a key after ','", 1, 7},
+		{DialectJSONC, "[1,,2]", "expected a value after ','", 1, 4},
+		{DialectJSON5, "[yes]", "unquoted string not allowed in JSON5", 1, 2},
+		{DialectJSON5, "[NaN]", "NaN is not representable in JSON", 1, 2},
+		{DialectJSON, "1 2", "unknown token after top-level value: type 0: 2 @ 0:2", 1, 3},
+		{DialectJSONC, "// c\n", "expected a value", 2, 1},
+	}
+	for _, tc := range tests {
+		_, err := FromJSONVariantWithOptions([]byte(tc.input), JSONVariantOptions{Dialect: tc.dialect})
+		pe := requireParseError(t, err)
+		if pe.Message != tc.msg || pe.Line != tc.line || pe.Column != tc.col {
+			t.Errorf("%s %q: got %v, want line %d, column %d: %s", tc.dialect, tc.input, err, tc.line, tc.col, tc.msg)
+		}
+	}
+}
+
+// TestJSONDialectSuite checks the dialects against the json5-tests suite,
+// whose file extensions say which formats accept each file: .json files are
+// JSON, .json5 files are JSON5 but not JSON, and .js and .txt files are
+// neither.
+func TestJSONDialectSuite(t *testing.T) {
+	skip := []string{
+		// NaN and Infinity are not representable in JSON.
+		"nan.json5", "infinity.json5", "negative-infinity.json5", "positive-infinity.json5",
+		"readme-example.json5",
+		// Whitespace beyond ASCII and escapes in identifiers are not recognized.
+		"valid-whitespace.json5", "unicode-escaped-unquoted-key.json5",
+	}
+	err := filepath.WalkDir("samples/json5-tests", func(path string, dir fs.DirEntry, err error) error {
+		ext := filepath.Ext(path)
+		if err != nil || dir.IsDir() || !slices.Contains([]string{".json", ".json5", ".js", ".txt"}, ext) ||
+			slices.Contains(skip, filepath.Base(path)) {
+			return err
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, d := range []JSONDialect{DialectJSON, DialectJSON5} {
+			want := ext == ".json" || ext == ".json5" && d == DialectJSON5
+			opts := JSONVariantOptions{Dialect: d, DuplicateKeys: DuplicateKeyLast}
+			if _, err := FromJSONVariantWithOptions(src, opts); (err == nil) != want {
+				t.Errorf("%s %s: got error %v", d, path, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJSONDialectOptions(t *testing.T) {
+	// Recovery reports each feature outside the dialect.
+	_, err := FromJSONVariantWithOptions([]byte("{a: 'x', // c\n \"b\": [0x1, 2,],}"),
+		JSONVariantOptions{Dialect: DialectJSON, MaxErrors: 10})
+	if got := errorPositions(t, err); !slices.Equal(got, []string{"1:2", "1:5", "1:10", "2:8", "2:14", "2:16"}) {
+		t.Errorf("got errors at %v\n%v", got, err)
+	}
+
+	c := Converter{JSON: JSONVariantOptions{Dialect: DialectJWCC}}
+	if got, err := c.AppendJSONVariant(nil, []byte("[1, /* two */ 2,]")); err != nil || string(got) != "[1,2]" {
+		t.Errorf("Converter: got %s, %v", got, err)
+	}
+	_, err = c.AppendJSONVariant(nil, []byte("[0x1]"))
+	requireParseError(t, err)
+
+	var v []int
+	o := UnmarshalOptions{JSON: JSONVariantOptions{Dialect: DialectJSON}}
+	err = o.UnmarshalJSONVariant([]byte("[1,\n 2,]"), &v)
+	if pe := requireParseError(t, err); pe.Line != 2 || pe.Column != 3 {
+		t.Errorf("Unmarshal: got %v", err)
+	}
+
+	// A dialect outside the constants is an error, not strict JSON.
+	for _, dialect := range []JSONDialect{-1, DialectJSON5 + 1} {
+		want := fmt.Sprintf("unknown JSONDialect %d", dialect)
+		opts := JSONVariantOptions{Dialect: dialect}
+		if _, err := FromJSONVariantWithOptions([]byte("[1]"), opts); err == nil || err.Error() != want {
+			t.Errorf("FromJSONVariantWithOptions: got %v, want %s", err, want)
+		}
+		c := Converter{JSON: opts}
+		if got, err := c.AppendJSONVariant([]byte("x"), []byte("[1]")); err == nil || string(got) != "x" {
+			t.Errorf("Converter: got %s, %v", got, err)
+		}
+		if err := (UnmarshalOptions{JSON: opts}).UnmarshalJSONVariant([]byte("[1]"), &v); err == nil {
+			t.Error("Unmarshal: want an error")
+		}
+	}
+}
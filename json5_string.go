@@ -57,12 +57,22 @@ func appendRecodeString(dst []byte, src []byte) []byte {
 							i += 3
 						}
 					}
-				case '\n':
-					b = '\n'
-					i++
+				case '\n', '\r':
+					// a line continuation, which JSON5 removes
+					i += 2
+					if src[i-1] == '\r' && i < len(src) && src[i] == '\n' {
+						i++
+					}
+					start = i
+					continue
 				case '\'':
 					b = '\''
 					i++
+				case '0':
+					// JSON5's NUL; \0 followed by a digit is rejected
+					// by checkString
+					b = 0
+					i++
 				}
 			}
 
@@ -32,9 +32,10 @@ func (t token) String() string {
 }
 
 type tokenizer struct {
-	row  int
-	col  int
-	data []byte
+	row     int
+	col     int
+	data    []byte
+	dialect JSONDialect
 }
 
 func newTokenizer(b []byte) *tokenizer {
@@ -69,16 +70,25 @@ func (tx *tokenizer) Next() (token, error) {
 			continue
 		case singleQuote, doubleQuote, backQuote:
 			tx.data = tx.data[i:]
-			return tx.string()
+			t, err := tx.string()
+			if err == nil {
+				err = tx.checkString(t)
+			}
+			return t, err
 		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', '+', '-', '.':
 			tx.data = tx.data[i:]
-			return tx.number()
-		case slash:
-			tx.data = tx.data[i:]
-			return tx.comment()
-		case '#':
+			t, err := tx.number()
+			if err == nil {
+				err = tx.checkNumber(&t)
+			}
+			return t, err
+		case slash, '#':
 			tx.data = tx.data[i:]
-			return tx.comment()
+			t, err := tx.comment()
+			if err == nil && t.kind == 'c' {
+				err = tx.checkComment(t)
+			}
+			return t, err
 		default:
 			tx.data = tx.data[i:]
 			return tx.bareword()
@@ -89,12 +99,17 @@ func (tx *tokenizer) Next() (token, error) {
 
 func (tx *tokenizer) string() (token, error) {
 	qchar := tx.data[0]
+	row := tx.row
 
 	skip := false
+	escapedCR := false // a backslash and CR, which may start a CRLF line continuation
 	// lineCol tracks the column of the most recently processed byte on the
 	// current line, so we can update tx.col correctly after the string ends.
 	lineCol := tx.col // opening quote column
 	for i, b := range tx.data[1:] {
+		if b != newline {
+			escapedCR = false
+		}
 		switch b {
 		case qchar:
 			if skip {
@@ -108,14 +123,14 @@ func (tx *tokenizer) string() (token, error) {
 			t := token{
 				kind:  's',
 				value: tx.data[:i],
-				row:   tx.row,
+				row:   row,
 				col:   tx.col,
 			}
 			tx.data = tx.data[i:]
 			tx.col = lineCol + 2 // advance past closing quote
 			return t, nil
 		case backslash:
-			skip = true
+			skip = !skip
 			lineCol++
 		case newline:
 			if !skip && qchar == backQuote {
@@ -124,17 +139,18 @@ func (tx *tokenizer) string() (token, error) {
 				continue
 			}
 
-			if !skip {
+			if !skip && !escapedCR {
 				return token{}, &ParseError{Line: tx.row + 1, Column: tx.col + 1, Message: "unescaped newline in string"}
 			}
+			// a line continuation, which writeString removes
 			skip = false
+			escapedCR = false
 			tx.row += 1
 			lineCol = -1
-			// remap from \[newline] to \n
-			tx.data[i] = 'n'
 		default:
 			if skip {
 				skip = false
+				escapedCR = b == '\r'
 			}
 			lineCol++
 		}
@@ -164,7 +180,6 @@ func (tx *tokenizer) commentMulti() (token, error) {
 	row := tx.row
 	col := tx.col
 	tx.col += 2 // account for /*
-	var i int
 	for i, b := range tx.data[2:] {
 		switch b {
 		case newline:
@@ -193,15 +208,15 @@ func (tx *tokenizer) commentMulti() (token, error) {
 		}
 	}
 
-	// multi-line comment wasn't closed
+	// multi-line comment wasn't closed: it runs to the end of the input
 
 	t := token{
 		kind:  'c',
-		value: tx.data[:i],
+		value: tx.data,
 		row:   row,
 		col:   col,
 	}
-	tx.data = tx.data[i:]
+	tx.data = nil
 	return t, nil
 }
 func (tx *tokenizer) commentSingle() (token, error) {
@@ -222,24 +237,26 @@ func (tx *tokenizer) commentSingle() (token, error) {
 	return t, nil
 }
 
-func (tx *tokenizer) hexnumber() (token, error) {
+// hexnumber reads a hex literal whose digits start at tx.data[start], after
+// the 0x prefix and any sign.
+func (tx *tokenizer) hexnumber(start int) (token, error) {
 
 	kind := byte('2')
 
-	// [2:] is safe since checked already
-	for i, b := range tx.data[2:] {
+	// [start:] is safe since checked already
+	for i, b := range tx.data[start:] {
 		switch b {
 
 		case leftBrace, rightBrace, leftBracket, rightBracket, colon, comma, ' ', '\t', '\n', '\r':
 
 			t := token{
 				kind:  kind,
-				value: tx.data[:i+2],
+				value: tx.data[:i+start],
 				row:   tx.row,
 				col:   tx.col,
 			}
-			tx.col += i
-			tx.data = tx.data[i+2:]
+			tx.col += i + start
+			tx.data = tx.data[i+start:]
 			return t, nil
 		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', 'a', 'b', 'c', 'd', 'e', 'f', 'A', 'B', 'C', 'D', 'E', 'F':
 			// keep going
@@ -263,9 +280,13 @@ func (tx *tokenizer) hexnumber() (token, error) {
 }
 func (tx *tokenizer) number() (token, error) {
 
-	// check if starts with "0x"
-	if len(tx.data) > 2 && tx.data[0] == '0' && (tx.data[1] == 'x' || tx.data[1] == 'X') {
-		return tx.hexnumber()
+	// check if starts with "0x", after the sign JSON5 allows
+	hex := tx.data
+	if hex[0] == '+' || hex[0] == '-' {
+		hex = hex[1:]
+	}
+	if len(hex) > 2 && hex[0] == '0' && (hex[1] == 'x' || hex[1] == 'X') {
+		return tx.hexnumber(len(tx.data) - len(hex) + 2)
 	}
 	kind := byte('0') // integer
 	for i, b := range tx.data {
@@ -0,0 +1,125 @@
+package tojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// NonFinitePolicy selects what conversion writes for a NaN or infinite
+// number, which JSON cannot represent: NaN and Infinity in JSON5, nan and
+// inf in TOML, and .nan and .inf in YAML.
+type NonFinitePolicy int
+
+const (
+	// NonFiniteDefault is the zero value. JSON variants and TOML report a
+	// NaN or infinity as NonFiniteError does, and YAML writes .nan and .inf
+	// as strings, as NonFiniteText does, like any other plain scalar. A
+	// YAML .nan or .inf tagged !!float is reported as NonFiniteError does.
+	NonFiniteDefault NonFinitePolicy = iota
+
+	// NonFiniteError reports a NaN or infinity as a *ParseError at its
+	// position.
+	NonFiniteError
+
+	// NonFiniteNull writes null, as JavaScript's JSON.stringify does.
+	NonFiniteNull
+
+	// NonFiniteString writes the string "NaN", "Infinity", or "-Infinity",
+	// the spellings that ECMAScript and many JSON libraries use.
+	NonFiniteString
+
+	// NonFiniteSentinel writes the JSON text in the options'
+	// NonFiniteSentinel field for every NaN and infinity.
+	NonFiniteSentinel
+
+	// NonFiniteText writes a NaN or infinity as a string holding its text
+	// in the input, such as ".inf" or "-inf".
+	NonFiniteText
+)
+
+// nonFinite is the NonFinite and NonFiniteSentinel pair that every options
+// type carries.
+type nonFinite struct {
+	policy   NonFinitePolicy
+	sentinel string
+}
+
+func (o YAMLOptions) nonFinite() nonFinite {
+	if o.NonFinite == NonFiniteDefault {
+		return nonFinite{NonFiniteText, o.NonFiniteSentinel}
+	}
+	return nonFinite{o.NonFinite, o.NonFiniteSentinel}
+}
+
+func (o TOMLOptions) nonFinite() nonFinite        { return nonFinite{o.NonFinite, o.NonFiniteSentinel} }
+func (o JSONVariantOptions) nonFinite() nonFinite { return nonFinite{o.NonFinite, o.NonFiniteSentinel} }
+
+// append appends to dst the JSON that n writes for the number text, which is
+// a NaN if nan is set and otherwise an infinity, negative if neg is set.
+func (n nonFinite) append(dst, text []byte, nan, neg bool) ([]byte, error) {
+	switch n.policy {
+	case NonFiniteNull:
+		return append(dst, "null"...), nil
+	case NonFiniteString:
+		switch {
+		case nan:
+			return append(dst, `"NaN"`...), nil
+		case neg:
+			return append(dst, `"-Infinity"`...), nil
+		}
+		return append(dst, `"Infinity"`...), nil
+	case NonFiniteSentinel:
+		buf := bytes.NewBuffer(dst)
+		if err := json.Compact(buf, []byte(n.sentinel)); err != nil {
+			return dst, fmt.Errorf("NonFiniteSentinel %q is not valid JSON", n.sentinel)
+		}
+		return buf.Bytes(), nil
+	case NonFiniteText:
+		buf := bytes.NewBuffer(dst)
+		writeJSONString(text, buf)
+		return buf.Bytes(), nil
+	}
+	return dst, fmt.Errorf("%s is not representable in JSON", text)
+}
+
+// write writes the JSON for text to out, as append does.
+func (n nonFinite) write(out *bytes.Buffer, text []byte, nan, neg bool) error {
+	b, err := n.append(out.AvailableBuffer(), text, nan, neg)
+	out.Write(b)
+	return err
+}
+
+// yamlNonFinite reports whether s is one of the YAML 1.2 core schema forms
+// of NaN or infinity, such as .nan, .Inf, or -.INF, and which one.
+func yamlNonFinite(s []byte) (nan, neg, ok bool) {
+	switch string(s) {
+	case ".nan", ".NaN", ".NAN":
+		return true, false, true
+	}
+	if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	switch string(s) {
+	case ".inf", ".Inf", ".INF":
+		return false, neg, true
+	}
+	return false, false, false
+}
+
+// tomlNonFinite reports whether s is TOML's nan or inf, with an optional
+// sign, and which one. TOML spells them in lowercase only.
+func tomlNonFinite(s []byte) (nan, neg, ok bool) {
+	t := s
+	if len(t) > 0 && (t[0] == '+' || t[0] == '-') {
+		t = t[1:]
+	}
+	switch string(t) {
+	case "nan":
+		return true, false, true
+	case "inf":
+		return false, s[0] == '-', true
+	}
+	return false, false, false
+}
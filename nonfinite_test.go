@@ -0,0 +1,155 @@
+package tojson
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNonFinite(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    string
+		input     string
+		null, str string // output under NonFiniteNull and NonFiniteString
+		sentinel  string // output under NonFiniteSentinel with sentinel {"nf": true}
+		text      string // output under NonFiniteText
+		line, col int    // of the error under NonFiniteError
+	}{
+		{"json5 value", "json", "NaN",
+			`null`, `"NaN"`, `{"nf":true}`, `"NaN"`, 1, 1},
+		{"json5 object", "json", "{a: Infinity, b: -Infinity}",
+			`{"a":null,"b":null}`, `{"a":"Infinity","b":"-Infinity"}`, `{"a":{"nf":true},"b":{"nf":true}}`, `{"a":"Infinity","b":"-Infinity"}`, 1, 5},
+		{"json5 array", "json", "[1, +Infinity, -NaN]",
+			`[1,null,null]`, `[1,"Infinity","NaN"]`, `[1,{"nf":true},{"nf":true}]`, `[1,"+Infinity","-NaN"]`, 1, 5},
+		{"toml line", "toml", "a = inf\nb = [-inf, +nan]\n",
+			`{"a":null,"b":[null,null]}`, `{"a":"Infinity","b":["-Infinity","NaN"]}`, `{"a":{"nf":true},"b":[{"nf":true},{"nf":true}]}`, `{"a":"inf","b":["-inf","+nan"]}`, 1, 5},
+		{"toml inline table", "toml", "t = {x = nan}\n",
+			`{"t":{"x":null}}`, `{"t":{"x":"NaN"}}`, `{"t":{"x":{"nf":true}}}`, `{"t":{"x":"nan"}}`, 1, 5},
+		{"toml tree", "toml", "[a]\nx = 1\n[b]\n[a.c]\ny = [-inf]\n",
+			`{"a":{"x":1,"c":{"y":[null]}},"b":{}}`, `{"a":{"x":1,"c":{"y":["-Infinity"]}},"b":{}}`, `{"a":{"x":1,"c":{"y":[{"nf":true}]}},"b":{}}`, `{"a":{"x":1,"c":{"y":["-inf"]}},"b":{}}`, 5, 5},
+		{"yaml block", "yaml", "a: .inf\nb: -.Inf\nc: .NAN\n",
+			`{"a":null,"b":null,"c":null}`, `{"a":"Infinity","b":"-Infinity","c":"NaN"}`, `{"a":{"nf":true},"b":{"nf":true},"c":{"nf":true}}`, `{"a":".inf","b":"-.Inf","c":".NAN"}`, 1, 4},
+		{"yaml flow", "yaml", "- [1, +.inf]\n",
+			`[[1,null]]`, `[[1,"Infinity"]]`, `[[1,{"nf":true}]]`, `[[1,"+.inf"]]`, 1, 3},
+	}
+	nonFinite := func(policy NonFinitePolicy) formatOptions {
+		sentinel := `{"nf": true}`
+		return formatOptions{
+			YAMLOptions{NonFinite: policy, NonFiniteSentinel: sentinel},
+			TOMLOptions{NonFinite: policy, NonFiniteSentinel: sentinel},
+			JSONVariantOptions{NonFinite: policy, NonFiniteSentinel: sentinel},
+		}
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := nonFinite(NonFiniteError).convert(tc.format, tc.input)
+			pe := requireParseError(t, err)
+			if pe.Line != tc.line || pe.Column != tc.col {
+				t.Errorf("got line %d, column %d, want line %d, column %d", pe.Line, pe.Column, tc.line, tc.col)
+			}
+			if !strings.Contains(pe.Message, "not representable in JSON") {
+				t.Errorf("got message %q", pe.Message)
+			}
+			// The default is an error, except in YAML, where it is the text.
+			got, err := nonFinite(NonFiniteDefault).convert(tc.format, tc.input)
+			if tc.format == "yaml" {
+				if err != nil || string(got) != tc.text {
+					t.Errorf("default: got %s, %v, want %s", got, err, tc.text)
+				}
+			} else if err == nil {
+				t.Errorf("default: got %s, want an error", got)
+			}
+			for _, want := range []struct {
+				policy NonFinitePolicy
+				out    string
+			}{{NonFiniteNull, tc.null}, {NonFiniteString, tc.str}, {NonFiniteSentinel, tc.sentinel}, {NonFiniteText, tc.text}} {
+				got, err := nonFinite(want.policy).convert(tc.format, tc.input)
+				if err != nil {
+					t.Fatalf("policy %d: unexpected error: %v", want.policy, err)
+				}
+				if string(got) != want.out {
+					t.Errorf("policy %d: got %s, want %s", want.policy, got, want.out)
+				}
+			}
+		})
+	}
+}
+
+func TestNonFiniteErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func() ([]byte, error)
+		msg  string
+	}{
+		{"invalid sentinel", func() ([]byte, error) {
+			return FromTOMLWithOptions([]byte("a = nan"), TOMLOptions{NonFinite: NonFiniteSentinel, NonFiniteSentinel: "{"})
+		}, `NonFiniteSentinel "{" is not valid JSON`},
+		{"empty sentinel", func() ([]byte, error) {
+			return FromYAMLWithOptions([]byte("a: .nan"), YAMLOptions{NonFinite: NonFiniteSentinel})
+		}, `NonFiniteSentinel "" is not valid JSON`},
+		{"toml uppercase", func() ([]byte, error) {
+			return FromTOMLWithOptions([]byte("a = Inf"), TOMLOptions{NonFinite: NonFiniteNull})
+		}, "invalid number: Inf"},
+		{"toml tagged uppercase", func() ([]byte, error) {
+			return FromTOMLWithOptions([]byte("a = -NaN"), TOMLOptions{Tagged: true})
+		}, "invalid number: -NaN"},
+		{"strict dialect", func() ([]byte, error) {
+			return FromJSONVariantWithOptions([]byte("[Infinity]"), JSONVariantOptions{Dialect: DialectJWCC, NonFinite: NonFiniteNull})
+		}, "Infinity not allowed in JWCC"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.fn()
+			pe := requireParseError(t, err)
+			if pe.Message != tc.msg {
+				t.Errorf("got message %q, want %q", pe.Message, tc.msg)
+			}
+		})
+	}
+}
+
+func TestNonFiniteOptions(t *testing.T) {
+	var v struct {
+		A *float64 `json:"a"`
+	}
+	o := UnmarshalOptions{TOML: TOMLOptions{NonFinite: NonFiniteNull}}
+	if err := o.UnmarshalTOML([]byte("a = nan"), &v); err != nil || v.A != nil {
+		t.Errorf("UnmarshalTOML: got %v, %v", v.A, err)
+	}
+
+	c := Converter{JSON: JSONVariantOptions{NonFinite: NonFiniteString}}
+	got, err := c.AppendJSONVariant([]byte("x"), []byte("[NaN]"))
+	if err != nil || string(got) != `x["NaN"]` {
+		t.Errorf("Converter: got %s, %v", got, err)
+	}
+	c.TOML.NonFinite = NonFiniteNull
+	got, err = c.AppendTOML(nil, []byte("a = -inf"))
+	if err != nil || string(got) != `{"a":null}` {
+		t.Errorf("Converter: got %s, %v", got, err)
+	}
+	got, err = c.AppendYAML(nil, []byte("a: .inf"))
+	if err != nil || string(got) != `{"a":".inf"}` {
+		t.Errorf("Converter: got %s, %v for YAML, whose policy is unset", got, err)
+	}
+
+	// A .inf written as a string stays a string.
+	got, err = FromYAML([]byte("a: '.inf'"))
+	if err != nil || string(got) != `{"a":".inf"}` {
+		t.Errorf("quoted: got %s, %v", got, err)
+	}
+
+	// A !!float .inf is a number, so the default reports it as other
+	// formats do, and the other policies apply as to an untagged .inf.
+	_, err = FromYAML([]byte("a: !!float .inf"))
+	requireParseError(t, err)
+	for policy, want := range map[NonFinitePolicy]string{
+		NonFiniteNull:   `{"a":null,"b":[null]}`,
+		NonFiniteString: `{"a":"Infinity","b":["NaN"]}`,
+		NonFiniteText:   `{"a":".inf","b":[".nan"]}`,
+	} {
+		got, err := FromYAMLWithOptions([]byte("a: !!float .inf\nb: [!!float .nan]"), YAMLOptions{NonFinite: policy})
+		if err != nil || string(got) != want {
+			t.Errorf("policy %d: got %s, %v, want %s", policy, got, err, want)
+		}
+	}
+}
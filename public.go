@@ -1,27 +1,345 @@
 package tojson
 
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
 // FromJSONVariant converts JSON and common JSON-derived variants to standard JSON.
 // It handles JSON5/HuJSON/JWCC/JSONC/HanSON features such as trailing/leading
 // commas, line and block comments, unquoted keys, single-quoted and backtick
-// strings, and hex literals.
+// strings, and hex literals. A key repeated within an object is an error; see
+// JSONVariantOptions.DuplicateKeys. To accept only one variant, see
+// JSONVariantOptions.Dialect.
 func FromJSONVariant(src []byte) ([]byte, error) {
+	return FromJSONVariantWithOptions(src, JSONVariantOptions{})
+}
+
+// JSONVariantOptions controls how FromJSONVariantWithOptions reads its input
+// and writes its output. The zero value selects the defaults, which match
+// FromJSONVariant.
+type JSONVariantOptions struct {
+	// Dialect restricts the input to one JSON variant, reporting any
+	// feature outside it as an error. The default, DialectPermissive,
+	// accepts all of them. A value other than the Dialect constants is an
+	// error.
+	Dialect JSONDialect
+
+	// DuplicateKeys selects what happens when an object repeats a key, as
+	// YAMLOptions.DuplicateKeys does.
+	DuplicateKeys DuplicateKeyPolicy
+
+	// NonFinite selects what NaN and Infinity convert to, since JSON has no
+	// NaN or infinity. The zero value, NonFiniteDefault, reports them as
+	// errors. Dialects other than DialectJSON5 and DialectPermissive reject
+	// them whatever the policy.
+	NonFinite NonFinitePolicy
+
+	// NonFiniteSentinel is as for YAMLOptions.NonFiniteSentinel.
+	NonFiniteSentinel string
+
+	// MaxErrors, when greater than one, continues past errors as
+	// YAMLOptions.MaxErrors does. After an error, parsing resumes at the
+	// next ',' or closing bracket of the enclosing object or array. A string
+	// left unterminated ends the conversion.
+	MaxErrors int
+
+	// UnsafeIntegers selects what an integer beyond 2^53-1 in magnitude
+	// converts to, as YAMLOptions.UnsafeIntegers does.
+	UnsafeIntegers UnsafeIntegerPolicy
+
+	// Indent, when non-empty, formats the output as YAMLOptions.Indent does.
+	Indent string
+
+	// Canonical writes the output as YAMLOptions.Canonical does.
+	Canonical bool
+}
+
+// FromJSONVariantWithOptions is like FromJSONVariant but reads and writes the
+// JSON according to opts.
+func FromJSONVariantWithOptions(src []byte, opts JSONVariantOptions) ([]byte, error) {
 	d := &decoder{}
 	d.out = &d.buf
 	d.stack = d.stackbuf[:0]
+	d.configure(opts)
 	d.buf.Grow(len(src))
-	err := d.Translate(src)
-	return d.buf.Bytes(), err
+	if err := d.Translate(src); err != nil {
+		return d.buf.Bytes(), err
+	}
+	return formatJSON(src, d.buf.Bytes(), opts.output())
 }
 
 // FromYAML converts a YAML subset to standard JSON.
 // The output can be passed directly to encoding/json.Unmarshal using only json struct tags.
-// Anchors/aliases, tags, and complex keys are not supported.
+// Anchors, aliases, and merge keys are expanded, up to
+// YAMLOptions.MaxAliasBytes of copied JSON. Core schema tags such as !!str
+// set the type of a value, and local tags such as !Ref are converted by
+// YAMLOptions.TagHandler. Complex keys are not supported. A second document
+// with content is an error; FromYAMLStream converts each document of a
+// stream.
 func FromYAML(src []byte) ([]byte, error) {
-	return yamlConvert(src)
+	return FromYAMLWithOptions(src, YAMLOptions{})
+}
+
+// FromYAMLWithOptions is like FromYAML but interprets the input according to
+// opts. FromYAML is equivalent to FromYAMLWithOptions(src, YAMLOptions{}).
+func FromYAMLWithOptions(src []byte, opts YAMLOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(src) + 64)
+	if err := yamlConvert(&buf, src, opts); err != nil {
+		return nil, err
+	}
+	return formatJSON(src, buf.Bytes(), opts.output())
+}
+
+// TOMLOptions controls how FromTOMLWithOptions reads its input and writes
+// its output. The zero value selects the defaults, which match FromTOML.
+type TOMLOptions struct {
+	// Version selects the TOML specification the input follows. The
+	// default, TOML10, is TOML 1.0.0.
+	Version TOMLVersion
+
+	// NonFinite selects what nan and inf convert to, since JSON has no NaN
+	// or infinity. The zero value, NonFiniteDefault, reports them as errors.
+	NonFinite NonFinitePolicy
+
+	// NonFiniteSentinel is as for YAMLOptions.NonFiniteSentinel.
+	NonFiniteSentinel string
+
+	// DateTimes selects how dates and times are written. The default,
+	// TOMLDateTimeRaw, writes each as a string of its text in the input.
+	// Every date and time is checked against TOML's grammar and calendar
+	// whatever the format, so 2026-13-45 is an error.
+	DateTimes TOMLDateTimeFormat
+
+	// Tagged writes the tagged JSON of the toml-test suite, in which every
+	// string, number, boolean, and date-time is an object giving its TOML
+	// type and its text, such as {"type":"integer","value":"42"}, while
+	// tables and arrays stay JSON objects and arrays. It keeps the
+	// distinctions that plain JSON loses, between integers and floats and
+	// among the four kinds of date-time. The types are "string",
+	// "integer", "float", "bool", "datetime", "datetime-local",
+	// "date-local", and "time-local". Tagged overrides NonFinite,
+	// DateTimes, and UnsafeIntegers.
+	Tagged bool
+
+	// Strict rejects every document that the TOML specification calls
+	// invalid, as the toml-test suite's invalid cases do, rather than
+	// converting what can be read of it. It adds checks for control
+	// characters and invalid UTF-8, escapes outside TOML's list such as \x41
+	// in TOML 1.0, text after a value on its line, a dotted key that extends
+	// an inline table, a [table] header naming an array of tables, and an
+	// integer outside int64. It may become the default in a later release.
+	Strict bool
+
+	// MaxErrors, when greater than one, continues past errors as
+	// YAMLOptions.MaxErrors does. After an error, parsing resumes at the
+	// next line that starts a [header] or a key = value pair.
+	MaxErrors int
+
+	// UnsafeIntegers selects what an integer beyond 2^53-1 in magnitude
+	// converts to, as YAMLOptions.UnsafeIntegers does.
+	UnsafeIntegers UnsafeIntegerPolicy
+
+	// Indent, when non-empty, formats the output as YAMLOptions.Indent does.
+	Indent string
+
+	// Canonical writes the output as YAMLOptions.Canonical does.
+	Canonical bool
 }
 
 // FromTOML converts TOML to standard JSON.
 // The output can be passed directly to encoding/json.Unmarshal using only json struct tags.
 func FromTOML(src []byte) ([]byte, error) {
-	return tomlConvert(src)
+	return FromTOMLWithOptions(src, TOMLOptions{})
+}
+
+// FromTOMLWithOptions is like FromTOML but converts according to opts.
+func FromTOMLWithOptions(src []byte, opts TOMLOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(src))
+	p := &tomlLineParser{opts: opts}
+	if err := tomlConvert(p, &buf, src); err != nil {
+		return nil, err
+	}
+	return formatJSON(src, buf.Bytes(), opts.output())
+}
+
+// outputFormat is the output layout selected by the Indent and Canonical
+// fields that every options type shares.
+type outputFormat struct {
+	indent    string
+	canonical bool
+}
+
+func (o YAMLOptions) output() outputFormat        { return outputFormat{o.Indent, o.Canonical} }
+func (o TOMLOptions) output() outputFormat        { return outputFormat{o.Indent, o.Canonical} }
+func (o JSONVariantOptions) output() outputFormat { return outputFormat{o.Indent, o.Canonical} }
+
+// formatJSON returns the compact JSON in data, converted from src, laid out
+// as f, or data itself for the default compact layout. Working on the
+// converted bytes, rather than decoding them, keeps key order and the text of
+// numbers exactly unless f is canonical. Empty data, the output for a JSON
+// variant input with no value, is returned as it is.
+func formatJSON(src, data []byte, f outputFormat) ([]byte, error) {
+	switch {
+	case len(data) == 0:
+		return data, nil
+	case f.canonical:
+		if !json.Valid(data) {
+			return nil, invalidOutputError(src, data)
+		}
+		out, err := appendCanonical(make([]byte, 0, len(data)), data)
+		if err != nil {
+			return nil, canonicalError(src, err)
+		}
+		return out, nil
+	case f.indent != "":
+		var buf bytes.Buffer
+		buf.Grow(len(data) * 2)
+		if err := json.Indent(&buf, data, "", f.indent); err != nil {
+			return nil, invalidOutputError(src, data)
+		}
+		return buf.Bytes(), nil
+	}
+	return data, nil
+}
+
+// formatTail lays out the JSON that out holds from offset start, converted
+// from src, as f, using scratch for a copy of the compact form.
+func formatTail(src []byte, out *bytes.Buffer, start int, f outputFormat, scratch *[]byte) error {
+	if !f.canonical && f.indent == "" || out.Len() == start {
+		return nil
+	}
+	*scratch = append((*scratch)[:0], out.Bytes()[start:]...)
+	out.Truncate(start)
+	if f.canonical {
+		if !json.Valid(*scratch) {
+			return invalidOutputError(src, *scratch)
+		}
+		b, err := appendCanonical(out.AvailableBuffer(), *scratch)
+		if err != nil {
+			return canonicalError(src, err)
+		}
+		out.Write(b)
+		return nil
+	}
+	if err := json.Indent(out, *scratch, "", f.indent); err != nil {
+		out.Truncate(start)
+		return invalidOutputError(src, *scratch)
+	}
+	return nil
+}
+
+// invalidOutputError reports that data, the JSON converted from src, is not
+// valid JSON, as the permissive JSON variant parser can leave it: tru, 1_000
+// and "\u00zz" pass through as they are. The *ParseError is at the first
+// place in src where the offending token appears, or at the start of src.
+func invalidOutputError(src, data []byte) error {
+	var raw json.RawMessage
+	err := json.Unmarshal(data, &raw)
+	off := 0
+	var se *json.SyntaxError
+	if errors.As(err, &se) {
+		if tok := jsonTokenAt(data, int(se.Offset)-1); len(tok) > 0 {
+			off = max(bytes.Index(src, tok), 0)
+		}
+	}
+	pe := atOffset(src, off, fmt.Errorf("invalid JSON: %v", err)).(*ParseError)
+	pe.Err = err
+	return pe
+}
+
+// jsonTokenAt returns the run of bytes around data[i], or just before it if
+// data[i] is a delimiter, up to the nearest JSON delimiters.
+func jsonTokenAt(data []byte, i int) []byte {
+	const delims = ",:[]{} \t\r\n"
+	i = min(max(i, 0), len(data))
+	start, end := i, i
+	for start > 0 && strings.IndexByte(delims, data[start-1]) < 0 {
+		start--
+	}
+	for end < len(data) && strings.IndexByte(delims, data[end]) < 0 {
+		end++
+	}
+	return data[start:end]
+}
+
+// AppendJSONVariant is like FromJSONVariant but appends the JSON to dst and
+// returns the extended buffer. On error dst is returned unextended.
+func AppendJSONVariant(dst, src []byte) ([]byte, error) {
+	d := &decoder{}
+	d.reset(bytes.NewBuffer(dst))
+	if err := d.Translate(src); err != nil {
+		return dst, err
+	}
+	return d.out.Bytes(), nil
+}
+
+// AppendYAML is like FromYAML but appends the JSON to dst and returns the
+// extended buffer. On error dst is returned unextended.
+func AppendYAML(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	if err := yamlConvert(buf, src, YAMLOptions{}); err != nil {
+		return dst, err
+	}
+	return buf.Bytes(), nil
+}
+
+// AppendTOML is like FromTOML but appends the JSON to dst and returns the
+// extended buffer. On error dst is returned unextended.
+func AppendTOML(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	if err := tomlConvert(new(tomlLineParser), buf, src); err != nil {
+		return dst, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteJSONVariant is like FromJSONVariant but writes the JSON to w.
+// Nothing is written if the input cannot be parsed.
+func WriteJSONVariant(w io.Writer, src []byte) error {
+	return writeWith(w, src, AppendJSONVariant)
+}
+
+// WriteYAML is like FromYAML but writes the JSON to w.
+// Nothing is written if the input cannot be parsed.
+func WriteYAML(w io.Writer, src []byte) error {
+	return writeWith(w, src, AppendYAML)
+}
+
+// WriteTOML is like FromTOML but writes the JSON to w.
+// Nothing is written if the input cannot be parsed.
+func WriteTOML(w io.Writer, src []byte) error {
+	return writeWith(w, src, AppendTOML)
+}
+
+// writePool holds scratch output buffers shared by the Write* functions so
+// steady-state calls do not allocate.
+var writePool = sync.Pool{
+	New: func() any { return new([]byte) },
+}
+
+// writePoolMaxCap bounds the buffers returned to writePool so one unusually
+// large document does not pin its memory for the life of the process.
+const writePoolMaxCap = 64 << 10
+
+// writeWith converts src with appendFn into a pooled buffer and writes the
+// result to w in a single call.
+func writeWith(w io.Writer, src []byte, appendFn func(dst, src []byte) ([]byte, error)) error {
+	bp := writePool.Get().(*[]byte)
+	out, err := appendFn((*bp)[:0], src)
+	if err == nil {
+		_, err = w.Write(out)
+	}
+	if cap(out) <= writePoolMaxCap {
+		*bp = out
+		writePool.Put(bp)
+	}
+	return err
 }
@@ -0,0 +1,168 @@
+package tojson
+
+import (
+	"bytes"
+	"testing"
+)
+
+var appendFuncs = []struct {
+	name string
+	fn   func(dst, src []byte) ([]byte, error)
+	src  string
+	bad  string
+	want string
+}{
+	{"json", AppendJSONVariant, "{a: 1, b: [2,],}", "{a: [1, }", `{"a":1,"b":[2]}`},
+	{"yaml", AppendYAML, "a: 1\nb: [2]\n", "a: \"bad", `{"a":1,"b":[2]}`},
+	{"toml", AppendTOML, "a = 1\nb = [2]\n", "a = \"bad", `{"a":1,"b":[2]}`},
+}
+
+func TestAppend(t *testing.T) {
+	for _, tc := range appendFuncs {
+		t.Run(tc.name, func(t *testing.T) {
+			dst := make([]byte, 0, 64)
+			dst = append(dst, "prefix:"...)
+			got, err := tc.fn(dst, []byte(tc.src))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := "prefix:" + tc.want; string(got) != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+			if &got[0] != &dst[0] {
+				t.Error("output did not reuse dst's backing array")
+			}
+		})
+	}
+}
+
+func TestAppendNilDst(t *testing.T) {
+	for _, tc := range appendFuncs {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.fn(nil, []byte(tc.src))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAppendError(t *testing.T) {
+	for _, tc := range appendFuncs {
+		t.Run(tc.name, func(t *testing.T) {
+			dst := []byte("prefix:")
+			got, err := tc.fn(dst, []byte(tc.bad))
+			requireParseError(t, err)
+			if string(got) != "prefix:" {
+				t.Errorf("got %q, want dst unchanged", got)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	writers := []struct {
+		name string
+		fn   func(w *bytes.Buffer, src []byte) error
+		src  string
+		bad  string
+		want string
+	}{
+		{"json", func(w *bytes.Buffer, src []byte) error { return WriteJSONVariant(w, src) }, "[1, 2,]", "[1,", `[1,2]`},
+		{"yaml", func(w *bytes.Buffer, src []byte) error { return WriteYAML(w, src) }, "- 1\n- 2\n", "- \"bad", `[1,2]`},
+		{"toml", func(w *bytes.Buffer, src []byte) error { return WriteTOML(w, src) }, "a = [1, 2]", "a = [1,", `{"a":[1,2]}`},
+	}
+	for _, tc := range writers {
+		t.Run(tc.name, func(t *testing.T) {
+			var w bytes.Buffer
+			// Run twice so the second call reuses a pooled buffer.
+			for range 2 {
+				w.Reset()
+				if err := tc.fn(&w, []byte(tc.src)); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if w.String() != tc.want {
+					t.Errorf("got %q, want %q", w.String(), tc.want)
+				}
+			}
+			w.Reset()
+			if err := tc.fn(&w, []byte(tc.bad)); err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if w.Len() != 0 {
+				t.Errorf("wrote %q on error, want nothing", w.String())
+			}
+		})
+	}
+}
+
+func TestIndent(t *testing.T) {
+	want := "{\n\t\"z\": [\n\t\t1.50,\n\t\t{}\n\t],\n\t\"a\": 18446744073709551615\n}"
+	tests := []struct {
+		name string
+		fn   func(src []byte) ([]byte, error)
+		src  string
+	}{
+		{"json", func(src []byte) ([]byte, error) {
+			return FromJSONVariantWithOptions(src, JSONVariantOptions{Indent: "\t"})
+		}, "{z: [1.50, {}], a: 18446744073709551615}"},
+		{"yaml", func(src []byte) ([]byte, error) {
+			return FromYAMLWithOptions(src, YAMLOptions{Indent: "\t"})
+		}, "z:\n  - 1.50\n  - {}\na: 18446744073709551615\n"},
+		{"toml", func(src []byte) ([]byte, error) {
+			return FromTOMLWithOptions(src, TOMLOptions{Indent: "\t"})
+		}, "z = [1.50, {}]\na = 18446744073709551615\n"},
+		{"front matter", func(src []byte) ([]byte, error) {
+			meta, _, err := FromFrontMatterWithOptions(src, FrontMatterOptions{TOML: TOMLOptions{Indent: "\t"}})
+			return meta, err
+		}, "+++\nz = [1.50, {}]\na = 18446744073709551615\n+++\nbody"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.fn([]byte(tc.src))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+
+	// The zero options give the same compact output as the plain functions.
+	got, err := FromTOMLWithOptions([]byte("a = 1"), TOMLOptions{})
+	if err != nil || string(got) != `{"a":1}` {
+		t.Errorf("got %s, %v", got, err)
+	}
+	_, err = FromJSONVariantWithOptions([]byte("{a: "), JSONVariantOptions{Indent: "  "})
+	requireParseError(t, err)
+
+	// Output the permissive parser lets through but json.Indent rejects is
+	// still a ParseError, at the offending token.
+	_, err = FromJSONVariantWithOptions([]byte("{a: 1,\n b: [tru]}"), JSONVariantOptions{Indent: "  "})
+	if pe := requireParseError(t, err); pe.Line != 2 || pe.Column != 6 {
+		t.Errorf("got %d:%d, want 2:6", pe.Line, pe.Column)
+	}
+	var ci Converter
+	ci.JSON.Indent = "  "
+	if got, err := ci.AppendJSONVariant([]byte("x"), []byte("[1_000]")); string(got) != "x" {
+		t.Errorf("Converter: got %q, want dst unchanged", got)
+	} else {
+		requireParseError(t, err)
+	}
+
+	// A JSON variant input with no value converts to no output either way.
+	var c Converter
+	c.JSON.Indent = "  "
+	for _, src := range []string{"", "  \n", "// comment"} {
+		if got, err := FromJSONVariantWithOptions([]byte(src), JSONVariantOptions{Indent: "  "}); err != nil || len(got) != 0 {
+			t.Errorf("%q: got %q, %v", src, got, err)
+		}
+		if got, err := c.AppendJSONVariant([]byte("x"), []byte(src)); err != nil || string(got) != "x" {
+			t.Errorf("Converter %q: got %q, %v", src, got, err)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package tojson
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SourceMap relates each value in a converted JSON document to the span of
+// input it came from, for tools such as editors that need to point at the
+// source of a problem found in the JSON.
+type SourceMap struct {
+	// Spans holds one entry per JSON value, in document order. The root
+	// value comes first, with the empty pointer.
+	Spans []SourceSpan
+}
+
+// SourceSpan is the input span of one JSON value.
+//
+// For a scalar the span covers its source text, including quotes. For an
+// object or array it runs from the opening bracket, header, or first key to
+// the end of its last member. A value that has no text of its own, such as
+// the null for a YAML key with no value, is given the span of its key.
+type SourceSpan struct {
+	Pointer    string   // RFC 6901 JSON Pointer to the value
+	Start, End Position // End is just past the last byte of the span
+}
+
+// Position is a location in the input. Line and Column are 1-based, with
+// Column counted in bytes like ParseError; Offset is the 0-based byte offset.
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+// Lookup returns the span of the value at the JSON Pointer pointer.
+func (m *SourceMap) Lookup(pointer string) (SourceSpan, bool) {
+	for _, s := range m.Spans {
+		if s.Pointer == pointer {
+			return s, true
+		}
+	}
+	return SourceSpan{}, false
+}
+
+// FromYAMLWithSourceMap is like FromYAML but also returns a source map of the
+// result.
+func FromYAMLWithSourceMap(src []byte) ([]byte, *SourceMap, error) {
+	return fromWithSourceMap("yaml", src)
+}
+
+// FromTOMLWithSourceMap is like FromTOML but also returns a source map of the
+// result.
+func FromTOMLWithSourceMap(src []byte) ([]byte, *SourceMap, error) {
+	return fromWithSourceMap("toml", src)
+}
+
+// FromJSONVariantWithSourceMap is like FromJSONVariant but also returns a
+// source map of the result.
+func FromJSONVariantWithSourceMap(src []byte) ([]byte, *SourceMap, error) {
+	return fromWithSourceMap("json", src)
+}
+
+// FromFrontMatterWithSourceMap is like FromFrontMatter but also returns a
+// source map of meta. Positions are relative to the whole of src. The map is
+// nil when meta is.
+func FromFrontMatterWithSourceMap(src []byte) (meta, body []byte, sm *SourceMap, err error) {
+	blk, found, err := splitFrontMatter(src, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !found {
+		return nil, src, nil, nil
+	}
+	if len(blk.src) == 0 {
+		meta = []byte("{}")
+		pos := Position{Line: 1, Column: 1}
+		return meta, blk.body, &SourceMap{Spans: []SourceSpan{{Start: pos, End: pos}}}, nil
+	}
+
+	m := &srcMap{doc: src, src: blk.src, base: blk.base}
+	var buf bytes.Buffer
+	buf.Grow(len(blk.src))
+	if err := convertMapped(blk.format, &buf, m, UnmarshalOptions{}); err != nil {
+		return nil, nil, nil, shiftParseError(err, m.lineShift())
+	}
+	return buf.Bytes(), blk.body, m.sourceMap(buf.Bytes()), nil
+}
+
+func fromWithSourceMap(format string, src []byte) ([]byte, *SourceMap, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(src))
+	m := newSrcMap(src, &buf)
+	if err := convertMapped(format, &buf, m, UnmarshalOptions{}); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), m.sourceMap(buf.Bytes()), nil
+}
+
+// sourceMap builds the public map for data, the JSON written while m was
+// recording. Empty data, converted from an input with nothing but space and
+// comments, has no values and so no spans.
+func (m *srcMap) sourceMap(data []byte) *SourceMap {
+	if len(data) == 0 {
+		return &SourceMap{}
+	}
+	w := smWalker{m: m, data: data}
+	w.value(nil, srcMark{start: -1})
+
+	lines := lineStarts(m.doc)
+	sm := &SourceMap{Spans: make([]SourceSpan, len(w.spans))}
+	for i, s := range w.spans {
+		sm.Spans[i] = SourceSpan{
+			Pointer: s.ptr,
+			Start:   m.docPosition(lines, s.start),
+			End:     m.docPosition(lines, s.end),
+		}
+	}
+	return sm
+}
+
+// smSpan is a SourceSpan with offsets still relative to srcMap.src.
+type smSpan struct {
+	ptr        string
+	start, end int
+}
+
+// smWalker walks compact JSON written by a converter, matching each value
+// with the marks recorded for it.
+type smWalker struct {
+	m     *srcMap
+	data  []byte
+	pos   int
+	spans []smSpan
+}
+
+// value records the value at w.pos, whose pointer is ptr, and moves past it.
+// fallback is used when no mark was recorded for the value. It returns the
+// span given to the value; start is -1 when it has none.
+func (w *smWalker) value(ptr []byte, fallback srcMark) (start, end int) {
+	mk, ok := w.m.markAt(w.pos, markValue)
+	if !ok {
+		mk = fallback
+	}
+	i := len(w.spans)
+	w.spans = append(w.spans, smSpan{ptr: string(ptr), start: mk.start, end: mk.end})
+
+	var open, close byte
+	switch w.data[w.pos] {
+	case '{':
+		open, close = '{', '}'
+	case '[':
+		open, close = '[', ']'
+	case '"':
+		w.pos = skipJSONString(w.data, w.pos)
+		return mk.start, mk.end
+	default:
+		for w.pos < len(w.data) && bytes.IndexByte([]byte(",]}"), w.data[w.pos]) < 0 {
+			w.pos++
+		}
+		return mk.start, mk.end
+	}
+
+	w.pos++ // consume open
+	start, end = mk.start, mk.end
+	for n := 0; w.data[w.pos] != close; n++ {
+		if n > 0 {
+			w.pos++ // consume ','
+		}
+		child := ptr
+		cfb := srcMark{start: start, end: start}
+		if open == '{' {
+			if k, ok := w.m.markAt(w.pos, markKey); ok {
+				cfb = k
+			}
+			child = append(child, '/')
+			child = appendPointerToken(child, jsonStringAt(w.data, w.pos))
+			w.pos = skipJSONString(w.data, w.pos) + 1 // and ':'
+		} else {
+			child = append(child, '/')
+			child = strconv.AppendInt(child, int64(n), 10)
+		}
+		cs, ce := w.value(child, cfb)
+		if cs >= 0 && (start < 0 || cs < start) {
+			start = cs
+		}
+		end = max(end, ce)
+	}
+	if c, ok := w.m.markAt(w.pos, markClose); ok {
+		end = max(end, c.end)
+	}
+	w.pos++ // consume close
+	w.spans[i].start, w.spans[i].end = start, max(start, end)
+	return start, max(start, end)
+}
+
+// markAt returns the first mark of the given kind recorded at output offset
+// off.
+func (m *srcMap) markAt(off int, kind markKind) (srcMark, bool) {
+	i := sort.Search(len(m.marks), func(i int) bool { return m.marks[i].out >= off })
+	for ; i < len(m.marks) && m.marks[i].out == off; i++ {
+		if m.marks[i].kind == kind {
+			return m.marks[i], true
+		}
+	}
+	return srcMark{}, false
+}
+
+// docPosition converts an offset in src to a Position in doc, given the
+// start offset of each doc line.
+func (m *srcMap) docPosition(lines []int, off int) Position {
+	off = min(max(m.base+max(off, 0), 0), len(m.doc))
+	line := sort.SearchInts(lines, off+1) - 1
+	return Position{Line: line + 1, Column: off - lines[line] + 1, Offset: off}
+}
+
+// lineStarts returns the offset at which each line of doc starts.
+func lineStarts(doc []byte) []int {
+	starts := []int{0}
+	for i, c := range doc {
+		if c == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+// skipJSONString returns the offset just past the JSON string at data[off].
+func skipJSONString(data []byte, off int) int {
+	for i := off + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(data)
+}
+
+// appendPointerToken appends key to dst escaped as a JSON Pointer reference
+// token.
+func appendPointerToken(dst []byte, key string) []byte {
+	if !strings.ContainsAny(key, "~/") {
+		return append(dst, key...)
+	}
+	for i := 0; i < len(key); i++ {
+		switch key[i] {
+		case '~':
+			dst = append(dst, "~0"...)
+		case '/':
+			dst = append(dst, "~1"...)
+		default:
+			dst = append(dst, key[i])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,245 @@
+package tojson
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSourceMap(t *testing.T) {
+	tests := []struct {
+		name  string
+		fn    func(src []byte) ([]byte, *SourceMap, error)
+		input string
+		want  map[string]string // pointer -> source text of its span
+	}{
+		{
+			name:  "yaml block",
+			fn:    FromYAMLWithSourceMap,
+			input: "name: web\nports:\n  - 80\n  - \"443\"\nempty:\nnote: |\n  one\n  two\n",
+			want: map[string]string{
+				"/name":    "web",
+				"/ports":   "- 80\n  - \"443\"",
+				"/ports/0": "80",
+				"/ports/1": `"443"`,
+				"/empty":   "empty",
+				"/note":    "|\n  one\n  two",
+			},
+		},
+		{
+			name:  "yaml flow",
+			fn:    FromYAMLWithSourceMap,
+			input: "b: [1, {c: 2}]\nm: {\n  x: 'a/b',\n  y: [2,\n   3]\n  }\n",
+			want: map[string]string{
+				"/b":     "[1, {c: 2}]",
+				"/b/1":   "{c: 2}",
+				"/b/1/c": "2",
+				"/m":     "{\n  x: 'a/b',\n  y: [2,\n   3]\n  }",
+				"/m/x":   "'a/b'",
+				"/m/y":   "[2,\n   3]",
+				"/m/y/1": "3",
+			},
+		},
+		{
+			name:  "toml",
+			fn:    FromTOMLWithSourceMap,
+			input: "title = 'x'\n[server]\nport = 80\nhosts = [\n  'a',\n  'b',\n]\n[[items]]\nn = 1\n[[items]]\nn = 2\n",
+			want: map[string]string{
+				"/title":          "'x'",
+				"/server":         "[server]\nport = 80\nhosts = [\n  'a',\n  'b',\n]",
+				"/server/hosts":   "[\n  'a',\n  'b',\n]",
+				"/server/hosts/1": "'b'",
+				"/items":          "[[items]]\nn = 1\n[[items]]\nn = 2",
+				"/items/1":        "[[items]]\nn = 2",
+				"/items/1/n":      "2",
+			},
+		},
+		{
+			name:  "toml inline and dotted",
+			fn:    FromTOMLWithSourceMap,
+			input: "a.b = {c = [1, 2], \"d/e\" = true}\n",
+			want: map[string]string{
+				"/a":        "a.b = {c = [1, 2], \"d/e\" = true}",
+				"/a/b":      "{c = [1, 2], \"d/e\" = true}",
+				"/a/b/c":    "[1, 2]",
+				"/a/b/c/1":  "2",
+				"/a/b/d~1e": "true",
+			},
+		},
+		{
+			name:  "toml out-of-order tables",
+			fn:    FromTOMLWithSourceMap,
+			input: "[a.b]\nx = 1\n[c]\ny = [\n  2,\n]\n[a]\nz = 'q'\n",
+			want: map[string]string{
+				"/a":     "[a.b]\nx = 1\n[c]\ny = [\n  2,\n]\n[a]\nz = 'q'",
+				"/a/b/x": "1",
+				"/a/z":   "'q'",
+				"/c/y":   "[\n  2,\n]",
+				"/c/y/0": "2",
+			},
+		},
+		{
+			name:  "json5",
+			fn:    FromJSONVariantWithSourceMap,
+			input: "{\n  // c\n  a: 1,\n  'b~c': [true, null, \"x\\u0041\"],\n  d: {},\n}",
+			want: map[string]string{
+				"":        "{\n  // c\n  a: 1,\n  'b~c': [true, null, \"x\\u0041\"],\n  d: {},\n}",
+				"/a":      "1",
+				"/b~0c":   `[true, null, "x\u0041"]`,
+				"/b~0c/2": `"x\u0041"`,
+				"/d":      "{}",
+			},
+		},
+		{name: "json5 empty", fn: FromJSONVariantWithSourceMap, input: ""},
+		{name: "json5 space", fn: FromJSONVariantWithSourceMap, input: "  \n"},
+		{name: "json5 comment", fn: FromJSONVariantWithSourceMap, input: "// c"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, sm, err := tc.fn([]byte(tc.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if n := countJSONValues(out); len(sm.Spans) != n {
+				t.Errorf("got %d spans, want one for each of %d values in %s", len(sm.Spans), n, out)
+			}
+			for ptr, want := range tc.want {
+				span, ok := sm.Lookup(ptr)
+				if !ok {
+					t.Errorf("%q: no span", ptr)
+					continue
+				}
+				if got := tc.input[span.Start.Offset:span.End.Offset]; got != want {
+					t.Errorf("%q: got span %q, want %q", ptr, got, want)
+				}
+				checkPosition(t, tc.input, span.Start)
+				checkPosition(t, tc.input, span.End)
+			}
+		})
+	}
+}
+
+func TestSourceMapLookupMissing(t *testing.T) {
+	_, sm, err := FromYAMLWithSourceMap([]byte("a: 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sm.Lookup("/b"); ok {
+		t.Error("found span for missing pointer")
+	}
+	if s, ok := sm.Lookup(""); !ok || s.Start.Line != 1 || s.Start.Column != 1 {
+		t.Errorf("got root span %+v, %v", s, ok)
+	}
+}
+
+func TestSourceMapError(t *testing.T) {
+	_, sm, err := FromTOMLWithSourceMap([]byte("a = \n"))
+	requireParseError(t, err)
+	if sm != nil {
+		t.Errorf("got source map %+v on error", sm)
+	}
+}
+
+func TestFrontMatterSourceMap(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		ptr   string
+		want  string
+		line  int
+		col   int
+	}{
+		{"yaml", "---\ntitle: x\ntags: [a, b]\n---\nbody", "/tags/1", "b", 3, 11},
+		{"toml", "+++\ntitle = 'x'\n[extra]\nn = 2\n+++\nbody", "/extra/n", "2", 4, 5},
+		{"brace", "{\n  \"title\": \"x\",\n  \"n\": 2\n}\nbody", "/n", "2", 3, 8},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, body, sm, err := FromFrontMatterWithSourceMap([]byte(tc.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(body) != "body" {
+				t.Errorf("got body %q", body)
+			}
+			span, ok := sm.Lookup(tc.ptr)
+			if !ok {
+				t.Fatalf("%q: no span", tc.ptr)
+			}
+			if got := tc.input[span.Start.Offset:span.End.Offset]; got != tc.want {
+				t.Errorf("got span %q, want %q", got, tc.want)
+			}
+			if span.Start.Line != tc.line || span.Start.Column != tc.col {
+				t.Errorf("got %d:%d, want %d:%d", span.Start.Line, span.Start.Column, tc.line, tc.col)
+			}
+		})
+	}
+
+	meta, body, sm, err := FromFrontMatterWithSourceMap([]byte("no front matter"))
+	if meta != nil || sm != nil || err != nil || string(body) != "no front matter" {
+		t.Errorf("got %q, %q, %v, %v", meta, body, sm, err)
+	}
+}
+
+func TestJoinLine(t *testing.T) {
+	input := []byte("a = [\n  1,\n\n  2]\n")
+	lines := bytes.Split(input, []byte("\n"))
+
+	// Lines narrowed from input with 2-index slicing are joined in place.
+	first := input[4:5]
+	second := input[6:10]
+	got := joinLine(first, second)
+	if string(got) != "[\n  1," || &got[0] != &input[4] {
+		t.Errorf("got %q, not joined in place", got)
+	}
+	got = joinLine(input[6:10], input[11:11])
+	if string(got) != "  1,\n" || &got[0] != &input[6] {
+		t.Errorf("got %q, not joined in place", got)
+	}
+
+	// bytes.Split caps its lines, so they are copied.
+	got = joinLine(lines[0], lines[1])
+	if string(got) != "a = [\n  1," || &got[0] == &input[0] {
+		t.Errorf("got %q, want a copy", got)
+	}
+	if string(input) != "a = [\n  1,\n\n  2]\n" {
+		t.Errorf("input modified: %q", input)
+	}
+}
+
+// countJSONValues returns the number of values, at any depth, in the compact
+// JSON document data.
+func countJSONValues(data []byte) int {
+	n := 0
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; {
+		case c == '"':
+			end := skipJSONString(data, i)
+			if end < len(data) && data[end] == ':' {
+				i = end // a key
+				continue
+			}
+			n++
+			i = end - 1
+		case c == '{' || c == '[':
+			n++
+		case c == ',' || c == ':' || c == '}' || c == ']':
+		default:
+			n++
+			for i+1 < len(data) && !bytes.ContainsRune([]byte(",]}"), rune(data[i+1])) {
+				i++
+			}
+		}
+	}
+	return n
+}
+
+// checkPosition verifies that the line and column of p agree with its offset.
+func checkPosition(t *testing.T, input string, p Position) {
+	t.Helper()
+	before := input[:p.Offset]
+	line := bytes.Count([]byte(before), []byte("\n")) + 1
+	col := p.Offset - bytes.LastIndexByte([]byte(before), '\n')
+	if p.Line != line || p.Column != col {
+		t.Errorf("position %+v: want line %d, column %d", p, line, col)
+	}
+}
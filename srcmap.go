@@ -0,0 +1,177 @@
+package tojson
+
+import (
+	"bytes"
+	"slices"
+	"sort"
+)
+
+// srcMap records, while a converter runs, where each object key and value it
+// writes came from in the input. Converters call its methods unconditionally;
+// a nil *srcMap records nothing, so the common path pays only a nil check.
+//
+// Values are located with the cap-difference trick: a slice narrowed from the
+// input by 2-index slicing shares its end of capacity, so its offset is
+// cap(src)-cap(sub). offset verifies the result, so text that was copied out
+// of the input (decoded escapes, joined lines) is simply not recorded and
+// lookups fall back to the enclosing key or container.
+type srcMap struct {
+	doc   []byte // document positions are reported against
+	src   []byte // bytes being converted: doc itself, a subslice, or a rebuilt copy
+	base  int    // offset in doc that src[0] corresponds to
+	out0  int    // output length when conversion began
+	marks []srcMark
+}
+
+// srcMark ties an output offset to a span of src. A container's span starts
+// at its opening and covers at most its opening token or header; when the
+// container has a closing bracket in src, a separate mark records it.
+type srcMark struct {
+	out        int // offset of the key or value in the output, relative to out0
+	start, end int // byte span in src
+	kind       markKind
+}
+
+type markKind uint8
+
+const (
+	markValue markKind = iota
+	markKey
+	markClose // closing bracket of an object or array
+)
+
+// newSrcMap returns a map for converting all of src into out.
+func newSrcMap(src []byte, out *bytes.Buffer) *srcMap {
+	return &srcMap{doc: src, src: src, out0: out.Len()}
+}
+
+// sliceOffset returns the offset of sub within whole, or false if sub is
+// empty or does not share whole's backing array.
+func sliceOffset(whole, sub []byte) (int, bool) {
+	if len(sub) == 0 {
+		return 0, false
+	}
+	off := cap(whole) - cap(sub)
+	if off < 0 || off+len(sub) > len(whole) || &whole[off] != &sub[0] {
+		return 0, false
+	}
+	return off, true
+}
+
+// offset returns the offset of sub within m.src.
+func (m *srcMap) offset(sub []byte) (int, bool) {
+	return sliceOffset(m.src, sub)
+}
+
+func (m *srcMap) add(out *bytes.Buffer, start, end int, kind markKind) {
+	m.marks = append(m.marks, srcMark{out: out.Len() - m.out0, start: start, end: end, kind: kind})
+}
+
+// value records src[start:end] as the source of the value about to be
+// written to out.
+func (m *srcMap) value(out *bytes.Buffer, start, end int) {
+	if m == nil {
+		return
+	}
+	m.add(out, start, end, markValue)
+}
+
+// valueOf records sub as the source of the value about to be written to out
+// and reports whether sub could be located.
+func (m *srcMap) valueOf(out *bytes.Buffer, sub []byte) bool {
+	if m == nil {
+		return false
+	}
+	off, ok := m.offset(sub)
+	if ok {
+		m.add(out, off, off+len(sub), markValue)
+	}
+	return ok
+}
+
+// openOf records the start of sub as the position of the object or array
+// about to be written to out.
+func (m *srcMap) openOf(out *bytes.Buffer, sub []byte) {
+	if m == nil {
+		return
+	}
+	if off, ok := m.offset(sub); ok {
+		m.add(out, off, off, markValue)
+	}
+}
+
+// keyOf records sub as the source of the object key about to be written to
+// out and reports whether sub could be located.
+func (m *srcMap) keyOf(out *bytes.Buffer, sub []byte) bool {
+	if m == nil {
+		return false
+	}
+	off, ok := m.offset(sub)
+	if ok {
+		m.add(out, off, off+len(sub), markKey)
+	}
+	return ok
+}
+
+// closeOf records sub, the closing bracket of an object or array, as written
+// at the current end of out. Call it just before writing the bracket.
+func (m *srcMap) closeOf(out *bytes.Buffer, sub []byte) {
+	if m == nil {
+		return
+	}
+	if off, ok := m.offset(sub); ok {
+		m.add(out, off, off+1, markClose)
+	}
+}
+
+// truncate discards marks at or beyond output length n, for converters that
+// rewind their output and start again.
+func (m *srcMap) truncate(n int) {
+	if m == nil {
+		return
+	}
+	i := sort.Search(len(m.marks), func(i int) bool { return m.marks[i].out >= n-m.out0 })
+	m.marks = m.marks[:i]
+}
+
+// cut discards marks for output offsets [from, to), which a converter has
+// removed from its output, and moves later marks back to match.
+func (m *srcMap) cut(from, to int) {
+	if m == nil {
+		return
+	}
+	from, to = from-m.out0, to-m.out0
+	i := sort.Search(len(m.marks), func(i int) bool { return m.marks[i].out >= from })
+	j := sort.Search(len(m.marks), func(i int) bool { return m.marks[i].out >= to })
+	m.marks = slices.Delete(m.marks, i, j)
+	for ; i < len(m.marks); i++ {
+		m.marks[i].out -= to - from
+	}
+}
+
+// at returns the innermost key or value mark recorded before output offset
+// off (relative to out0), excluding a mark that starts exactly at off.
+func (m *srcMap) at(off int) (srcMark, bool) {
+	i := sort.Search(len(m.marks), func(i int) bool { return m.marks[i].out >= off })
+	for i--; i >= 0; i-- {
+		if m.marks[i].kind != markClose {
+			return m.marks[i], true
+		}
+	}
+	return srcMark{}, false
+}
+
+// position converts an offset in src to a 1-based line and column in doc.
+func (m *srcMap) position(off int) (line, col int) {
+	off = min(max(m.base+off, 0), len(m.doc))
+	before := m.doc[:off]
+	line = bytes.Count(before, []byte{'\n'}) + 1
+	col = off - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// lineShift returns the number of doc lines before src, for translating
+// positions that converters report relative to src.
+func (m *srcMap) lineShift() int {
+	return bytes.Count(m.doc[:max(m.base, 0)], []byte{'\n'})
+}
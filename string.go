@@ -131,7 +131,14 @@ func hexVal(b byte) int {
 	return -1
 }
 
+// appendString appends src to dst as a JSON string. A CR that starts a CRLF
+// line break is dropped, so the break is written as \n.
 func appendString(dst []byte, src []byte) []byte {
+	return appendStringCR(dst, src, false)
+}
+
+// appendStringCR is appendString, keeping every CR when keepCR is set.
+func appendStringCR(dst []byte, src []byte, keepCR bool) []byte {
 	dst = append(dst, '"')
 	start := 0
 	for i := 0; i < len(src); {
@@ -151,7 +158,7 @@ func appendString(dst []byte, src []byte) []byte {
 			case '\n':
 				dst = append(dst, '\\', 'n')
 			case '\r':
-				if i+1 < len(src) && src[i+1] == '\n' {
+				if !keepCR && i+1 < len(src) && src[i+1] == '\n' {
 					break
 				}
 				dst = append(dst, '\\', 'r')
@@ -3,9 +3,8 @@
 //
 // Supported: key-value pairs, standard tables [header], array-of-tables
 // [[header]], inline tables {k=v}, inline arrays [v,v], all scalar types,
-// dotted keys, comments.
-//
-// Not supported: TOML integers larger than int64.
+// dotted keys, comments. Integers of any size convert exactly. TOML 1.1 is
+// accepted when TOMLOptions.Version is TOML11.
 
 package tojson
 
@@ -13,18 +12,76 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"unicode/utf8"
+)
+
+// TOMLVersion selects the version of the TOML specification that
+// FromTOMLWithOptions accepts.
+type TOMLVersion int
+
+const (
+	// TOML10 accepts TOML 1.0.0. It is the default.
+	TOML10 TOMLVersion = iota
+
+	// TOML11 accepts TOML 1.1.0, which adds newlines, comments, and a
+	// trailing comma inside inline tables; the escapes \e, for U+001B, and
+	// \xHH, for the code points U+0000 to U+00FF; and times without
+	// seconds, such as 07:32, which convert as 07:32:00.
+	TOML11
 )
 
-// errReentry signals that the line parser detected an out-of-order section
-// and the caller should fall back to tomlConvertTree.
+func (o TOMLOptions) v11() bool { return o.Version >= TOML11 }
+
+// errReentry signals that the line parser met a table it cannot re-enter —
+// an out-of-order section while recording a source map, or a table opened
+// by dotted keys — and the caller should fall back to tomlConvertTree.
 var errReentry = errors.New("toml: out-of-order section")
 
-func tomlConvert(input []byte) ([]byte, error) {
-	out, err := fromTOMLLine(input)
+// tomlConvert appends the JSON form of input to out using p, which may be a
+// zero parser or one retained from an earlier call. On error out may hold a
+// partial document; callers discard it.
+func tomlConvert(p *tomlLineParser, out *bytes.Buffer, input []byte) error {
+	start := out.Len()
+	err := p.run(out, input)
 	if err == errReentry {
-		return tomlConvertTree(input)
+		out.Truncate(start)
+		p.sm.truncate(start)
+		return tomlConvertTree(out, input, p.sm, p.opts)
+	}
+	return err
+}
+
+// trimTOMLSpace returns s without the leading and trailing whitespace TOML
+// allows around its tokens. Unlike bytes.TrimSpace it keeps other Unicode
+// spaces, such as U+3000, which TOML does not treat as whitespace.
+func trimTOMLSpace(s []byte) []byte {
+	return bytes.Trim(s, " \t\r\n")
+}
+
+// checkTOMLText reports the first byte of input that TOML allows nowhere,
+// even in a comment or string: a control character other than tab and
+// newline, a carriage return not followed by a newline, or invalid UTF-8.
+func checkTOMLText(input []byte) error {
+	line, lineStart := 0, 0
+	for i := 0; i < len(input); {
+		c := input[i]
+		switch {
+		case c == '\n':
+			line, lineStart = line+1, i+1
+		case c == '\r' && i+1 < len(input) && input[i+1] == '\n', c == '\t':
+		case c < 0x20 || c == 0x7f:
+			return atLineCol(line, i-lineStart, fmt.Errorf("control character %U is not allowed", c))
+		case c >= utf8.RuneSelf:
+			r, size := utf8.DecodeRune(input[i:])
+			if r == utf8.RuneError && size == 1 {
+				return atLineCol(line, i-lineStart, fmt.Errorf("invalid UTF-8"))
+			}
+			i += size
+			continue
+		}
+		i++
 	}
-	return out, err
+	return nil
 }
 
 // --------------------------------------------------------------------------
@@ -34,7 +91,8 @@ func tomlConvert(input []byte) ([]byte, error) {
 // parseTOMLKeyPath parses a dotted key (e.g. a."b c".d) from the start of s.
 // Returns the decoded key segments and the remainder of s after the last segment.
 // buf is caller-provided backing storage (pass yourArray[:0]); avoids a heap alloc for ≤ cap(buf) keys.
-func parseTOMLKeyPath(s []byte, buf [][]byte) ([][]byte, []byte, error) {
+// Quoted keys are read as parseTOMLBasicStringRaw reads strings under opts.
+func parseTOMLKeyPath(s []byte, buf [][]byte, opts *TOMLOptions) ([][]byte, []byte, error) {
 	keys := buf[:0]
 	for {
 		s = bytes.TrimLeft(s, " \t")
@@ -46,12 +104,14 @@ func parseTOMLKeyPath(s []byte, buf [][]byte) ([][]byte, []byte, error) {
 		var err error
 		switch s[0] {
 		case '"':
-			key, rest, err = parseTOMLBasicStringRaw(s)
+			key, rest, err = parseTOMLBasicStringRaw(s, opts)
 			if err != nil {
 				return nil, nil, err
 			}
 		case '\'':
-			key, rest = parseTOMLLiteralStringRaw(s)
+			if key, rest, err = parseTOMLLiteralStringRaw(s); err != nil {
+				return nil, nil, err
+			}
 		default:
 			// bare key: [A-Za-z0-9_-]+
 			i := 0
@@ -64,15 +124,19 @@ func parseTOMLKeyPath(s []byte, buf [][]byte) ([][]byte, []byte, error) {
 					break
 				}
 			}
+			if i == 0 && s[0] >= utf8.RuneSelf {
+				r, _ := utf8.DecodeRune(s)
+				return nil, s, fmt.Errorf("invalid character %U in key", r)
+			}
 			if i == 0 {
-				break
+				if len(keys) != 0 {
+					return nil, nil, fmt.Errorf("expected a key after '.'")
+				}
+				return nil, s, fmt.Errorf("empty key")
 			}
 			key = s[:i]
 			rest = s[i:]
 		}
-		if len(key) == 0 && len(keys) == 0 {
-			break
-		}
 		keys = append(keys, key)
 		rest = bytes.TrimLeft(rest, " \t")
 		if len(rest) == 0 || rest[0] != '.' {
@@ -83,5 +147,26 @@ func parseTOMLKeyPath(s []byte, buf [][]byte) ([][]byte, []byte, error) {
 	if len(keys) == 0 {
 		return nil, s, fmt.Errorf("empty key")
 	}
-	return keys, s, nil
+	return nil, nil, fmt.Errorf("expected a key after '.'")
+}
+
+// stripTOMLComment removes a # comment, and the whitespace before it, from a
+// line. Unlike stripInlineComment, which follows YAML, a '#' outside a
+// string starts a comment whatever precedes it, and a literal string has no
+// escapes. A string left open, such as the first line of a multi-line
+// string, keeps the rest of the line.
+func stripTOMLComment(s []byte) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '#':
+			return bytes.TrimRight(s[:i], " \t")
+		case '"', '\'':
+			n := tomlValueEnd(s[i:])
+			if n == len(s)-i {
+				return s
+			}
+			i += n - 1
+		}
+	}
+	return s
 }
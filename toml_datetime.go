@@ -0,0 +1,232 @@
+package tojson
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// TOMLDateTimeFormat selects how FromTOMLWithOptions writes TOML dates and
+// times, which JSON has no type for.
+type TOMLDateTimeFormat int
+
+const (
+	// TOMLDateTimeRaw writes a date or time as a JSON string of its text in
+	// the input, such as "1979-05-27 07:32:00Z". It is the default.
+	TOMLDateTimeRaw TOMLDateTimeFormat = iota
+
+	// TOMLDateTimeRFC3339 writes a date or time as a JSON string in RFC 3339
+	// form, with a 'T' between the date and the time and an upper-case 'Z',
+	// such as "1979-05-27T07:32:00Z". A local date or local time is written
+	// as RFC 3339's full-date or partial-time.
+	TOMLDateTimeRFC3339
+
+	// TOMLDateTimeTagged writes a date or time as an object holding its RFC
+	// 3339 form and its kind, such as
+	// {"$datetime":"1979-05-27","kind":"local-date"}, so that readers can
+	// tell the four kinds apart. The kind is "offset-datetime",
+	// "local-datetime", "local-date", or "local-time".
+	TOMLDateTimeTagged
+)
+
+// tomlDateTimeKind is one of the four TOML date and time types.
+type tomlDateTimeKind uint8
+
+const (
+	tomlOffsetDateTime tomlDateTimeKind = iota
+	tomlLocalDateTime
+	tomlLocalDate
+	tomlLocalTime
+)
+
+func (k tomlDateTimeKind) String() string {
+	switch k {
+	case tomlOffsetDateTime:
+		return "offset-datetime"
+	case tomlLocalDateTime:
+		return "local-datetime"
+	case tomlLocalDate:
+		return "local-date"
+	}
+	return "local-time"
+}
+
+// isTOMLDateTime reports whether s has the shape of a TOML date or time,
+// HH: or YYYY-, and so should be parsed as one rather than as a number.
+func isTOMLDateTime(s []byte) bool {
+	if len(s) >= 5 && s[2] == ':' && isDigits(s[0:2]) {
+		return true
+	}
+	if len(s) >= 10 && s[4] == '-' && isDigits(s[0:4]) {
+		return true
+	}
+	return false
+}
+
+// parseTOMLDateTime validates s, which isTOMLDateTime accepted, as one of the
+// four TOML date and time types, and returns its kind and its RFC 3339 form.
+// The RFC 3339 form is s itself unless s uses a space or a lower-case 't' or
+// 'z', or omits the seconds, which TOML 1.1 allows when v11 is set.
+func parseTOMLDateTime(s []byte, v11 bool) (tomlDateTimeKind, []byte, error) {
+	invalid := func(why string) error {
+		return fmt.Errorf("invalid date-time %s: %s", s, why)
+	}
+	rest := s
+	hasDate := s[2] != ':'
+	if hasDate {
+		if why := checkTOMLDate(s); why != "" {
+			return 0, nil, invalid(why)
+		}
+		rest = s[10:]
+		if len(rest) == 0 {
+			return tomlLocalDate, s, nil
+		}
+		if c := rest[0]; c != 'T' && c != 't' && c != ' ' {
+			return 0, nil, invalid("expected 'T' or a space after the date")
+		}
+		rest = rest[1:]
+	}
+
+	n, seconds, why := checkTOMLTime(rest, v11)
+	if why != "" {
+		return 0, nil, invalid(why)
+	}
+	timeEnd := len(s) - len(rest) + n
+	rest = rest[n:]
+	kind := tomlLocalDateTime
+	switch {
+	case !hasDate:
+		kind = tomlLocalTime
+		if len(rest) != 0 {
+			return 0, nil, invalid("a local time has no offset")
+		}
+	case len(rest) == 0:
+	case len(rest) == 1 && (rest[0] == 'Z' || rest[0] == 'z'):
+		kind = tomlOffsetDateTime
+	case len(rest) == 6 && (rest[0] == '+' || rest[0] == '-') && rest[3] == ':':
+		h, okH := twoDigits(rest[1:3])
+		m, okM := twoDigits(rest[4:6])
+		if !okH || !okM || h > 23 || m > 59 {
+			return 0, nil, invalid("offset out of range")
+		}
+		kind = tomlOffsetDateTime
+	default:
+		return 0, nil, invalid("expected Z or an offset such as +07:00 after the time")
+	}
+
+	norm := s
+	if !seconds {
+		norm = make([]byte, 0, len(s)+3)
+		norm = append(norm, s[:timeEnd]...)
+		norm = append(norm, ":00"...)
+		norm = append(norm, s[timeEnd:]...)
+	}
+	if hasDate && norm[10] != 'T' || norm[len(norm)-1] == 'z' {
+		if seconds {
+			norm = bytes.Clone(s)
+		}
+		norm[10] = 'T'
+		if norm[len(norm)-1] == 'z' {
+			norm[len(norm)-1] = 'Z'
+		}
+	}
+	return kind, norm, nil
+}
+
+// checkTOMLDate checks the full-date YYYY-MM-DD at the start of s and
+// returns what is wrong with it, or "".
+func checkTOMLDate(s []byte) string {
+	if len(s) < 10 || s[4] != '-' || s[7] != '-' || !isDigits(s[:4]) {
+		return "expected a date of the form YYYY-MM-DD"
+	}
+	month, okM := twoDigits(s[5:7])
+	day, okD := twoDigits(s[8:10])
+	if !okM || !okD {
+		return "expected a date of the form YYYY-MM-DD"
+	}
+	if month < 1 || month > 12 {
+		return "month out of range"
+	}
+	year := int(s[0]-'0')*1000 + int(s[1]-'0')*100 + int(s[2]-'0')*10 + int(s[3]-'0')
+	days := [...]int{31, 28, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}[month-1]
+	if month == 2 && year%4 == 0 && (year%100 != 0 || year%400 == 0) {
+		days = 29
+	}
+	if day < 1 || day > days {
+		return "day out of range"
+	}
+	return ""
+}
+
+// checkTOMLTime checks the partial-time HH:MM:SS with an optional fraction
+// at the start of s and returns its length, or what is wrong with it. When
+// v11 is set the seconds may be left out, as TOML 1.1 allows, and seconds
+// reports whether they were present.
+func checkTOMLTime(s []byte, v11 bool) (n int, seconds bool, why string) {
+	const form = "expected a time of the form HH:MM:SS"
+	if len(s) < 5 || s[2] != ':' {
+		return 0, false, form
+	}
+	h, okH := twoDigits(s[0:2])
+	m, okM := twoDigits(s[3:5])
+	if !okH || !okM {
+		return 0, false, form
+	}
+	sec := 0
+	n = 5
+	if len(s) > 5 && s[5] == ':' {
+		var okS bool
+		if len(s) >= 8 {
+			sec, okS = twoDigits(s[6:8])
+		}
+		if !okS {
+			return 0, false, "expected two digits of seconds after HH:MM:"
+		}
+		n, seconds = 8, true
+	} else if !v11 {
+		return 0, false, form
+	} else if len(s) > 5 && s[5] == '.' {
+		return 0, false, "expected seconds before the fraction"
+	}
+	// RFC 3339 allows a leap second, 60.
+	if h > 23 || m > 59 || sec > 60 {
+		return 0, false, "time out of range"
+	}
+	if seconds && n < len(s) && s[n] == '.' {
+		n++
+		for n < len(s) && '0' <= s[n] && s[n] <= '9' {
+			n++
+		}
+		if n == 9 {
+			return 0, false, "expected digits after the decimal point"
+		}
+	}
+	return n, seconds, ""
+}
+
+// twoDigits returns the value of the two decimal digits in s.
+func twoDigits(s []byte) (int, bool) {
+	if !isDigits(s) {
+		return 0, false
+	}
+	return int(s[0]-'0')*10 + int(s[1]-'0'), true
+}
+
+// appendTOMLDateTime validates the TOML date or time s and appends its JSON
+// form, as f selects, to dst. v11 is as for parseTOMLDateTime.
+func appendTOMLDateTime(dst, s []byte, f TOMLDateTimeFormat, v11 bool) ([]byte, error) {
+	kind, norm, err := parseTOMLDateTime(s, v11)
+	if err != nil {
+		return dst, err
+	}
+	switch f {
+	case TOMLDateTimeRFC3339:
+		return appendString(dst, norm), nil
+	case TOMLDateTimeTagged:
+		dst = append(dst, `{"$datetime":`...)
+		dst = appendString(dst, norm)
+		dst = append(dst, `,"kind":"`...)
+		dst = append(dst, kind.String()...)
+		return append(dst, `"}`...), nil
+	}
+	return appendString(dst, s), nil
+}
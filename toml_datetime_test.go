@@ -0,0 +1,158 @@
+package tojson
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTOMLDateTime(t *testing.T) {
+	tests := []struct {
+		in   string
+		kind string
+		rfc  string // TOMLDateTimeRFC3339 form
+	}{
+		{"1979-05-27T07:32:00Z", "offset-datetime", "1979-05-27T07:32:00Z"},
+		{"1979-05-27 07:32:00z", "offset-datetime", "1979-05-27T07:32:00Z"},
+		{"1979-05-27t00:32:00.999999-07:00", "offset-datetime", "1979-05-27T00:32:00.999999-07:00"},
+		{"1979-05-27T07:32:00+23:59", "offset-datetime", "1979-05-27T07:32:00+23:59"},
+		{"1979-05-27T07:32:00", "local-datetime", "1979-05-27T07:32:00"},
+		{"1979-05-27 00:32:00.5", "local-datetime", "1979-05-27T00:32:00.5"},
+		{"1979-05-27", "local-date", "1979-05-27"},
+		{"2000-02-29", "local-date", "2000-02-29"},
+		{"2024-02-29", "local-date", "2024-02-29"},
+		{"07:32:00", "local-time", "07:32:00"},
+		{"23:59:60.999", "local-time", "23:59:60.999"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			src := []byte("d = " + tc.in + "\na = [" + tc.in + "]\nt = {d = " + tc.in + "}\n")
+			for _, f := range []struct {
+				format TOMLDateTimeFormat
+				value  string
+			}{
+				{TOMLDateTimeRaw, `"` + tc.in + `"`},
+				{TOMLDateTimeRFC3339, `"` + tc.rfc + `"`},
+				{TOMLDateTimeTagged, `{"$datetime":"` + tc.rfc + `","kind":"` + tc.kind + `"}`},
+			} {
+				want := `{"d":` + f.value + `,"a":[` + f.value + `],"t":{"d":` + f.value + `}}`
+				opts := TOMLOptions{DateTimes: f.format}
+				got, err := FromTOMLWithOptions(src, opts)
+				if err != nil || string(got) != want {
+					t.Errorf("format %d: got %s, %v, want %s", f.format, got, err, want)
+				}
+				var buf bytes.Buffer
+				if err := tomlConvertTree(&buf, src, nil, opts); err != nil || buf.String() != want {
+					t.Errorf("format %d, tree: got %s, %v, want %s", f.format, buf.Bytes(), err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTOMLDateTimeErrors(t *testing.T) {
+	tests := []struct {
+		in  string
+		msg string
+	}{
+		{"2026-13-45T99:00:00", "month out of range"},
+		{"2026-00-01", "month out of range"},
+		{"2026-04-31", "day out of range"},
+		{"2023-02-29", "day out of range"},
+		{"1900-02-29", "day out of range"},
+		{"2026-01-00", "day out of range"},
+		{"2026-01-1x", "expected a date of the form YYYY-MM-DD"},
+		{"2026-01-01X07:32:00", "expected 'T' or a space after the date"},
+		{"2026-01-01T24:00:00", "time out of range"},
+		{"2026-01-01T07:60:00", "time out of range"},
+		{"2026-01-01T07:32:61", "time out of range"},
+		{"2026-01-01T07:32", "expected a time of the form HH:MM:SS"},
+		{"2026-01-01T07:32:00.", "expected digits after the decimal point"},
+		{"2026-01-01T07:32:00+0700", "expected Z or an offset such as +07:00 after the time"},
+		{"2026-01-01T07:32:00+24:00", "offset out of range"},
+		{"2026-01-01T07:32:00ZZ", "expected Z or an offset such as +07:00 after the time"},
+		{"07:32:00Z", "a local time has no offset"},
+		{"07:32", "expected a time of the form HH:MM:SS"},
+		{"07:32:5", "expected two digits of seconds after HH:MM:"},
+		{"2026-01-01T07:32:5Z", "expected two digits of seconds after HH:MM:"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			src := []byte("x = 1\nd = " + tc.in + "\n")
+			_, err := FromTOML(src)
+			pe := requireParseError(t, err)
+			if pe.Line != 2 || pe.Column != 5 {
+				t.Errorf("got line %d, column %d, want line 2, column 5", pe.Line, pe.Column)
+			}
+			if !strings.HasSuffix(pe.Message, tc.msg) {
+				t.Errorf("got message %q, want suffix %q", pe.Message, tc.msg)
+			}
+			var buf bytes.Buffer
+			if err := tomlConvertTree(&buf, src, nil, TOMLOptions{}); err == nil {
+				t.Error("tree: expected error")
+			}
+		})
+	}
+}
+
+func TestTOMLDateTimeWithoutSeconds(t *testing.T) {
+	tests := []struct {
+		in   string
+		kind string
+		rfc  string
+		v10  bool // valid TOML 1.0 too
+	}{
+		{"07:32", "local-time", "07:32:00", false},
+		{"1979-05-27T07:32", "local-datetime", "1979-05-27T07:32:00", false},
+		{"1979-05-27 07:32z", "offset-datetime", "1979-05-27T07:32:00Z", false},
+		{"1979-05-27T07:32-07:00", "offset-datetime", "1979-05-27T07:32:00-07:00", false},
+		{"1979-05-27T07:32:05", "local-datetime", "1979-05-27T07:32:05", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			src := []byte("d = " + tc.in + "\na = [" + tc.in + "]\n")
+			want := `{"d":"` + tc.rfc + `","a":["` + tc.rfc + `"]}`
+			opts := TOMLOptions{Version: TOML11, DateTimes: TOMLDateTimeRFC3339}
+			got, err := FromTOMLWithOptions(src, opts)
+			if err != nil || string(got) != want {
+				t.Errorf("got %s, %v, want %s", got, err, want)
+			}
+			opts.DateTimes = TOMLDateTimeTagged
+			want = `{"$datetime":"` + tc.rfc + `","kind":"` + tc.kind + `"}`
+			if got, err := FromTOMLWithOptions([]byte("d = "+tc.in), opts); err != nil || string(got) != `{"d":`+want+`}` {
+				t.Errorf("tagged: got %s, %v, want %s", got, err, want)
+			}
+			if _, err := FromTOML(src); (err == nil) != tc.v10 {
+				t.Errorf("TOML 1.0: got error %v", err)
+			}
+		})
+	}
+
+	// Seconds may be left out, but not cut short.
+	for _, in := range []string{"07:32:5", "1979-05-27T07:32:5Z"} {
+		_, err := FromTOMLWithOptions([]byte("d = "+in), TOMLOptions{Version: TOML11})
+		pe := requireParseError(t, err)
+		if !strings.HasSuffix(pe.Message, "expected two digits of seconds after HH:MM:") {
+			t.Errorf("%s: got message %q", in, pe.Message)
+		}
+	}
+}
+
+func TestTOMLDateTimeOptions(t *testing.T) {
+	var v struct {
+		D struct {
+			Value string `json:"$datetime"`
+			Kind  string `json:"kind"`
+		} `json:"d"`
+	}
+	o := UnmarshalOptions{TOML: TOMLOptions{DateTimes: TOMLDateTimeTagged}}
+	if err := o.UnmarshalTOML([]byte("d = 1979-05-27"), &v); err != nil || v.D.Value != "1979-05-27" || v.D.Kind != "local-date" {
+		t.Errorf("UnmarshalTOML: got %+v, %v", v, err)
+	}
+
+	c := Converter{TOML: TOMLOptions{DateTimes: TOMLDateTimeRFC3339}}
+	got, err := c.AppendTOML(nil, []byte("d = 1979-05-27 07:32:00Z"))
+	if err != nil || string(got) != `{"d":"1979-05-27T07:32:00Z"}` {
+		t.Errorf("Converter: got %s, %v", got, err)
+	}
+}
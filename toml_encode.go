@@ -0,0 +1,346 @@
+package tojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ToTOML converts a JSON object to TOML that FromTOML reads back as the same
+// JSON. Within each table, keys keep their input order, except that values
+// written as key = value lines come before sub-tables and arrays of tables,
+// which TOML requires. As with ToYAML, a CR LF pair inside a string reads
+// back as a bare LF.
+//
+// Objects become [table] sections and non-empty arrays of objects become
+// [[array]] sections, however deeply they nest. Other arrays and empty
+// objects are written inline. An array that mixes
+// objects with other values is written as an inline array, which TOML 1.0
+// allows.
+//
+// TOML cannot express every JSON document. ToTOML returns a *ParseError,
+// positioned in src, for a root value that is not an object, a null, and a
+// number outside the range of a TOML integer or float, as well as for invalid
+// JSON.
+func ToTOML(src []byte) ([]byte, error) {
+	e := tomlEncoder{
+		src: src,
+		dec: json.NewDecoder(bytes.NewReader(src)),
+		out: make([]byte, 0, len(src)+len(src)/2),
+	}
+	e.dec.UseNumber()
+
+	start := e.dec.InputOffset()
+	tok, err := e.dec.Token()
+	if err != nil {
+		return nil, jsonInputError(src, e.dec.InputOffset(), err)
+	}
+	if tok != json.Delim('{') {
+		return nil, e.errorAt(start, "TOML document must be an object")
+	}
+	root, err := e.decode(tok, start)
+	if err != nil {
+		return nil, err
+	}
+	if err := jsonTrailingError(src, e.dec.InputOffset()); err != nil {
+		return nil, err
+	}
+	e.table(nil, root.(*tomlTable))
+	return e.out, nil
+}
+
+// tomlTable is a JSON object with its members in input order.
+type tomlTable struct {
+	keys []string
+	vals []any
+}
+
+type tomlEncoder struct {
+	src []byte
+	dec *json.Decoder
+	out []byte
+}
+
+// decode reads the rest of the value starting with tok, which began at input
+// offset start, into a *tomlTable, []any, string, json.Number, or bool.
+func (e *tomlEncoder) decode(tok json.Token, start int64) (any, error) {
+	switch v := tok.(type) {
+	case json.Delim:
+		if v == '{' {
+			t := &tomlTable{}
+			for e.dec.More() {
+				key, err := e.dec.Token()
+				if err != nil {
+					return nil, jsonInputError(e.src, e.dec.InputOffset(), err)
+				}
+				val, err := e.next()
+				if err != nil {
+					return nil, err
+				}
+				t.keys = append(t.keys, key.(string))
+				t.vals = append(t.vals, val)
+			}
+			_, err := e.dec.Token() // '}'
+			return t, err
+		}
+		arr := []any{}
+		for e.dec.More() {
+			val, err := e.next()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+		_, err := e.dec.Token() // ']'
+		return arr, err
+	case json.Number:
+		// TOML integers are 64-bit, so a larger one would not be read
+		// back by other parsers.
+		if _, err := parseTOMLNumber([]byte(v), intFormat{}); err != nil || isBigInt([]byte(v)) {
+			return nil, e.errorAt(start, "number "+string(v)+" is out of range for TOML")
+		}
+		return v, nil
+	case nil:
+		return nil, e.errorAt(start, "TOML has no null value")
+	default:
+		return v, nil
+	}
+}
+
+// next reads and decodes the next value.
+func (e *tomlEncoder) next() (any, error) {
+	start := e.dec.InputOffset()
+	tok, err := e.dec.Token()
+	if err != nil {
+		return nil, jsonInputError(e.src, e.dec.InputOffset(), err)
+	}
+	return e.decode(tok, start)
+}
+
+// errorAt reports msg at the first token at or after input offset off, which
+// is the decoder's offset before reading the token and so may precede the
+// separators in front of it.
+func (e *tomlEncoder) errorAt(off int64, msg string) error {
+	rest := bytes.TrimLeft(e.src[off:], " \t\r\n,:")
+	return atOffset(e.src, len(e.src)-len(rest), errors.New(msg))
+}
+
+// table writes the members of t, the table at path. Members written as
+// key = value lines come first, then sub-tables and arrays of tables in
+// input order.
+func (e *tomlEncoder) table(path []string, t *tomlTable) {
+	for i, k := range t.keys {
+		if !tomlIsSection(t.vals[i]) {
+			e.out = appendTOMLKey(e.out, k)
+			e.out = append(e.out, " = "...)
+			if s, ok := t.vals[i].(string); ok && strings.Contains(s, "\n") {
+				e.out = appendTOMLMultiline(e.out, s)
+			} else {
+				e.inline(t.vals[i])
+			}
+			e.out = append(e.out, '\n')
+		}
+	}
+	for i, k := range t.keys {
+		if !tomlIsSection(t.vals[i]) {
+			continue
+		}
+		sub := append(path[:len(path):len(path)], k)
+		switch v := t.vals[i].(type) {
+		case *tomlTable:
+			// A table holding only sections needs no header of its own.
+			if tomlHasValues(v) {
+				e.header(sub, false)
+			}
+			e.table(sub, v)
+		case []any:
+			for _, elem := range v {
+				e.header(sub, true)
+				e.table(sub, elem.(*tomlTable))
+			}
+		}
+	}
+}
+
+// tomlIsSection reports whether v, a member of a table, is written as a
+// [table] or [[array]] section rather than as key = value.
+func tomlIsSection(v any) bool {
+	switch v := v.(type) {
+	case *tomlTable:
+		return len(v.keys) > 0
+	case []any:
+		for _, elem := range v {
+			if _, ok := elem.(*tomlTable); !ok {
+				return false
+			}
+		}
+		return len(v) > 0
+	}
+	return false
+}
+
+// tomlHasValues reports whether any member of t is written as key = value.
+func tomlHasValues(t *tomlTable) bool {
+	for _, v := range t.vals {
+		if !tomlIsSection(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// header writes a [path] or, for an array of tables, [[path]] line,
+// separated from any earlier content by a blank line.
+func (e *tomlEncoder) header(path []string, array bool) {
+	if len(e.out) > 0 {
+		e.out = append(e.out, '\n')
+	}
+	e.out = append(e.out, '[')
+	if array {
+		e.out = append(e.out, '[')
+	}
+	for i, k := range path {
+		if i > 0 {
+			e.out = append(e.out, '.')
+		}
+		e.out = appendTOMLKey(e.out, k)
+	}
+	e.out = append(e.out, ']')
+	if array {
+		e.out = append(e.out, ']')
+	}
+	e.out = append(e.out, '\n')
+}
+
+// inline writes v as a TOML value on the current line.
+func (e *tomlEncoder) inline(v any) {
+	switch v := v.(type) {
+	case *tomlTable:
+		if len(v.keys) == 0 {
+			e.out = append(e.out, "{}"...)
+			return
+		}
+		e.out = append(e.out, "{ "...)
+		for i, k := range v.keys {
+			if i > 0 {
+				e.out = append(e.out, ", "...)
+			}
+			e.out = appendTOMLKey(e.out, k)
+			e.out = append(e.out, " = "...)
+			e.inline(v.vals[i])
+		}
+		e.out = append(e.out, " }"...)
+	case []any:
+		e.out = append(e.out, '[')
+		for i, elem := range v {
+			if i > 0 {
+				e.out = append(e.out, ", "...)
+			}
+			e.inline(elem)
+		}
+		e.out = append(e.out, ']')
+	case string:
+		e.out = appendTOMLString(e.out, v)
+	case json.Number:
+		e.out = append(e.out, v...)
+	case bool:
+		e.out = strconv.AppendBool(e.out, v)
+	}
+}
+
+// appendTOMLKey appends k as a bare key when it is one and as a quoted key
+// otherwise. The empty key is always quoted, as "".
+func appendTOMLKey(dst []byte, k string) []byte {
+	if k == "" {
+		return append(dst, `""`...)
+	}
+	for i := 0; i < len(k); i++ {
+		c := k[i]
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return appendTOMLBasic(dst, k)
+		}
+	}
+	return append(dst, k...)
+}
+
+// appendTOMLMultiline appends s, which has a line break, as a multi-line
+// basic string. Only key = value lines use them: FromTOML, like TOML 1.0
+// parsers in general, reads inline tables one line at a time.
+func appendTOMLMultiline(dst []byte, s string) []byte {
+	dst = append(dst, `"""`...)
+	dst = append(dst, '\n') // trimmed by the parser; keeps the first line intact
+	dst = appendTOMLEscaped(dst, s, true)
+	return append(dst, `"""`...)
+}
+
+// appendTOMLString appends s as a single-line TOML string: a literal string
+// when it needs escaping as a basic string but has nothing a literal string
+// cannot hold, and a basic string otherwise.
+func appendTOMLString(dst []byte, s string) []byte {
+	switch {
+	case strings.ContainsAny(s, `"\`) && tomlLiteralOK(s):
+		dst = append(dst, '\'')
+		dst = append(dst, s...)
+		return append(dst, '\'')
+	default:
+		return appendTOMLBasic(dst, s)
+	}
+}
+
+// tomlLiteralOK reports whether s fits in a single-line literal string, which
+// has no escapes and so cannot hold a quote or control characters.
+func tomlLiteralOK(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c == '\'' || c < 0x20 && c != '\t' || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func appendTOMLBasic(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	dst = appendTOMLEscaped(dst, s, false)
+	return append(dst, '"')
+}
+
+// appendTOMLEscaped appends s escaped for a basic string. In a multi-line
+// string line feeds and most quotes are written as is.
+func appendTOMLEscaped(dst []byte, s string, multiline bool) []byte {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' && multiline:
+			// FromTOML ends the string at the first """, escaped or not, so
+			// quotes that touch another quote or the closing """ are written
+			// without a quote character.
+			if i+1 == len(s) || s[i+1] == '"' || i > 0 && s[i-1] == '"' {
+				dst = append(dst, `\u0022`...)
+			} else {
+				dst = append(dst, c)
+			}
+		case c == '"' || c == '\\':
+			dst = append(dst, '\\', c)
+		case c == '\n' && multiline:
+			dst = append(dst, c)
+		case c == '\n':
+			dst = append(dst, `\n`...)
+		case c == '\r':
+			dst = append(dst, `\r`...)
+		case c == '\t':
+			dst = append(dst, `\t`...)
+		case c == '\b':
+			dst = append(dst, `\b`...)
+		case c == '\f':
+			dst = append(dst, `\f`...)
+		case c < 0x20 || c == 0x7f:
+			dst = append(dst, `\u00`...)
+			dst = append(dst, "0123456789ABCDEF"[c>>4], "0123456789ABCDEF"[c&0xF])
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,143 @@
+package tojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToTOML(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty", `{}`, ""},
+		{"scalars", `{"name":"web","port":8080,"pi":3.14,"debug":false}`,
+			"name = \"web\"\nport = 8080\npi = 3.14\ndebug = false\n"},
+		{"numbers verbatim", `{"a":1.50,"b":-0,"c":1E+2,"d":18446744073709551615}`,
+			"a = 1.50\nb = -0\nc = 1E+2\nd = 18446744073709551615\n"},
+		{"table", `{"title":"x","server":{"host":"h","port":80}}`,
+			"title = \"x\"\n\n[server]\nhost = \"h\"\nport = 80\n"},
+		{"values before tables", `{"server":{"port":80},"title":"x"}`,
+			"title = \"x\"\n\n[server]\nport = 80\n"},
+		{"implicit table", `{"a":{"b":{"x":1},"c":{"y":2}}}`,
+			"[a.b]\nx = 1\n\n[a.c]\ny = 2\n"},
+		{"array of tables", `{"items":[{"n":1},{"n":2,"tags":["a"]},{}]}`,
+			"[[items]]\nn = 1\n\n[[items]]\nn = 2\ntags = [\"a\"]\n\n[[items]]\n"},
+		{"nested array of tables", `{"a":[{"x":1,"b":[{"y":2}],"c":{"z":3}},{"x":2,"c":{"z":4}}]}`,
+			"[[a]]\nx = 1\n\n[[a.b]]\ny = 2\n\n[a.c]\nz = 3\n\n[[a]]\nx = 2\n\n[a.c]\nz = 4\n"},
+		{"inline arrays", `{"a":[1,[2,3],[]],"b":[]}`, "a = [1, [2, 3], []]\nb = []\n"},
+		{"mixed array", `{"m":[1,"a",{"b":1},{}]}`, "m = [1, \"a\", { b = 1 }, {}]\n"},
+		{"empty table", `{"e":{},"t":{"e":{}}}`, "e = {}\n\n[t]\ne = {}\n"},
+		{"deep tables", `{"a":{"b":{"c":{"d":{"e":{"f":1},"g":[{"h":2}]}}}}}`,
+			"[a.b.c.d.e]\nf = 1\n\n[[a.b.c.d.g]]\nh = 2\n"},
+		{"keys", `{"bare-key_1":1,"a b":2,"a.b":3,"é":4,"q\"":5}`,
+			"bare-key_1 = 1\n\"a b\" = 2\n\"a.b\" = 3\n\"é\" = 4\n\"q\\\"\" = 5\n"},
+		{"empty keys", `{"":1,"a":{"":{"x":2}},"b":[{"":3}],"c":[{"":4},1]}`,
+			"\"\" = 1\nc = [{ \"\" = 4 }, 1]\n\n[a.\"\"]\nx = 2\n\n[[b]]\n\"\" = 3\n"},
+		{"quoted header", `{"a.b":{"c d":{"x":1}}}`, "[\"a.b\".\"c d\"]\nx = 1\n"},
+		{"strings", `{"a":"say \"hi\"","b":"C:\\dir","c":"it's \"x\"","d":"tab\tnul\u0000del\u007f","e":"é"}`,
+			"a = 'say \"hi\"'\nb = 'C:\\dir'\nc = \"it's \\\"x\\\"\"\nd = \"tab\\tnul\\u0000del\\u007F\"\ne = \"é\"\n"},
+		{"multi-line strings", `{"a":"one\ntwo\n","b":"\"q\"\rx\ny\\"}`,
+			"a = \"\"\"\none\ntwo\n\"\"\"\nb = \"\"\"\n\"q\"\\rx\ny\\\\\"\"\"\n"},
+		{"multi-line quote runs", `{"a":"x\n\"\"\"y\"","b":[{"c":"x\ny"}]}`,
+			"a = \"\"\"\nx\n\\u0022\\u0022\\u0022y\\u0022\"\"\"\n\n[[b]]\nc = \"\"\"\nx\ny\"\"\"\n"},
+		{"multi-line inline", `{"a":[1,{"c":"x\ny"}]}`, "a = [1, { c = \"x\\ny\" }]\n"},
+		{"datetime look-alike", `{"d":"1979-05-27T07:32:00Z"}`, "d = \"1979-05-27T07:32:00Z\"\n"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ToTOML([]byte(tc.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+			requireTOMLRoundTrip(t, tc.input, got)
+		})
+	}
+}
+
+func TestToTOMLRoundTripFiles(t *testing.T) {
+	files, err := filepath.Glob("testdata/toml/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		t.Run(filepath.Base(f), func(t *testing.T) {
+			src, err := os.ReadFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(bytes.TrimSpace(src)) == 0 {
+				t.Skip("no expected output")
+			}
+			out, err := ToTOML(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			requireTOMLRoundTrip(t, string(src), out)
+		})
+	}
+}
+
+func TestToTOMLUnsupported(t *testing.T) {
+	tests := []struct {
+		input string
+		line  int
+		col   int
+		msg   string
+	}{
+		{`[1, 2]`, 1, 1, "must be an object"},
+		{` "x"`, 1, 2, "must be an object"},
+		{`{"a": 1, "b": null}`, 1, 15, "no null"},
+		{"{\n  \"a\": [1,\n    null]\n}", 3, 5, "no null"},
+		{`{"n": 123456789012345678901234567890}`, 1, 7, "out of range"},
+		{`{"f": 1e400}`, 1, 7, "out of range"},
+		{`{"a": }`, 1, 7, "invalid JSON"},
+		{`{"a": 1} x`, 1, 10, "invalid JSON"},
+		{``, 1, 1, "invalid JSON"},
+	}
+	for _, tc := range tests {
+		_, err := ToTOML([]byte(tc.input))
+		pe := requireParseError(t, err)
+		if pe.Line != tc.line || pe.Column != tc.col {
+			t.Errorf("%q: got line %d, column %d, want line %d, column %d (%s)", tc.input, pe.Line, pe.Column, tc.line, tc.col, pe.Message)
+		}
+		if !strings.Contains(pe.Message, tc.msg) {
+			t.Errorf("%q: got message %q, want it to contain %q", tc.input, pe.Message, tc.msg)
+		}
+	}
+}
+
+// requireTOMLRoundTrip checks that FromTOML reads toml back as the JSON input.
+// Key order is not compared, since ToTOML writes values before tables.
+func requireTOMLRoundTrip(t *testing.T, input string, toml []byte) {
+	t.Helper()
+	got, err := FromTOML(toml)
+	if err != nil {
+		t.Fatalf("FromTOML: %v\n%s", err, toml)
+	}
+	want, have := decodeJSONNumbers(t, []byte(input)), decodeJSONNumbers(t, got)
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("round trip:\n got %s\nwant %s\nTOML:\n%s", got, input, toml)
+	}
+}
+
+// decodeJSONNumbers decodes data keeping numbers as their source text.
+func decodeJSONNumbers(t *testing.T, data []byte) any {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("invalid JSON %q: %v", data, err)
+	}
+	return v
+}
@@ -1,12 +1,20 @@
 // toml_line.go — TOML→JSON converter using a top-level line-by-line state machine.
 //
 // A single outer loop lazily scans newlines and drives a four-state machine.
-// Multiline constructs ("""...""", '''...''', multi-line inline arrays)
+// Multiline constructs ("""...""", '''...''', multi-line inline arrays, and
+// under TOML 1.1 multi-line inline tables)
 // accumulate content across iterations rather than pulling lines from a
 // pre-split slice inside helper functions.
 //
 // TOML's root is always a table (the spec defines the document as a hash table),
 // so the output always begins and ends with { }.
+//
+// A header that re-enters a table closed by an earlier header writes into a
+// tomlSplice placed before that table's '}', and the splices are put in place
+// once the input is consumed, so most out-of-order files take one pass too.
+// The rest fall back to the tree converter: a dotted key that re-enters a
+// table an earlier dotted key opened, a header naming a sub-table of such a
+// table, and any re-entry while a source map is being recorded.
 
 package tojson
 
@@ -20,33 +28,36 @@ const (
 	tomlStateMLBasic            // accumulating a """...""" basic string
 	tomlStateMLLiteral          // accumulating a '''...''' literal string
 	tomlStateInlineArray        // accumulating a [...] inline array spanning lines
+	tomlStateInlineTable        // accumulating a {...} inline table spanning lines (TOML 1.1)
 )
 
 // multilineStart reports whether the TOML value s requires more than one line,
 // returning the state to enter for accumulation. Returns (false, 0) when the value
-// fits on the current line and can be written immediately.
+// fits on the current line and can be written immediately. An inline table
+// spans lines under TOML 1.0 too: a newline may fall inside an array or
+// string that it holds, and the inline table parser rejects any other.
 func multilineStart(s []byte) (bool, int) {
 	switch {
 	case len(s) >= 3 && s[0] == '"' && s[1] == '"' && s[2] == '"':
-		if !bytes.Contains(s[3:], []byte(`"""`)) {
+		if tomlMultilineEnd(s[3:], '"') < 0 {
 			return true, tomlStateMLBasic
 		}
 	case len(s) >= 3 && s[0] == '\'' && s[1] == '\'' && s[2] == '\'':
 		if !bytes.Contains(s[3:], []byte("'''")) {
 			return true, tomlStateMLLiteral
 		}
-	case len(s) > 0 && s[0] == '[':
-		if s[len(s)-1] != ']' {
+	case len(s) > 0 && (s[0] == '[' || s[0] == '{'):
+		var sc tomlBracketScan
+		if sc.end(s) < 0 {
+			if s[0] == '{' {
+				return true, tomlStateInlineTable
+			}
 			return true, tomlStateInlineArray
 		}
 	}
 	return false, 0
 }
 
-// tomlMaxNesting is the maximum number of table-header levels ([a.b.c.d] = 4).
-// Inputs that nest deeper return an error rather than allocating unboundedly.
-const tomlMaxNesting = 4
-
 // dotPath joins the segments of path with '.' for use in human-readable
 // error messages. Called only on error paths; do not use on the hot path.
 func dotPath(path [][]byte) string {
@@ -59,38 +70,42 @@ func dotPath(path [][]byte) string {
 // tomlLineParser holds the mutable state shared across methods during a single
 // fromTOMLLine call.
 //
-// The parser maintains two parallel stacks. stackBuf/stackLen tracks the
-// section nesting introduced by [table] and [[array]] headers; entries are
+// The parser maintains two parallel stacks. stack tracks the section
+// nesting introduced by [table] and [[array]] headers; entries are
 // tomlFrame values keyed by header segment. inlineKeys/inlineComma/inlineUsed
 // track dotted-key prefixes opened on the current line (e.g. a.b.c = 1 opens
 // the temporary inline objects for a and a.b) and are collapsed back to depth
 // zero before any new header or bare key/value pair is processed.
 //
 // state, accumStart, and startLine drive the multi-line value sub-state
-// machine: when a value begins with """, ”', or an unterminated [,
+// machine: when a value begins with """, ”', an unterminated [, or under
+// TOML 1.1 an unterminated {,
 // accumStart records the byte offset in the input slice where the value's
 // content begins; the outer loop continues consuming lines until the
 // matching terminator is found, at which point input[accumStart:lineEnd]
 // is parsed and emitted as a single JSON value.
-// arrayDepth/arrayDouble/arraySingle are the bookkeeping fields used by
-// scanArrayLine to track bracket nesting and quoted regions while in the
-// tomlStateInlineArray state.
+// brackets tracks bracket nesting and quoted regions while in the
+// tomlStateInlineArray and tomlStateInlineTable states.
 type tomlLineParser struct {
-	buf         bytes.Buffer
-	input       []byte                        // the source slice; sliced on terminator to feed the multi-line parsers
-	stackBuf    [tomlMaxNesting + 1]tomlFrame // fixed backing; index 0 is the root frame
-	stackLen    int                           // number of active frames in stackBuf
+	out         *bytes.Buffer // where the top frame's entries go: main, or the buffer of the frame's splice
+	main        *bytes.Buffer // the output
+	splices     []*tomlSplice // splices into main
+	input       []byte        // the source slice; sliced on terminator to feed the multi-line parsers
+	stack       tomlStack     // open sections, held in the parser while shallow
 	closed      tomlClosedTables
 	inlineKeys  [][]byte
 	inlineComma []bool
-	inlineUsed  [][][]byte
+	inlineUsed  [][]tomlKey
 	state       int
 	accumStart  int
 	startLine   int
 	startCol    int // 0-based column of the first byte of the multi-line value, for error attribution
-	arrayDepth  int
-	arrayDouble bool
-	arraySingle bool
+	resume      int // offset of the line after an inline array or table's first line, where recovery resumes
+	brackets    tomlBracketScan
+	sm          *srcMap     // when non-nil, receives the source of each key and value; kept by reset
+	opts        TOMLOptions // kept by reset
+	errs        errorList
+	skipping    bool // after an error, until the next header or key/value line
 }
 
 // topNC reports whether the innermost open container — an inline dotted-key
@@ -100,7 +115,7 @@ func (p *tomlLineParser) topNC() bool {
 	if len(p.inlineKeys) > 0 {
 		return p.inlineComma[len(p.inlineComma)-1]
 	}
-	return p.stackBuf[p.stackLen-1].needComma
+	return p.stack.top().needComma
 }
 
 // setTopNC sets the needs-comma flag on whichever container topNC inspects.
@@ -108,27 +123,32 @@ func (p *tomlLineParser) setTopNC(v bool) {
 	if len(p.inlineKeys) > 0 {
 		p.inlineComma[len(p.inlineComma)-1] = v
 	} else {
-		p.stackBuf[p.stackLen-1].needComma = v
+		p.stack.top().needComma = v
 	}
 }
 
 // markKey records key as used in the innermost open container and returns an
-// error if the same key has already been seen there. The lookup is a linear
-// bytes.Equal scan; TOML tables are typically small enough that this beats
-// hashing.
-func (p *tomlLineParser) markKey(key []byte) error {
-	var keys *[][]byte
+// error if the same key has already been seen there. dotted reports that key
+// opens a table for a dotted key; reopening one that an earlier dotted key
+// opened is valid TOML that the line parser cannot write, so it returns
+// errReentry. The lookup is a linear bytes.Equal scan; TOML tables are
+// typically small enough that this beats hashing.
+func (p *tomlLineParser) markKey(key []byte, dotted bool) error {
+	var keys *[]tomlKey
 	if len(p.inlineKeys) > 0 {
 		keys = &p.inlineUsed[len(p.inlineUsed)-1]
 	} else {
-		keys = &p.stackBuf[p.stackLen-1].usedKeys
+		keys = &p.stack.top().usedKeys
 	}
 	for _, k := range *keys {
-		if bytes.Equal(k, key) {
+		if bytes.Equal(k.name, key) {
+			if k.dotted && dotted {
+				return errReentry
+			}
 			return fmt.Errorf("duplicate key %q", key)
 		}
 	}
-	*keys = append(*keys, key)
+	*keys = append(*keys, tomlKey{key, dotted})
 	return nil
 }
 
@@ -137,152 +157,260 @@ func (p *tomlLineParser) markKey(key []byte) error {
 // entirely — required before any new header or top-level key/value pair.
 func (p *tomlLineParser) closeInlineTo(depth int) {
 	for len(p.inlineKeys) > depth {
-		p.buf.WriteByte('}')
+		p.out.WriteByte('}')
 		p.inlineKeys = p.inlineKeys[:len(p.inlineKeys)-1]
 		p.inlineComma = p.inlineComma[:len(p.inlineComma)-1]
 		p.inlineUsed = p.inlineUsed[:len(p.inlineUsed)-1]
 	}
 }
 
-// closeSectionsTo pops section frames until stackLen equals depth, emitting
-// '}' (or '}]' for an array-of-tables frame) for each one and recording the
-// closed paths in p.closed so future re-entry can be detected.
-func (p *tomlLineParser) closeSectionsTo(depth int) {
-	for p.stackLen > depth {
-		top := p.stackBuf[p.stackLen-1]
-		p.closed.mark(p.stackBuf[:p.stackLen])
-		p.stackLen--
-		if top.isAoT {
-			p.buf.WriteString("}]")
-		} else {
-			p.buf.WriteByte('}')
-		}
+// closeSectionsTo pops section frames until stack.n equals depth, emitting
+// each one's closer and, if record is set, recording the closed paths in
+// p.closed so that a later header can re-enter them. Nothing follows the
+// sections closed at the end of the document, so those are not recorded.
+func (p *tomlLineParser) closeSectionsTo(depth int, record bool) {
+	for p.stack.n > depth {
+		top := p.stack.top()
+		end := tomlPos{top.dst, p.out.Len()}
+		if top.closer != "" {
+			// The element's '}' comes first; ']' follows it or, for a
+			// re-entered frame, is already in place after the splice.
+			top.arrEnd = tomlPos{top.dst, end.off + 1}
+		}
+		if record {
+			p.closed.mark(&p.stack, end)
+		}
+		p.out.WriteString(top.closer)
+		p.stack.n--
+		p.out = p.bufOf(p.stack.top().dst)
+	}
+}
+
+// bufOf returns the buffer that text bound for dst is written to.
+func (p *tomlLineParser) bufOf(dst *tomlSplice) *bytes.Buffer {
+	if dst == nil {
+		return p.main
 	}
+	return &dst.buf
 }
 
-// currentSectionIs reports whether the open section stack (excluding the root
-// frame at index 0) names exactly path. Used to detect a sibling [[a.b]]
-// header that should append a new element to an existing array of tables
-// rather than open a fresh nested chain.
-func (p *tomlLineParser) currentSectionIs(path [][]byte) bool {
-	if len(path) != p.stackLen-1 {
-		return false
+// spliceAt returns the splice at pos, creating it if there is none yet.
+func (p *tomlLineParser) spliceAt(pos tomlPos) *tomlSplice {
+	list := &p.splices
+	if pos.dst != nil {
+		list = &pos.dst.children
 	}
-	for i := range path {
-		if !bytes.Equal(p.stackBuf[i+1].key, path[i]) {
-			return false
+	for _, s := range *list {
+		if s.at == pos.off {
+			return s
 		}
 	}
-	return true
+	s := &tomlSplice{at: pos.off}
+	*list = append(*list, s)
+	return s
+}
+
+// reopen pushes a frame for the table that node records as closed. Its '}'
+// (and for an array of tables, ']') is already in the output, so the frame
+// writes into a splice just before it and its closer is empty; starting a
+// further array element sets the closer to "}".
+func (p *tomlLineParser) reopen(node *tomlClosedNode) {
+	node.closed = false
+	frame := p.stack.push()
+	if frame.usedKeys != nil {
+		p.closed.free = append(p.closed.free, frame.usedKeys[:0])
+	}
+	*frame = tomlFrame{
+		key:       node.key,
+		isAoT:     node.isAoT,
+		explicit:  node.explicit,
+		needComma: node.needComma,
+		usedKeys:  node.usedKeys,
+		dst:       p.spliceAt(node.end),
+		arrEnd:    node.arrEnd,
+	}
+	node.usedKeys = nil // the frame's again
+	p.out = &frame.dst.buf
+}
+
+// markKeySrc records key, or when key was decoded from escapes the whole key
+// path it came from, as the source of the key about to be written.
+func (p *tomlLineParser) markKeySrc(key, path []byte) {
+	if p.sm != nil && !p.sm.keyOf(p.out, key) {
+		p.sm.keyOf(p.out, path)
+	}
+}
+
+// accumEnd returns the end offset of a multi-line value whose final line is
+// line, ending at lineEnd, excluding any trailing comment and whitespace.
+func (p *tomlLineParser) accumEnd(line []byte, lineEnd int) int {
+	content := stripInlineComment(bytes.TrimRight(line, " \t\r"))
+	return lineEnd - len(line) + len(content)
 }
 
 // openSection brings the section stack into the state required by a
 // [path] or [[path]] header. It computes the longest common prefix with the
-// currently open stack, closes the divergent suffix, then opens any newly
-// named segments — emitting the appropriate JSON punctuation as it goes.
+// currently open stack, closes the divergent suffix, re-enters any tables on
+// path that earlier headers closed, then opens any newly named segments —
+// emitting the appropriate JSON punctuation as it goes.
 //
-// For [[...]] headers whose path matches the current AoT frame exactly,
-// openSection emits "},{" to start a new array element instead of opening
-// a fresh chain. Returns errReentry if path would re-open a table that has
-// already been closed by a prior header.
-func (p *tomlLineParser) openSection(path [][]byte, isAoT bool) error {
-	if isAoT && p.stackLen > 1 {
-		top := &p.stackBuf[p.stackLen-1]
-		if top.isAoT && p.currentSectionIs(path) {
-			p.buf.WriteString("},{")
-			top.needComma = false
-			top.usedKeys = top.usedKeys[:0]
-			return nil
-		}
-	}
+// For [[...]] headers whose path names an open AoT frame, openSection closes
+// anything nested in it and emits "},{" to start a new array element instead
+// of opening a fresh chain. Re-entering a table returns errReentry when a
+// source map is being recorded, since its offsets cannot follow the splices.
+func (p *tomlLineParser) openSection(path [][]byte, hdr []byte, isAoT bool) error {
 	cd := 0
-	for cd < len(path) && cd+1 < p.stackLen {
-		if !bytes.Equal(p.stackBuf[cd+1].key, path[cd]) {
+	for cd < len(path) && cd+1 < p.stack.n {
+		if !bytes.Equal(p.stack.at(cd+1).key, path[cd]) {
 			break
 		}
 		cd++
 	}
-	if p.closed.reopens(path, cd) {
-		return errReentry
+	p.closeSectionsTo(cd+1, true)
+	for ; cd < len(path); cd++ {
+		node := p.closed.lookup(path[:cd+1])
+		if node == nil {
+			break
+		}
+		if p.sm != nil {
+			return errReentry
+		}
+		p.reopen(node)
 	}
-	p.closeSectionsTo(cd + 1)
 	if cd == len(path) {
-		frame := &p.stackBuf[p.stackLen-1]
-		if !isAoT {
-			if frame.explicit {
-				return fmt.Errorf("duplicate table header [%s]", dotPath(path))
+		frame := p.stack.top()
+		if isAoT && frame.isAoT {
+			// A sibling [[path]]: start the next element, closing any
+			// sub-tables of the previous one above.
+			if frame.closer == "" {
+				// Re-entered: the previous element's '}' is in place.
+				frame.dst = p.spliceAt(frame.arrEnd)
+				frame.closer = "}"
+				p.out = &frame.dst.buf
+				p.out.WriteByte(',')
+			} else {
+				p.out.WriteString("},")
 			}
-			frame.explicit = true
+			p.sm.openOf(p.out, hdr)
+			p.out.WriteByte('{')
+			frame.needComma = false
+			frame.usedKeys = frame.usedKeys[:0]
+			p.closed.forget(&p.stack)
+			return nil
 		}
+		if isAoT {
+			return fmt.Errorf("cannot use [[%s]]: key already exists as a non-array", dotPath(path))
+		}
+		if frame.isAoT && p.opts.Strict {
+			return fmt.Errorf("cannot use [%s]: key already exists as an array of tables", dotPath(path))
+		}
+		if frame.explicit {
+			return fmt.Errorf("duplicate table header [%s]", dotPath(path))
+		}
+		frame.explicit = true
 		return nil
 	}
 	for i := cd; i < len(path); i++ {
-		top := &p.stackBuf[p.stackLen-1]
+		top := p.stack.top()
 		for _, k := range top.usedKeys {
-			if bytes.Equal(k, path[i]) {
-				return fmt.Errorf("cannot define table %q: key already has a value", dotPath(path[:i+1]))
+			if !bytes.Equal(k.name, path[i]) {
+				continue
 			}
+			if k.dotted && i < len(path)-1 {
+				// A sub-table of a table that dotted keys opened.
+				return errReentry
+			}
+			return fmt.Errorf("cannot define table %q: key already has a value", dotPath(path[:i+1]))
 		}
-		top.usedKeys = append(top.usedKeys, path[i])
+		top.usedKeys = append(top.usedKeys, tomlKey{name: path[i]})
 		if top.needComma {
-			p.buf.WriteByte(',')
+			p.out.WriteByte(',')
 		}
-		writeJSONString(path[i], &p.buf)
-		p.buf.WriteByte(':')
+		p.markKeySrc(path[i], hdr)
+		writeTOMLKey(path[i], p.out)
+		p.out.WriteByte(':')
 		isAoTFrame := i == len(path)-1 && isAoT
+		closer := "}"
 		if isAoTFrame {
-			p.buf.WriteString("[{")
-		} else {
-			p.buf.WriteByte('{')
+			p.sm.openOf(p.out, hdr)
+			p.out.WriteByte('[')
+			closer = "}]"
 		}
+		p.sm.openOf(p.out, hdr)
+		p.out.WriteByte('{')
 		top.needComma = true
-		if p.stackLen >= len(p.stackBuf) {
-			return fmt.Errorf("table nesting exceeds maximum depth of %d", tomlMaxNesting)
-		}
-		p.stackBuf[p.stackLen] = tomlFrame{
+		dst := top.dst
+		frame := p.stack.push()
+		*frame = tomlFrame{
 			key:      path[i],
 			isAoT:    isAoTFrame,
 			explicit: i == len(path)-1 && !isAoT,
+			usedKeys: frame.usedKeys[:0],
+			dst:      dst,
+			closer:   closer,
 		}
-		p.stackLen++
 	}
 	return nil
 }
 
-// scanArrayLine advances the inline-array parse state by scanning b,
-// returning true when the top-level ']' is reached (arrayDepth → 0).
-// A '#' outside any quoted region starts a comment that runs to the end
-// of b, so brackets and quotes inside the comment are ignored.
-func (p *tomlLineParser) scanArrayLine(b []byte) bool {
+// tomlBracketScan tracks bracket nesting and quoted regions, including
+// multi-line strings, across the lines of a multi-line inline array or table.
+type tomlBracketScan struct {
+	depth  int
+	quote  byte // the quote of the string being scanned, or 0
+	triple bool // the string is multi-line
+}
+
+// scan advances the state by scanning b, returning true when the outermost
+// '[' or '{' is closed (depth → 0).
+func (sc *tomlBracketScan) scan(b []byte) bool {
+	return sc.end(b) >= 0
+}
+
+// end advances the state by scanning b and returns the index just past the
+// bracket that closes the outermost '[' or '{', or -1 if b does not close
+// it. A '#' outside any string starts a comment that runs to the end of its
+// line, so brackets and quotes inside the comment are ignored.
+func (sc *tomlBracketScan) end(b []byte) int {
 	for i := 0; i < len(b); i++ {
 		c := b[i]
 		switch {
-		case p.arrayDouble:
-			if c == '\\' {
+		case sc.quote != 0:
+			switch {
+			case c == '\\' && sc.quote == '"':
 				i++
-			} else if c == '"' {
-				p.arrayDouble = false
-			}
-		case p.arraySingle:
-			if c == '\'' {
-				p.arraySingle = false
+			case c != sc.quote:
+			case !sc.triple:
+				sc.quote = 0
+			case i+2 < len(b) && b[i+1] == c && b[i+2] == c:
+				// Up to two more quotes belong to the string, as in """a"""".
+				n := i + 3
+				for n < i+5 && n < len(b) && b[n] == c {
+					n++
+				}
+				i = n - 1
+				sc.quote = 0
 			}
 		case c == '#':
-			return false
-		case c == '"':
-			p.arrayDouble = true
-		case c == '\'':
-			p.arraySingle = true
-		case c == '[':
-			p.arrayDepth++
-		case c == ']':
-			p.arrayDepth--
-			if p.arrayDepth == 0 {
-				return true
+			for i < len(b) && b[i] != '\n' {
+				i++
+			}
+		case c == '"', c == '\'':
+			sc.quote = c
+			sc.triple = i+2 < len(b) && b[i+1] == c && b[i+2] == c
+			if sc.triple {
+				i += 2
+			}
+		case c == '[', c == '{':
+			sc.depth++
+		case c == ']', c == '}':
+			sc.depth--
+			if sc.depth == 0 {
+				return i + 1
 			}
 		}
 	}
-	return false
+	return -1
 }
 
 // finishAccumValue marks the just-emitted value as present and returns the
@@ -306,14 +434,19 @@ func (p *tomlLineParser) handleAccumLine(line []byte, lineEnd int) (bool, error)
 	case tomlStateNormal:
 		return false, nil
 	case tomlStateMLBasic:
-		if !bytes.Contains(line, []byte(`"""`)) {
+		// The quick test skips most lines; the full one finds an escaped \""".
+		if !bytes.Contains(line, []byte(`"""`)) || tomlMultilineEnd(p.input[p.accumStart+3:lineEnd], '"') < 0 {
 			return true, nil
 		}
-		str, _, err := parseTOMLMultilineBasic(p.input[p.accumStart:lineEnd], nil, 0)
+		str, _, err := parseTOMLMultilineBasic(p.input[p.accumStart:lineEnd], nil, 0, &p.opts)
+		if err == nil {
+			err = p.checkAccumEnd(lineEnd)
+		}
 		if err != nil {
 			return true, atLineCol(p.startLine, p.startCol, err)
 		}
-		writeJSONString(str, &p.buf)
+		p.sm.value(p.out, p.accumStart, p.accumEnd(line, lineEnd))
+		writeTOMLString(str, p.out, &p.opts)
 		p.finishAccumValue()
 		return true, nil
 	case tomlStateMLLiteral:
@@ -321,19 +454,36 @@ func (p *tomlLineParser) handleAccumLine(line []byte, lineEnd int) (bool, error)
 			return true, nil
 		}
 		str, _, err := parseTOMLMultilineLiteral(p.input[p.accumStart:lineEnd], nil, 0)
+		if err == nil {
+			err = p.checkAccumEnd(lineEnd)
+		}
 		if err != nil {
 			return true, atLineCol(p.startLine, p.startCol, err)
 		}
-		writeJSONString(str, &p.buf)
+		p.sm.value(p.out, p.accumStart, p.accumEnd(line, lineEnd))
+		writeTOMLString(str, p.out, &p.opts)
 		p.finishAccumValue()
 		return true, nil
 	case tomlStateInlineArray:
-		if !p.scanArrayLine(line) {
+		if !p.brackets.scan(line) {
 			return true, nil
 		}
-		if _, err := writeTOMLInlineArray(p.input[p.accumStart:lineEnd], nil, 0, &p.buf); err != nil {
+		if err := p.checkAccumEnd(lineEnd); err != nil {
 			return true, atLineCol(p.startLine, p.startCol, err)
 		}
+		p.sm.value(p.out, p.accumStart, p.accumEnd(line, lineEnd))
+		if _, err := writeTOMLInlineArray(p.input[p.accumStart:lineEnd], nil, 0, p.out, p.sm, &p.opts); err != nil {
+			return true, atTOMLValue(p.input, p.startLine, p.startCol, err)
+		}
+		p.finishAccumValue()
+		return true, nil
+	case tomlStateInlineTable:
+		if !p.brackets.scan(line) {
+			return true, nil
+		}
+		if _, err := writeTOMLValue(p.input[p.accumStart:lineEnd], nil, 0, p.out, p.sm, &p.opts); err != nil {
+			return true, atTOMLValue(p.input, p.startLine, p.startCol, err)
+		}
 		p.finishAccumValue()
 		return true, nil
 	default:
@@ -341,6 +491,15 @@ func (p *tomlLineParser) handleAccumLine(line []byte, lineEnd int) (bool, error)
 	}
 }
 
+// checkAccumEnd reports, when opts.Strict is set, any text after the end of
+// the multi-line value that input[p.accumStart:lineEnd] holds.
+func (p *tomlLineParser) checkAccumEnd(lineEnd int) error {
+	if !p.opts.Strict {
+		return nil
+	}
+	return checkTOMLValueEnd(p.input[p.accumStart:lineEnd])
+}
+
 // handleHeader parses a [table] or [[array.of.tables]] header and updates
 // the section stack accordingly. trimmed is the line with surrounding
 // whitespace removed; lineNum and leading are used to attach source
@@ -362,17 +521,17 @@ func (p *tomlLineParser) handleHeader(trimmed []byte, lineNum, leading int, path
 		inner = trimmed[1 : len(trimmed)-1]
 	}
 
-	path, rest, err := parseTOMLKeyPath(inner, pathBuf[:0])
+	path, rest, err := parseTOMLKeyPath(inner, pathBuf[:0], &p.opts)
 	if err != nil {
 		return atLineCol(lineNum, leading, err)
 	}
-	if rest = bytes.TrimSpace(rest); len(rest) != 0 {
+	if rest = trimTOMLSpace(rest); len(rest) != 0 {
 		if isAoT {
 			return atLineCol(lineNum, leading, fmt.Errorf("unexpected content after [[header]]: %s", rest))
 		}
 		return atLineCol(lineNum, leading, fmt.Errorf("unexpected content after [header]: %s", rest))
 	}
-	if err := p.openSection(path, isAoT); err != nil {
+	if err := p.openSection(path, trimmed, isAoT); err != nil {
 		if err == errReentry {
 			return err
 		}
@@ -410,38 +569,78 @@ func tomlBareKeyValue(trimmed []byte) (key, rest []byte, ok bool) {
 // inline objects for every prefix segment, marks the leaf key as used in its
 // parent, then writes the value.
 func (p *tomlLineParser) handleDottedKeyValue(trimmed []byte, lineNum, leading int, pathBuf *[4][]byte) error {
-	path, rest, err := parseTOMLKeyPath(trimmed, pathBuf[:0])
+	path, rest, err := parseTOMLKeyPath(trimmed, pathBuf[:0], &p.opts)
 	if err != nil {
 		return atLineCol(lineNum, leading, err)
 	}
-	rest = bytes.TrimSpace(rest)
+	keySrc := trimTOMLSpace(trimmed[:len(trimmed)-len(rest)])
+	rest = trimTOMLSpace(rest)
 	if len(rest) == 0 || rest[0] != '=' {
 		return atLineCol(lineNum, leading, fmt.Errorf("expected '=' after key, got: %s", rest))
 	}
-	rest = bytes.TrimSpace(rest[1:])
+	rest = trimTOMLSpace(rest[1:])
 	valCol := leading + len(trimmed) - len(rest)
 
 	lastKey := path[len(path)-1]
 	prefix := path[:len(path)-1]
-	if err := p.openInlinePrefix(prefix, lineNum, leading); err != nil {
+	if err := p.openInlinePrefix(prefix, keySrc, lineNum, leading); err != nil {
 		return err
 	}
-	if err := p.markKey(lastKey); err != nil {
+	if err := p.markKey(lastKey, false); err != nil {
 		return atLineCol(lineNum, leading, err)
 	}
 	if p.topNC() {
-		p.buf.WriteByte(',')
+		p.out.WriteByte(',')
 	}
-	writeJSONString(lastKey, &p.buf)
-	p.buf.WriteByte(':')
+	p.markKeySrc(lastKey, keySrc)
+	writeTOMLKey(lastKey, p.out)
+	p.out.WriteByte(':')
 	return p.writeValue(rest, lineNum, valCol)
 }
 
+// handleBareKeyValue handles a key/value line whose key is a single bare
+// key, the common case, without parsing a key path.
+func (p *tomlLineParser) handleBareKeyValue(key, rest, trimmed []byte, lineNum, leading int) error {
+	p.closeInlineTo(0)
+	if err := p.markKey(key, false); err != nil {
+		return atLineCol(lineNum, leading, err)
+	}
+	if p.topNC() {
+		p.out.WriteByte(',')
+	}
+	p.sm.keyOf(p.out, key)
+	writeTOMLKey(key, p.out)
+	p.out.WriteByte(':')
+	valCol := leading + len(trimmed) - len(rest)
+	if ml, mlState := multilineStart(rest); ml {
+		p.startMultilineValue(rest, lineNum, valCol, mlState)
+		return nil
+	}
+	if _, err := writeTOMLValue(rest, nil, 0, p.out, p.sm, &p.opts); err != nil {
+		return atLineCol(lineNum, valCol, err)
+	}
+	p.setTopNC(true)
+	return nil
+}
+
+// tomlResyncPoint reports whether trimmed, a line stripped of whitespace and
+// comments, starts a header or a key/value pair, where conversion resumes
+// after an error.
+func tomlResyncPoint(trimmed []byte) bool {
+	if trimmed[0] == '[' {
+		return true
+	}
+	var pathBuf [4][]byte
+	// Either version's keys will do for finding the next key/value line.
+	_, rest, err := parseTOMLKeyPath(trimmed, pathBuf[:0], &TOMLOptions{Version: TOML11})
+	return err == nil && len(rest) > 0 && rest[0] == '='
+}
+
 // openInlinePrefix ensures the inline-object stack matches prefix exactly,
 // closing any divergent suffix and opening any missing segments. Each newly
 // opened segment is marked as a used key in its parent so a later attempt to
 // redefine it as a scalar (or vice versa) is rejected.
-func (p *tomlLineParser) openInlinePrefix(prefix [][]byte, lineNum, leading int) error {
+func (p *tomlLineParser) openInlinePrefix(prefix [][]byte, keySrc []byte, lineNum, leading int) error {
 	if len(prefix) == 0 {
 		p.closeInlineTo(0)
 		return nil
@@ -456,19 +655,30 @@ func (p *tomlLineParser) openInlinePrefix(prefix [][]byte, lineNum, leading int)
 	}
 	p.closeInlineTo(cd)
 	for i := cd; i < len(prefix); i++ {
-		if err := p.markKey(prefix[i]); err != nil {
+		if err := p.markKey(prefix[i], true); err == errReentry {
+			return err
+		} else if err != nil {
 			return atLineCol(lineNum, leading, err)
 		}
 		if p.topNC() {
-			p.buf.WriteByte(',')
+			p.out.WriteByte(',')
 		}
-		writeJSONString(prefix[i], &p.buf)
-		p.buf.WriteByte(':')
-		p.buf.WriteByte('{')
+		p.markKeySrc(prefix[i], keySrc)
+		writeTOMLKey(prefix[i], p.out)
+		p.out.WriteByte(':')
+		p.sm.openOf(p.out, keySrc)
+		p.out.WriteByte('{')
 		p.setTopNC(true)
 		p.inlineKeys = append(p.inlineKeys, prefix[i])
 		p.inlineComma = append(p.inlineComma, false)
-		p.inlineUsed = append(p.inlineUsed, nil)
+		// Reuse the used-key slice left behind by an earlier, deeper prefix.
+		n := len(p.inlineUsed)
+		if n < cap(p.inlineUsed) {
+			p.inlineUsed = p.inlineUsed[:n+1]
+			p.inlineUsed[n] = p.inlineUsed[n][:0]
+		} else {
+			p.inlineUsed = append(p.inlineUsed, nil)
+		}
 	}
 	return nil
 }
@@ -488,12 +698,32 @@ func (p *tomlLineParser) startMultilineValue(rest []byte, lineNum, valCol, mlSta
 	p.state = mlState
 	p.startLine = lineNum
 	p.startCol = valCol
-	if mlState == tomlStateInlineArray {
-		p.arrayDepth, p.arrayDouble, p.arraySingle = 0, false, false
-		p.scanArrayLine(rest)
+	if mlState == tomlStateInlineArray || mlState == tomlStateInlineTable {
+		p.brackets = tomlBracketScan{}
+		p.brackets.scan(rest)
+		p.resume = len(p.input)
+		if i := bytes.IndexByte(p.input[p.accumStart:], '\n'); i >= 0 {
+			p.resume = p.accumStart + i + 1
+		}
 	}
 }
 
+// recoverAccum ends a multi-line value that is in error and returns the
+// offset and 0-based number of the line before the one where conversion
+// resumes, skipping to the next header or key/value line. An inline array
+// or table resumes on the line after its first, so that one left open does
+// not swallow the rest of the document; a string resumes after the line
+// where the error was found, pos and lineNum.
+func (p *tomlLineParser) recoverAccum(pos, lineNum int) (int, int) {
+	state := p.state
+	p.state = tomlStateNormal
+	if state != tomlStateInlineArray && state != tomlStateInlineTable {
+		return pos, lineNum
+	}
+	p.skipping = true
+	return p.resume, p.startLine
+}
+
 // writeValue emits the JSON encoding of a TOML value. If rest opens a
 // multi-line construct the parser transitions into the matching accumulation
 // state instead and emits nothing until the terminator arrives. valCol is
@@ -503,27 +733,102 @@ func (p *tomlLineParser) writeValue(rest []byte, lineNum, valCol int) error {
 		p.startMultilineValue(rest, lineNum, valCol, mlState)
 		return nil
 	}
-	if _, err := writeTOMLValue(rest, nil, 0, &p.buf); err != nil {
+	if _, err := writeTOMLValue(rest, nil, 0, p.out, p.sm, &p.opts); err != nil {
 		return atLineCol(lineNum, valCol, err)
 	}
 	p.setTopNC(true)
 	return nil
 }
 
-// fromTOMLLine is the entry point for the line-based TOML→JSON converter.
-// A returned errReentry indicates the caller should fall back to a stricter
-// parser that handles out-of-order table re-entry.
+// fromTOMLLine converts input with the line-based converter alone and returns
+// the JSON document in a fresh buffer.
 func fromTOMLLine(input []byte) ([]byte, error) {
-	p := &tomlLineParser{stackLen: 1} // stackBuf[0] is the root frame, zero-initialised
-	p.buf.Grow(len(input))
-	p.buf.WriteByte('{')
-	return p.convert(input)
+	var buf bytes.Buffer
+	buf.Grow(len(input))
+	if err := tomlConvertLine(&buf, input); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unterminated reports the multi-line value still open at the end of the
+// input.
+func (p *tomlLineParser) unterminated() error {
+	what := "multiline string"
+	switch p.state {
+	case tomlStateInlineArray:
+		what = "inline array"
+	case tomlStateInlineTable:
+		what = "inline table"
+	}
+	return atLineCol(p.startLine, p.startCol, fmt.Errorf("unterminated %s", what))
+}
+
+// tomlConvertLine is the entry point for the line-based TOML→JSON converter.
+// It appends the JSON document to out.
+func tomlConvertLine(out *bytes.Buffer, input []byte) error {
+	p := &tomlLineParser{}
+	return p.run(out, input)
+}
+
+// run resets p and converts input, appending the JSON document to out. p may
+// be a zero parser or one retained from an earlier call. A returned
+// errReentry indicates the caller should fall back to the tree converter to
+// record the source map.
+func (p *tomlLineParser) run(out *bytes.Buffer, input []byte) error {
+	start := out.Len()
+	if p.opts.Strict {
+		if err := checkTOMLText(input); err != nil {
+			return err
+		}
+	}
+	p.reset(out)
+	p.sm.value(p.out, 0, 0)
+	p.out.WriteByte('{')
+	err := p.convert(input)
+	if err == errReentry {
+		return err
+	}
+	if err = p.errs.result(err); err == nil && len(p.splices) > 0 {
+		tail := bytes.Clone(out.Bytes()[start:])
+		out.Truncate(start)
+		writeSpliced(out, tail, start, p.splices)
+	}
+	return err
+}
+
+// reset returns p to its initial state with out as the destination, keeping
+// the capacity of the key and inline-prefix slices from any earlier call.
+// The root frame is pushed. The source map and options are kept.
+func (p *tomlLineParser) reset(out *bytes.Buffer) {
+	p.stack.reset()
+	for i := range p.inlineUsed[:cap(p.inlineUsed)] {
+		clear(p.inlineUsed[:cap(p.inlineUsed)][i])
+	}
+	clear(p.inlineKeys)
+	p.closed.release(&p.closed.root)
+	clear(p.closed.root.children)
+	*p = tomlLineParser{
+		out:   out,
+		main:  out,
+		stack: p.stack,
+		closed: tomlClosedTables{
+			root: tomlClosedNode{children: p.closed.root.children[:0]},
+			free: p.closed.free,
+		},
+		inlineKeys:  p.inlineKeys[:0],
+		inlineComma: p.inlineComma[:0],
+		inlineUsed:  p.inlineUsed[:0],
+		sm:          p.sm,
+		opts:        p.opts,
+		errs:        errorList{max: p.opts.MaxErrors},
+	}
 }
 
 // convert walks input one line at a time, dispatching to the header,
-// key/value, and accumulation handlers, and returns the emitted JSON
-// document.
-func (p *tomlLineParser) convert(input []byte) ([]byte, error) {
+// key/value, and accumulation handlers, and appends the emitted JSON
+// document to p.out.
+func (p *tomlLineParser) convert(input []byte) error {
 	p.input = input
 	var pathBuf [4][]byte
 	// lineNum is the 0-based index of the line currently being processed.
@@ -532,7 +837,15 @@ func (p *tomlLineParser) convert(input []byte) ([]byte, error) {
 	lineNum := -1
 	pos := 0
 
-	for pos < len(input) {
+	for pos < len(input) || p.state != tomlStateNormal {
+		if pos >= len(input) {
+			err := p.unterminated()
+			if !p.errs.add(err) {
+				return err
+			}
+			pos, lineNum = p.recoverAccum(pos, lineNum)
+			continue
+		}
 		// Lazily scan the next line without pre-splitting the whole input.
 		nl := bytes.IndexByte(input[pos:], '\n')
 		var line []byte
@@ -550,73 +863,52 @@ func (p *tomlLineParser) convert(input []byte) ([]byte, error) {
 
 		if handled, err := p.handleAccumLine(line, lineEnd); handled || err != nil {
 			if err != nil {
-				return nil, err
+				if !p.errs.add(err) {
+					return err
+				}
+				pos, lineNum = p.recoverAccum(pos, lineNum)
 			}
 			continue
 		}
 
 		line = bytes.TrimRight(line, " \t\r")
-		line = stripInlineComment(line)
-		trimmed := bytes.TrimSpace(line)
+		line = stripTOMLComment(line)
+		trimmed := trimTOMLSpace(line)
 		if len(trimmed) == 0 || trimmed[0] == '#' {
 			continue
 		}
+		if p.skipping {
+			if !tomlResyncPoint(trimmed) {
+				continue
+			}
+			p.skipping = false
+		}
 		leading := leadingSpaces(line)
 
+		var err error
 		switch {
 		case bytes.HasPrefix(trimmed, []byte("[[")):
-			if err := p.handleHeader(trimmed, lineNum, leading, &pathBuf, true); err != nil {
-				return nil, err
-			}
+			err = p.handleHeader(trimmed, lineNum, leading, &pathBuf, true)
 		case trimmed[0] == '[':
-			if err := p.handleHeader(trimmed, lineNum, leading, &pathBuf, false); err != nil {
-				return nil, err
-			}
+			err = p.handleHeader(trimmed, lineNum, leading, &pathBuf, false)
 		default:
 			key, rest, ok := tomlBareKeyValue(trimmed)
 			if !ok {
-				if err := p.handleDottedKeyValue(trimmed, lineNum, leading, &pathBuf); err != nil {
-					return nil, err
-				}
-				continue
-			}
-			p.closeInlineTo(0)
-			if err := p.markKey(key); err != nil {
-				return nil, atLineCol(lineNum, leading, err)
-			}
-			if p.topNC() {
-				p.buf.WriteByte(',')
+				err = p.handleDottedKeyValue(trimmed, lineNum, leading, &pathBuf)
+			} else {
+				err = p.handleBareKeyValue(key, rest, trimmed, lineNum, leading)
 			}
-			writeJSONString(key, &p.buf)
-			p.buf.WriteByte(':')
-			valCol := leading + len(trimmed) - len(rest)
-			if ml, mlState := multilineStart(rest); ml {
-				p.startMultilineValue(rest, lineNum, valCol, mlState)
-				continue
-			}
-			if _, err := writeTOMLValue(rest, nil, 0, &p.buf); err != nil {
-				return nil, atLineCol(lineNum, valCol, err)
-			}
-			p.setTopNC(true)
 		}
-	}
-
-	if p.state != tomlStateNormal {
-		what := "multiline string"
-		if p.state == tomlStateInlineArray {
-			what = "inline array"
+		if err != nil {
+			if err == errReentry || !p.errs.add(err) {
+				return err
+			}
+			p.skipping = true
 		}
-		return nil, atLineCol(p.startLine, p.startCol, fmt.Errorf("unterminated %s", what))
 	}
 
 	p.closeInlineTo(0)
-	for i := p.stackLen - 1; i >= 1; i-- {
-		if p.stackBuf[i].isAoT {
-			p.buf.WriteString("}]")
-		} else {
-			p.buf.WriteByte('}')
-		}
-	}
-	p.buf.WriteByte('}')
-	return p.buf.Bytes(), nil
+	p.closeSectionsTo(1, false)
+	p.out.WriteByte('}')
+	return nil
 }
@@ -2,7 +2,9 @@ package tojson
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"unicode/utf8"
 )
@@ -26,34 +28,51 @@ func parseUnicodeEscape(hex4 []byte) (rune, error) {
 	return r, nil
 }
 
-// scalarStringNode encodes s as a JSON string and returns it as a scalar jnode.
+// scalarStringNode encodes s as a JSON string, as writeTOMLKey does, and
+// returns it as a scalar jnode.
 func scalarStringNode(s []byte) *jnode {
-	return &jnode{raw: appendString(make([]byte, 0, len(s)+2), s)}
+	return &jnode{raw: appendStringCR(make([]byte, 0, len(s)+2), s, true)}
 }
 
 // parseTOMLValue parses a TOML value from s.
 // rawLines/lineIdx are needed for multiline strings.
 // Returns pre-encoded JSON bytes, number of additional lines consumed, and error.
-func parseTOMLValue(s []byte, rawLines [][]byte, lineIdx int) (*jnode, int, error) {
-	s = bytes.TrimSpace(s)
+// The returned node records s as its source text.
+func parseTOMLValue(s []byte, rawLines [][]byte, lineIdx int, opts *TOMLOptions) (*jnode, int, error) {
+	s = trimTOMLSpace(s)
+	if opts.Strict {
+		if err := checkTOMLValueEnd(s); err != nil {
+			return nil, 0, err
+		}
+	}
+	node, consumed, err := parseTOMLValueNode(s, rawLines, lineIdx, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	node.src = s
+	return node, consumed, nil
+}
+
+// parseTOMLValueNode parses the trimmed value s for parseTOMLValue.
+func parseTOMLValueNode(s []byte, rawLines [][]byte, lineIdx int, opts *TOMLOptions) (*jnode, int, error) {
 	if len(s) == 0 {
 		return nil, 0, fmt.Errorf("expected value")
 	}
 
 	if bytes.HasPrefix(s, []byte(`"""`)) {
-		str, consumed, err := parseTOMLMultilineBasic(s, rawLines, lineIdx)
+		str, consumed, err := parseTOMLMultilineBasic(s, rawLines, lineIdx, opts)
 		if err != nil {
 			return nil, 0, err
 		}
-		return scalarStringNode(str), consumed, nil
+		return tomlStringNode(str, opts), consumed, nil
 	}
 
 	if s[0] == '"' {
-		str, _, err := parseTOMLBasicStringRaw(s)
+		str, _, err := parseTOMLBasicStringRaw(s, opts)
 		if err != nil {
 			return nil, 0, err
 		}
-		return scalarStringNode(str), 0, nil
+		return tomlStringNode(str, opts), 0, nil
 	}
 
 	if bytes.HasPrefix(s, []byte("'''")) {
@@ -61,16 +80,19 @@ func parseTOMLValue(s []byte, rawLines [][]byte, lineIdx int) (*jnode, int, erro
 		if err != nil {
 			return nil, 0, err
 		}
-		return scalarStringNode(str), consumed, nil
+		return tomlStringNode(str, opts), consumed, nil
 	}
 
 	if s[0] == '\'' {
-		str, _ := parseTOMLLiteralStringRaw(s)
-		return scalarStringNode(str), 0, nil
+		str, _, err := parseTOMLLiteralStringRaw(s)
+		if err != nil {
+			return nil, 0, err
+		}
+		return tomlStringNode(str, opts), 0, nil
 	}
 
 	if s[0] == '{' {
-		node, _, err := parseTOMLInlineTable(s, 0)
+		node, _, err := parseTOMLInlineTable(s, 0, opts)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -78,32 +100,45 @@ func parseTOMLValue(s []byte, rawLines [][]byte, lineIdx int) (*jnode, int, erro
 	}
 
 	if s[0] == '[' {
-		node, _, consumed, err := parseTOMLInlineArray(s, rawLines, lineIdx, 0)
+		node, _, consumed, err := parseTOMLInlineArray(s, rawLines, lineIdx, 0, opts)
 		if err != nil {
 			return nil, 0, err
 		}
 		return node, consumed, nil
 	}
 
+	if opts.Tagged {
+		raw, err := appendTOMLTagged(nil, s, opts)
+		if err != nil {
+			return nil, 0, err
+		}
+		return newScalarNode(raw), 0, nil
+	}
+
 	if bytes.Equal(s, []byte("true")) {
-		return nodeTrue, 0, nil
+		return newScalarNode(rawTrue), 0, nil
 	}
 	if bytes.Equal(s, []byte("false")) {
-		return nodeFalse, 0, nil
+		return newScalarNode(rawFalse), 0, nil
 	}
 
-	if bytes.EqualFold(s, []byte("inf")) || bytes.EqualFold(s, []byte("+inf")) || bytes.EqualFold(s, []byte("-inf")) {
-		return nil, 0, fmt.Errorf("inf is not representable in JSON")
-	}
-	if bytes.EqualFold(s, []byte("nan")) || bytes.EqualFold(s, []byte("+nan")) || bytes.EqualFold(s, []byte("-nan")) {
-		return nil, 0, fmt.Errorf("nan is not representable in JSON")
+	if nan, neg, ok := tomlNonFinite(s); ok {
+		raw, err := opts.nonFinite().append(nil, s, nan, neg)
+		if err != nil {
+			return nil, 0, err
+		}
+		return newScalarNode(raw), 0, nil
 	}
 
 	if isTOMLDateTime(s) {
-		return scalarStringNode(s), 0, nil
+		raw, err := appendTOMLDateTime(nil, s, opts.DateTimes, opts.v11())
+		if err != nil {
+			return nil, 0, err
+		}
+		return newScalarNode(raw), 0, nil
 	}
 
-	raw, err := parseTOMLNumber(s)
+	raw, err := parseTOMLNumber(s, opts.ints())
 	if err != nil {
 		return nil, 0, err
 	}
@@ -117,11 +152,31 @@ func parseTOMLValue(s []byte, rawLines [][]byte, lineIdx int) (*jnode, int, erro
 // applyTOMLEscape processes a TOML escape sequence. i points to the character
 // immediately after the backslash within s. The decoded rune is written to b.
 // Returns the number of additional characters consumed beyond s[i], or an error.
-func applyTOMLEscape(s []byte, i int, b *bytes.Buffer) (int, error) {
+// Under TOML 1.1 the escapes \e and \xHH are also accepted. A \u escape of a
+// high surrogate followed by one of a low surrogate is decoded as the pair
+// unless opts.Strict is set, which rejects any surrogate, as TOML does.
+func applyTOMLEscape(s []byte, i int, b *bytes.Buffer, opts *TOMLOptions) (int, error) {
 	if i >= len(s) {
 		return 0, fmt.Errorf("unexpected end of string after backslash")
 	}
+	if opts.v11() {
+		switch s[i] {
+		case 'e':
+			b.WriteByte(0x1b)
+			return 0, nil
+		case 'x': // \xHH, the code point U+00HH
+			if i+2 < len(s) {
+				if r, err := parseUnicodeEscape(s[i+1 : i+3]); err == nil {
+					b.WriteRune(r)
+					return 2, nil
+				}
+			}
+			return 0, fmt.Errorf("invalid \\x escape")
+		}
+	}
 	switch s[i] {
+	case 'e', 'x':
+		return 0, errTOML11Escape(s[i])
 	case 'b':
 		b.WriteByte('\b')
 	case 't':
@@ -139,6 +194,9 @@ func applyTOMLEscape(s []byte, i int, b *bytes.Buffer) (int, error) {
 	case 'u': // \uXXXX
 		if i+4 < len(s) {
 			r, err := parseUnicodeEscape(s[i+1 : i+5])
+			if err == nil && opts.Strict && 0xD800 <= r && r <= 0xDFFF {
+				return 0, fmt.Errorf("invalid \\u escape of a surrogate")
+			}
 			if err == nil {
 				// surrogate pair \uHigh\uLow
 				if r >= 0xD800 && r <= 0xDBFF && i+10 < len(s) && s[i+5] == '\\' && s[i+6] == 'u' {
@@ -174,9 +232,11 @@ func applyTOMLEscape(s []byte, i int, b *bytes.Buffer) (int, error) {
 }
 
 // parseTOMLBasicStringRaw parses a TOML basic (double-quoted) string from s[0]
-// using Go string literal rules (strconv.Unquote).
-// Returns the decoded bytes and the remainder after the closing quote.
-func parseTOMLBasicStringRaw(s []byte) ([]byte, []byte, error) {
+// using Go string literal rules (strconv.Unquote), which accept escapes such
+// as \a and \x41 that TOML does not, or TOML's escapes alone under TOML 1.1
+// or when opts.Strict is set. Returns the decoded bytes and the remainder
+// after the closing quote.
+func parseTOMLBasicStringRaw(s []byte, opts *TOMLOptions) ([]byte, []byte, error) {
 	if len(s) < 2 || s[0] != '"' {
 		return nil, s, fmt.Errorf("expected double-quoted string")
 	}
@@ -184,33 +244,60 @@ func parseTOMLBasicStringRaw(s []byte) ([]byte, []byte, error) {
 	if end < 0 {
 		return nil, s, fmt.Errorf("unterminated basic string")
 	}
+	if opts.v11() || opts.Strict {
+		var b bytes.Buffer
+		body := s[1 : end-1]
+		for i := 0; i < len(body); i++ {
+			if body[i] != '\\' {
+				b.WriteByte(body[i])
+				continue
+			}
+			extra, err := applyTOMLEscape(body, i+1, &b, opts)
+			if err != nil {
+				return nil, s, fmt.Errorf("invalid basic string: %w", err)
+			}
+			i += 1 + extra
+		}
+		return b.Bytes(), s[end:], nil
+	}
 	str, err := strconv.Unquote(string(s[:end]))
 	if err != nil {
+		// strconv.Unquote accepts \x but not \e, which it reports only as
+		// invalid syntax.
+		for i := 1; i < end-2; i++ {
+			if s[i] == '\\' {
+				if s[i+1] == 'e' {
+					err = errTOML11Escape('e')
+					break
+				}
+				i++
+			}
+		}
 		return nil, s, fmt.Errorf("invalid basic string: %w", err)
 	}
 	return []byte(str), s[end:], nil
 }
 
+// errTOML11Escape reports the escape \c, which only TOML 1.1 has, in a
+// string read under TOML 1.0.
+func errTOML11Escape(c byte) error {
+	return fmt.Errorf("\\%c is a TOML 1.1 escape; set TOMLOptions.Version to TOML11 to accept it", c)
+}
+
 // parseTOMLLiteralStringRaw parses a TOML literal (single-quoted) string from s[0].
 // No escape processing. Returns the raw content and the remainder.
-func parseTOMLLiteralStringRaw(s []byte) ([]byte, []byte) {
-	if len(s) < 2 || s[0] != '\'' {
-		return s, nil
+func parseTOMLLiteralStringRaw(s []byte) ([]byte, []byte, error) {
+	if i := bytes.IndexByte(s[1:], '\''); i >= 0 {
+		return s[1 : i+1], s[i+2:], nil
 	}
-	i := 1
-	for i < len(s) {
-		if s[i] == '\'' {
-			return s[1:i], s[i+1:]
-		}
-		i++
-	}
-	return s[1:], nil
+	return nil, s, fmt.Errorf("unterminated literal string")
 }
 
 // parseTOMLMultilineBasic parses a triple-double-quoted multiline basic string.
 // s is the portion of the current line starting at the opening """.
 // Returns the decoded bytes and the number of additional lines consumed.
-func parseTOMLMultilineBasic(s []byte, rawLines [][]byte, lineIdx int) ([]byte, int, error) {
+// Escapes are as for decodeTOMLMultilineBasic.
+func parseTOMLMultilineBasic(s []byte, rawLines [][]byte, lineIdx int, opts *TOMLOptions) ([]byte, int, error) {
 	if !bytes.HasPrefix(s, []byte(`"""`)) {
 		return nil, 0, fmt.Errorf("expected \"\"\"")
 	}
@@ -218,9 +305,9 @@ func parseTOMLMultilineBasic(s []byte, rawLines [][]byte, lineIdx int) ([]byte,
 	content := s[3:len(s):len(s)]
 	extraLines := 0
 	for {
-		if idx := bytes.Index(content, []byte(`"""`)); idx >= 0 {
+		if idx := tomlMultilineEnd(content, '"'); idx >= 0 {
 			body := content[:idx]
-			str, _, err := decodeTOMLMultilineBasic(body)
+			str, _, err := decodeTOMLMultilineBasic(body, opts)
 			return str, extraLines, err
 		}
 		nextIdx := lineIdx + extraLines + 1
@@ -233,7 +320,12 @@ func parseTOMLMultilineBasic(s []byte, rawLines [][]byte, lineIdx int) ([]byte,
 	}
 }
 
-func decodeTOMLMultilineBasic(s []byte) ([]byte, int, error) {
+// decodeTOMLMultilineBasic decodes the body of a multi-line basic string,
+// with escapes as for applyTOMLEscape and each CRLF line break as a newline.
+// A backslash that ends a line trims the whitespace after it; when
+// opts.Strict is set it must be followed only by whitespace up to the end of
+// its line, as TOML requires.
+func decodeTOMLMultilineBasic(s []byte, opts *TOMLOptions) ([]byte, int, error) {
 	if bytes.HasPrefix(s, []byte("\n")) {
 		s = s[1:]
 	} else if bytes.HasPrefix(s, []byte("\r\n")) {
@@ -246,6 +338,9 @@ func decodeTOMLMultilineBasic(s []byte) ([]byte, int, error) {
 		if c == '\\' && i+1 < len(s) {
 			next := s[i+1]
 			if next == '\n' || next == '\r' || next == ' ' || next == '\t' {
+				if ws := bytes.TrimLeft(s[i+1:], " \t"); opts.Strict && (len(ws) == 0 || ws[0] != '\n' && ws[0] != '\r') {
+					return nil, 0, fmt.Errorf("invalid escape \\%c", next)
+				}
 				i++
 				for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n' || s[i] == '\r') {
 					i++
@@ -253,12 +348,12 @@ func decodeTOMLMultilineBasic(s []byte) ([]byte, int, error) {
 				continue
 			}
 			i++
-			extra, err := applyTOMLEscape(s, i, &b)
+			extra, err := applyTOMLEscape(s, i, &b, opts)
 			if err != nil {
 				return nil, 0, err
 			}
 			i += extra
-		} else {
+		} else if c != '\r' || i+1 == len(s) || s[i+1] != '\n' {
 			b.WriteByte(c)
 		}
 		i++
@@ -266,7 +361,28 @@ func decodeTOMLMultilineBasic(s []byte) ([]byte, int, error) {
 	return b.Bytes(), 0, nil
 }
 
-// parseTOMLMultilineLiteral parses a triple-single-quoted multiline literal string.
+// tomlMultilineEnd returns the length of the body of a multi-line string,
+// the text s after its opening delimiter of three q, or -1 if s does not
+// close it. Up to two q just before the closing delimiter belong to the
+// body, so """a"""" is the string a".
+func tomlMultilineEnd(s []byte, q byte) int {
+	for i := 0; i+2 < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case s[i] == q && s[i+1] == q && s[i+2] == q:
+			n := i
+			for n < i+2 && n+3 < len(s) && s[n+3] == q {
+				n++
+			}
+			return n
+		}
+	}
+	return -1
+}
+
+// parseTOMLMultilineLiteral parses a triple-single-quoted multiline literal
+// string, with each CRLF line break as a newline.
 func parseTOMLMultilineLiteral(s []byte, rawLines [][]byte, lineIdx int) ([]byte, int, error) {
 	if !bytes.HasPrefix(s, []byte("'''")) {
 		return nil, 0, fmt.Errorf("expected '''")
@@ -274,13 +390,16 @@ func parseTOMLMultilineLiteral(s []byte, rawLines [][]byte, lineIdx int) ([]byte
 	content := s[3:len(s):len(s)]
 	extraLines := 0
 	for {
-		if idx := bytes.Index(content, []byte("'''")); idx >= 0 {
+		if idx := tomlMultilineEnd(content, '\''); idx >= 0 {
 			body := content[:idx]
 			if bytes.HasPrefix(body, []byte("\n")) {
 				body = body[1:]
 			} else if bytes.HasPrefix(body, []byte("\r\n")) {
 				body = body[2:]
 			}
+			if bytes.Contains(body, []byte("\r\n")) {
+				body = bytes.ReplaceAll(body, []byte("\r\n"), []byte("\n"))
+			}
 			return body, extraLines, nil
 		}
 		nextIdx := lineIdx + extraLines + 1
@@ -298,8 +417,9 @@ func parseTOMLMultilineLiteral(s []byte, rawLines [][]byte, lineIdx int) ([]byte
 // --------------------------------------------------------------------------
 
 // parseTOMLNumber parses a TOML number, returning JSON-ready bytes.
+// Integers of any size are exact, and are written as f selects.
 // Works entirely in []byte to avoid string conversion allocations.
-func parseTOMLNumber(s []byte) ([]byte, error) {
+func parseTOMLNumber(s []byte, f intFormat) ([]byte, error) {
 	if len(s) == 0 {
 		return nil, fmt.Errorf("invalid number")
 	}
@@ -317,36 +437,36 @@ func parseTOMLNumber(s []byte) ([]byte, error) {
 	// Radix-prefixed integers: no sign allowed.
 	if !hasSign {
 		if (body[0] == '0') && len(body) > 1 {
+			base, name := 0, ""
 			switch body[1] {
-			case 'x', 'X':
-				digits := stripUnderscoresBytes(body[2:])
-				if len(digits) == 0 {
-					return nil, fmt.Errorf("invalid hex number: %s", s)
-				}
-				v, err := strconv.ParseInt(string(digits), 16, 64)
+			case 'x':
+				base, name = 16, "hex"
+			case 'o':
+				base, name = 8, "octal"
+			case 'b':
+				base, name = 2, "binary"
+			}
+			if base != 0 {
+				digits, err := stripTOMLUnderscores(body[2:], isHexDigit)
 				if err != nil {
-					return nil, fmt.Errorf("invalid hex number %s: %v", s, err)
+					return nil, fmt.Errorf("invalid %s number %s: %v", name, s, err)
 				}
-				return strconv.AppendInt(nil, v, 10), nil
-			case 'o', 'O':
-				digits := stripUnderscoresBytes(body[2:])
-				v, err := strconv.ParseInt(string(digits), 8, 64)
-				if err != nil {
-					return nil, fmt.Errorf("invalid octal number %s: %v", s, err)
+				if len(digits) == 0 {
+					return nil, fmt.Errorf("invalid %s number: %s", name, s)
 				}
-				return strconv.AppendInt(nil, v, 10), nil
-			case 'b', 'B':
-				digits := stripUnderscoresBytes(body[2:])
-				v, err := strconv.ParseInt(string(digits), 2, 64)
+				raw, err := appendRadixInt(nil, digits, base, false)
 				if err != nil {
-					return nil, fmt.Errorf("invalid binary number %s: %v", s, err)
+					return nil, fmt.Errorf("invalid %s number %s: %v", name, s, err)
+				}
+				if f != (intFormat{}) {
+					return f.append(nil, raw)
 				}
-				return strconv.AppendInt(nil, v, 10), nil
+				return raw, nil
 			}
 		}
 	}
 
-	stripped, err := stripUnderscoresBytesValidated(body)
+	stripped, err := stripTOMLUnderscores(body, isDecimalDigit)
 	if err != nil {
 		return nil, fmt.Errorf("invalid number %s: %v", s, err)
 	}
@@ -356,8 +476,10 @@ func parseTOMLNumber(s []byte) ([]byte, error) {
 	if len(body) > 1 && body[0] == '0' && body[1] >= '0' && body[1] <= '9' {
 		return nil, fmt.Errorf("leading zeros not allowed in integer: %s", s)
 	}
-
-	isFloat := bytes.ContainsAny(body, ".eE")
+	isFloat, ok := tomlDecimal(body)
+	if !ok {
+		return nil, fmt.Errorf("invalid number: %s", s)
+	}
 
 	// Build result without unnecessary allocation:
 	// - no sign or '+': return body (sub-slice of s, or stripped copy)
@@ -382,72 +504,76 @@ func parseTOMLNumber(s []byte) ([]byte, error) {
 		}
 		return result, nil
 	}
-
-	if _, err := strconv.ParseInt(string(result), 10, 64); err != nil {
-		if _, err2 := strconv.ParseUint(string(result), 10, 64); err2 != nil {
-			return nil, fmt.Errorf("invalid integer %s: %v", s, err)
-		}
+	if f != (intFormat{}) {
+		return f.append(nil, result)
 	}
 	return result, nil
 }
 
-// stripUnderscoresBytes removes underscores without validation (for radix-prefixed numbers).
-// Returns s unchanged (same backing array) if no underscores present.
-func stripUnderscoresBytes(s []byte) []byte {
-	if bytes.IndexByte(s, '_') < 0 {
-		return s
+// tomlDecimal reports whether s, without sign or underscores, is a TOML
+// decimal integer or float: digits with no leading zero, then optionally a
+// '.' and digits, then optionally an exponent. The grammar is JSON's, so s is
+// also a valid JSON number. isFloat reports a fraction or exponent.
+func tomlDecimal(s []byte) (isFloat, ok bool) {
+	i := 0
+	for i < len(s) && isDecimalDigit(s[i]) {
+		i++
 	}
-	out := make([]byte, 0, len(s))
-	for _, c := range s {
-		if c != '_' {
-			out = append(out, c)
+	if i == 0 || i > 1 && s[0] == '0' {
+		return false, false
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		n := i
+		for i < len(s) && isDecimalDigit(s[i]) {
+			i++
+		}
+		if i == n {
+			return false, false
+		}
+		isFloat = true
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		n := i
+		for i < len(s) && isDecimalDigit(s[i]) {
+			i++
+		}
+		if i == n {
+			return false, false
 		}
+		isFloat = true
 	}
-	return out
+	return isFloat, i == len(s)
 }
 
-// stripUnderscoresBytesValidated removes underscores from a decimal/float number,
-// validating that they are not at the start, end, or adjacent.
+// stripTOMLUnderscores removes the underscores from the digits of a number,
+// validating that each lies between two digits, as isDigit tells them.
 // Returns s unchanged (same backing array) if no underscores present.
-func stripUnderscoresBytesValidated(s []byte) ([]byte, error) {
+func stripTOMLUnderscores(s []byte, isDigit func(byte) bool) ([]byte, error) {
 	if bytes.IndexByte(s, '_') < 0 {
 		return s, nil
 	}
 	out := make([]byte, 0, len(s))
-	for i := range s {
-		c := s[i]
-		if c == '_' {
-			if i == 0 || i == len(s)-1 {
-				return nil, fmt.Errorf("underscore at start or end of number")
-			}
-			if s[i-1] == '_' {
-				return nil, fmt.Errorf("adjacent underscores in number")
-			}
-			if s[i-1] == '.' || (i+1 < len(s) && s[i+1] == '.') {
-				return nil, fmt.Errorf("underscore adjacent to decimal point")
-			}
-			if s[i-1] == 'e' || s[i-1] == 'E' || (i+1 < len(s) && (s[i+1] == 'e' || s[i+1] == 'E')) {
-				return nil, fmt.Errorf("underscore adjacent to exponent")
-			}
+	for i, c := range s {
+		if c != '_' {
+			out = append(out, c)
 			continue
 		}
-		out = append(out, c)
+		if i == 0 || i == len(s)-1 || !isDigit(s[i-1]) || !isDigit(s[i+1]) {
+			return nil, fmt.Errorf("underscore not between digits")
+		}
 	}
 	return out, nil
 }
 
-// --------------------------------------------------------------------------
-// Datetime detection
-// --------------------------------------------------------------------------
+func isDecimalDigit(c byte) bool { return '0' <= c && c <= '9' }
 
-func isTOMLDateTime(s []byte) bool {
-	if len(s) >= 5 && s[2] == ':' && isDigits(s[0:2]) {
-		return true
-	}
-	if len(s) >= 10 && s[4] == '-' && isDigits(s[0:4]) {
-		return true
-	}
-	return false
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
 }
 
 func isDigits(s []byte) bool {
@@ -465,90 +591,161 @@ func isDigits(s []byte) bool {
 
 // parseTOMLInlineTable parses {k = v, ...} starting at s[pos].
 // Returns the built jnode, position after the closing '}', and any error.
-func parseTOMLInlineTable(s []byte, pos int) (*jnode, int, error) {
+// Under TOML 1.1, s may span lines, with comments and a trailing comma.
+func parseTOMLInlineTable(s []byte, pos int, opts *TOMLOptions) (*jnode, int, error) {
 	if pos >= len(s) || s[pos] != '{' {
 		return nil, pos, fmt.Errorf("expected '{'")
 	}
-	pos++ // consume '{'
+	skipWS := tomlSkipSpace
+	if opts.v11() {
+		skipWS = tomlFlowSkipWS
+	}
 	node := newObjectNode()
-	pos = flowSkipWS(s, pos)
+	node.src = s[pos:]
+	pos++ // consume '{'
+	pos = skipWS(s, pos)
 
 	if pos < len(s) && s[pos] == '}' {
+		node.end = s[pos : pos+1]
 		return node, pos + 1, nil
 	}
 
 	var pathBuf [4][]byte
+	var dotted []*jnode // tables made by dotted keys, which later keys may extend
 	first := true
 	for pos < len(s) {
+		if tomlNewlineAt(s, pos) {
+			return nil, pos, tomlInlineErrorAt(s, pos, fmt.Errorf("newline not allowed in inline table"))
+		}
 		if !first {
-			if pos >= len(s) || s[pos] != ',' {
-				return nil, pos, fmt.Errorf("expected ',' or '}' in inline table")
+			if s[pos] != ',' {
+				return nil, pos, tomlInlineErrorAt(s, pos, fmt.Errorf("expected ',' or '}' in inline table"))
 			}
-			pos = flowSkipWS(s, pos+1)
+			pos = skipWS(s, pos+1)
 			if pos < len(s) && s[pos] == '}' {
-				return nil, pos, fmt.Errorf("trailing comma not allowed in inline table")
+				if opts.v11() {
+					node.end = s[pos : pos+1]
+					return node, pos + 1, nil
+				}
+				return nil, pos, tomlInlineErrorAt(s, pos, fmt.Errorf("trailing comma not allowed in inline table"))
+			}
+			if tomlNewlineAt(s, pos) {
+				return nil, pos, tomlInlineErrorAt(s, pos, fmt.Errorf("newline not allowed in inline table"))
 			}
 		}
 		first = false
 
-		path, rest, err := parseTOMLKeyPath(s[pos:], pathBuf[:0])
+		keyPos := pos
+		path, rest, err := parseTOMLKeyPath(s[pos:], pathBuf[:0], opts)
 		if err != nil {
-			return nil, pos, err
+			return nil, pos, tomlInlineErrorAt(s, keyPos, err)
 		}
+		keySrc := trimTOMLSpace(s[pos : len(s)-len(rest)])
 		pos += len(s[pos:]) - len(rest)
-		pos = flowSkipWS(s, pos)
+		pos = tomlSkipSpace(s, pos)
 		if pos >= len(s) || s[pos] != '=' {
-			return nil, pos, fmt.Errorf("expected '=' in inline table")
+			return nil, pos, tomlInlineErrorAt(s, pos, fmt.Errorf("expected '=' in inline table"))
 		}
-		pos = flowSkipWS(s, pos+1)
+		pos = tomlSkipSpace(s, pos+1)
 
-		valNode, newPos, err := parseTOMLInlineValue(s, pos)
+		valNode, newPos, err := parseTOMLInlineValue(s, pos, opts)
 		if err != nil {
-			return nil, pos, err
+			return nil, pos, tomlInlineErrorAt(s, pos, err)
 		}
-		pos = flowSkipWS(s, newPos)
+		pos = skipWS(s, newPos)
 
 		target := node
 		for i := 0; i < len(path)-1; i++ {
 			pair := target.findPair(path[i])
 			if pair == nil {
 				next := newObjectNode()
-				target.obj = append(target.obj, &jpair{key: path[i], val: next})
+				next.src = keySrc
+				target.obj = append(target.obj, &jpair{key: path[i], keySrc: keySrc, val: next})
 				target = next
+				if opts.Strict {
+					dotted = append(dotted, next)
+				}
+			} else if opts.Strict && pair.val.obj != nil && !slices.Contains(dotted, pair.val) {
+				return nil, pos, tomlInlineErrorAt(s, keyPos, fmt.Errorf("cannot extend inline table %q with a dotted key", path[i]))
 			} else if pair.val.obj != nil {
 				target = pair.val
 			} else {
-				return nil, pos, fmt.Errorf("duplicate key %q in inline table", path[i])
+				return nil, pos, tomlInlineErrorAt(s, keyPos, fmt.Errorf("duplicate key %q in inline table", path[i]))
 			}
 		}
 		lastKey := path[len(path)-1]
 		if target.findPair(lastKey) != nil {
-			return nil, pos, fmt.Errorf("duplicate key %q in inline table", lastKey)
+			return nil, pos, tomlInlineErrorAt(s, keyPos, fmt.Errorf("duplicate key %q in inline table", lastKey))
 		}
-		target.obj = append(target.obj, &jpair{key: lastKey, val: valNode})
+		target.obj = append(target.obj, &jpair{key: lastKey, keySrc: keySrc, val: valNode})
 
 		if pos < len(s) && s[pos] == '}' {
+			node.end = s[pos : pos+1]
 			return node, pos + 1, nil
 		}
 	}
 	return nil, pos, fmt.Errorf("unterminated inline table")
 }
 
+// tomlInlineError is an error inside an inline table or array. at is the
+// rest of the value from where the error was found, so that an error in a
+// value spanning lines can be reported on its own line.
+type tomlInlineError struct {
+	at  []byte
+	err error
+}
+
+func (e *tomlInlineError) Error() string { return e.err.Error() }
+func (e *tomlInlineError) Unwrap() error { return e.err }
+
+// tomlInlineErrorAt returns err as found at s[pos], unless it is already a
+// tomlInlineError from a value nested there.
+func tomlInlineErrorAt(s []byte, pos int, err error) error {
+	if _, ok := err.(*tomlInlineError); ok {
+		return err
+	}
+	return &tomlInlineError{at: s[min(pos, len(s)):], err: err}
+}
+
+// atTOMLValue wraps err, from a value at the 0-based rawLine and col, as
+// atLineCol does. An error inside an inline table or array is reported
+// where it was found instead, when that is within input.
+func atTOMLValue(input []byte, rawLine, col int, err error) error {
+	var ie *tomlInlineError
+	if errors.As(err, &ie) && len(ie.at) > 0 {
+		off := cap(input) - cap(ie.at)
+		if off >= 0 && off < len(input) && &input[off] == &ie.at[0] {
+			return atOffset(input, off, ie.err)
+		}
+		err = ie.err
+	}
+	return atLineCol(rawLine, col, err)
+}
+
 // parseTOMLInlineValue parses a single value inside an inline collection (no multiline).
-func parseTOMLInlineValue(s []byte, pos int) (*jnode, int, error) {
+func parseTOMLInlineValue(s []byte, pos int, opts *TOMLOptions) (*jnode, int, error) {
 	pos = flowSkipWS(s, pos)
 	if pos >= len(s) {
 		return nil, pos, fmt.Errorf("expected value")
 	}
 	rest := s[pos:]
 	end := tomlValueEnd(rest)
-	node, _, err := parseTOMLValue(rest[:end], nil, 0)
+	node, _, err := parseTOMLValue(rest[:end], nil, 0, opts)
 	if err != nil {
 		return nil, pos, err
 	}
 	return node, pos + end, nil
 }
 
+// checkTOMLValueEnd reports an error if anything but whitespace and a
+// comment follows the first TOML value in s, as in a = 1 2.
+func checkTOMLValueEnd(s []byte) error {
+	if rest := trimTOMLSpace(stripTOMLComment(s[tomlValueEnd(s):])); len(rest) != 0 {
+		return fmt.Errorf("unexpected content after value: %s", rest)
+	}
+	return nil
+}
+
 // tomlValueEnd returns the number of bytes in s consumed by the first TOML value.
 func tomlValueEnd(s []byte) int {
 	s = bytes.TrimLeft(s, " \t")
@@ -556,17 +753,9 @@ func tomlValueEnd(s []byte) int {
 		return 0
 	}
 	switch {
-	case bytes.HasPrefix(s, []byte(`"""`)):
-		i := 3
-		for i < len(s) {
-			if bytes.HasPrefix(s[i:], []byte(`"""`)) {
-				return i + 3
-			}
-			if s[i] == '\\' {
-				i += 2
-			} else {
-				i++
-			}
+	case bytes.HasPrefix(s, []byte(`"""`)), bytes.HasPrefix(s, []byte("'''")):
+		if n := tomlMultilineEnd(s[3:], s[0]); n >= 0 {
+			return n + 6
 		}
 		return len(s)
 	case s[0] == '"':
@@ -581,15 +770,6 @@ func tomlValueEnd(s []byte) int {
 			}
 		}
 		return len(s)
-	case bytes.HasPrefix(s, []byte("'''")):
-		i := 3
-		for i < len(s) {
-			if bytes.HasPrefix(s[i:], []byte("'''")) {
-				return i + 3
-			}
-			i++
-		}
-		return len(s)
 	case s[0] == '\'':
 		i := 1
 		for i < len(s) {
@@ -599,70 +779,19 @@ func tomlValueEnd(s []byte) int {
 			i++
 		}
 		return len(s)
-	case s[0] == '{':
-		depth := 0
-		inDouble, inSingle := false, false
-		for i := 0; i < len(s); i++ {
-			c := s[i]
-			switch {
-			case inDouble:
-				if c == '\\' {
-					i++
-				} else if c == '"' {
-					inDouble = false
-				}
-			case inSingle:
-				if c == '\'' {
-					inSingle = false
-				}
-			case c == '"':
-				inDouble = true
-			case c == '\'':
-				inSingle = true
-			case c == '{':
-				depth++
-			case c == '}':
-				depth--
-				if depth == 0 {
-					return i + 1
-				}
-			}
-		}
-		return len(s)
-	case s[0] == '[':
-		depth := 0
-		inDouble, inSingle := false, false
-		for i := 0; i < len(s); i++ {
-			c := s[i]
-			switch {
-			case inDouble:
-				if c == '\\' {
-					i++
-				} else if c == '"' {
-					inDouble = false
-				}
-			case inSingle:
-				if c == '\'' {
-					inSingle = false
-				}
-			case c == '"':
-				inDouble = true
-			case c == '\'':
-				inSingle = true
-			case c == '[':
-				depth++
-			case c == ']':
-				depth--
-				if depth == 0 {
-					return i + 1
-				}
-			}
+	case s[0] == '{', s[0] == '[':
+		var sc tomlBracketScan
+		if n := sc.end(s); n >= 0 {
+			return n
 		}
 		return len(s)
 	default:
 		for i := 0; i < len(s); i++ {
 			c := s[i]
-			if c == ',' || c == '}' || c == ']' || c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			if c == ' ' && i == 10 && isTOMLDateTime(s) && len(s) > 13 && isDigits(s[11:13]) && s[13] == ':' {
+				continue // the space between the date and time of a date-time
+			}
+			if c == ',' || c == '}' || c == ']' || c == '#' || c == ' ' || c == '\t' || c == '\r' || c == '\n' {
 				return i
 			}
 		}
@@ -676,26 +805,36 @@ func tomlValueEnd(s []byte) int {
 
 // writeTOMLValue writes the JSON representation of a TOML value directly to buf.
 // Returns extra lines consumed (for multiline strings) and any error.
-func writeTOMLValue(s []byte, rawLines [][]byte, lineIdx int, buf *bytes.Buffer) (int, error) {
-	s = bytes.TrimSpace(s)
+// When sm is non-nil the source of each value written is recorded in it.
+func writeTOMLValue(s []byte, rawLines [][]byte, lineIdx int, buf *bytes.Buffer, sm *srcMap, opts *TOMLOptions) (int, error) {
+	s = trimTOMLSpace(s)
 	if len(s) == 0 {
 		return 0, fmt.Errorf("expected value")
 	}
+	if opts.Strict {
+		if err := checkTOMLValueEnd(s); err != nil {
+			return 0, err
+		}
+	}
+	if s[0] != '{' {
+		// Inline tables are recorded node by node by serializeNode.
+		sm.valueOf(buf, s)
+	}
 
 	if bytes.HasPrefix(s, []byte(`"""`)) {
-		str, consumed, err := parseTOMLMultilineBasic(s, rawLines, lineIdx)
+		str, consumed, err := parseTOMLMultilineBasic(s, rawLines, lineIdx, opts)
 		if err != nil {
 			return 0, err
 		}
-		writeJSONString(str, buf)
+		writeTOMLString(str, buf, opts)
 		return consumed, nil
 	}
 	if s[0] == '"' {
-		str, _, err := parseTOMLBasicStringRaw(s)
+		str, _, err := parseTOMLBasicStringRaw(s, opts)
 		if err != nil {
 			return 0, err
 		}
-		writeJSONString(str, buf)
+		writeTOMLString(str, buf, opts)
 		return 0, nil
 	}
 	if bytes.HasPrefix(s, []byte("'''")) {
@@ -703,24 +842,32 @@ func writeTOMLValue(s []byte, rawLines [][]byte, lineIdx int, buf *bytes.Buffer)
 		if err != nil {
 			return 0, err
 		}
-		writeJSONString(str, buf)
+		writeTOMLString(str, buf, opts)
 		return consumed, nil
 	}
 	if s[0] == '\'' {
-		str, _ := parseTOMLLiteralStringRaw(s)
-		writeJSONString(str, buf)
+		str, _, err := parseTOMLLiteralStringRaw(s)
+		if err != nil {
+			return 0, err
+		}
+		writeTOMLString(str, buf, opts)
 		return 0, nil
 	}
 	if s[0] == '{' {
-		node, _, err := parseTOMLInlineTable(s, 0)
+		node, _, err := parseTOMLInlineTable(s, 0, opts)
 		if err != nil {
 			return 0, err
 		}
-		serializeNode(node, buf)
+		serializeNode(node, buf, sm)
 		return 0, nil
 	}
 	if s[0] == '[' {
-		return writeTOMLInlineArray(s, rawLines, lineIdx, buf)
+		return writeTOMLInlineArray(s, rawLines, lineIdx, buf, sm, opts)
+	}
+	if opts.Tagged {
+		b, err := appendTOMLTagged(buf.AvailableBuffer(), s, opts)
+		buf.Write(b)
+		return 0, err
 	}
 	if bytes.Equal(s, []byte("true")) {
 		buf.WriteString("true")
@@ -730,37 +877,27 @@ func writeTOMLValue(s []byte, rawLines [][]byte, lineIdx int, buf *bytes.Buffer)
 		buf.WriteString("false")
 		return 0, nil
 	}
-	if bytes.EqualFold(s, []byte("inf")) || bytes.EqualFold(s, []byte("+inf")) || bytes.EqualFold(s, []byte("-inf")) {
-		return 0, fmt.Errorf("inf is not representable in JSON")
-	}
-	if bytes.EqualFold(s, []byte("nan")) || bytes.EqualFold(s, []byte("+nan")) || bytes.EqualFold(s, []byte("-nan")) {
-		return 0, fmt.Errorf("nan is not representable in JSON")
+	if nan, neg, ok := tomlNonFinite(s); ok {
+		return 0, opts.nonFinite().write(buf, s, nan, neg)
 	}
 	if isTOMLDateTime(s) {
-		writeJSONString(s, buf)
-		return 0, nil
+		b, err := appendTOMLDateTime(buf.AvailableBuffer(), s, opts.DateTimes, opts.v11())
+		buf.Write(b)
+		return 0, err
 	}
-	// fast path: valid JSON number as-is (no + prefix, no leading zeros, no underscores/radix)
-	if isYAMLNumber(s) && s[0] != '+' {
+	// fast path: a decimal number without underscores is valid JSON as-is,
+	// once any '+' is dropped
+	if opts.ints() == (intFormat{}) {
 		digits := s
-		if digits[0] == '-' {
-			digits = digits[1:]
-		}
-		if len(digits) < 2 || digits[0] != '0' || digits[1] < '0' || digits[1] > '9' {
-			buf.Write(s)
-			return 0, nil
+		if s[0] == '+' || s[0] == '-' {
+			digits = s[1:]
 		}
-	}
-	// strip leading + and retry (parseTOMLNumber handles validation)
-	if len(s) > 1 && s[0] == '+' && isYAMLNumber(s[1:]) {
-		s2 := s[1:]
-		digits := s2
-		if len(digits) < 2 || digits[0] != '0' || digits[1] < '0' || digits[1] > '9' {
-			buf.Write(s2)
+		if _, ok := tomlDecimal(digits); ok {
+			buf.Write(bytes.TrimPrefix(s, []byte("+")))
 			return 0, nil
 		}
 	}
-	raw, err := parseTOMLNumber(s)
+	raw, err := parseTOMLNumber(s, opts.ints())
 	if err != nil {
 		return 0, err
 	}
@@ -768,6 +905,21 @@ func writeTOMLValue(s []byte, rawLines [][]byte, lineIdx int, buf *bytes.Buffer)
 	return 0, nil
 }
 
+// tomlSkipSpace returns the position of the first byte of s at or after pos
+// that is not a space or tab.
+func tomlSkipSpace(s []byte, pos int) int {
+	for pos < len(s) && (s[pos] == ' ' || s[pos] == '\t') {
+		pos++
+	}
+	return pos
+}
+
+// tomlNewlineAt reports whether s[pos] starts a line break or a comment,
+// which TOML 1.0 does not allow between the entries of an inline table.
+func tomlNewlineAt(s []byte, pos int) bool {
+	return pos < len(s) && (s[pos] == '\n' || s[pos] == '\r' || s[pos] == '#')
+}
+
 // tomlFlowSkipWS advances pos past ASCII whitespace, newlines, and TOML
 // '#' line comments (which run to the end of the current line). Used inside
 // multi-line inline arrays, where comments are permitted between values,
@@ -790,9 +942,7 @@ func tomlFlowSkipWS(s []byte, pos int) int {
 }
 
 // writeTOMLInlineArray writes [v, v, ...] starting at s[0] directly to buf.
-func writeTOMLInlineArray(s []byte, rawLines [][]byte, lineIdx int, buf *bytes.Buffer) (int, error) {
-	// 3-index slice prevents appending into caller's buffer.
-	s = s[:len(s):len(s)]
+func writeTOMLInlineArray(s []byte, rawLines [][]byte, lineIdx int, buf *bytes.Buffer, sm *srcMap, opts *TOMLOptions) (int, error) {
 	pos := 1 // consume '['
 	extraLines := 0
 	buf.WriteByte('[')
@@ -808,19 +958,19 @@ func writeTOMLInlineArray(s []byte, rawLines [][]byte, lineIdx int, buf *bytes.B
 			if rawLines == nil || nextIdx >= len(rawLines) {
 				return extraLines, fmt.Errorf("unterminated inline array")
 			}
-			s = append(s, '\n')
-			s = append(s, rawLines[nextIdx]...)
+			s = joinLine(s, rawLines[nextIdx])
 			extraLines++
 		}
 
 		if s[pos] == ']' {
+			sm.closeOf(buf, s[pos:pos+1])
 			buf.WriteByte(']')
 			return extraLines, nil
 		}
 
 		if count > 0 {
 			if s[pos] != ',' {
-				return extraLines, fmt.Errorf("expected ',' or ']' in array")
+				return extraLines, tomlInlineErrorAt(s, pos, fmt.Errorf("expected ',' or ']' in array"))
 			}
 			pos = tomlFlowSkipWS(s, pos+1)
 			for {
@@ -832,11 +982,11 @@ func writeTOMLInlineArray(s []byte, rawLines [][]byte, lineIdx int, buf *bytes.B
 				if rawLines == nil || nextIdx >= len(rawLines) {
 					return extraLines, fmt.Errorf("unterminated inline array")
 				}
-				s = append(s, '\n')
-				s = append(s, rawLines[nextIdx]...)
+				s = joinLine(s, rawLines[nextIdx])
 				extraLines++
 			}
 			if s[pos] == ']' {
+				sm.closeOf(buf, s[pos:pos+1])
 				buf.WriteByte(']')
 				return extraLines, nil
 			}
@@ -846,9 +996,9 @@ func writeTOMLInlineArray(s []byte, rawLines [][]byte, lineIdx int, buf *bytes.B
 		rest := bytes.TrimLeft(s[pos:], " \t")
 		lead := len(s[pos:]) - len(rest)
 		valEnd := tomlValueEnd(rest)
-		consumed, err := writeTOMLValue(rest[:valEnd], rawLines, lineIdx+extraLines, buf)
+		consumed, err := writeTOMLValue(rest[:valEnd], rawLines, lineIdx+extraLines, buf, sm, opts)
 		if err != nil {
-			return extraLines, err
+			return extraLines, tomlInlineErrorAt(s, pos+lead, err)
 		}
 		extraLines += consumed
 		pos = pos + lead + valEnd
@@ -860,16 +1010,30 @@ func writeTOMLInlineArray(s []byte, rawLines [][]byte, lineIdx int, buf *bytes.B
 // Inline array parser
 // --------------------------------------------------------------------------
 
+// joinLine returns s followed by a newline and next, the input line after
+// it. When next directly follows s in the same input, s is extended over it
+// in place, keeping the result a slice of the input; otherwise the lines are
+// copied so that the caller's buffer is never appended into.
+func joinLine(s, next []byte) []byte {
+	n := len(s)
+	if cap(next) > 0 && cap(s)-n-1 == cap(next) {
+		ext := s[:n+1+len(next)]
+		if ext[n] == '\n' && &ext[n+1 : cap(ext)][0] == &next[:1][0] {
+			return ext
+		}
+	}
+	s = append(s[:n:n], '\n')
+	return append(s, next...)
+}
+
 // parseTOMLInlineArray parses [v, v, ...] starting at s[pos].
-func parseTOMLInlineArray(s []byte, rawLines [][]byte, lineIdx int, pos int) (*jnode, int, int, error) {
+func parseTOMLInlineArray(s []byte, rawLines [][]byte, lineIdx int, pos int, opts *TOMLOptions) (*jnode, int, int, error) {
 	if pos >= len(s) || s[pos] != '[' {
 		return nil, pos, 0, fmt.Errorf("expected '['")
 	}
-	// 3-index slice prevents appending into caller's buffer.
-	s = s[:len(s):len(s)]
+	node := &jnode{arr: []*jnode{}, src: s[pos:]}
 	pos++ // consume '['
 	extraLines := 0
-	node := &jnode{arr: []*jnode{}}
 
 	for {
 		for {
@@ -881,18 +1045,18 @@ func parseTOMLInlineArray(s []byte, rawLines [][]byte, lineIdx int, pos int) (*j
 			if rawLines == nil || nextIdx >= len(rawLines) {
 				return nil, pos, extraLines, fmt.Errorf("unterminated inline array")
 			}
-			s = append(s, '\n')
-			s = append(s, rawLines[nextIdx]...)
+			s = joinLine(s, rawLines[nextIdx])
 			extraLines++
 		}
 
 		if s[pos] == ']' {
+			node.end = s[pos : pos+1]
 			return node, pos + 1, extraLines, nil
 		}
 
 		if len(node.arr) > 0 {
 			if s[pos] != ',' {
-				return nil, pos, extraLines, fmt.Errorf("expected ',' or ']' in array")
+				return nil, pos, extraLines, tomlInlineErrorAt(s, pos, fmt.Errorf("expected ',' or ']' in array"))
 			}
 			pos = tomlFlowSkipWS(s, pos+1)
 			for {
@@ -904,11 +1068,11 @@ func parseTOMLInlineArray(s []byte, rawLines [][]byte, lineIdx int, pos int) (*j
 				if rawLines == nil || nextIdx >= len(rawLines) {
 					return nil, pos, extraLines, fmt.Errorf("unterminated inline array")
 				}
-				s = append(s, '\n')
-				s = append(s, rawLines[nextIdx]...)
+				s = joinLine(s, rawLines[nextIdx])
 				extraLines++
 			}
 			if s[pos] == ']' {
+				node.end = s[pos : pos+1]
 				return node, pos + 1, extraLines, nil
 			}
 		}
@@ -916,9 +1080,9 @@ func parseTOMLInlineArray(s []byte, rawLines [][]byte, lineIdx int, pos int) (*j
 		rest := bytes.TrimLeft(s[pos:], " \t")
 		lead := len(s[pos:]) - len(rest)
 		valEnd := tomlValueEnd(rest)
-		valNode, consumed, err := parseTOMLValue(rest[:valEnd], rawLines, lineIdx+extraLines)
+		valNode, consumed, err := parseTOMLValue(rest[:valEnd], rawLines, lineIdx+extraLines, opts)
 		if err != nil {
-			return nil, pos, extraLines, err
+			return nil, pos, extraLines, tomlInlineErrorAt(s, pos+lead, err)
 		}
 		extraLines += consumed
 		pos = pos + lead + valEnd
@@ -2,38 +2,156 @@ package tojson
 
 import (
 	"bytes"
+	"slices"
 )
 
 // tomlFrame tracks one open TOML section on the parser's section stack.
 type tomlFrame struct {
 	key       []byte
-	isAoT     bool     // opened by [[...]]
-	explicit  bool     // set when a [table] header explicitly named this frame
-	needComma bool     // next entry in this object needs a leading comma
-	usedKeys  [][]byte // lazily allocated; detects duplicate keys via bytes.Equal linear scan
+	isAoT     bool        // opened by [[...]]
+	explicit  bool        // set when a [table] header explicitly named this frame
+	needComma bool        // next entry in this object needs a leading comma
+	usedKeys  []tomlKey   // lazily allocated; detects duplicate keys via bytes.Equal linear scan
+	dst       *tomlSplice // where the frame's entries are written; nil for the output itself
+	closer    string      // written when the frame is popped; see tomlLineParser.reopen
+	arrEnd    tomlPos     // for an isAoT frame once closed, where a further element goes
+}
+
+// tomlInlineNesting is the number of table-header levels ([a.b.c.d] = 4) whose
+// frames fit in tomlStack.buf. Deeper headers grow the stack on the heap, so
+// only unusually deep files allocate for it.
+const tomlInlineNesting = 4
+
+// tomlStack is the stack of open sections; frame 0 is the root. Its first
+// frames are held in buf, inside the parser itself, and the rest in more.
+// Frames are reached by index rather than through a slice of buf, which
+// would point the parser at itself and move it to the heap.
+type tomlStack struct {
+	buf  [tomlInlineNesting + 1]tomlFrame
+	more []tomlFrame
+	n    int // number of active frames
+}
+
+// at returns the frame at depth i.
+func (s *tomlStack) at(i int) *tomlFrame {
+	if i < len(s.buf) {
+		return &s.buf[i]
+	}
+	return &s.more[i-len(s.buf)]
+}
+
+// top returns the innermost open frame.
+func (s *tomlStack) top() *tomlFrame { return s.at(s.n - 1) }
+
+// push adds a frame and returns it. The frame still holds what was last
+// pushed at its depth, so that the caller can reuse the capacity of its
+// usedKeys.
+func (s *tomlStack) push() *tomlFrame {
+	if s.n >= len(s.buf) && s.n-len(s.buf) == len(s.more) {
+		s.more = append(s.more, tomlFrame{})
+	}
+	s.n++
+	return s.top()
+}
+
+// reset empties s to the root frame alone, keeping the capacity of every
+// frame's usedKeys.
+func (s *tomlStack) reset() {
+	for i := range len(s.buf) + len(s.more) {
+		f := s.at(i)
+		clear(f.usedKeys)
+		*f = tomlFrame{usedKeys: f.usedKeys[:0]}
+	}
+	s.n = 1
+}
+
+// tomlKey is a key used in a table. A table opened by a dotted key, such as
+// a in a.b = 1, may be extended by later dotted keys in the same table.
+type tomlKey struct {
+	name   []byte
+	dotted bool
+}
+
+// tomlPos is a position in the output: an offset into the text of the splice
+// dst, or into the output itself when dst is nil.
+type tomlPos struct {
+	dst *tomlSplice
+	off int
+}
+
+// tomlSplice holds text that belongs at offset at of its parent, the splice
+// or output whose children list holds it. A table re-entered after its
+// closing '}' has been written gets its further entries in a splice placed
+// just before that '}', and the splices are put in place when the document
+// is complete, so an out-of-order file is usually still converted in one
+// pass; see toml_line.go for the cases that are not.
+type tomlSplice struct {
+	at       int
+	buf      bytes.Buffer
+	children []*tomlSplice
+}
+
+// writeSpliced writes text to out with each of splices, and recursively
+// their own children, inserted at its offset. base is the offset in the
+// parent of text[0].
+func writeSpliced(out *bytes.Buffer, text []byte, base int, splices []*tomlSplice) {
+	slices.SortFunc(splices, func(a, b *tomlSplice) int { return a.at - b.at })
+	prev := 0
+	for _, s := range splices {
+		at := s.at - base
+		out.Write(text[prev:at])
+		writeSpliced(out, s.buf.Bytes(), 0, s.children)
+		prev = at
+	}
+	out.Write(text[prev:])
 }
 
 // tomlClosedTables records every table path that has been popped off the
-// section stack so far. The TOML spec forbids re-opening a table once another
-// header has closed it (e.g. defining [a.b], then [a], then [a.b] again);
-// openSection consults this set to detect such re-entry and reject the input.
+// section stack so far, with the state needed to re-open it. TOML lets a
+// later header add sub-tables to a table closed by an intervening header
+// (e.g. defining [a.b], then [x], then [a.c]); openSection looks the table
+// up here to re-enter it rather than open a second one.
+//
+// A node keeps only what reopen needs of a closed frame, with a copy of its
+// used keys cut to their length, so that the stack slot keeps its capacity
+// for the next sibling table. free holds the copies released by forgotten
+// paths and earlier documents, for mark to reuse.
 type tomlClosedTables struct {
 	root tomlClosedNode
+	free [][]tomlKey
 }
 
 // tomlClosedNode is one node in the trie of closed table paths. The root
 // node carries no key; each child key is a single segment of a dotted header
 // path. closed is true when the full path from the root to this node has been
-// closed at least once.
+// closed and not re-opened since; the node then keeps what reopen needs of
+// the frame as it was popped, and end, the position of its closing '}'.
 type tomlClosedNode struct {
-	key      []byte
-	closed   bool
-	children []tomlClosedNode
+	key       []byte
+	closed    bool
+	isAoT     bool
+	explicit  bool
+	needComma bool
+	usedKeys  []tomlKey
+	end       tomlPos
+	arrEnd    tomlPos
+	children  []tomlClosedNode
+	index     map[string]int // children by key, once there are tomlClosedIndexMin
 }
 
+// tomlClosedIndexMin is the number of children at which a node indexes them
+// by key, so that a file of many sibling tables is not quadratic.
+const tomlClosedIndexMin = 16
+
 // find returns the child of n whose key equals the argument, or nil if no
 // such child exists.
 func (n *tomlClosedNode) find(key []byte) *tomlClosedNode {
+	if n.index != nil {
+		if i, ok := n.index[string(key)]; ok {
+			return &n.children[i]
+		}
+		return nil
+	}
 	for i := range n.children {
 		if bytes.Equal(n.children[i].key, key) {
 			return &n.children[i]
@@ -49,48 +167,89 @@ func (n *tomlClosedNode) child(key []byte) *tomlClosedNode {
 		return child
 	}
 	n.children = append(n.children, tomlClosedNode{key: key})
+	switch {
+	case n.index != nil:
+		n.index[string(key)] = len(n.children) - 1
+	case len(n.children) == tomlClosedIndexMin:
+		n.index = make(map[string]int, 2*tomlClosedIndexMin)
+		for i := range n.children {
+			n.index[string(n.children[i].key)] = i
+		}
+	}
 	return &n.children[len(n.children)-1]
 }
 
-// mark records the path described by stack as closed. The root frame at
-// stack[0] is skipped, so a stack of length one is a no-op (the document root
-// is never closed). Intermediate ancestors are inserted into the trie without
+// mark records the path described by stack as closed, saving its top frame
+// and end, the position of the frame's closing '}'. The root frame is
+// skipped, so a stack of the root alone is a no-op (the document root is
+// never closed). Intermediate ancestors are inserted into the trie without
 // being marked closed; only the deepest node is flagged.
-func (c *tomlClosedTables) mark(stack []tomlFrame) {
-	if len(stack) <= 1 {
+func (c *tomlClosedTables) mark(stack *tomlStack, end tomlPos) {
+	if stack.n <= 1 {
 		return
 	}
 	node := &c.root
-	for i := 1; i < len(stack); i++ {
-		node = node.child(stack[i].key)
+	for i := 1; i < stack.n; i++ {
+		node = node.child(stack.at(i).key)
+	}
+	top := stack.top()
+	node.closed, node.end = true, end
+	node.isAoT, node.explicit, node.needComma = top.isAoT, top.explicit, top.needComma
+	node.usedKeys, node.arrEnd = c.save(top.usedKeys), top.arrEnd
+}
+
+// save returns a copy of keys, in a slice released earlier if there is one.
+func (c *tomlClosedTables) save(keys []tomlKey) []tomlKey {
+	if len(keys) == 0 {
+		return nil
+	}
+	var dst []tomlKey
+	if n := len(c.free); n > 0 {
+		dst, c.free = c.free[n-1][:0], c.free[:n-1]
+	}
+	return append(dst, keys...)
+}
+
+// release adds the used keys saved in the children of n, and recursively
+// in theirs, to the free list.
+func (c *tomlClosedTables) release(n *tomlClosedNode) {
+	for i := range n.children {
+		child := &n.children[i]
+		if keys := child.usedKeys; keys != nil {
+			clear(keys)
+			c.free = append(c.free, keys)
+		}
+		c.release(child)
 	}
-	node.closed = true
 }
 
-// contains reports whether the exact dotted path has previously been marked
-// closed. It returns false if any segment of the path is missing from the
-// trie, or if the terminal node exists but has not been flagged closed.
-func (c *tomlClosedTables) contains(path [][]byte) bool {
+// lookup returns the node for path when the table it names has been closed,
+// or nil.
+func (c *tomlClosedTables) lookup(path [][]byte) *tomlClosedNode {
 	node := &c.root
 	for _, key := range path {
-		node = node.find(key)
-		if node == nil {
-			return false
+		if node = node.find(key); node == nil {
+			return nil
 		}
 	}
-	return node.closed
+	if !node.closed {
+		return nil
+	}
+	return node
 }
 
-// reopens reports whether opening path would re-enter a table that has
-// already been closed. commonDepth is the number of leading segments that
-// path shares with the currently open section stack; only the suffix beyond
-// that prefix is checked, since the shared prefix is by definition still
-// open.
-func (c *tomlClosedTables) reopens(path [][]byte, commonDepth int) bool {
-	for i := commonDepth; i < len(path); i++ {
-		if c.contains(path[:i+1]) {
-			return true
+// forget drops every closed path below the one described by stack. A new
+// array-of-tables element starts with none of its sub-tables defined, so a
+// header naming one of the previous element's opens a fresh table instead of
+// re-entering the old one.
+func (c *tomlClosedTables) forget(stack *tomlStack) {
+	node := &c.root
+	for i := 1; i < stack.n; i++ {
+		if node = node.find(stack.at(i).key); node == nil {
+			return
 		}
 	}
-	return false
+	c.release(node)
+	node.children = node.children[:0]
+	node.index = nil
 }
@@ -0,0 +1,102 @@
+package tojson
+
+import (
+	"bytes"
+)
+
+// FromTOMLTagged converts TOML to the tagged JSON of the toml-test suite
+// (https://github.com/toml-lang/toml-test), as FromTOMLWithOptions does
+// with TOMLOptions.Tagged set.
+func FromTOMLTagged(src []byte) ([]byte, error) {
+	return FromTOMLWithOptions(src, TOMLOptions{Tagged: true})
+}
+
+// writeTOMLString writes the decoded TOML string str to buf as a JSON
+// string, or in tagged form when opts.Tagged is set.
+func writeTOMLString(str []byte, buf *bytes.Buffer, opts *TOMLOptions) {
+	if opts.Tagged {
+		buf.Write(appendTOMLTaggedString(buf.AvailableBuffer(), str))
+		return
+	}
+	writeTOMLKey(str, buf)
+}
+
+// writeTOMLKey writes the decoded TOML key or string s to buf as a JSON
+// string. Unlike writeJSONString it keeps a CR before a newline, which after
+// decoding can only come from an escape such as \r.
+func writeTOMLKey(s []byte, buf *bytes.Buffer) {
+	buf.Write(appendStringCR(buf.AvailableBuffer(), s, true))
+}
+
+// tomlStringNode is writeTOMLString for the tree converter.
+func tomlStringNode(str []byte, opts *TOMLOptions) *jnode {
+	if opts.Tagged {
+		return &jnode{raw: appendTOMLTaggedString(make([]byte, 0, len(str)+28), str)}
+	}
+	return scalarStringNode(str)
+}
+
+// appendTOMLTaggedString appends {"type":"string","value":str} to dst.
+func appendTOMLTaggedString(dst, str []byte) []byte {
+	dst = append(dst, `{"type":"string","value":`...)
+	dst = appendStringCR(dst, str, true)
+	return append(dst, '}')
+}
+
+// appendTOMLTagged appends the tagged form of s, a TOML boolean, number, or
+// date-time, to dst. The value of a number is its decimal form, with -0 as 0
+// for an integer, and that of nan or inf is "nan", "inf", or "-inf". The
+// value of a date-time is its RFC 3339 form, as TOMLDateTimeRFC3339 writes
+// it. Date-times are read under opts.Version, and opts.Strict rejects an
+// integer outside int64.
+func appendTOMLTagged(dst, s []byte, opts *TOMLOptions) ([]byte, error) {
+	var typ string
+	value := s
+	switch {
+	case bytes.Equal(s, rawTrue), bytes.Equal(s, rawFalse):
+		typ = "bool"
+	case isTOMLDateTime(s):
+		kind, norm, err := parseTOMLDateTime(s, opts.v11())
+		if err != nil {
+			return dst, err
+		}
+		typ, value = tomlTaggedDateTimeTypes[kind], norm
+	default:
+		if nan, neg, ok := tomlNonFinite(s); ok {
+			switch {
+			case nan:
+				value = []byte("nan")
+			case neg:
+				value = []byte("-inf")
+			default:
+				value = []byte("inf")
+			}
+			typ = "float"
+			break
+		}
+		raw, err := parseTOMLNumber(s, intFormat{within64: opts.Strict})
+		if err != nil {
+			return dst, err
+		}
+		typ, value = "integer", raw
+		switch {
+		case bytes.ContainsAny(raw, ".eE"):
+			typ = "float"
+		case string(raw) == "-0":
+			value = raw[1:] // an integer has no negative zero
+		}
+	}
+	dst = append(dst, `{"type":"`...)
+	dst = append(dst, typ...)
+	dst = append(dst, `","value":`...)
+	dst = appendString(dst, value)
+	return append(dst, '}'), nil
+}
+
+// tomlTaggedDateTimeTypes holds toml-test's type for each tomlDateTimeKind.
+var tomlTaggedDateTimeTypes = [...]string{
+	tomlOffsetDateTime: "datetime",
+	tomlLocalDateTime:  "datetime-local",
+	tomlLocalDate:      "date-local",
+	tomlLocalTime:      "time-local",
+}
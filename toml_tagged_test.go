@@ -0,0 +1,216 @@
+package tojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOMLTagged(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"string", `s = "a\tb"`, `{"s":{"type":"string","value":"a\tb"}}`},
+		{"literal string", `s = 'C:\x'`, `{"s":{"type":"string","value":"C:\\x"}}`},
+		{"multiline string", "s = \"\"\"\none\ntwo\"\"\"\n", `{"s":{"type":"string","value":"one\ntwo"}}`},
+		{"multiline literal", "s = '''\none\n'''\n", `{"s":{"type":"string","value":"one\n"}}`},
+		{"integer", "i = +1_000", `{"i":{"type":"integer","value":"1000"}}`},
+		{"negative zero", "i = [-0, +0, -0.0]",
+			`{"i":[{"type":"integer","value":"0"},{"type":"integer","value":"0"},{"type":"float","value":"-0.0"}]}`},
+		{"hex", "i = 0xff", `{"i":{"type":"integer","value":"255"}}`},
+		{"big integer", "i = 0x1_0000_0000_0000_0000", `{"i":{"type":"integer","value":"18446744073709551616"}}`},
+		{"float", "f = 1e3", `{"f":{"type":"float","value":"1e3"}}`},
+		{"float fraction", "f = -0.5", `{"f":{"type":"float","value":"-0.5"}}`},
+		{"inf", "f = [inf, +inf, -inf, nan, -nan]",
+			`{"f":[{"type":"float","value":"inf"},{"type":"float","value":"inf"},{"type":"float","value":"-inf"},{"type":"float","value":"nan"},{"type":"float","value":"nan"}]}`},
+		{"bool", "b = [true, false]", `{"b":[{"type":"bool","value":"true"},{"type":"bool","value":"false"}]}`},
+		{"datetime", "d = 1979-05-27 07:32:00z", `{"d":{"type":"datetime","value":"1979-05-27T07:32:00Z"}}`},
+		{"datetime-local", "d = 1979-05-27T07:32:00", `{"d":{"type":"datetime-local","value":"1979-05-27T07:32:00"}}`},
+		{"date-local", "d = 1979-05-27", `{"d":{"type":"date-local","value":"1979-05-27"}}`},
+		{"time-local", "d = 07:32:00.5", `{"d":{"type":"time-local","value":"07:32:00.5"}}`},
+		{"inline table", "t = {a = 1, b.c = 'x'}", `{"t":{"a":{"type":"integer","value":"1"},"b":{"c":{"type":"string","value":"x"}}}}`},
+		{"empty", "a = []\nt = {}", `{"a":[],"t":{}}`},
+		{"tables", "[a]\nx = 1\n[[b]]\ny = 2", `{"a":{"x":{"type":"integer","value":"1"}},"b":[{"y":{"type":"integer","value":"2"}}]}`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := FromTOMLTagged([]byte(tc.input))
+			if err != nil || string(got) != tc.want {
+				t.Errorf("got %s, %v, want %s", got, err, tc.want)
+			}
+			var buf bytes.Buffer
+			if err := tomlConvertTree(&buf, []byte(tc.input), nil, TOMLOptions{Tagged: true}); err != nil || buf.String() != tc.want {
+				t.Errorf("tree: got %s, %v, want %s", buf.Bytes(), err, tc.want)
+			}
+		})
+	}
+}
+
+func TestTOMLTaggedOverrides(t *testing.T) {
+	// Tagged output has no need of the policies for plain JSON.
+	opts := TOMLOptions{
+		Tagged:         true,
+		NonFinite:      NonFiniteError,
+		UnsafeIntegers: UnsafeIntegerError,
+		DateTimes:      TOMLDateTimeTagged,
+	}
+	got, err := FromTOMLWithOptions([]byte("a = [nan, 0xffff_ffff_ffff_ffff_ff, 1979-05-27]"), opts)
+	want := `{"a":[{"type":"float","value":"nan"},{"type":"integer","value":"4722366482869645213695"},{"type":"date-local","value":"1979-05-27"}]}`
+	if err != nil || string(got) != want {
+		t.Errorf("got %s, %v, want %s", got, err, want)
+	}
+
+	// Invalid values are still errors.
+	for _, in := range []string{"a = 2026-02-30", "a = 0123", `a = "\q"`, "a = [1, 2x]"} {
+		if _, err := FromTOMLTagged([]byte(in)); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
+
+// TestTOMLTestSuite runs the toml-test corpus vendored into
+// testdata/toml/toml-test.
+func TestTOMLTestSuite(t *testing.T) {
+	runTOMLTestSuite(t, "testdata/toml/toml-test")
+}
+
+// runTOMLTestSuite runs the cases in dir, laid out as toml-test's tests
+// directory: each valid/**/*.toml beside a .json file of its expected tagged
+// JSON, and each invalid/**/*.toml expected to fail. As in toml-test's own
+// runner, the files-toml-1.0.0 and files-toml-1.1.0 lists name the cases of
+// each TOML version, which are converted with the matching
+// TOMLOptions.Version and with Strict set.
+func runTOMLTestSuite(t *testing.T, dir string) {
+	for _, v := range []struct {
+		list    string
+		version TOMLVersion
+	}{{"files-toml-1.0.0", TOML10}, {"files-toml-1.1.0", TOML11}} {
+		list, err := os.ReadFile(filepath.Join(dir, v.list))
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := TOMLOptions{Version: v.version, Tagged: true, Strict: true}
+		var valid, invalid []string
+		for _, name := range strings.Fields(string(list)) {
+			if path.Ext(name) != ".toml" {
+				continue
+			}
+			switch strings.SplitN(name, "/", 2)[0] {
+			case "valid":
+				valid = append(valid, name)
+			case "invalid":
+				invalid = append(invalid, name)
+			}
+		}
+		if len(valid) == 0 || len(invalid) == 0 {
+			t.Fatalf("%s/%s: no valid or invalid cases listed", dir, v.list)
+		}
+		t.Run(v.list, func(t *testing.T) {
+			for _, name := range valid {
+				t.Run(name, func(t *testing.T) {
+					f := filepath.Join(dir, filepath.FromSlash(name))
+					src, err := os.ReadFile(f)
+					if err != nil {
+						t.Fatal(err)
+					}
+					golden, err := os.ReadFile(strings.TrimSuffix(f, ".toml") + ".json")
+					if err != nil {
+						t.Fatal(err)
+					}
+					var want any
+					if err := json.Unmarshal(golden, &want); err != nil {
+						t.Fatalf("golden file: %v", err)
+					}
+					got, err := FromTOMLWithOptions(src, opts)
+					if err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					var v any
+					if err := json.Unmarshal(got, &v); err != nil {
+						t.Fatalf("output is not JSON: %v\n%s", err, got)
+					}
+					if !tomlTaggedEqual(v, want) {
+						t.Errorf("got %s\nwant %s", got, golden)
+					}
+				})
+			}
+			for _, name := range invalid {
+				t.Run(name, func(t *testing.T) {
+					src, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if got, err := FromTOMLWithOptions(src, opts); err == nil {
+						t.Errorf("expected error, got %s", got)
+					}
+				})
+			}
+		})
+	}
+}
+
+// tomlTaggedLayouts holds the time.Parse layout of each tagged date-time
+// type. Fractional seconds parse whether or not a layout has them.
+var tomlTaggedLayouts = map[string]string{
+	"datetime":       time.RFC3339Nano,
+	"datetime-local": "2006-01-02T15:04:05",
+	"date-local":     "2006-01-02",
+	"time-local":     "15:04:05",
+}
+
+// tomlTaggedEqual reports whether the decoded tagged JSON a and b are equal
+// as toml-test compares them: floats by value, so that 1e-2 and 0.01 are
+// equal, date-times as times, so that .6 and .600 seconds are equal, and
+// everything else exactly.
+func tomlTaggedEqual(a, b any) bool {
+	switch a := a.(type) {
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !tomlTaggedEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		typ, _ := a["type"].(string)
+		x, okX := a["value"].(string)
+		y, okY := b["value"].(string)
+		if typ == b["type"] && okX && okY {
+			switch layout, isTime := tomlTaggedLayouts[typ]; {
+			case typ == "float":
+				x, errX := strconv.ParseFloat(x, 64)
+				y, errY := strconv.ParseFloat(y, 64)
+				return errX == nil && errY == nil && (x == y || math.IsNaN(x) && math.IsNaN(y))
+			case isTime:
+				r := strings.NewReplacer(" ", "T", "t", "T", "z", "Z")
+				x, errX := time.Parse(layout, r.Replace(x))
+				y, errY := time.Parse(layout, r.Replace(y))
+				return errX == nil && errY == nil && x.Equal(y)
+			}
+		}
+		for k, v := range a {
+			if !tomlTaggedEqual(v, b[k]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
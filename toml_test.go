@@ -1,6 +1,7 @@
 package tojson
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"os"
@@ -22,10 +23,6 @@ var tomlParsers = []struct {
 	{"router", FromTOML},
 }
 
-// skipStreaming is the skip list for tests requiring out-of-order table re-entry,
-// which the line parser cannot handle (it returns errReentry instead of falling back).
-var skipStreaming = []string{"line"}
-
 // forParsers runs f as a subtest for each parser not in the skip list.
 func forParsers(t *testing.T, skip []string, f func(*testing.T, tomlFn)) {
 	t.Helper()
@@ -95,6 +92,11 @@ func TestTOMLMultilineBasic(t *testing.T) {
 		checkTOML(t, fn, "key = \"\"\"hello \\\n   world\"\"\"", `{"key":"hello world"}`)
 		// CRLF line endings inside the multi-line value
 		checkTOML(t, fn, "key = \"\"\"\r\nline one\r\nline two\r\n\"\"\"", `{"key":"line one\nline two\n"}`)
+		// up to two quotes before the closing delimiter are content
+		checkTOML(t, fn, `key = """say "hi""""`, `{"key":"say \"hi\""}`)
+		checkTOML(t, fn, `key = """x"""""`, `{"key":"x\"\""}`)
+		// an escaped quote does not close the string
+		checkTOML(t, fn, "key = \"\"\"\nx \\\"\"\" y\n\"\"\"\nb = 1", `{"key":"x \"\"\" y\n","b":1}`)
 	})
 }
 
@@ -103,6 +105,7 @@ func TestTOMLMultilineLiteral(t *testing.T) {
 		checkTOML(t, fn, "key = '''\nline one\nline two\n'''", `{"key":"line one\nline two\n"}`)
 		// no escape processing
 		checkTOML(t, fn, "key = '''no \\n escape'''", `{"key":"no \\n escape"}`)
+		checkTOML(t, fn, "key = '''it's''''", `{"key":"it's'"}`)
 	})
 }
 
@@ -157,6 +160,8 @@ func TestTOMLKeyForms(t *testing.T) {
 		checkTOML(t, fn, `bare-key = 1`, `{"bare-key":1}`)
 		checkTOML(t, fn, `"quoted key" = 1`, `{"quoted key":1}`)
 		checkTOML(t, fn, `'literal key' = 1`, `{"literal key":1}`)
+		checkTOML(t, fn, `"" = 1`, `{"":1}`)
+		checkTOML(t, fn, `a."".b = 1`, `{"a":{"":{"b":1}}}`)
 	})
 }
 
@@ -168,6 +173,32 @@ func TestTOMLDottedKeys(t *testing.T) {
 	})
 }
 
+func TestTOMLDottedKeyTables(t *testing.T) {
+	// The line parser hands these to the tree parser.
+	forParsers(t, []string{"line"}, func(t *testing.T, fn tomlFn) {
+		checkTOML(t, fn, "apple.type = 1\norange.type = 2\napple.skin = 3\nx.y.a = 4\nx.z = 5\nx.y.b = 6",
+			`{"apple":{"type":1,"skin":3},"orange":{"type":2},"x":{"y":{"a":4,"b":6},"z":5}}`)
+		checkTOML(t, fn, "[fruit]\napple.color = 'red'\napple.taste.sweet = true\n[fruit.apple.texture]\nsmooth = true",
+			`{"fruit":{"apple":{"color":"red","taste":{"sweet":true},"texture":{"smooth":true}}}}`)
+		checkTOML(t, fn, "[fruit]\napple.color = 'red'\n[[fruit.apple.seeds]]\nsize = 2",
+			`{"fruit":{"apple":{"color":"red","seeds":[{"size":2}]}}}`)
+	})
+	forParsers(t, nil, func(t *testing.T, fn tomlFn) {
+		for _, in := range []string{
+			"[fruit]\napple.color = 'red'\n[fruit.apple]",
+			"[fruit]\napple.taste.sweet = true\n[fruit.apple.taste]",
+			"[fruit]\napple.taste.sweet = true\nx.y = 1\napple.taste = 2",
+			"[a.b.c]\nz = 1\n[a]\nb.c.t = 2",
+			"a = {b = 1}\nx.y = 1\na.c = 2",
+			"x.y = 1\nz.y = 1\nx.y.z = 1",
+		} {
+			if _, err := fn([]byte(in)); err == nil {
+				t.Errorf("%q: expected error", in)
+			}
+		}
+	})
+}
+
 // --------------------------------------------------------------------------
 // Standard tables
 // --------------------------------------------------------------------------
@@ -195,8 +226,7 @@ func TestTOMLMultipleTables(t *testing.T) {
 
 func TestTOMLImplicitTables(t *testing.T) {
 	// [a.b] creates 'a' implicitly; then [a] can add sibling keys.
-	// Streaming parser treats this as re-entry into a closed section.
-	forParsers(t, skipStreaming, func(t *testing.T, fn tomlFn) {
+	forParsers(t, nil, func(t *testing.T, fn tomlFn) {
 		checkTOML(t, fn,
 			"[a.b]\nx = 1\n[a]\ny = 2",
 			`{"a":{"b":{"x":1},"y":2}}`)
@@ -205,7 +235,7 @@ func TestTOMLImplicitTables(t *testing.T) {
 
 func TestTOMLTableReentry(t *testing.T) {
 	// Critical: [a] ... [b] ... [a.c] must re-enter the 'a' object.
-	forParsers(t, skipStreaming, func(t *testing.T, fn tomlFn) {
+	forParsers(t, nil, func(t *testing.T, fn tomlFn) {
 		checkTOML(t, fn,
 			"[a]\nx = 1\n[b]\ny = 2\n[a.c]\nz = 3",
 			`{"a":{"x":1,"c":{"z":3}},"b":{"y":2}}`)
@@ -224,11 +254,15 @@ func TestTOMLLineOrderedTablesStayOnFastPath(t *testing.T) {
 	}
 }
 
-// TestTOMLLineOutOfOrderTableReentry verifies the errReentry sentinel is returned.
+// TestTOMLLineOutOfOrderTableReentry verifies re-entry is handled in one pass.
 func TestTOMLLineOutOfOrderTableReentry(t *testing.T) {
-	_, err := fromTOMLLine([]byte("[fruit.apple]\nx = 1\n[animal]\nz = 3\n[fruit.orange]\ny = 2"))
-	if err != errReentry {
-		t.Fatalf("fromTOMLLine error = %v, want errReentry", err)
+	got, err := fromTOMLLine([]byte("[fruit.apple]\nx = 1\n[animal]\nz = 3\n[fruit.orange]\ny = 2"))
+	if err != nil {
+		t.Fatalf("fromTOMLLine error: %v", err)
+	}
+	want := `{"fruit":{"apple":{"x":1},"orange":{"y":2}},"animal":{"z":3}}`
+	if string(got) != want {
+		t.Fatalf("fromTOMLLine = %s, want %s", got, want)
 	}
 }
 
@@ -274,6 +308,19 @@ func TestTOMLNestedAoT(t *testing.T) {
 	})
 }
 
+// A [[header]] after a sub-table of the previous element starts a new element,
+// whose sub-tables may be defined again without leaving the line parser.
+func TestTOMLAoTAfterSubTable(t *testing.T) {
+	forParsers(t, nil, func(t *testing.T, fn tomlFn) {
+		checkTOML(t, fn,
+			"[[a]]\nx = 1\n[a.c]\nz = 3\n[[a]]\nx = 2\n[a.c]\nz = 4",
+			`{"a":[{"x":1,"c":{"z":3}},{"x":2,"c":{"z":4}}]}`)
+		checkTOML(t, fn,
+			"[[a]]\nx = 1\n[[a.b]]\ny = 2\n[a.c]\nz = 3\n[[a]]\nx = 2",
+			`{"a":[{"x":1,"b":[{"y":2}],"c":{"z":3}},{"x":2}]}`)
+	})
+}
+
 func TestTOMLMixedTableAndAoT(t *testing.T) {
 	forParsers(t, nil, func(t *testing.T, fn tomlFn) {
 		checkTOML(t, fn,
@@ -283,11 +330,11 @@ func TestTOMLMixedTableAndAoT(t *testing.T) {
 }
 
 // Out-of-order AoT: [[section]] reappears after an intervening [other] section,
-// which closes the first section and prevents the streaming path from handling
-// the re-entry. These tests exercise the tree-based fallback (tomlConvertTree).
+// which closes the first section, so the line parser must splice the new
+// element into the array it has already written.
 
 func TestTOMLAoTOutOfOrder(t *testing.T) {
-	forParsers(t, skipStreaming, func(t *testing.T, fn tomlFn) {
+	forParsers(t, nil, func(t *testing.T, fn tomlFn) {
 		checkTOML(t, fn,
 			"[[a]]\nx = 1\n[b]\ny = 2\n[[a]]\nx = 2",
 			`{"a":[{"x":1},{"x":2}],"b":{"y":2}}`)
@@ -296,7 +343,7 @@ func TestTOMLAoTOutOfOrder(t *testing.T) {
 
 func TestTOMLAoTOutOfOrderStrings(t *testing.T) {
 	// string values exercise scalarStringNode via parseTOMLValue in tree path
-	forParsers(t, skipStreaming, func(t *testing.T, fn tomlFn) {
+	forParsers(t, nil, func(t *testing.T, fn tomlFn) {
 		checkTOML(t, fn,
 			"[[items]]\nname = \"hammer\"\n[meta]\nv = 1\n[[items]]\nname = \"nail\"",
 			`{"items":[{"name":"hammer"},{"name":"nail"}],"meta":{"v":1}}`)
@@ -305,7 +352,7 @@ func TestTOMLAoTOutOfOrderStrings(t *testing.T) {
 
 func TestTOMLAoTOutOfOrderInlineArray(t *testing.T) {
 	// inline array values exercise parseTOMLInlineArray via parseTOMLValue in tree path
-	forParsers(t, skipStreaming, func(t *testing.T, fn tomlFn) {
+	forParsers(t, nil, func(t *testing.T, fn tomlFn) {
 		checkTOML(t, fn,
 			"[[items]]\nnums = [1, 2, 3]\n[meta]\nv = 1\n[[items]]\nnums = [4, 5]",
 			`{"items":[{"nums":[1,2,3]},{"nums":[4,5]}],"meta":{"v":1}}`)
@@ -313,14 +360,97 @@ func TestTOMLAoTOutOfOrderInlineArray(t *testing.T) {
 }
 
 func TestTOMLTableAfterAoTReentry(t *testing.T) {
-	// [a] after [[a]] in the tree path enters the last aot element as context
-	forParsers(t, skipStreaming, func(t *testing.T, fn tomlFn) {
+	// [a] after [[a]] enters the last aot element as context
+	forParsers(t, nil, func(t *testing.T, fn tomlFn) {
 		checkTOML(t, fn,
 			"[[a]]\nx = 1\n[b]\ny = 2\n[a]\nz = 3",
 			`{"a":[{"x":1,"z":3}],"b":{"y":2}}`)
 	})
 }
 
+func TestTOMLReentrySplices(t *testing.T) {
+	// Each re-entry writes into a splice of the output already written; these
+	// re-enter tables inside splices and splices inside arrays of tables.
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"twice",
+			"[a]\nx = 1\n[b]\n[a.c]\ny = 2\n[d]\n[a.e]\nz = 3",
+			`{"a":{"x":1,"c":{"y":2},"e":{"z":3}},"b":{},"d":{}}`},
+		{"table in splice",
+			"[a]\n[b]\n[a.c]\nx = 1\n[d]\n[a.c.e]\ny = 2\n[a.f]",
+			`{"a":{"c":{"x":1,"e":{"y":2}},"f":{}},"b":{},"d":{}}`},
+		{"empty table",
+			"[a]\n[b]\n[a.c]\nx = 1",
+			`{"a":{"c":{"x":1}},"b":{}}`},
+		{"aot elements",
+			"[[a]]\nx = 1\n[b]\n[[a]]\nx = 2\n[[a]]\nx = 3\n[c]\n[[a]]\nx = 4",
+			`{"a":[{"x":1},{"x":2},{"x":3},{"x":4}],"b":{},"c":{}}`},
+		{"aot last element",
+			"[[a]]\nx = 1\n[[a]]\nx = 2\n[b]\n[a.c]\ny = 3\n[d]\n[[a]]\n[e]\n[a.c]",
+			`{"a":[{"x":1},{"x":2,"c":{"y":3}},{"c":{}}],"b":{},"d":{},"e":{}}`},
+		{"aot in splice",
+			"[a]\n[b]\n[[a.c]]\nx = 1\n[d]\n[[a.c]]\nx = 2",
+			`{"a":{"c":[{"x":1},{"x":2}]},"b":{},"d":{}}`},
+		{"multi-line values",
+			"[a.z]\n[b]\n[a]\ns = \"\"\"\none\"\"\"\nl = [\n  1,\n  2,\n]\nd.e = 'x'",
+			`{"a":{"z":{},"s":"one","l":[1,2],"d":{"e":"x"}},"b":{}}`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			forParsers(t, nil, func(t *testing.T, fn tomlFn) {
+				checkTOML(t, fn, tc.input, tc.want)
+			})
+			// Splices are placed relative to the document, not to dst.
+			var c Converter
+			for range 2 {
+				got, err := c.AppendTOML([]byte("x"), []byte(tc.input))
+				if err != nil || string(got) != "x"+tc.want {
+					t.Errorf("Converter: got %s, %v", got, err)
+				}
+			}
+		})
+	}
+
+	// One Converter for all of them reuses the used keys that each saved.
+	var c Converter
+	for range 2 {
+		for _, tc := range tests {
+			if got, err := c.AppendTOML(nil, []byte(tc.input)); err != nil || string(got) != tc.want {
+				t.Errorf("shared Converter %s: got %s, %v", tc.name, got, err)
+			}
+		}
+	}
+}
+
+func TestTOMLReentryErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{"[a]\nx = 1\n[b]\n[a]", "duplicate table header [a]"},
+		{"[a.c]\n[b]\n[a]\n[d]\n[a]", "duplicate table header [a]"},
+		{"[a]\nc = 1\n[b]\n[a.c]", `cannot define table "a.c": key already has a value`},
+		{"[a]\nx = 1\n[b]\n[a.c]\n[d]\n[a.x]", `cannot define table "a.x": key already has a value`},
+		{"[a]\nx = 1\n[b]\n[a.c]\n[d]\n[a.c.x]\n[a]\nx = 2", "duplicate table header [a]"},
+		{"[a]\n[b]\n[[a]]", "cannot use [[a]]: key already exists as a non-array"},
+		{"[a]\n[[a]]", "cannot use [[a]]: key already exists as a non-array"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			_, err := fromTOMLLine([]byte(tc.input))
+			if pe := requireParseError(t, err); !strings.Contains(pe.Message, tc.msg) {
+				t.Errorf("got %q, want %q", pe.Message, tc.msg)
+			}
+			if _, err := fromTOMLTree([]byte(tc.input)); err == nil {
+				t.Error("tree: expected error")
+			}
+		})
+	}
+}
+
 // --------------------------------------------------------------------------
 // Inline tables
 // --------------------------------------------------------------------------
@@ -358,9 +488,108 @@ func TestTOMLInlineArrayMultiline(t *testing.T) {
 		checkTOML(t, fn, "nums = [\n  1,\n  2,\n  3,\n]", `{"nums":[1,2,3]}`)
 		// CRLF line endings inside the array
 		checkTOML(t, fn, "nums = [\r\n  1,\r\n  2,\r\n]", `{"nums":[1,2]}`)
+		// nested arrays spanning lines, with a comment
+		checkTOML(t, fn, "nested = [[1, # one\n  2], 3]\nafter = 1", `{"nested":[[1,2],3],"after":1}`)
 	})
 }
 
+func TestTOML11(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		in10  bool // also accepted, differently, in TOML 1.0
+	}{
+		{"multiline inline table", "t = {\n  a = 1,\n  b = 'x'\n}\nafter = 2",
+			`{"t":{"a":1,"b":"x"},"after":2}`, false},
+		{"inline table comments", "t = { # open\n  a = 1, # one\n  # alone\n  b.c = 2 }",
+			`{"t":{"a":1,"b":{"c":2}}}`, false},
+		{"inline table trailing comma", "t = {a = 1, b = 2,}", `{"t":{"a":1,"b":2}}`, false},
+		{"nested multiline", "t = {\n  u = {\n    v = [\n      1,\n    ],\n  },\n}",
+			`{"t":{"u":{"v":[1]}}}`, false},
+		{"in array", "a = [\n  {x = 1,\n   y = 2,},\n  {},\n]", `{"a":[{"x":1,"y":2},{}]}`, false},
+		{"escape e", `s = "\e[0m"`, `{"s":"\u001b[0m"}`, false},
+		{"escape x", `s = "caf\xE9 \x41"`, `{"s":"café A"}`, true},
+		{"multiline escapes", "s = \"\"\"\n\\e\\x7e\"\"\"", `{"s":"\u001b~"}`, false},
+		{"key escape", `"\x41" = 1`, `{"A":1}`, true},
+		{"time without seconds", "t = 07:32\nd = 1979-05-27T07:32Z", `{"t":"07:32","d":"1979-05-27T07:32Z"}`, false},
+	}
+	opts := TOMLOptions{Version: TOML11}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			checkTOML(t, func(src []byte) ([]byte, error) {
+				return FromTOMLWithOptions(src, opts)
+			}, tc.input, tc.want)
+			checkTOML(t, func(src []byte) ([]byte, error) {
+				var buf bytes.Buffer
+				err := tomlConvertTree(&buf, src, nil, opts)
+				return buf.Bytes(), err
+			}, tc.input, tc.want)
+
+			if got, err := FromTOML([]byte(tc.input)); err == nil && !tc.in10 {
+				t.Errorf("TOML 1.0: expected error, got %s", got)
+			}
+		})
+	}
+}
+
+func TestTOML11Errors(t *testing.T) {
+	tests := []struct {
+		input     string
+		line, col int
+		msg       string
+	}{
+		{"t = {\n  a = 1,\n", 1, 5, "unterminated inline table"},
+		{"t = {a = 1,, b = 2}", 1, 5, "empty key"},
+		{`s = "\x4"`, 1, 5, `invalid \x escape`},
+		{`s = "\xZZ"`, 1, 5, `invalid \x escape`},
+		{"t = 07:3x", 1, 5, "expected a time of the form HH:MM:SS"},
+		{"t = 24:00", 1, 5, "time out of range"},
+		{"t = 07:32.5", 1, 5, "expected seconds before the fraction"},
+		{"t = 07:32:5", 1, 5, "expected two digits of seconds after HH:MM:"},
+		{"t = {\n  a = 1,\n  b = 2x,\n}\n", 3, 7, "invalid number: 2x"},
+		{"t = {\n  a = 1\n  b = 2\n}\n", 3, 3, "expected ',' or '}' in inline table"},
+		{"t = {\n  a = 1,\n  a = 2,\n}\n", 3, 3, `duplicate key "a" in inline table`},
+		{"t = {\n  a = [\n    1,\n    07:32:5,\n  ],\n}\n", 4, 5, "expected two digits of seconds"},
+		{"[x]\n[y]\n[x.z]\nt = {\n  a = 1,\n  b = 2x,\n}\n", 6, 7, "invalid number: 2x"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			_, err := FromTOMLWithOptions([]byte(tc.input), TOMLOptions{Version: TOML11})
+			pe := requireParseError(t, err)
+			if pe.Line != tc.line || pe.Column != tc.col || !strings.Contains(pe.Message, tc.msg) {
+				t.Errorf("got %v, want line %d, column %d: %s", pe, tc.line, tc.col, tc.msg)
+			}
+		})
+	}
+}
+
+func TestTOML11EscapesIn10(t *testing.T) {
+	tests := []struct {
+		input  string
+		strict bool
+		msg    string
+	}{
+		{`s = "\e[0m"`, false, `\e is a TOML 1.1 escape`},
+		{`s = "\\\e"`, false, `\e is a TOML 1.1 escape`},
+		{`s = "\e"`, true, `\e is a TOML 1.1 escape`},
+		{"s = \"\"\"\n\\e\"\"\"", false, `\e is a TOML 1.1 escape`},
+		{`t = {s = "\e"}`, false, `\e is a TOML 1.1 escape`},
+		{`"\e" = 1`, false, `\e is a TOML 1.1 escape`},
+		{`s = "\x41"`, true, `\x is a TOML 1.1 escape`},
+	}
+	for _, tc := range tests {
+		_, err := FromTOMLWithOptions([]byte(tc.input), TOMLOptions{Strict: tc.strict})
+		pe := requireParseError(t, err)
+		if !strings.Contains(pe.Message, tc.msg) {
+			t.Errorf("%q: got %v, want %s", tc.input, pe, tc.msg)
+		}
+	}
+	if got, err := FromTOML([]byte(`s = "\\e"`)); err != nil || string(got) != `{"s":"\\e"}` {
+		t.Errorf("escaped backslash: got %s, %v", got, err)
+	}
+}
+
 func TestTOMLUnterminatedMultiline(t *testing.T) {
 	forParsers(t, nil, func(t *testing.T, fn tomlFn) {
 		for _, input := range []string{
@@ -384,6 +613,8 @@ func TestTOMLComments(t *testing.T) {
 		checkTOML(t, fn, "# full line comment\nkey = 1", `{"key":1}`)
 		checkTOML(t, fn, `key = 1 # inline comment`, `{"key":1}`)
 		checkTOML(t, fn, "# comment\n[section]\n# another\nkey = 2", `{"section":{"key":2}}`)
+		// no space is needed before a comment
+		checkTOML(t, fn, "[s]#c\nkey = 1#c\nlist = [1]#c\nstr = 'x'#c", `{"s":{"key":1,"list":[1],"str":"x"}}`)
 	})
 }
 
@@ -501,6 +732,83 @@ func TestTOMLErrorInvalidEscape(t *testing.T) {
 	})
 }
 
+func TestTOMLErrorGrammar(t *testing.T) {
+	forParsers(t, nil, func(t *testing.T, fn tomlFn) {
+		for _, input := range []string{
+			"n = 1.", "n = .5", "n = +.5", "n = 1.e5", "n = 1.5e", "n = 1e+",
+			"n = 1_.5", "n = 1._5", "n = 1_", "n = 0x_1", "n = 0x1_", "n = 0X1", "n = 0B1",
+			"a. = 1", ".a = 1", "[a.]", "'a = 1", "s = 'abc",
+		} {
+			if got, err := fn([]byte(input)); err == nil {
+				t.Errorf("input %q: expected error, got %s", input, got)
+			}
+		}
+	})
+}
+
+func TestTOMLStrict(t *testing.T) {
+	tests := []struct {
+		input     string
+		lenient   string // output without Strict
+		line, col int
+		msg       string
+	}{
+		{"a = 1\nb = \"x\x01\"", `{"a":1,"b":"x\u0001"}`, 2, 7, "control character U+0001 is not allowed"},
+		{"a = 1\r", `{"a":1}`, 1, 6, "control character U+000D is not allowed"},
+		{"# \xff\na = 1", `{"a":1}`, 1, 3, "invalid UTF-8"},
+		{`a = "\x41"`, `{"a":"A"}`, 1, 5, `\x is a TOML 1.1 escape; set TOMLOptions.Version to TOML11 to accept it`},
+		{`a = "\a"`, `{"a":"\u0007"}`, 1, 5, `invalid escape \a`},
+		{"a = \"\"\"\nx \\ y\"\"\"", `{"a":"x y"}`, 1, 5, `invalid escape \ `},
+		{`a = "x" "y"`, `{"a":"x"}`, 1, 5, `unexpected content after value: "y"`},
+		{"a = [\n  1,\n] 2", `{"a":[1]}`, 1, 5, "unexpected content after value: 2"},
+		{"a = '''\nx''' y # c", `{"a":"x"}`, 1, 5, "unexpected content after value: y"},
+		{"a = {b = {c = 1}, b.d = 2}", `{"a":{"b":{"c":1,"d":2}}}`, 1, 5, `cannot extend inline table "b" with a dotted key`},
+		{"[[a]]\nx = 1\n[b]\n[a]", `{"a":[{"x":1}],"b":{}}`, 4, 1, "cannot use [a]: key already exists as an array of tables"},
+		{"a.b = 1\nx = 1\na.c = 2\n[[t]]\n[t]", `{"a":{"b":1,"c":2},"x":1,"t":[{}]}`, 5, 1, "cannot use [t]: key already exists as an array of tables"},
+		{"a.b = 1\nx = 1\na.c = '''\ny''' z", `{"a":{"b":1,"c":"y"},"x":1}`, 3, 7, "unexpected content after value: z"},
+		{"a = \"\"\"t\\ \"\"\"", `{"a":"t"}`, 1, 5, `invalid escape \ `},
+		{"a = 9223372036854775808", `{"a":9223372036854775808}`, 1, 5, "integer 9223372036854775808 is outside the range of a 64-bit integer"},
+		{"a = -9223372036854775809", `{"a":-9223372036854775809}`, 1, 5, "integer -9223372036854775809 is outside the range of a 64-bit integer"},
+		{"a = 0x1_0000_0000_0000_0000", `{"a":18446744073709551616}`, 1, 5, "integer 18446744073709551616 is outside the range of a 64-bit integer"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := FromTOML([]byte(tc.input))
+			if err != nil || string(got) != tc.lenient {
+				t.Errorf("lenient: got %s, %v, want %s", got, err, tc.lenient)
+			}
+			_, err = FromTOMLWithOptions([]byte(tc.input), TOMLOptions{Strict: true})
+			pe := requireParseError(t, err)
+			if pe.Line != tc.line || pe.Column != tc.col || !strings.HasSuffix(pe.Message, tc.msg) {
+				t.Errorf("got %v, want line %d, column %d: %s", pe, tc.line, tc.col, tc.msg)
+			}
+			// After a dotted key re-enters its table, the line parser
+			// hands the whole file to the tree parser, which makes the
+			// same checks.
+			_, err = FromTOMLWithOptions([]byte("z.a = 1\ny = 1\nz.b = 2\n"+tc.input), TOMLOptions{Strict: true})
+			pe = requireParseError(t, err)
+			if pe.Line != tc.line+3 || pe.Column != tc.col || !strings.HasSuffix(pe.Message, tc.msg) {
+				t.Errorf("after re-entry: got %v, want line %d, column %d: %s", pe, tc.line+3, tc.col, tc.msg)
+			}
+		})
+	}
+
+	// Both conversions reject these, as Strict does with Tagged set.
+	for _, in := range []string{"a = [{\n  x = 1\n}]", "\u3000a = 1", "v = Inf", "v = NaN", "a = 9223372036854775808"} {
+		if got, err := FromTOMLWithOptions([]byte(in), TOMLOptions{Strict: true, Tagged: true}); err == nil {
+			t.Errorf("%q: expected error, got %s", in, got)
+		}
+	}
+
+	// Dotted keys may still build up an inline table, and valid input
+	// converts as it does without Strict.
+	src := []byte("a = {b.c = 1, b.d = '''x''''}\ns = \"\\u00e9\" # c\n[[t]]\n[t.u]")
+	want, _ := FromTOML(src)
+	if got, err := FromTOMLWithOptions(src, TOMLOptions{Strict: true}); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("got %s, %v, want %s", got, err, want)
+	}
+}
+
 func TestTOMLErrorInlineTableTrailingComma(t *testing.T) {
 	forParsers(t, nil, func(t *testing.T, fn tomlFn) {
 		if _, err := fn([]byte(`t = {a = 1,}`)); err == nil {
@@ -509,22 +817,61 @@ func TestTOMLErrorInlineTableTrailingComma(t *testing.T) {
 	})
 }
 
-func TestTOMLErrorNestingLimit(t *testing.T) {
-	// [a.b.c.d] is exactly at the limit (tomlMaxNesting = 4); must succeed.
-	ok := "[a.b.c.d]\nk = 1"
+func TestTOMLDeepNesting(t *testing.T) {
+	// Headers at, just past, and well past the depth held in tomlStack.buf.
 	forParsers(t, nil, func(t *testing.T, fn tomlFn) {
-		if _, err := fn([]byte(ok)); err != nil {
-			t.Errorf("depth-%d header rejected: %v", tomlMaxNesting, err)
-		}
+		checkTOML(t, fn, "[a.b.c.d]\nk = 1", `{"a":{"b":{"c":{"d":{"k":1}}}}}`)
+		checkTOML(t, fn, "[a.b.c.d.e]\nk = 1", `{"a":{"b":{"c":{"d":{"e":{"k":1}}}}}}`)
+		checkTOML(t, fn,
+			"[spec.template.spec.containers.resources.limits]\ncpu = '1'\n"+
+				"[spec.template.spec.containers.resources.requests]\ncpu = '0.5'\n"+
+				"[[spec.template.spec.volumes.a.b.c]]\nx = 1\n[[spec.template.spec.volumes.a.b.c]]\nx = 2\n"+
+				"[spec.template.metadata]\nname = 'n'\n",
+			`{"spec":{"template":{"spec":{"containers":{"resources":{"limits":{"cpu":"1"},"requests":{"cpu":"0.5"}}},`+
+				`"volumes":{"a":{"b":{"c":[{"x":1},{"x":2}]}}}},"metadata":{"name":"n"}}}}`)
 	})
 
-	// [a.b.c.d.e] is one level too deep; only the line parser enforces the limit.
-	over := "[a.b.c.d.e]\nk = 1"
-	forParsers(t, []string{"streaming", "tree", "router"}, func(t *testing.T, fn tomlFn) {
-		if _, err := fn([]byte(over)); err == nil {
-			t.Errorf("depth-%d header accepted, want error", tomlMaxNesting+1)
-		}
+	// Deep sections close, and are re-entered, as shallow ones are.
+	forParsers(t, nil, func(t *testing.T, fn tomlFn) {
+		checkTOML(t, fn, "[a.b.c.d.e.f]\nk = 1\n[x]\n[a.b.c.d.e.g]\nk = 2",
+			`{"a":{"b":{"c":{"d":{"e":{"f":{"k":1},"g":{"k":2}}}}}},"x":{}}`)
 	})
+	if _, err := FromTOML([]byte("[a.b.c.d.e.f]\nk = 1\n[a.b.c.d.e.f]\n")); err == nil {
+		t.Error("expected error for a duplicate deep table")
+	}
+
+	// A Converter keeps a grown stack for the next document.
+	var c Converter
+	for _, src := range []string{"[a.b.c.d.e.f.g]\nk = 1", "[a]\nk = 2", "[a.b.c.d.e.f.g.h]\nk = 3"} {
+		if _, err := c.AppendTOML(nil, []byte(src)); err != nil {
+			t.Errorf("Converter %q: %v", src, err)
+		}
+	}
+}
+
+func TestTOMLShallowAllocs(t *testing.T) {
+	// The frames of a shallow file fit in the parser, which stays on the
+	// stack, and a table closed at the end of the document is not recorded
+	// for re-entry: the allocations are the output buffer, its bytes, and
+	// the sizes each table's used keys grow through.
+	tests := []struct {
+		name string
+		src  []byte
+		max  float64
+	}{
+		{"keys", []byte("a = 1\nb = 'x'\nc = [1, 2]\n"), 5},
+		{"table", frontmatter1TOMLBytes, 7},
+	}
+	for _, tc := range tests {
+		allocs := testing.AllocsPerRun(100, func() {
+			if _, err := FromTOML(tc.src); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if allocs > tc.max {
+			t.Errorf("%s: %.1f allocs per conversion, want at most %v", tc.name, allocs, tc.max)
+		}
+	}
 }
 
 // --------------------------------------------------------------------------
@@ -532,8 +879,7 @@ func TestTOMLErrorNestingLimit(t *testing.T) {
 // --------------------------------------------------------------------------
 //
 // Each testdata/toml/*.toml file is paired with a matching .json golden file.
-// All parsers run over every .toml; if a parser returns errReentry the subtest
-// is skipped (the parser doesn't support that input pattern).
+// All parsers run over every .toml.
 //
 // To add a test: drop a .toml file in testdata/toml/ and run:
 //
@@ -592,11 +938,6 @@ func TestTOMLFiles(t *testing.T) {
 
 			forParsers(t, nil, func(t *testing.T, fn tomlFn) {
 				raw, err := fn(tomlData)
-				// TODO: not quite right.  Need to add condition if the parser is 'streaming' or 'line'
-				//  the tree based parser should work, and not error
-				if err == errReentry && strings.Contains(name, "reentry") {
-					t.Skip("parser does not support out-of-order tables")
-				}
 				if err != nil {
 					t.Fatalf("%v", err)
 				}
@@ -610,6 +951,12 @@ func TestTOMLFiles(t *testing.T) {
 					t.Errorf("got:\n%s\nwant:\n%s", pretty, want)
 				}
 			})
+
+			// Valid input converts the same when checked strictly.
+			lenient, _ := FromTOML(tomlData)
+			if got, err := FromTOMLWithOptions(tomlData, TOMLOptions{Strict: true}); err != nil || !bytes.Equal(got, lenient) {
+				t.Errorf("strict: got %s, %v, want %s", got, err, lenient)
+			}
 		})
 	}
 }
@@ -1,5 +1,6 @@
 // Tree-based TOML→JSON translator. Used as a fallback when the streaming path
-// detects an out-of-order section that requires re-entry into a closed table.
+// meets a table it cannot re-enter: a table opened by dotted keys, or any
+// closed table while recording a source map.
 
 package tojson
 
@@ -19,17 +20,21 @@ type jnode struct {
 	obj []*jpair // object: ordered key-value pairs
 	arr []*jnode // inline array  (immutable after parse)
 	aot []*jnode // array-of-tables (grows with each [[header]])
+	src []byte   // input the value was parsed from; for tables, the header or key path
+	end []byte   // closing bracket of an inline table or array
 }
 
 type jpair struct {
 	key      []byte
+	keySrc   []byte // input key path that named key, used when key was decoded
 	val      *jnode
 	explicit bool // true when created by a [table] header line
+	dotted   bool // true when created by a dotted key, which later dotted keys may extend
 }
 
 var (
-	nodeTrue  = &jnode{raw: []byte("true")}
-	nodeFalse = &jnode{raw: []byte("false")}
+	rawTrue  = []byte("true")
+	rawFalse = []byte("false")
 )
 
 func newObjectNode() *jnode {
@@ -54,37 +59,48 @@ func (n *jnode) findPair(key []byte) *jpair {
 // Serializer
 // --------------------------------------------------------------------------
 
-func serializeNode(n *jnode, buf *bytes.Buffer) {
+// serializeNode writes n to buf. When sm is non-nil the source of each key
+// and value written is recorded in it.
+func serializeNode(n *jnode, buf *bytes.Buffer, sm *srcMap) {
 	switch {
 	case n.raw != nil:
+		sm.valueOf(buf, n.src)
 		buf.Write(n.raw)
 	case n.obj != nil:
+		sm.openOf(buf, n.src)
 		buf.WriteByte('{')
 		for i, p := range n.obj {
 			if i > 0 {
 				buf.WriteByte(',')
 			}
-			writeJSONString(p.key, buf)
+			if sm != nil && !sm.keyOf(buf, p.key) {
+				sm.keyOf(buf, p.keySrc)
+			}
+			writeTOMLKey(p.key, buf)
 			buf.WriteByte(':')
-			serializeNode(p.val, buf)
+			serializeNode(p.val, buf, sm)
 		}
+		sm.closeOf(buf, n.end)
 		buf.WriteByte('}')
 	case n.arr != nil:
+		sm.openOf(buf, n.src)
 		buf.WriteByte('[')
 		for i, elem := range n.arr {
 			if i > 0 {
 				buf.WriteByte(',')
 			}
-			serializeNode(elem, buf)
+			serializeNode(elem, buf, sm)
 		}
+		sm.closeOf(buf, n.end)
 		buf.WriteByte(']')
 	case n.aot != nil:
+		sm.openOf(buf, n.src)
 		buf.WriteByte('[')
 		for i, elem := range n.aot {
 			if i > 0 {
 				buf.WriteByte(',')
 			}
-			serializeNode(elem, buf)
+			serializeNode(elem, buf, sm)
 		}
 		buf.WriteByte(']')
 	}
@@ -99,52 +115,73 @@ type tomlParser struct {
 	lineIdx  int
 	root     *jnode
 	ctx      *jnode // current table context (reset by [header] and [[header]])
+	opts     TOMLOptions
+	errs     errorList
 }
 
 func newTOMLParser(input []byte) *tomlParser {
-	lines := bytes.Split(input, []byte{'\n'})
-	// remove spurious trailing empty element from Split
-	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
-		lines = lines[:len(lines)-1]
+	// Split by hand: bytes.Split caps each line, which would hide the
+	// lines' positions in input from the source map.
+	var lines [][]byte
+	for rest := input; len(rest) > 0; {
+		line, after, _ := bytes.Cut(rest, []byte{'\n'})
+		lines = append(lines, line)
+		rest = after
 	}
 	root := newObjectNode()
+	root.src = input
 	return &tomlParser{rawLines: lines, root: root, ctx: root}
 }
 
-func tomlConvertTree(input []byte) ([]byte, error) {
+// tomlConvertTree parses input into a jnode tree and appends its JSON
+// serialization to out, recording source positions in sm when it is non-nil.
+func tomlConvertTree(out *bytes.Buffer, input []byte, sm *srcMap, opts TOMLOptions) error {
+	if opts.Strict {
+		if err := checkTOMLText(input); err != nil {
+			return err
+		}
+	}
 	p := newTOMLParser(input)
-	if err := p.parseDocument(); err != nil {
-		return nil, err
+	p.opts = opts
+	p.errs.max = opts.MaxErrors
+	if err := p.errs.result(p.parseDocument()); err != nil {
+		return err
 	}
-	var buf bytes.Buffer
-	buf.Grow(len(input))
-	serializeNode(p.root, &buf)
-	return buf.Bytes(), nil
+	serializeNode(p.root, out, sm)
+	return nil
 }
 
 func (p *tomlParser) parseDocument() error {
+	skipping := false // after an error, until the next header or key/value line
 	for p.lineIdx < len(p.rawLines) {
 		line := p.rawLines[p.lineIdx]
 		p.lineIdx++
 		line = bytes.TrimRight(line, " \t\r")
-		line = stripInlineComment(line)
-		trimmed := bytes.TrimSpace(line)
+		line = stripTOMLComment(line)
+		trimmed := trimTOMLSpace(line)
 		if len(trimmed) == 0 || trimmed[0] == '#' {
 			continue
 		}
+		if skipping {
+			if !tomlResyncPoint(trimmed) {
+				continue
+			}
+			skipping = false
+		}
 		leading := leadingSpaces(line)
+		var err error
 		if bytes.HasPrefix(trimmed, []byte("[[")) {
-			if err := p.parseArrayTableHeader(trimmed); err != nil {
-				return atLineCol(p.lineIdx-1, leading, err)
-			}
+			err = atLineCol(p.lineIdx-1, leading, p.parseArrayTableHeader(trimmed))
 		} else if trimmed[0] == '[' {
-			if err := p.parseTableHeader(trimmed); err != nil {
-				return atLineCol(p.lineIdx-1, leading, err)
-			}
+			err = atLineCol(p.lineIdx-1, leading, p.parseTableHeader(trimmed))
 		} else {
-			if err := p.parseKeyValue(trimmed, p.lineIdx-1, leading, p.ctx); err != nil {
+			err = p.parseKeyValue(trimmed, p.lineIdx-1, leading, p.ctx)
+		}
+		if err != nil {
+			if !p.errs.add(err) {
 				return err
 			}
+			skipping = true
 		}
 	}
 	return nil
@@ -160,18 +197,18 @@ func (p *tomlParser) parseTableHeader(line []byte) error {
 	}
 	inner := line[1 : len(line)-1]
 	var pathBuf [4][]byte
-	path, rest, err := parseTOMLKeyPath(inner, pathBuf[:0])
+	path, rest, err := parseTOMLKeyPath(inner, pathBuf[:0], &p.opts)
 	if err != nil {
 		return err
 	}
-	rest = bytes.TrimSpace(rest)
+	rest = trimTOMLSpace(rest)
 	if len(rest) != 0 {
 		return fmt.Errorf("unexpected content after table header key: %s", rest)
 	}
 	if len(path) == 0 {
 		return fmt.Errorf("empty table header")
 	}
-	node, err := p.getOrCreateNode(p.root, path[:len(path)-1], false)
+	node, err := p.getOrCreateNode(p.root, path[:len(path)-1], line)
 	if err != nil {
 		return err
 	}
@@ -179,12 +216,16 @@ func (p *tomlParser) parseTableHeader(line []byte) error {
 	existing := node.findPair(lastKey)
 	if existing != nil {
 		switch {
+		case existing.dotted, existing.val.end != nil:
+			return fmt.Errorf("cannot define table %q: key already has a value", bytes.Join(path, []byte(".")))
 		case existing.val.raw != nil:
 			return fmt.Errorf("cannot define table %q: key already has a scalar value", bytes.Join(path, []byte(".")))
 		case existing.val.arr != nil:
 			return fmt.Errorf("cannot define table %q: key already has an inline array", bytes.Join(path, []byte(".")))
 		case existing.explicit:
 			return fmt.Errorf("duplicate table header [%s]", bytes.Join(path, []byte(".")))
+		case existing.val.aot != nil && p.opts.Strict:
+			return fmt.Errorf("cannot use [%s]: key already exists as an array of tables", bytes.Join(path, []byte(".")))
 		case existing.val.aot != nil:
 			// [a] after [[a]] — enter the last aot element
 			p.ctx = existing.val.aot[len(existing.val.aot)-1]
@@ -196,7 +237,8 @@ func (p *tomlParser) parseTableHeader(line []byte) error {
 		return nil
 	}
 	newNode := newObjectNode()
-	node.obj = append(node.obj, &jpair{key: lastKey, val: newNode, explicit: true})
+	newNode.src = line
+	node.obj = append(node.obj, &jpair{key: lastKey, keySrc: line, val: newNode, explicit: true})
 	p.ctx = newNode
 	return nil
 }
@@ -207,40 +249,42 @@ func (p *tomlParser) parseArrayTableHeader(line []byte) error {
 	}
 	inner := line[2 : len(line)-2]
 	var pathBuf [4][]byte
-	path, rest, err := parseTOMLKeyPath(inner, pathBuf[:0])
+	path, rest, err := parseTOMLKeyPath(inner, pathBuf[:0], &p.opts)
 	if err != nil {
 		return err
 	}
-	rest = bytes.TrimSpace(rest)
+	rest = trimTOMLSpace(rest)
 	if len(rest) != 0 {
 		return fmt.Errorf("unexpected content after array-of-tables header key: %s", rest)
 	}
 	if len(path) == 0 {
 		return fmt.Errorf("empty array-of-tables header")
 	}
-	node, err := p.getOrCreateNode(p.root, path[:len(path)-1], false)
+	node, err := p.getOrCreateNode(p.root, path[:len(path)-1], line)
 	if err != nil {
 		return err
 	}
 	lastKey := path[len(path)-1]
 	existing := node.findPair(lastKey)
 	newEntry := newObjectNode()
+	newEntry.src = line
 	if existing != nil {
 		if existing.val.aot == nil {
 			return fmt.Errorf("cannot use [[%s]]: key already exists as a non-array", bytes.Join(path, []byte(".")))
 		}
 		existing.val.aot = append(existing.val.aot, newEntry)
 	} else {
-		aotNode := &jnode{aot: []*jnode{newEntry}}
-		node.obj = append(node.obj, &jpair{key: lastKey, val: aotNode})
+		aotNode := &jnode{aot: []*jnode{newEntry}, src: line}
+		node.obj = append(node.obj, &jpair{key: lastKey, keySrc: line, val: aotNode})
 	}
 	p.ctx = newEntry
 	return nil
 }
 
 // getOrCreateNode navigates or creates a path of intermediate object nodes
-// under root. Used for table headers and dotted key traversal.
-func (p *tomlParser) getOrCreateNode(root *jnode, path [][]byte, _ bool) (*jnode, error) {
+// under root. Used for table headers and dotted key traversal. src is the
+// header or key path text recorded as the source of any created node.
+func (p *tomlParser) getOrCreateNode(root *jnode, path [][]byte, src []byte) (*jnode, error) {
 	cur := root
 	for i, key := range path {
 		if cur.obj == nil {
@@ -249,7 +293,8 @@ func (p *tomlParser) getOrCreateNode(root *jnode, path [][]byte, _ bool) (*jnode
 		pair := cur.findPair(key)
 		if pair == nil {
 			next := newObjectNode()
-			cur.obj = append(cur.obj, &jpair{key: key, val: next})
+			next.src = src
+			cur.obj = append(cur.obj, &jpair{key: key, keySrc: src, val: next})
 			cur = next
 			continue
 		}
@@ -259,6 +304,8 @@ func (p *tomlParser) getOrCreateNode(root *jnode, path [][]byte, _ bool) (*jnode
 			return nil, fmt.Errorf("key %q already has a scalar value", bytes.Join(path[:i+1], []byte(".")))
 		case v.arr != nil:
 			return nil, fmt.Errorf("key %q is an inline array and cannot have subtables", bytes.Join(path[:i+1], []byte(".")))
+		case v.end != nil:
+			return nil, fmt.Errorf("key %q is an inline table and cannot have subtables", bytes.Join(path[:i+1], []byte(".")))
 		case v.aot != nil:
 			cur = v.aot[len(v.aot)-1]
 		default:
@@ -268,26 +315,52 @@ func (p *tomlParser) getOrCreateNode(root *jnode, path [][]byte, _ bool) (*jnode
 	return cur, nil
 }
 
+// dottedTable navigates or creates the tables that the dotted key prefix
+// path names under ctx. A table may be extended only by the dotted keys that
+// created it, not by a header, an inline table, or an array of tables.
+func (p *tomlParser) dottedTable(ctx *jnode, path [][]byte, src []byte) (*jnode, error) {
+	cur := ctx
+	for i, key := range path {
+		pair := cur.findPair(key)
+		if pair == nil {
+			next := newObjectNode()
+			next.src = src
+			cur.obj = append(cur.obj, &jpair{key: key, keySrc: src, val: next, dotted: true})
+			cur = next
+			continue
+		}
+		switch {
+		case pair.val.raw != nil:
+			return nil, fmt.Errorf("key %q already has a scalar value", bytes.Join(path[:i+1], []byte(".")))
+		case !pair.dotted:
+			return nil, fmt.Errorf("duplicate key %q", key)
+		}
+		cur = pair.val
+	}
+	return cur, nil
+}
+
 // --------------------------------------------------------------------------
 // Key-value parsing
 // --------------------------------------------------------------------------
 
 func (p *tomlParser) parseKeyValue(line []byte, rawLine int, leading int, ctx *jnode) error {
 	var pathBuf [4][]byte
-	path, rest, err := parseTOMLKeyPath(line, pathBuf[:0])
+	path, rest, err := parseTOMLKeyPath(line, pathBuf[:0], &p.opts)
 	if err != nil {
 		return atLineCol(rawLine, leading, err)
 	}
-	rest = bytes.TrimSpace(rest)
+	keySrc := trimTOMLSpace(line[:len(line)-len(rest)])
+	rest = trimTOMLSpace(rest)
 	if len(rest) == 0 || rest[0] != '=' {
 		return atLineCol(rawLine, leading+len(line)-len(rest), fmt.Errorf("expected '=' after key, got: %s", rest))
 	}
-	rest = bytes.TrimSpace(rest[1:])
+	rest = trimTOMLSpace(rest[1:])
 	valCol := leading + len(line) - len(rest)
 
 	var targetNode *jnode
 	if len(path) > 1 {
-		targetNode, err = p.getOrCreateNode(ctx, path[:len(path)-1], false)
+		targetNode, err = p.dottedTable(ctx, path[:len(path)-1], keySrc)
 		if err != nil {
 			return atLineCol(rawLine, leading, err)
 		}
@@ -299,18 +372,74 @@ func (p *tomlParser) parseKeyValue(line []byte, rawLine int, leading int, ctx *j
 		return atLineCol(rawLine, leading, fmt.Errorf("duplicate key %q", lastKey))
 	}
 
-	raw, consumed, err := parseTOMLValue(rest, p.rawLines, p.lineIdx-1)
+	spans := false
+	if ml, state := multilineStart(rest); ml && state != tomlStateMLBasic && state != tomlStateMLLiteral {
+		// Gather the whole array or table first, as the line parser does,
+		// so that values nested in it may span lines too.
+		joined, consumed, err := p.joinBrackets(rest)
+		if err != nil {
+			return atLineCol(rawLine, valCol, err)
+		}
+		rest = joined
+		p.lineIdx += consumed
+		spans = consumed > 0
+	}
+	raw, consumed, err := parseTOMLValue(rest, p.rawLines, p.lineIdx-1, &p.opts)
 	if err != nil {
+		if spans {
+			return atTOMLValue(p.root.src, rawLine, valCol, err)
+		}
 		return atLineCol(rawLine, valCol, err)
 	}
+	if consumed > 0 && p.opts.Strict {
+		// A multi-line string, which ends on a later line of the input
+		// that rest was sliced from.
+		last := p.rawLines[p.lineIdx-1+consumed]
+		if err := checkTOMLValueEnd(rest[:cap(rest)-cap(last)+len(last)]); err != nil {
+			return atLineCol(rawLine, valCol, err)
+		}
+	}
 	p.lineIdx += consumed
 
-	targetNode.obj = append(targetNode.obj, &jpair{key: lastKey, val: raw})
+	targetNode.obj = append(targetNode.obj, &jpair{key: lastKey, keySrc: keySrc, val: raw})
 	return nil
 }
 
+// joinBrackets extends rest, an inline array or table that the current line
+// leaves open, over the lines that follow until its outermost bracket
+// closes. It returns the joined text, including any comments, and the number
+// of lines added.
+func (p *tomlParser) joinBrackets(rest []byte) ([]byte, int, error) {
+	// rest was sliced from the current line with its comment stripped; take
+	// the whole of the line from rest onward, since the parsers skip comments
+	// inside arrays and tables.
+	line := p.rawLines[p.lineIdx-1]
+	s := line[cap(line)-cap(rest):]
+	var sc tomlBracketScan
+	if sc.scan(s) {
+		return s, 0, nil
+	}
+	for n := 1; p.lineIdx-1+n < len(p.rawLines); n++ {
+		next := p.rawLines[p.lineIdx-1+n]
+		s = joinLine(s, next)
+		if sc.scan(next) {
+			return s, n, nil
+		}
+	}
+	what := "inline array"
+	if rest[0] == '{' {
+		what = "inline table"
+	}
+	return nil, 0, fmt.Errorf("unterminated %s", what)
+}
+
 // fromTOMLTree converts TOML to JSON using the tree-based path directly,
 // skipping the streaming attempt.
 func fromTOMLTree(src []byte) ([]byte, error) {
-	return tomlConvertTree(src)
+	var buf bytes.Buffer
+	buf.Grow(len(src))
+	if err := tomlConvertTree(&buf, src, nil, TOMLOptions{}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
@@ -0,0 +1,224 @@
+package tojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// UnmarshalOptions configures the Unmarshal methods. The zero value decodes
+// like encoding/json.Unmarshal; the package-level Unmarshal functions use it.
+type UnmarshalOptions struct {
+	// DisallowUnknownFields reports an object key that does not match any
+	// field of the destination struct as an error, as
+	// json.Decoder.DisallowUnknownFields does.
+	DisallowUnknownFields bool
+
+	// YAML configures UnmarshalYAML and YAML front matter.
+	YAML YAMLOptions
+
+	// TOML configures UnmarshalTOML and TOML front matter. Indent and
+	// Canonical do not apply.
+	TOML TOMLOptions
+
+	// JSON configures UnmarshalJSONVariant and JSON front matter. Indent and
+	// Canonical do not apply.
+	JSON JSONVariantOptions
+}
+
+// UnmarshalYAML converts src like FromYAML and decodes the result into v
+// like json.Unmarshal. Errors from decoding, such as a string where v
+// expects a number, are returned as *ParseError pointing at the offending
+// key or value in src; the original encoding/json error is available with
+// errors.As.
+func UnmarshalYAML(src []byte, v any) error {
+	return UnmarshalOptions{}.UnmarshalYAML(src, v)
+}
+
+// UnmarshalTOML is like UnmarshalYAML for TOML input.
+func UnmarshalTOML(src []byte, v any) error {
+	return UnmarshalOptions{}.UnmarshalTOML(src, v)
+}
+
+// UnmarshalJSONVariant is like UnmarshalYAML for JSON and JSON-variant input.
+func UnmarshalJSONVariant(src []byte, v any) error {
+	return UnmarshalOptions{}.UnmarshalJSONVariant(src, v)
+}
+
+// UnmarshalFrontMatter splits src like FromFrontMatter, decodes the front
+// matter into v, and returns the body. Positions in errors are relative to
+// the whole of src. If src has no front matter, v is left unchanged and body
+// is src.
+func UnmarshalFrontMatter(src []byte, v any) (body []byte, err error) {
+	return UnmarshalOptions{}.UnmarshalFrontMatter(src, v)
+}
+
+// UnmarshalYAML is like the package-level UnmarshalYAML, using o.
+func (o UnmarshalOptions) UnmarshalYAML(src []byte, v any) error {
+	return o.unmarshal("yaml", src, v)
+}
+
+// UnmarshalTOML is like the package-level UnmarshalTOML, using o.
+func (o UnmarshalOptions) UnmarshalTOML(src []byte, v any) error {
+	return o.unmarshal("toml", src, v)
+}
+
+// UnmarshalJSONVariant is like the package-level UnmarshalJSONVariant, using o.
+func (o UnmarshalOptions) UnmarshalJSONVariant(src []byte, v any) error {
+	return o.unmarshal("json", src, v)
+}
+
+// UnmarshalFrontMatter is like the package-level UnmarshalFrontMatter, using o.
+func (o UnmarshalOptions) UnmarshalFrontMatter(src []byte, v any) (body []byte, err error) {
+	blk, found, err := splitFrontMatter(src, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return src, nil
+	}
+	if len(blk.src) == 0 {
+		if err := o.decode([]byte("{}"), v, nil); err != nil {
+			return nil, err
+		}
+		return blk.body, nil
+	}
+
+	sm := &srcMap{doc: src, src: blk.src, base: blk.base}
+	var buf bytes.Buffer
+	buf.Grow(len(blk.src))
+	if err := convertMapped(blk.format, &buf, sm, o); err != nil {
+		return nil, shiftParseError(err, sm.lineShift())
+	}
+	if err := o.decode(buf.Bytes(), v, sm); err != nil {
+		return nil, err
+	}
+	return blk.body, nil
+}
+
+func (o UnmarshalOptions) unmarshal(format string, src []byte, v any) error {
+	var buf bytes.Buffer
+	buf.Grow(len(src))
+	sm := newSrcMap(src, &buf)
+	if err := convertMapped(format, &buf, sm, o); err != nil {
+		return err
+	}
+	return o.decode(buf.Bytes(), v, sm)
+}
+
+// convertMapped appends the JSON form of sm.src, in the named format, to out
+// while recording source positions in sm, using the options in o for the
+// format.
+func convertMapped(format string, out *bytes.Buffer, sm *srcMap, o UnmarshalOptions) error {
+	switch format {
+	case "yaml":
+		p := parser{opts: o.YAML, sm: sm}
+		return p.convert(out, sm.src)
+	case "toml":
+		return tomlConvert(&tomlLineParser{sm: sm, opts: o.TOML}, out, sm.src)
+	default:
+		d := &decoder{sm: sm}
+		d.reset(out)
+		d.configure(o.JSON)
+		return d.Translate(sm.src)
+	}
+}
+
+// decode unmarshals data into v, rewriting decoding errors to point into the
+// source recorded by sm.
+func (o UnmarshalOptions) decode(data []byte, v any, sm *srcMap) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if o.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	err := dec.Decode(v)
+	if err == nil || sm == nil {
+		return err
+	}
+	return sm.locate(data, err)
+}
+
+// locate returns err as a *ParseError at the source of the key or value it
+// concerns, or err unchanged if that cannot be determined.
+//
+// An *json.UnmarshalTypeError carries the output offset just past the
+// offending value (or just inside an offending object or array), so the
+// value is the last one recorded before it. An unknown-field error carries
+// only the key name, and is attributed to the first key with that name.
+func (m *srcMap) locate(data []byte, err error) error {
+	var te *json.UnmarshalTypeError
+	if errors.As(err, &te) {
+		if mk, ok := m.at(int(te.Offset)); ok {
+			return m.errorAt(mk.start, err)
+		}
+		return err
+	}
+	quoted, ok := strings.CutPrefix(err.Error(), "json: unknown field ")
+	if !ok {
+		return err
+	}
+	name, uerr := strconv.Unquote(quoted)
+	if uerr != nil {
+		return err
+	}
+	for _, mk := range m.marks {
+		if mk.kind == markKey && jsonStringAt(data, mk.out) == name {
+			return m.errorAt(mk.start, err)
+		}
+	}
+	return err
+}
+
+// errorAt wraps err in a *ParseError positioned at offset off in m.src.
+func (m *srcMap) errorAt(off int, err error) error {
+	line, col := m.position(off)
+	return &ParseError{
+		Line:    line,
+		Column:  col,
+		Message: strings.TrimPrefix(err.Error(), "json: "),
+		Err:     err,
+	}
+}
+
+// jsonStringAt decodes the JSON string starting at data[off], or returns ""
+// if there is none.
+func jsonStringAt(data []byte, off int) string {
+	if off >= len(data) || data[off] != '"' {
+		return ""
+	}
+	end := off + 1
+	for end < len(data) && data[end] != '"' {
+		if data[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	var s string
+	if json.Unmarshal(data[off:min(end+1, len(data))], &s) != nil {
+		return ""
+	}
+	return s
+}
+
+// shiftParseError returns err with its line moved down by lines when it is a
+// *ParseError, for errors reported relative to a block within a document.
+func shiftParseError(err error, lines int) error {
+	if lines == 0 {
+		return err
+	}
+	switch e := err.(type) {
+	case *ParseError:
+		shifted := *e
+		shifted.Line += lines
+		return &shifted
+	case ParseErrors:
+		shifted := make(ParseErrors, len(e))
+		for i, pe := range e {
+			shifted[i] = shiftParseError(pe, lines).(*ParseError)
+		}
+		return shifted
+	}
+	return err
+}
@@ -0,0 +1,166 @@
+package tojson
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type unmarshalTarget struct {
+	Title string
+	Count int
+	Tags  []int
+	Table struct {
+		Num int
+	}
+}
+
+func TestUnmarshalTypeErrorPosition(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       func(src []byte, v any) error
+		input    string
+		line     int
+		col      int
+		wantText string
+	}{
+		{"yaml scalar", UnmarshalYAML, "title: x\ncount: abc\n", 2, 8, "cannot unmarshal string into Go struct field unmarshalTarget.count of type int"},
+		{"yaml quoted", UnmarshalYAML, "count: \"12\"\n", 1, 8, ""},
+		{"yaml block scalar", UnmarshalYAML, "count: |\n  many\n", 1, 8, ""},
+		{"yaml nested", UnmarshalYAML, "table:\n  num: abc\n", 2, 8, ""},
+		{"yaml object for scalar", UnmarshalYAML, "count:\n  a: 1\n", 2, 3, ""},
+		{"yaml sequence item", UnmarshalYAML, "tags:\n  - 1\n  - two\n", 3, 5, ""},
+		{"yaml flow item", UnmarshalYAML, "tags: [1, two]\n", 1, 11, ""},
+		{"yaml multi-line flow item", UnmarshalYAML, "tags: [1,\n  2,\n  three]\n", 3, 3, ""},
+		{"toml bare key", UnmarshalTOML, "title = 'x'\ncount = 'abc'\n", 2, 9, ""},
+		{"toml table", UnmarshalTOML, "title = 'x'\n[table]\nnum = 'abc'\n", 3, 7, ""},
+		{"toml header for scalar", UnmarshalTOML, "[count]\na = 1\n", 1, 1, ""},
+		{"toml dotted key", UnmarshalTOML, "table.num = 'abc'\n", 1, 13, ""},
+		{"toml inline array item", UnmarshalTOML, "tags = [1, 'two']\n", 1, 12, ""},
+		{"toml inline table value", UnmarshalTOML, "table = {num = 'abc'}\n", 1, 16, ""},
+		{"toml multi-line array item", UnmarshalTOML, "tags = [\n  1,\n  'two',\n]\n", 3, 3, ""},
+		{"toml multi-line string", UnmarshalTOML, "count = '''\nabc'''\n", 1, 9, ""},
+		{"toml out-of-order tables", UnmarshalTOML, "[table.a]\nx = 1\n[other]\ny = 2\n[table]\nnum = 'abc'\n", 6, 7, ""},
+		{"json5 value", UnmarshalJSONVariant, "{\n  title: 'x',\n  count: 'abc',\n}", 3, 10, ""},
+		{"json5 array item", UnmarshalJSONVariant, "{tags: [1, 'two']}", 1, 12, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var v unmarshalTarget
+			pe := requireParseError(t, tc.fn([]byte(tc.input), &v))
+			if pe.Line != tc.line || pe.Column != tc.col {
+				t.Errorf("got line %d, column %d, want line %d, column %d (%s)", pe.Line, pe.Column, tc.line, tc.col, pe.Message)
+			}
+			if tc.wantText != "" && pe.Message != tc.wantText {
+				t.Errorf("got message %q, want %q", pe.Message, tc.wantText)
+			}
+			var te *json.UnmarshalTypeError
+			if !errors.As(pe, &te) {
+				t.Errorf("error does not wrap *json.UnmarshalTypeError: %v", pe.Err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalUnknownField(t *testing.T) {
+	strict := UnmarshalOptions{DisallowUnknownFields: true}
+	tests := []struct {
+		name  string
+		fn    func(src []byte, v any) error
+		input string
+		line  int
+		col   int
+	}{
+		{"yaml", strict.UnmarshalYAML, "title: x\nextra: 1\n", 2, 1},
+		{"yaml quoted key", strict.UnmarshalYAML, "title: x\n\"ex tra\": 1\n", 2, 1},
+		{"yaml nested", strict.UnmarshalYAML, "table:\n  num: 1\n  extra: 2\n", 3, 3},
+		{"toml", strict.UnmarshalTOML, "title = 'x'\n  extra = 1\n", 2, 3},
+		{"toml header", strict.UnmarshalTOML, "title = 'x'\n[extra]\n", 2, 2},
+		{"json5", strict.UnmarshalJSONVariant, "{title: 'x', extra: 1}", 1, 14},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var v unmarshalTarget
+			pe := requireParseError(t, tc.fn([]byte(tc.input), &v))
+			if pe.Line != tc.line || pe.Column != tc.col {
+				t.Errorf("got line %d, column %d, want line %d, column %d (%s)", pe.Line, pe.Column, tc.line, tc.col, pe.Message)
+			}
+		})
+	}
+
+	// Without the option unknown fields are ignored.
+	var v unmarshalTarget
+	if err := UnmarshalYAML([]byte("title: x\nextra: 1\n"), &v); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestUnmarshalSuccess(t *testing.T) {
+	inputs := map[string]func(src []byte, v any) error{
+		"title: x\ncount: 3\ntags: [1, 2]\ntable:\n  num: 4\n":              UnmarshalYAML,
+		"title = 'x'\ncount = 3\ntags = [1, 2]\n[table]\nnum = 4\n":         UnmarshalTOML,
+		"{title: 'x', count: 3, tags: [1, 2,], table: {num: 4}, // done\n}": UnmarshalJSONVariant,
+	}
+	for input, fn := range inputs {
+		var v unmarshalTarget
+		if err := fn([]byte(input), &v); err != nil {
+			t.Fatalf("%q: unexpected error: %v", input, err)
+		}
+		if v.Title != "x" || v.Count != 3 || len(v.Tags) != 2 || v.Table.Num != 4 {
+			t.Errorf("%q: got %+v", input, v)
+		}
+	}
+}
+
+func TestUnmarshalSyntaxError(t *testing.T) {
+	var v unmarshalTarget
+	pe := requireParseError(t, UnmarshalYAML([]byte("title: x\ncount: \"bad\n"), &v))
+	if pe.Line != 2 {
+		t.Errorf("got line %d, want 2", pe.Line)
+	}
+	if pe.Err != nil {
+		t.Errorf("syntax error wraps %v, want nil", pe.Err)
+	}
+}
+
+func TestUnmarshalFrontMatter(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		line  int
+		col   int
+	}{
+		{"yaml", "---\ntitle: x\ncount: abc\n---\nbody", 3, 8},
+		{"toml", "+++\ntitle = 'x'\n\ncount = 'abc'\n+++\nbody", 4, 9},
+		{"json", "---json\n{\"count\": \"abc\"}\n---\nbody", 2, 11},
+		{"brace", "{\n  \"title\": \"x\",\n  \"count\": \"abc\"\n}\nbody", 3, 12},
+		{"yaml syntax error", "---\ntitle: x\ncount: \"bad\n---\nbody", 3, 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var v unmarshalTarget
+			body, err := UnmarshalFrontMatter([]byte(tc.input), &v)
+			pe := requireParseError(t, err)
+			if pe.Line != tc.line || (tc.col > 1 && pe.Column != tc.col) {
+				t.Errorf("got line %d, column %d, want line %d, column %d (%s)", pe.Line, pe.Column, tc.line, tc.col, pe.Message)
+			}
+			if body != nil {
+				t.Errorf("got body %q on error, want nil", body)
+			}
+		})
+	}
+
+	var v unmarshalTarget
+	body, err := UnmarshalFrontMatter([]byte("---\ntitle: x\ncount: 2\n---\nbody"), &v)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(body) != "body" || v.Title != "x" || v.Count != 2 {
+		t.Errorf("got body %q, value %+v", body, v)
+	}
+
+	body, err = UnmarshalFrontMatter([]byte("no front matter"), &v)
+	if err != nil || string(body) != "no front matter" {
+		t.Errorf("got body %q, err %v", body, err)
+	}
+}
@@ -3,28 +3,84 @@
 //
 // Supported: block mappings, block sequences, flow style, bare/quoted strings (scalars),
 // null/bool literals, numbers, nested structures, comments,
-// literal (|) and folded (>) strings (block scalars).
+// literal (|) and folded (>) strings (block scalars), anchors & aliases,
+// merge keys (<<).
 //
-// Not supported: anchors & aliases, tags, complex keys (? ...).
+// A stream of documents separated by --- and ... lines is converted one
+// document at a time; see yaml_stream.go.
+//
+// Tags of the core schema (!!str, !!int, ...) coerce their values, and local
+// tags (!Ref) go to YAMLOptions.TagHandler; see yaml_tag.go.
+//
+// Not supported: complex keys (? ...), anchors and tags on mapping keys.
 
 package tojson
 
-import "bytes"
+import (
+	"bytes"
+	"errors"
+	"slices"
+)
+
+// yamlConvert appends the JSON form of input to out. On error out may hold
+// a partial document; callers discard it.
+func yamlConvert(out *bytes.Buffer, input []byte, opts YAMLOptions) error {
+	p := parser{opts: opts}
+	return p.convert(out, input)
+}
 
-func yamlConvert(input []byte) ([]byte, error) {
-	var p parser
+// convert runs p over input, appending the JSON form to out. p may be a zero
+// parser or one retained from an earlier call; its line slices are reused.
+func (p *parser) convert(out *bytes.Buffer, input []byte) error {
+	p.errs = errorList{max: p.opts.MaxErrors}
 	if err := p.init(input); err != nil {
-		return nil, err
+		return p.errs.result(err)
+	}
+	// Empty documents after the first, such as the --- a manifest generator
+	// may write at the end, are allowed; FromYAML converts only the first.
+	for i := 1; i < len(p.docs); i++ {
+		if p.docs[i].first < p.docEnd(i) {
+			return p.errs.result(atLineCol(p.docs[i].line, 0, errYAMLStream))
+		}
+	}
+	return p.errs.result(p.document(0, out))
+}
+
+// errYAMLTagDirective reports a %TAG directive, whose tag handles are not
+// supported; a %YAML directive is skipped.
+var errYAMLTagDirective = errors.New("%TAG directives are not supported")
+
+// errYAMLStream reports a second document with content in input to FromYAML.
+var errYAMLStream = errors.New("a second document starts here; use FromYAMLStream, or tojson -yaml-stream, for a stream of documents")
+
+// document appends the JSON form of the document at index i in p.docs to out,
+// or null if the document is empty or the input has no documents.
+func (p *parser) document(i int, out *bytes.Buffer) error {
+	if i >= len(p.docs) {
+		out.WriteString("null")
+		return nil
+	}
+	p.pos, p.end = p.docs[i].first, p.docEnd(i)
+	if i > 0 {
+		p.keys.reset()
+		clear(p.anchors)
+		p.merges = p.merges[:0]
+		p.aliasBytes = 0
 	}
-	if len(p.lines) == 0 {
-		return []byte("null"), nil
+	if p.pos == p.end {
+		out.WriteString("null")
+		return nil
 	}
-	var buf bytes.Buffer
-	buf.Grow(len(input) + 64)
-	if err := p.parseBlock(-1, &buf); err != nil {
-		return nil, err
+	return p.parseBlock(-1, true, out)
+}
+
+// docEnd returns the index in p.lines just past the document at index i in
+// p.docs.
+func (p *parser) docEnd(i int) int {
+	if i+1 < len(p.docs) {
+		return p.docs[i+1].first
 	}
-	return buf.Bytes(), nil
+	return len(p.lines)
 }
 
 // --------------------------------------------------------------------------
@@ -36,6 +92,45 @@ type parser struct {
 	pos      int
 	rawLines [][]byte // original input lines (split on \n, \r stripped)
 	rawIdx   []int    // rawIdx[i] = index into rawLines for lines[i]
+	opts     YAMLOptions
+
+	// docs locates the documents of the stream, in order, and lines[pos:end]
+	// are what remains of the current one.
+	docs []yamlDoc
+	end  int
+
+	// sm, when non-nil, receives the source of each key and value written.
+	// flow and flowSegs map a multi-line flow expression, which
+	// gatherFlowSrc joins into a copy, back to the input lines it came from.
+	sm       *srcMap
+	flow     []byte
+	flowSegs []yamlFlowSeg
+
+	keys dupKeys   // applies opts.DuplicateKeys
+	errs errorList // applies opts.MaxErrors
+
+	// anchors holds the JSON of each anchored value, by name, and merges
+	// the merge-key values of the open mappings; see yaml_alias.go.
+	// aliasBytes counts the bytes aliases have written, up to aliasLimit.
+	anchors    map[string][]byte
+	merges     []yamlMerge
+	aliasBytes int
+	aliasLimit int
+
+	// tag is the tag of the value about to be written, until the scalar or
+	// collection takes it; see yaml_tag.go.
+	tag []byte
+}
+
+// yamlFlowSeg records that flow[at:] continues the input at offset src.
+type yamlFlowSeg struct {
+	at, src int
+}
+
+// yamlDoc locates a document of a stream: its lines start at lines[first],
+// and its first line, or its --- marker, is rawLines[line].
+type yamlDoc struct {
+	first, line int
 }
 
 type pline struct {
@@ -50,10 +145,11 @@ func (p *parser) init(input []byte) error {
 	n := bytes.Count(input, []byte{'\n'}) + 1
 
 	// Build rawLines without bytes.Split to avoid genSplit's backing alloc;
-	// pre-allocate with n so we get exactly one allocation.
+	// pre-allocate with n so we get at most one allocation, and none when a
+	// retained parser already has the capacity.
 	// We must match bytes.Split semantics: always emit one element after the
 	// last separator, even when it is empty (so "a\n" → ["a",""] not ["a"]).
-	rawLines := make([][]byte, 0, n)
+	rawLines := slices.Grow(p.rawLines[:0], n)
 	remaining := input
 	for {
 		i := bytes.IndexByte(remaining, '\n')
@@ -73,40 +169,99 @@ func (p *parser) init(input []byte) error {
 		rawLines = rawLines[:len(rawLines)-1]
 	}
 
-	lines := make([]pline, 0, n)
-	rawIdx := make([]int, 0, n)
+	lines := slices.Grow(p.lines[:0], n)
+	rawIdx := slices.Grow(p.rawIdx[:0], n)
+	docs := p.docs[:0]
+	inDoc := false // a document is open, so content continues it
 	for i, raw := range rawLines {
 		s := bytes.TrimRight(raw, " \t\r")
 		if len(s) == 0 {
 			continue
 		}
+		// A --- at the start of a line starts a document, whose content may
+		// begin on the same line, and ... ends one.
+		if rest, ok := cutYAMLMarker(s, "---"); ok {
+			docs = append(docs, yamlDoc{first: len(lines), line: i})
+			inDoc = true
+			if rest = stripInlineComment(rest); len(rest) > 0 && rest[0] != '#' {
+				lines = append(lines, pline{content: rest})
+				rawIdx = append(rawIdx, i)
+			}
+			continue
+		}
+		if _, ok := cutYAMLMarker(s, "..."); ok {
+			inDoc = false
+			continue
+		}
+		if s[0] == '%' && !inDoc {
+			// A directive, before the --- of the next document.
+			if bytes.HasPrefix(s, []byte("%TAG")) {
+				if err := atLineCol(i, 0, errYAMLTagDirective); !p.errs.add(err) {
+					return err
+				}
+			}
+			continue
+		}
 		trimmed := bytes.TrimSpace(s)
-		// skip blank, comment-only, and YAML document-marker lines
+		// skip blank, comment-only, and indented document-marker lines
 		if len(trimmed) == 0 || trimmed[0] == '#' ||
 			bytes.Equal(trimmed, []byte("---")) || bytes.Equal(trimmed, []byte("...")) {
 			continue
 		}
-		indent, err := yamlLeadingIndent(s)
+		indent, err := yamlLeadingIndent(s, p.opts.tabWidth())
 		if err != nil {
-			return atLineCol(i, 0, err)
+			if err = atLineCol(i, 0, err); !p.errs.add(err) {
+				return err
+			}
+			continue
 		}
-		content := s[indent:]
+		// indent counts tabs as TabWidth columns, so strip by bytes instead.
+		content := bytes.TrimLeft(s, " \t")
 		// strip inline comment (outside quotes) — best-effort
 		content = stripInlineComment(content)
 		if len(content) == 0 {
 			continue
 		}
+		if !inDoc {
+			docs = append(docs, yamlDoc{first: len(lines), line: i})
+			inDoc = true
+		}
 		lines = append(lines, pline{indent: indent, content: content})
 		rawIdx = append(rawIdx, i)
 	}
 	p.lines = lines
 	p.rawLines = rawLines
 	p.rawIdx = rawIdx
+	p.docs = docs
+	p.pos, p.end = 0, len(lines)
+	p.keys.policy = p.opts.DuplicateKeys
+	p.keys.reset()
+	clear(p.anchors)
+	p.merges = p.merges[:0]
+	p.aliasBytes, p.aliasLimit = 0, p.opts.maxAliasBytes(len(input))
+	p.tag = nil
 	return nil
 }
 
+// release drops p's references into the most recent input while keeping the
+// capacity of its line slices for the next call.
+func (p *parser) release() {
+	clear(p.rawLines)
+	clear(p.lines)
+	p.rawLines = p.rawLines[:0]
+	p.lines = p.lines[:0]
+	p.rawIdx = p.rawIdx[:0]
+	p.docs = p.docs[:0]
+	p.flow = nil
+	p.flowSegs = p.flowSegs[:0]
+	clear(p.anchors)
+	clear(p.merges)
+	p.merges = p.merges[:0]
+	p.tag = nil
+}
+
 func (p *parser) peek() (pline, bool) {
-	if p.pos >= len(p.lines) {
+	if p.pos >= p.end {
 		return pline{}, false
 	}
 	return p.lines[p.pos], true
@@ -119,10 +274,11 @@ func (p *parser) consume() pline {
 }
 
 // parseBlock writes a JSON value for the block starting at the current
-// position. Only considers lines with indent > parentIndent, except that
-// a block sequence may begin at the same indent as its parent mapping key
-// (YAML compact notation).
-func (p *parser) parseBlock(parentIndent int, buf *bytes.Buffer) error {
+// position. Only considers lines with indent > parentIndent, except that,
+// if compact is set, a block sequence may begin at the same indent as its
+// parent mapping key (YAML compact notation). Under a sequence item, a "- "
+// at the same indent is the next item instead.
+func (p *parser) parseBlock(parentIndent int, compact bool, buf *bytes.Buffer) error {
 	l, ok := p.peek()
 	if !ok {
 		buf.WriteString("null")
@@ -130,12 +286,15 @@ func (p *parser) parseBlock(parentIndent int, buf *bytes.Buffer) error {
 	}
 	if l.indent <= parentIndent {
 		// Compact notation: block sequence value at same indent as mapping key.
-		if l.indent == parentIndent && isSeqItem(l.content) {
+		if compact && l.indent == parentIndent && isSeqItem(l.content) {
 			return p.parseSequence(l.indent, buf)
 		}
 		buf.WriteString("null")
 		return nil
 	}
+	if l.content[0] == '&' || l.content[0] == '!' {
+		return p.parseBlockWithProps(parentIndent, compact, buf)
+	}
 	blockIndent := l.indent
 
 	switch {
@@ -152,76 +311,159 @@ func (p *parser) parseBlock(parentIndent int, buf *bytes.Buffer) error {
 				return err
 			}
 			p.skipPastRawLine(last)
-			writeJSONString(scalar, buf)
+			p.markBlockScalar(buf, l.content, last)
+			if err := p.writeBlockScalar(scalar, buf); err != nil {
+				return atLineCol(rawLine, l.indent, err)
+			}
 			return nil
 		}
 		if isFlowValue(l.content) {
 			src, last := p.gatherFlowSrc(l.content, rawLine)
-			if err := parseFlowExpr(src, buf); err != nil {
+			if err := p.parseFlowExpr(src, buf); err != nil {
 				return atLineCol(rawLine, l.indent, err)
 			}
 			p.skipPastRawLine(last)
 			return nil
 		}
-		if err := writeScalar(l.content, buf); err != nil {
+		if err := p.writeScalar(l.content, buf); err != nil {
 			return atLineCol(rawLine, l.indent, err)
 		}
 		return nil
 	}
 }
 
+// parseBlockWithProps is parseBlock for a block whose first line starts with
+// an anchor or tag. The value is either the rest of that line or, if nothing
+// follows them, the block on the lines below it.
+func (p *parser) parseBlockWithProps(parentIndent int, compact bool, buf *bytes.Buffer) error {
+	l := &p.lines[p.pos]
+	rawLine, col := p.rawIdx[p.pos], l.indent
+	props, rest, err := cutYAMLProps(l.content)
+	if err == nil && isMapKey(rest) {
+		err = errYAMLKeyProperties
+	}
+	if err != nil {
+		p.consume()
+		return atLineCol(rawLine, col, err)
+	}
+	if len(rest) == 0 {
+		p.consume()
+	} else {
+		l.content = rest
+	}
+	p.tag = props.tag
+	start := buf.Len()
+	if err := p.parseBlock(parentIndent, compact, buf); err != nil {
+		return err
+	}
+	return atLineCol(rawLine, col, p.endProps(props, buf, start))
+}
+
 // parseMapping writes a JSON object for all map-key lines at indent.
 func (p *parser) parseMapping(indent int, buf *bytes.Buffer) error {
+	rawLine := p.rawIdx[p.pos]
+	tag, err := p.collectionTag("map")
+	if err != nil {
+		return atLineCol(rawLine, indent, err)
+	}
+	start := buf.Len()
+	if l, ok := p.peek(); ok {
+		p.sm.openOf(buf, l.content)
+	}
 	buf.WriteByte('{')
-	first := true
+	p.keys.open()
 	for {
 		l, ok := p.peek()
 		if !ok || l.indent != indent || !isMapKey(l.content) {
 			break
 		}
-		if !first {
-			buf.WriteByte(',')
-		}
-		first = false
+		writeMemberComma(buf)
 		p.consume()
-		rawLine := p.rawIdx[p.pos-1]
-
-		key, rest, err := splitMapKey(l.content)
-		if err != nil {
+		keys := p.keys.depth()
+		if err := p.mappingEntry(l, indent, buf); err != nil && !p.resync(err, indent, keys) {
 			return err
 		}
+	}
+	p.closeMapping(buf)
+	buf.WriteByte('}')
+	return atLineCol(rawLine, indent, p.localTag(tag, buf, start))
+}
+
+// mappingEntry writes the key and value of l, a map-key line of the block
+// mapping at indent that has just been consumed.
+func (p *parser) mappingEntry(l pline, indent int, buf *bytes.Buffer) error {
+	rawLine := p.rawIdx[p.pos-1]
+
+	key, rest, err := splitMapKey(l.content)
+	if err == nil && isYAMLKeyProperty(l.content[0]) {
+		err = errYAMLKeyProperties
+	}
+	if err != nil {
+		return atLineCol(rawLine, l.indent, err)
+	}
+	merge := isYAMLMergeKey(l.content)
+	start := buf.Len()
+	if !merge {
+		p.markKey(buf, l.content)
 		writeJSONString(key, buf)
+		if err := p.keys.key(buf, start, p.sm); err != nil {
+			return atLineCol(rawLine, l.indent, err)
+		}
 		buf.WriteByte(':')
+	}
+	valCol := l.indent + len(l.content) - len(rest)
+	props, rest, err := cutYAMLProps(rest)
+	if err != nil {
+		return atLineCol(rawLine, valCol, err)
+	}
+	p.tag = props.tag
+	valStart := buf.Len()
 
-		if len(rest) == 0 {
-			if err := p.parseBlock(indent, buf); err != nil {
-				return err
-			}
-		} else if style, chomping, ok := detectBlockScalar(rest); ok {
-			scalar, last, err := p.collectBlockScalar(style, chomping, rawLine, l.indent)
-			if err != nil {
-				return err
-			}
-			p.skipPastRawLine(last)
-			writeJSONString(scalar, buf)
-		} else if isFlowValue(rest) {
-			src, last := p.gatherFlowSrc(rest, rawLine)
-			if err := parseFlowExpr(src, buf); err != nil {
-				return atLineCol(rawLine, l.indent+len(l.content)-len(rest), err)
-			}
-			p.skipPastRawLine(last)
-		} else {
-			if err := writeScalar(rest, buf); err != nil {
-				return atLineCol(rawLine, l.indent+len(l.content)-len(rest), err)
-			}
+	if len(rest) == 0 {
+		if err := p.parseBlock(indent, true, buf); err != nil {
+			return err
+		}
+	} else if style, chomping, ok := detectBlockScalar(rest); ok {
+		scalar, last, err := p.collectBlockScalar(style, chomping, rawLine, l.indent)
+		if err != nil {
+			return err
+		}
+		p.skipPastRawLine(last)
+		p.markBlockScalar(buf, rest, last)
+		if err := p.writeBlockScalar(scalar, buf); err != nil {
+			return atLineCol(rawLine, valCol, err)
+		}
+	} else if isFlowValue(rest) {
+		src, last := p.gatherFlowSrc(rest, rawLine)
+		if err := p.parseFlowExpr(src, buf); err != nil {
+			return atLineCol(rawLine, l.indent+len(l.content)-len(rest), err)
+		}
+		p.skipPastRawLine(last)
+	} else {
+		if err := p.writeScalar(rest, buf); err != nil {
+			return atLineCol(rawLine, l.indent+len(l.content)-len(rest), err)
 		}
 	}
-	buf.WriteByte('}')
+	if err := p.endProps(props, buf, valStart); err != nil {
+		return atLineCol(rawLine, valCol, err)
+	}
+	if merge {
+		return atLineCol(rawLine, l.indent, p.merge(buf, start, valStart))
+	}
 	return nil
 }
 
 // parseSequence writes a JSON array for all sequence-item lines at indent.
 func (p *parser) parseSequence(indent int, buf *bytes.Buffer) error {
+	rawLine := p.rawIdx[p.pos]
+	tag, err := p.collectionTag("seq")
+	if err != nil {
+		return atLineCol(rawLine, indent, err)
+	}
+	start := buf.Len()
+	if l, ok := p.peek(); ok {
+		p.sm.openOf(buf, l.content)
+	}
 	buf.WriteByte('[')
 	first := true
 	for {
@@ -234,46 +476,70 @@ func (p *parser) parseSequence(indent int, buf *bytes.Buffer) error {
 		}
 		first = false
 		p.consume()
-		rawLine := p.rawIdx[p.pos-1]
-
-		rest := bytes.TrimPrefix(l.content, []byte("-"))
-		if len(rest) > 0 && rest[0] == ' ' {
-			rest = rest[1:]
+		keys := p.keys.depth()
+		if err := p.sequenceItem(l, indent, buf); err != nil && !p.resync(err, indent, keys) {
+			return err
 		}
-		rest = bytes.TrimSpace(rest)
+	}
+	buf.WriteByte(']')
+	return atLineCol(rawLine, indent, p.localTag(tag, buf, start))
+}
 
-		if len(rest) == 0 {
-			if err := p.parseBlock(indent, buf); err != nil {
-				return err
-			}
-		} else if style, chomping, ok := detectBlockScalar(rest); ok {
-			scalar, last, err := p.collectBlockScalar(style, chomping, rawLine, l.indent)
-			if err != nil {
+// sequenceItem writes the value of l, a sequence-item line of the block
+// sequence at indent that has just been consumed.
+func (p *parser) sequenceItem(l pline, indent int, buf *bytes.Buffer) error {
+	rawLine := p.rawIdx[p.pos-1]
+
+	rest := bytes.TrimPrefix(l.content, []byte("-"))
+	if len(rest) > 0 && rest[0] == ' ' {
+		rest = rest[1:]
+	}
+	rest = bytes.TrimSpace(rest)
+	valCol := l.indent + len(l.content) - len(rest)
+	props, rest, err := cutYAMLProps(rest)
+	if err == nil && (props.anchor != nil || props.tag != nil) && isMapKey(rest) {
+		err = errYAMLKeyProperties
+	}
+	if err != nil {
+		return atLineCol(rawLine, l.indent+len(l.content)-len(rest), err)
+	}
+	p.tag = props.tag
+	valStart := buf.Len()
+
+	if len(rest) == 0 {
+		if err := p.parseBlock(indent, false, buf); err != nil {
+			return err
+		}
+	} else if style, chomping, ok := detectBlockScalar(rest); ok {
+		scalar, last, err := p.collectBlockScalar(style, chomping, rawLine, l.indent)
+		if err != nil {
+			return err
+		}
+		p.skipPastRawLine(last)
+		p.markBlockScalar(buf, rest, last)
+		if err := p.writeBlockScalar(scalar, buf); err != nil {
+			return atLineCol(rawLine, valCol, err)
+		}
+	} else if isFlowValue(rest) {
+		src, last := p.gatherFlowSrc(rest, rawLine)
+		if err := p.parseFlowExpr(src, buf); err != nil {
+			return atLineCol(rawLine, l.indent+len(l.content)-len(rest), err)
+		}
+		p.skipPastRawLine(last)
+	} else {
+		if isMapKey(rest) {
+			p.sm.openOf(buf, rest)
+			firstLineCol := l.indent + len(l.content) - len(rest)
+			if err := p.parseInlineMap(rest, l.indent+2, rawLine, firstLineCol, buf); err != nil {
 				return err
 			}
-			p.skipPastRawLine(last)
-			writeJSONString(scalar, buf)
-		} else if isFlowValue(rest) {
-			src, last := p.gatherFlowSrc(rest, rawLine)
-			if err := parseFlowExpr(src, buf); err != nil {
-				return atLineCol(rawLine, l.indent+len(l.content)-len(rest), err)
-			}
-			p.skipPastRawLine(last)
 		} else {
-			if isMapKey(rest) {
-				firstLineCol := l.indent + len(l.content) - len(rest)
-				if err := p.parseInlineMap(rest, l.indent+2, rawLine, firstLineCol, buf); err != nil {
-					return err
-				}
-			} else {
-				if err := writeScalar(rest, buf); err != nil {
-					return atLineCol(rawLine, l.indent+len(l.content)-len(rest), err)
-				}
+			if err := p.writeScalar(rest, buf); err != nil {
+				return atLineCol(rawLine, l.indent+len(l.content)-len(rest), err)
 			}
 		}
 	}
-	buf.WriteByte(']')
-	return nil
+	return atLineCol(rawLine, valCol, p.endProps(props, buf, valStart))
 }
 
 // parseInlineMap handles the case where a sequence item starts an inline
@@ -283,33 +549,59 @@ func (p *parser) parseSequence(indent int, buf *bytes.Buffer) error {
 //     age: 30
 func (p *parser) parseInlineMap(firstLine []byte, virtIndent int, startRawLine int, firstLineCol int, buf *bytes.Buffer) error {
 	buf.WriteByte('{')
+	p.keys.open()
 
 	writeKeyValue := func(line []byte, rawLine int, lineCol int) error {
 		key, rest, err := splitMapKey(line)
+		if err == nil && isYAMLKeyProperty(line[0]) {
+			err = errYAMLKeyProperties
+		}
 		if err != nil {
-			return err
+			return atLineCol(rawLine, lineCol, err)
 		}
-		writeJSONString(key, buf)
-		buf.WriteByte(':')
+		merge := isYAMLMergeKey(line)
+		start := buf.Len()
+		if !merge {
+			p.markKey(buf, line)
+			writeJSONString(key, buf)
+			if err := p.keys.key(buf, start, p.sm); err != nil {
+				return atLineCol(rawLine, lineCol, err)
+			}
+			buf.WriteByte(':')
+		}
+		valCol := lineCol + len(line) - len(rest)
+		props, rest, err := cutYAMLProps(rest)
+		if err != nil {
+			return atLineCol(rawLine, valCol, err)
+		}
+		p.tag = props.tag
+		valStart := buf.Len()
 		if len(rest) == 0 {
-			if err := p.parseBlock(virtIndent-1, buf); err != nil {
+			if err := p.parseBlock(virtIndent-1, true, buf); err != nil {
 				return err
 			}
 		} else if isFlowValue(rest) {
 			src, last := p.gatherFlowSrc(rest, rawLine)
-			if err := parseFlowExpr(src, buf); err != nil {
+			if err := p.parseFlowExpr(src, buf); err != nil {
 				return atLineCol(rawLine, lineCol+len(line)-len(rest), err)
 			}
 			p.skipPastRawLine(last)
 		} else {
-			if err := writeScalar(rest, buf); err != nil {
+			if err := p.writeScalar(rest, buf); err != nil {
 				return atLineCol(rawLine, lineCol+len(line)-len(rest), err)
 			}
 		}
+		if err := p.endProps(props, buf, valStart); err != nil {
+			return atLineCol(rawLine, valCol, err)
+		}
+		if merge {
+			return atLineCol(rawLine, lineCol, p.merge(buf, start, valStart))
+		}
 		return nil
 	}
 
-	if err := writeKeyValue(firstLine, startRawLine, firstLineCol); err != nil {
+	keys := p.keys.depth()
+	if err := writeKeyValue(firstLine, startRawLine, firstLineCol); err != nil && !p.resync(err, virtIndent, keys) {
 		return err
 	}
 
@@ -318,14 +610,15 @@ func (p *parser) parseInlineMap(firstLine []byte, virtIndent int, startRawLine i
 		if !ok || l.indent != virtIndent || !isMapKey(l.content) {
 			break
 		}
-		buf.WriteByte(',')
+		writeMemberComma(buf)
 		p.consume()
 		rawLine := p.rawIdx[p.pos-1]
-		if err := writeKeyValue(l.content, rawLine, l.indent); err != nil {
+		if err := writeKeyValue(l.content, rawLine, l.indent); err != nil && !p.resync(err, virtIndent, keys) {
 			return err
 		}
 	}
 
+	p.closeMapping(buf)
 	buf.WriteByte('}')
 	return nil
 }
@@ -373,7 +666,7 @@ func (p *parser) collectBlockScalar(style, chomping byte, rawLineIdx, keyIndent
 			}
 			continue
 		}
-		ind, err := yamlLeadingIndent(raw)
+		ind, err := yamlLeadingIndent(raw, p.opts.tabWidth())
 		if err != nil {
 			return nil, -1, atLineCol(i, 0, err)
 		}
@@ -425,9 +718,130 @@ func (p *parser) collectBlockScalar(style, chomping byte, rawLineIdx, keyIndent
 	return result, lastIdx, nil
 }
 
+// --------------------------------------------------------------------------
+// Source positions
+// --------------------------------------------------------------------------
+
+// sourceOffset returns the input offset of s, which is either a slice of the
+// input or of the multi-line flow expression most recently gathered into
+// p.flow.
+func (p *parser) sourceOffset(s []byte) (int, bool) {
+	if off, ok := p.sm.offset(s); ok {
+		return off, true
+	}
+	at, ok := sliceOffset(p.flow, s)
+	if !ok {
+		return 0, false
+	}
+	seg := p.flowSegs[0]
+	for _, sg := range p.flowSegs[1:] {
+		if sg.at > at {
+			break
+		}
+		seg = sg
+	}
+	return seg.src + at - seg.at, true
+}
+
+// markValue records s as the source of the value about to be written to buf.
+func (p *parser) markValue(buf *bytes.Buffer, s []byte) {
+	if p.sm == nil {
+		return
+	}
+	start, ok := p.sourceOffset(s)
+	if !ok {
+		return
+	}
+	// A value in a multi-line flow may span a join; locate its last byte
+	// separately rather than assuming the input is contiguous.
+	end, ok := p.sourceOffset(s[len(s)-1:])
+	if !ok {
+		end = start + len(s) - 1
+	}
+	p.sm.value(buf, start, end+1)
+}
+
+// markOpen records s as the opening of the flow mapping or sequence about to
+// be written to buf.
+func (p *parser) markOpen(buf *bytes.Buffer, s []byte) {
+	if p.sm == nil {
+		return
+	}
+	if start, ok := p.sourceOffset(s); ok {
+		p.sm.value(buf, start, start)
+	}
+}
+
+// markClose records s, which starts with the closing bracket of a flow
+// mapping or sequence, as about to be written to buf.
+func (p *parser) markClose(buf *bytes.Buffer, s []byte) {
+	if p.sm == nil {
+		return
+	}
+	if start, ok := p.sourceOffset(s); ok {
+		p.sm.add(buf, start, start+1, markClose)
+	}
+}
+
+// markKey records the key token at the start of content, a map-key line, as
+// the source of the key about to be written to buf.
+func (p *parser) markKey(buf *bytes.Buffer, content []byte) {
+	if p.sm == nil {
+		return
+	}
+	p.sm.keyOf(buf, content[:mapKeyLen(content)])
+}
+
+// markFlowKey records s as the source of the flow mapping key about to be
+// written to buf.
+func (p *parser) markFlowKey(buf *bytes.Buffer, s []byte) {
+	if p.sm == nil {
+		return
+	}
+	start, ok := p.sourceOffset(s)
+	if ok {
+		p.sm.add(buf, start, start+len(s), markKey)
+	}
+}
+
+// markBlockScalar records a block scalar value as spanning from its
+// indicator to the end of raw line last.
+func (p *parser) markBlockScalar(buf *bytes.Buffer, indicator []byte, last int) {
+	if p.sm == nil {
+		return
+	}
+	start, ok := p.sm.offset(indicator)
+	end, ok2 := p.sm.offset(p.rawLines[last])
+	if !ok {
+		return
+	}
+	end += len(p.rawLines[last])
+	if !ok2 {
+		end = start + len(indicator)
+	}
+	p.sm.value(buf, start, end)
+}
+
+// resync records err, from an entry of the block at indent, and reports
+// whether parsing should continue. If so it skips the rest of the entry, the
+// lines indented deeper than the block, so that parsing resumes at the next
+// line at the block's indentation, and closes the objects the entry left
+// open, leaving keys open.
+func (p *parser) resync(err error, indent, keys int) bool {
+	if !p.errs.add(err) {
+		return false
+	}
+	p.keys.unwind(keys)
+	p.tag = nil
+	for p.pos < p.end && p.lines[p.pos].indent > indent {
+		p.pos++
+	}
+	return true
+}
+
 // skipPastRawLine advances p.pos past all plines whose raw-line index is ≤ lastRawIdx.
 func (p *parser) skipPastRawLine(lastRawIdx int) {
-	for p.pos < len(p.lines) && p.rawIdx[p.pos] <= lastRawIdx {
+	for p.pos < p.end && p.rawIdx[p.pos] <= lastRawIdx {
 		p.pos++
 	}
 }
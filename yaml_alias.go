@@ -0,0 +1,185 @@
+package tojson
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// Anchors, aliases, and merge keys.
+//
+// An anchored value is converted as usual and its JSON, once written, is
+// copied into p.anchors. An alias writes that JSON again, so the converter
+// keeps streaming and never builds a tree. A merge key's value is written
+// in place too, then cut from the output and held in p.merges until its
+// mapping closes, when the members it adds are appended: only then is it
+// known which keys the mapping defines itself.
+
+// yamlDefaultMaxAliasBytes is the least budget for aliases when
+// YAMLOptions.MaxAliasBytes is zero.
+const yamlDefaultMaxAliasBytes = 1 << 20
+
+// yamlMerge is the value of a merge key, a JSON object or an array of them,
+// waiting for the mapping at depth in p.keys to close.
+type yamlMerge struct {
+	depth int
+	json  []byte
+}
+
+// maxAliasBytes returns the budget for aliases when converting input of n
+// bytes, or a negative value if aliases are not allowed.
+func (o *YAMLOptions) maxAliasBytes(n int) int {
+	if o.MaxAliasBytes != 0 {
+		return o.MaxAliasBytes
+	}
+	return max(yamlDefaultMaxAliasBytes, 16*n)
+}
+
+// yamlAnchorNameLen returns the length of the anchor or alias name at the
+// start of s, which runs to a space or a flow indicator.
+func yamlAnchorNameLen(s []byte) int {
+	for i, c := range s {
+		switch c {
+		case ' ', '\t', ',', '[', ']', '{', '}':
+			return i
+		}
+	}
+	return len(s)
+}
+
+// isYAMLMergeKey reports whether content, a map-key line or flow mapping
+// entry, has the merge key <<. A quoted "<<" is an ordinary key.
+func isYAMLMergeKey(content []byte) bool {
+	return bytes.HasPrefix(content, []byte("<<")) &&
+		(len(content) == 2 || content[2] == ':' || content[2] == ' ' || content[2] == '\t')
+}
+
+// setAnchor records the JSON written to buf from offset start as the value
+// of the anchor name. An empty name records nothing.
+func (p *parser) setAnchor(name []byte, buf *bytes.Buffer, start int) {
+	if len(name) == 0 {
+		return
+	}
+	if p.anchors == nil {
+		p.anchors = make(map[string][]byte)
+	}
+	p.anchors[string(name)] = bytes.Clone(buf.Bytes()[start:])
+}
+
+// writeAlias writes the JSON of the anchor that the alias *name in s refers
+// to, charging it to the budget for aliases.
+func (p *parser) writeAlias(s []byte, buf *bytes.Buffer) error {
+	name := s[1:]
+	if n := yamlAnchorNameLen(name); n == 0 || n != len(name) {
+		return fmt.Errorf("invalid alias %s", s)
+	}
+	if p.aliasLimit < 0 {
+		return fmt.Errorf("alias %s: aliases are not allowed", s)
+	}
+	val, ok := p.anchors[string(name)]
+	if !ok {
+		return fmt.Errorf("alias %s has no anchor", s)
+	}
+	if p.aliasBytes += len(val); p.aliasBytes > p.aliasLimit {
+		return fmt.Errorf("alias %s: aliases expand beyond the limit of %d bytes", s, p.aliasLimit)
+	}
+	buf.Write(val)
+	return nil
+}
+
+// merge cuts the value of a merge key, written to buf from offset valStart,
+// from the output along with the rest of its entry, from offset start, and
+// holds it for closeMapping.
+func (p *parser) merge(buf *bytes.Buffer, start, valStart int) error {
+	val := buf.Bytes()[valStart:]
+	ok := len(val) > 0 && val[0] == '{'
+	if len(val) > 0 && val[0] == '[' {
+		ok = true
+		for i := 1; ok && val[i] != ']'; i = skipJSONValue(val, i) {
+			if val[i] == ',' {
+				i++
+			}
+			ok = val[i] == '{'
+		}
+	}
+	if !ok {
+		return fmt.Errorf("the value of a merge key must be a mapping or a sequence of mappings")
+	}
+	p.merges = append(p.merges, yamlMerge{depth: p.keys.depth(), json: bytes.Clone(val)})
+	if start > 0 && buf.Bytes()[start-1] == ',' {
+		start--
+	}
+	buf.Truncate(start)
+	p.sm.truncate(start)
+	return nil
+}
+
+// writeMemberComma writes the ',' before an object member unless buf ends
+// with the object's '{', as it does when a merge key came first.
+func writeMemberComma(buf *bytes.Buffer) {
+	if b := buf.Bytes(); b[len(b)-1] != '{' {
+		buf.WriteByte(',')
+	}
+}
+
+// errYAMLKeyProperties reports an anchor, alias, or tag on a mapping key.
+var errYAMLKeyProperties = errors.New("anchors, aliases, and tags are not supported on mapping keys")
+
+// isYAMLKeyProperty reports whether c, the first byte of a mapping key,
+// starts an anchor, alias, or tag.
+func isYAMLKeyProperty(c byte) bool {
+	return c == '&' || c == '*' || c == '!'
+}
+
+// closeMapping ends the innermost open mapping, appending the members of
+// the mappings merged into it that do not repeat one of its keys, earlier
+// merges taking precedence over later ones. Call it just before writing the
+// closing brace.
+func (p *parser) closeMapping(buf *bytes.Buffer) {
+	depth := p.keys.depth()
+	i := len(p.merges)
+	for i > 0 && p.merges[i-1].depth >= depth {
+		i--
+	}
+	if i < len(p.merges) {
+		for _, m := range p.merges[i:] {
+			if m.depth == depth {
+				p.mergeMembers(buf, m.json)
+			}
+		}
+		clear(p.merges[i:])
+		p.merges = p.merges[:i]
+	}
+	p.keys.close(buf, p.sm)
+}
+
+// mergeMembers appends to buf the members of the JSON object, or of each
+// object in the JSON array, val whose keys the innermost open mapping lacks.
+func (p *parser) mergeMembers(buf *bytes.Buffer, val []byte) {
+	if val[0] == '[' {
+		for i := 1; val[i] != ']'; {
+			if val[i] == ',' {
+				i++
+			}
+			end := skipJSONValue(val, i)
+			p.mergeMembers(buf, val[i:end])
+			i = end
+		}
+		return
+	}
+	for i := 1; val[i] != '}'; {
+		if val[i] == ',' {
+			i++
+		}
+		keyEnd := skipJSONString(val, i)
+		end := skipJSONValue(val, keyEnd+1)
+		if !p.keys.has(buf, val[i:keyEnd]) {
+			writeMemberComma(buf)
+			keyStart := buf.Len()
+			buf.Write(val[i:keyEnd])
+			p.keys.key(buf, keyStart, p.sm) // cannot fail: the key is new
+			buf.Write(val[keyEnd:end])
+		}
+		i = end
+	}
+}
@@ -0,0 +1,189 @@
+package tojson
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestYAMLAnchors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"scalar", "a: &x 1\nb: *x", `{"a":1,"b":1}`},
+		{"quoted scalar", "a: &x 'hi there'\nb: [*x]", `{"a":"hi there","b":["hi there"]}`},
+		{"block mapping", "a: &x\n  b: 1\n  c: [2]\nd: *x", `{"a":{"b":1,"c":[2]},"d":{"b":1,"c":[2]}}`},
+		{"block sequence", "a: &x\n  - 1\n  - 2\nb: *x", `{"a":[1,2],"b":[1,2]}`},
+		{"compact sequence", "a: &x\n- 1\nb: *x", `{"a":[1],"b":[1]}`},
+		{"block scalar", "a: &x |\n  text\nb: *x", `{"a":"text\n","b":"text\n"}`},
+		{"sequence items", "- &x one\n- *x\n- &y\n  k: v\n- *y", `["one","one",{"k":"v"},{"k":"v"}]`},
+		{"empty sequence item", "- &a\n- *a", `[null,null]`},
+		{"inline map", "- a: &x 1\n  b: *x", `[{"a":1,"b":1}]`},
+		{"root", "&x\na: 1", `{"a":1}`},
+		{"root flow", "&x [1, 2]", `[1,2]`},
+		{"flow", "a: [&x 1, *x, &y {b: *x}, *y]", `{"a":[1,1,{"b":1},{"b":1}]}`},
+		{"flow mapping value", "a: {b: &x c, d: *x}", `{"a":{"b":"c","d":"c"}}`},
+		{"empty anchored flow item", "a: [&x , *x]", `{"a":[null,null]}`},
+		{"redefined", "a: &x 1\nb: &x 2\nc: *x", `{"a":1,"b":2,"c":2}`},
+		{"nested", "a: &x {b: &y 1}\nc: *y\nd: *x", `{"a":{"b":1},"c":1,"d":{"b":1}}`},
+		{"ampersand in plain scalar", "a: b&c\nd: b*c", `{"a":"b&c","d":"b*c"}`},
+		{"quoted", "a: '&x'\nb: \"*x\"", `{"a":"&x","b":"*x"}`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := FromYAML([]byte(tc.in))
+			if err != nil || string(got) != tc.want {
+				t.Errorf("got %s, %v, want %s", got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestYAMLMergeKeys(t *testing.T) {
+	base := "base: &b\n  x: 1\n  y: 2\n"
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"merge", base + "d:\n  <<: *b\n  z: 3", `{"base":{"x":1,"y":2},"d":{"z":3,"x":1,"y":2}}`},
+		{"override after", base + "d:\n  <<: *b\n  y: 3", `{"base":{"x":1,"y":2},"d":{"y":3,"x":1}}`},
+		{"override before", base + "d:\n  y: 3\n  <<: *b", `{"base":{"x":1,"y":2},"d":{"y":3,"x":1}}`},
+		{"only merge", base + "d:\n  <<: *b", `{"base":{"x":1,"y":2},"d":{"x":1,"y":2}}`},
+		{"list", "a: &a {x: 1}\nb: &b {x: 2, z: 3}\nc:\n  <<: [*a, *b]\n  w: 4",
+			`{"a":{"x":1},"b":{"x":2,"z":3},"c":{"w":4,"x":1,"z":3}}`},
+		{"block list", "a: &a {x: 1}\nb: &b {y: 2}\nc:\n  <<:\n    - *a\n    - *b",
+			`{"a":{"x":1},"b":{"y":2},"c":{"x":1,"y":2}}`},
+		{"two merge keys", "a: &a {x: 1}\nb: &b {x: 2, y: 2}\nc:\n  <<: *a\n  <<: *b",
+			`{"a":{"x":1},"b":{"x":2,"y":2},"c":{"x":1,"y":2}}`},
+		{"literal mapping", "a:\n  <<: {x: 1}\n  y: 2", `{"a":{"y":2,"x":1}}`},
+		{"nested mappings", base + "d:\n  <<: *b\n  e:\n    <<: *b\n    x: 0",
+			`{"base":{"x":1,"y":2},"d":{"e":{"x":0,"y":2},"x":1,"y":2}}`},
+		{"sequence item", "- <<: {x: 1, y: 1}\n  y: 0", `[{"y":0,"x":1}]`},
+		{"inline map", "- <<: {m: 1}\n  n: 2", `[{"n":2,"m":1}]`},
+		{"flow", base + "d: {<<: *b, y: 0}", `{"base":{"x":1,"y":2},"d":{"y":0,"x":1}}`},
+		{"flow merge last", base + "d: {y: 0, <<: *b}", `{"base":{"x":1,"y":2},"d":{"y":0,"x":1}}`},
+		{"quoted key", "'<<': {x: 1}", `{"<<":{"x":1}}`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := FromYAML([]byte(tc.in))
+			if err != nil || string(got) != tc.want {
+				t.Errorf("got %s, %v, want %s", got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestYAMLMergeKeysDuplicates(t *testing.T) {
+	src := []byte("b: &b {x: 1, y: 1}\nd:\n  x: 2\n  <<: *b\n  x: 3\n")
+	tests := []struct {
+		policy DuplicateKeyPolicy
+		want   string
+	}{
+		{DuplicateKeyFirst, `{"b":{"x":1,"y":1},"d":{"x":2,"y":1}}`},
+		{DuplicateKeyLast, `{"b":{"x":1,"y":1},"d":{"x":3,"y":1}}`},
+	}
+	for _, tc := range tests {
+		got, err := FromYAMLWithOptions(src, YAMLOptions{DuplicateKeys: tc.policy})
+		if err != nil || string(got) != tc.want {
+			t.Errorf("policy %d: got %s, %v, want %s", tc.policy, got, err, tc.want)
+		}
+	}
+	// Keys from a merge never count as duplicates; repeated explicit keys do.
+	_, err := FromYAML(src)
+	pe := requireParseError(t, err)
+	if pe.Line != 5 || !strings.Contains(pe.Message, `duplicate key "x"`) {
+		t.Errorf("got %v", pe)
+	}
+}
+
+func TestYAMLAliasErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		opts      YAMLOptions
+		line, col int
+		msg       string
+	}{
+		{"undefined", "a: 1\nb: *x", YAMLOptions{}, 2, 4, "alias *x has no anchor"},
+		{"defined later", "a: *x\nb: &x 1", YAMLOptions{}, 1, 4, "alias *x has no anchor"},
+		{"inside own anchor", "a: &x\n  b: *x", YAMLOptions{}, 2, 6, "alias *x has no anchor"},
+		{"empty alias", "a: *", YAMLOptions{}, 1, 4, "invalid alias *"},
+		{"alias with text", "a: &x 1\nb: *x y", YAMLOptions{}, 2, 4, "invalid alias *x y"},
+		{"empty anchor", "a: & 1", YAMLOptions{}, 1, 4, "expected an anchor name after '&'"},
+		{"anchored key", "&x a: 1", YAMLOptions{}, 1, 1, "anchors, aliases, and tags are not supported on mapping keys"},
+		{"alias key", "a: 1\n*x : 1", YAMLOptions{}, 2, 1, "anchors, aliases, and tags are not supported on mapping keys"},
+		{"anchored item key", "- &x a: 1", YAMLOptions{}, 1, 6, "anchors, aliases, and tags are not supported on mapping keys"},
+		{"flow key", "a: {&x b: 1}", YAMLOptions{}, 1, 4, "anchors, aliases, and tags are not supported on mapping keys"},
+		{"merge scalar", "<<: 1", YAMLOptions{}, 1, 1, "the value of a merge key must be a mapping or a sequence of mappings"},
+		{"merge list of scalars", "a:\n  <<: [{b: 1}, 2]", YAMLOptions{}, 2, 3, "the value of a merge key must be a mapping or a sequence of mappings"},
+		{"not allowed", "a: &x 1\nb: *x", YAMLOptions{MaxAliasBytes: -1}, 2, 4, "alias *x: aliases are not allowed"},
+		{"limit", "a: &x [1, 2, 3]\nb: *x\nc: *x", YAMLOptions{MaxAliasBytes: 10}, 3, 4, "alias *x: aliases expand beyond the limit of 10 bytes"},
+		{"merge limit", "a: &x {b: 1, c: 2}\nd:\n  <<: *x", YAMLOptions{MaxAliasBytes: 5}, 3, 7, "alias *x: aliases expand beyond the limit of 5 bytes"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := FromYAMLWithOptions([]byte(tc.in), tc.opts)
+			pe := requireParseError(t, err)
+			if pe.Line != tc.line || pe.Column != tc.col || pe.Message != tc.msg {
+				t.Errorf("got %d:%d %q, want %d:%d %q", pe.Line, pe.Column, pe.Message, tc.line, tc.col, tc.msg)
+			}
+		})
+	}
+}
+
+func TestYAMLAliasLimitDefault(t *testing.T) {
+	// The "billion laughs": nine levels of nine aliases each would expand to
+	// 9^9 copies of the innermost string.
+	var b strings.Builder
+	b.WriteString(`a: &a ["lol","lol","lol","lol","lol","lol","lol","lol","lol"]` + "\n")
+	for c := 'b'; c <= 'i'; c++ {
+		prev := string(c - 1)
+		b.WriteString(string(c) + ": &" + string(c) + " [" + strings.Repeat("*"+prev+",", 8) + "*" + prev + "]\n")
+	}
+	_, err := FromYAML([]byte(b.String()))
+	pe := requireParseError(t, err)
+	if !strings.HasSuffix(pe.Message, "aliases expand beyond the limit of 1048576 bytes") {
+		t.Errorf("got %v", pe)
+	}
+
+	// The default grows with the input so that large documents that reuse
+	// big anchors a few times still convert.
+	big := "a: &a [" + strings.Repeat(`"0123456789abcdef",`, 1<<16) + "0]\n"
+	if _, err := FromYAML([]byte(big + "b: *a\nc: *a\n")); err != nil {
+		t.Errorf("large input: %v", err)
+	}
+}
+
+func TestYAMLAnchorsConverter(t *testing.T) {
+	// Anchors do not carry over from one document to the next.
+	var c Converter
+	if got, err := c.AppendYAML(nil, []byte("a: &x 1\nb: *x")); err != nil || string(got) != `{"a":1,"b":1}` {
+		t.Errorf("first: got %s, %v", got, err)
+	}
+	if _, err := c.AppendYAML(nil, []byte("b: *x")); err == nil {
+		t.Error("second: expected an error for an anchor from the previous document")
+	}
+}
+
+func TestYAMLAnchorsSourceMap(t *testing.T) {
+	src := []byte("base: &b\n  x: 1\nd:\n  <<: *b\n  y: 2\ne: *b\n")
+	out, sm, err := FromYAMLWithSourceMap(src)
+	if err != nil || string(out) != `{"base":{"x":1},"d":{"y":2,"x":1},"e":{"x":1}}` {
+		t.Fatalf("got %s, %v", out, err)
+	}
+	for _, tc := range []struct {
+		ptr       string
+		line, col int
+	}{
+		{"/d/y", 5, 6},
+		{"/e", 6, 4},
+	} {
+		s, ok := sm.Lookup(tc.ptr)
+		if !ok || s.Start.Line != tc.line || s.Start.Column != tc.col {
+			t.Errorf("%s: got %+v, %v, want %d:%d", tc.ptr, s, ok, tc.line, tc.col)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package tojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// ToYAMLOptions controls the output of ToYAML. The zero value selects the
+// defaults.
+type ToYAMLOptions struct {
+	// Indent is the number of spaces per nesting level. Zero selects the
+	// default of 2.
+	Indent int
+}
+
+// yamlDefaultIndent is the indent used when ToYAMLOptions.Indent is zero.
+const yamlDefaultIndent = 2
+
+// ToYAML converts JSON to block-style YAML in the subset FromYAML accepts,
+// keeping object keys in their input order. Strings that FromYAML would read
+// back as something else, such as "true", "12", or "a: b", are quoted, so
+// FromYAML(ToYAML(src)) yields the same JSON as src in compact form. The one
+// exception is a CR LF pair inside a string, which FromYAML, like the other
+// converters, writes as a bare LF.
+//
+// Multi-line strings are written as literal block scalars (|) where the
+// round trip allows it and as double-quoted strings otherwise. Empty objects
+// and arrays are written in flow style as {} and [].
+//
+// Invalid JSON is reported as a *ParseError.
+func ToYAML(src []byte, opts ToYAMLOptions) ([]byte, error) {
+	e := yamlEncoder{
+		dec:    json.NewDecoder(bytes.NewReader(src)),
+		indent: opts.Indent,
+		out:    make([]byte, 0, len(src)+len(src)/2),
+	}
+	if e.indent <= 0 {
+		e.indent = yamlDefaultIndent
+	}
+	e.dec.UseNumber()
+	if err := e.document(); err != nil {
+		return nil, jsonInputError(src, e.dec.InputOffset(), err)
+	}
+	if err := jsonTrailingError(src, e.dec.InputOffset()); err != nil {
+		return nil, err
+	}
+	return e.out, nil
+}
+
+// yamlContext says where a value is being written: the text before it on
+// its line decides how a value must begin.
+type yamlContext int
+
+const (
+	yamlAtRoot       yamlContext = iota // at the start of the document
+	yamlAfterKey                        // after "key:" in a block mapping
+	yamlAfterDash                       // after "-" in a block sequence
+	yamlAfterItemKey                    // after "key:" in a mapping that started on a "- " line
+)
+
+type yamlEncoder struct {
+	dec    *json.Decoder
+	indent int
+	out    []byte
+}
+
+func (e *yamlEncoder) document() error {
+	tok, err := e.dec.Token()
+	if err != nil {
+		return err
+	}
+	return e.value(tok, 0, yamlAtRoot)
+}
+
+// value writes the value starting with tok. lineIndent is the indentation
+// of the line the value starts on.
+func (e *yamlEncoder) value(tok json.Token, lineIndent int, ctx yamlContext) error {
+	switch v := tok.(type) {
+	case json.Delim:
+		empty := !e.dec.More()
+		if empty {
+			if _, err := e.dec.Token(); err != nil {
+				return err
+			}
+			e.sep(ctx)
+			if v == '{' {
+				e.out = append(e.out, "{}\n"...)
+			} else {
+				e.out = append(e.out, "[]\n"...)
+			}
+			return nil
+		}
+		if v == '{' {
+			switch ctx {
+			case yamlAtRoot:
+				return e.object(0, false, false)
+			case yamlAfterDash:
+				// The first key shares the "- " line; the rest line up with it.
+				e.out = append(e.out, ' ')
+				return e.object(lineIndent+2, true, true)
+			default:
+				e.out = append(e.out, '\n')
+				return e.object(lineIndent+e.indent, false, false)
+			}
+		}
+		itemIndent := lineIndent + e.indent
+		if ctx == yamlAtRoot {
+			itemIndent = 0
+		} else {
+			e.out = append(e.out, '\n')
+		}
+		return e.array(itemIndent)
+	case string:
+		e.sep(ctx)
+		if ctx != yamlAfterItemKey && yamlBlockOK(v) {
+			e.blockScalar(v, lineIndent+e.indent)
+		} else {
+			e.scalar(v, false)
+		}
+	case json.Number:
+		e.sep(ctx)
+		e.out = append(e.out, v...)
+	case bool:
+		e.sep(ctx)
+		e.out = strconv.AppendBool(e.out, v)
+	case nil:
+		e.sep(ctx)
+		e.out = append(e.out, "null"...)
+	}
+	e.out = append(e.out, '\n')
+	return nil
+}
+
+// sep writes the space between a key or dash and a value on the same line.
+func (e *yamlEncoder) sep(ctx yamlContext) {
+	if ctx != yamlAtRoot {
+		e.out = append(e.out, ' ')
+	}
+}
+
+// object writes the members of an object whose '{' has been read, with keys
+// at keyIndent. When inline is set the first key continues the current line.
+// itemKeys marks a mapping that began on a "- " line, where FromYAML does not
+// accept block scalars.
+func (e *yamlEncoder) object(keyIndent int, inline, itemKeys bool) error {
+	ctx := yamlAfterKey
+	if itemKeys {
+		ctx = yamlAfterItemKey
+	}
+	for first := true; e.dec.More(); first = false {
+		tok, err := e.dec.Token()
+		if err != nil {
+			return err
+		}
+		if !first || !inline {
+			e.writeIndent(keyIndent)
+		}
+		e.scalar(tok.(string), true)
+		e.out = append(e.out, ':')
+
+		if tok, err = e.dec.Token(); err != nil {
+			return err
+		}
+		if err := e.value(tok, keyIndent, ctx); err != nil {
+			return err
+		}
+	}
+	_, err := e.dec.Token() // '}'
+	return err
+}
+
+// array writes the elements of an array whose '[' has been read, with dashes
+// at itemIndent.
+func (e *yamlEncoder) array(itemIndent int) error {
+	for e.dec.More() {
+		tok, err := e.dec.Token()
+		if err != nil {
+			return err
+		}
+		e.writeIndent(itemIndent)
+		e.out = append(e.out, '-')
+		if err := e.value(tok, itemIndent, yamlAfterDash); err != nil {
+			return err
+		}
+	}
+	_, err := e.dec.Token() // ']'
+	return err
+}
+
+func (e *yamlEncoder) writeIndent(n int) {
+	for range n {
+		e.out = append(e.out, ' ')
+	}
+}
+
+// scalar writes s plain when FromYAML reads it back unchanged and
+// double-quoted otherwise.
+func (e *yamlEncoder) scalar(s string, key bool) {
+	if yamlPlainOK(s, key) {
+		e.out = append(e.out, s...)
+		return
+	}
+	e.out = strconv.AppendQuote(e.out, s)
+}
+
+// blockScalar writes s, for which yamlBlockOK is true, as a literal block
+// scalar with its content lines at indent.
+func (e *yamlEncoder) blockScalar(s string, indent int) {
+	body := strings.TrimRight(s, "\n")
+	e.out = append(e.out, '|')
+	switch trailing := len(s) - len(body); {
+	case trailing == 0:
+		e.out = append(e.out, '-')
+	case trailing > 1:
+		e.out = append(e.out, '+')
+	}
+	for line := range strings.SplitSeq(body, "\n") {
+		e.out = append(e.out, '\n')
+		if line != "" {
+			e.writeIndent(indent)
+			e.out = append(e.out, line...)
+		}
+	}
+	// With keep chomping, each newline past the first is a blank line. The
+	// caller writes the final newline.
+	for range len(s) - len(body) - 1 {
+		e.out = append(e.out, '\n')
+	}
+}
+
+// yamlPlainOK reports whether s can be written as a plain scalar that
+// FromYAML reads back as the string s, under any YAMLOptions. Keys are always
+// strings, so for keys null, boolean, and number look-alikes are allowed; <<
+// is not, as it would be a merge key.
+func yamlPlainOK(s string, key bool) bool {
+	if s == "" || s != strings.TrimSpace(s) {
+		return false
+	}
+	// Indicators that start flow values, quotes, block scalars, comments,
+	// sequence items, and YAML features outside the supported subset.
+	if strings.IndexByte("-?:,[]{}#&*!|>'\"%@`", s[0]) >= 0 {
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+	for _, r := range s {
+		if !strconv.IsPrint(r) {
+			return false
+		}
+	}
+	if key {
+		return s != "<<"
+	}
+	switch s {
+	case "null", "Null", "NULL", "~",
+		"true", "True", "TRUE", "false", "False", "FALSE",
+		"yes", "Yes", "YES", "on", "On", "ON",
+		"no", "No", "NO", "off", "Off", "OFF",
+		"...":
+		return false
+	}
+	if _, _, ok := yamlNonFinite([]byte(s)); ok {
+		return false
+	}
+	return !isYAMLNumber([]byte(s))
+}
+
+// yamlBlockOK reports whether the multi-line string s round-trips through a
+// literal block scalar: FromYAML finds the content indentation from the
+// first line and trims trailing whitespace from every line.
+func yamlBlockOK(s string) bool {
+	if !strings.Contains(s, "\n") || s[0] == '\n' || s[0] == ' ' {
+		return false
+	}
+	for line := range strings.SplitSeq(strings.TrimRight(s, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		if line[0] == '\t' || line[len(line)-1] == ' ' || line[len(line)-1] == '\t' {
+			return false
+		}
+		for _, r := range line {
+			if r != '\t' && !strconv.IsPrint(r) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,157 @@
+package tojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestToYAML(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"scalar", `"hello"`, "hello\n"},
+		{"null", `null`, "null\n"},
+		{"empty object", `{}`, "{}\n"},
+		{"empty array", `[]`, "[]\n"},
+		{"mapping", `{"name":"web","port":8080,"debug":false,"extra":null}`,
+			"name: web\nport: 8080\ndebug: false\nextra: null\n"},
+		{"key order kept", `{"b":1,"a":2}`, "b: 1\na: 2\n"},
+		{"nested mapping", `{"server":{"host":"x","tls":{"on":true}}}`,
+			"server:\n  host: x\n  tls:\n    on: true\n"},
+		{"sequence", `{"tags":["a","b"]}`, "tags:\n  - a\n  - b\n"},
+		{"root sequence", `[1,[2,3],[]]`, "- 1\n-\n  - 2\n  - 3\n- []\n"},
+		{"sequence of mappings", `[{"name":"a","port":1},{"name":"b","nested":{"x":1}}]`,
+			"- name: a\n  port: 1\n- name: b\n  nested:\n    x: 1\n"},
+		{"empty containers", `{"a":{},"b":[]}`, "a: {}\nb: []\n"},
+		{"numbers verbatim", `[1.50,-0,1E+2,12345678901234567890]`, "- 1.50\n- -0\n- 1E+2\n- 12345678901234567890\n"},
+		{"look-alikes quoted", `["true","null","12","1.5","yes","~","",".inf",".NaN"]`,
+			"- \"true\"\n- \"null\"\n- \"12\"\n- \"1.5\"\n- \"yes\"\n- \"~\"\n- \"\"\n- \".inf\"\n- \".NaN\"\n"},
+		{"indicators quoted", `["a: b","- x","#c","x #c","[x]","{x}","&a","*a","|",">","end:"," pad"]`,
+			"- \"a: b\"\n- \"- x\"\n- \"#c\"\n- \"x #c\"\n- \"[x]\"\n- \"{x}\"\n- \"&a\"\n- \"*a\"\n- \"|\"\n- \">\"\n- \"end:\"\n- \" pad\"\n"},
+		{"plain punctuation", `["a,b","x#y","a:b","https://example.com/a?b=c","é"]`,
+			"- a,b\n- x#y\n- a:b\n- https://example.com/a?b=c\n- é\n"},
+		{"keys", `{"true":1,"1":2,"a b":3,"a: b":4,"":5,"-k":6,"<<":7}`,
+			"true: 1\n1: 2\na b: 3\n\"a: b\": 4\n\"\": 5\n\"-k\": 6\n\"<<\": 7\n"},
+		{"block clip", `{"text":"one\ntwo\n"}`, "text: |\n  one\n  two\n"},
+		{"block strip", `{"text":"one\n\ntwo"}`, "text: |-\n  one\n\n  two\n"},
+		{"block keep", `{"text":"one\n\n\n","next":1}`, "text: |+\n  one\n\n\nnext: 1\n"},
+		{"multi-line quoted", `{"a":" lead\nx","b":"x \ny","c":"\nx"}`,
+			"a: \" lead\\nx\"\nb: \"x \\ny\"\nc: \"\\nx\"\n"},
+		{"multi-line in sequence mapping", `[{"a":"x\ny\n"}]`, "- a: \"x\\ny\\n\"\n"},
+		{"control characters", `["tab\there","nul\u0000"]`, "- \"tab\\there\"\n- \"nul\\x00\"\n"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ToYAML([]byte(tc.input), ToYAMLOptions{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+			requireYAMLRoundTrip(t, tc.input, got)
+		})
+	}
+}
+
+func TestToYAMLIndent(t *testing.T) {
+	input := `{"a":{"b":[1,{"c":"x\ny","d":2}]}}`
+	got, err := ToYAML([]byte(input), ToYAMLOptions{Indent: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keys after the first in a "- " mapping always line up with the first.
+	want := "a:\n    b:\n        - 1\n        - c: \"x\\ny\"\n          d: 2\n"
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	requireYAMLRoundTrip(t, input, got)
+}
+
+func TestToYAMLRoundTripOptions(t *testing.T) {
+	// Quoting does not depend on the options the output is read back with.
+	input := `{"a":"yes","b":"off","c":"~","d":["On","NO"]}`
+	out, err := ToYAML([]byte(input), ToYAMLOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := FromYAMLWithOptions(out, YAMLOptions{BoolAliases: true, TildeNull: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != input {
+		t.Errorf("got %s, want %s", got, input)
+	}
+}
+
+func TestToYAMLRoundTripFiles(t *testing.T) {
+	files, err := filepath.Glob("testdata/toml/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, "samples/chromium/runtime_enabled_features.json5")
+	for _, f := range files {
+		t.Run(filepath.Base(f), func(t *testing.T) {
+			src, err := os.ReadFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(bytes.TrimSpace(src)) == 0 {
+				t.Skip("no expected output")
+			}
+			// The chromium sample repeats a key.
+			js, err := FromJSONVariantWithOptions(src, JSONVariantOptions{DuplicateKeys: DuplicateKeyLast})
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := ToYAML(js, ToYAMLOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			requireYAMLRoundTrip(t, string(js), out)
+		})
+	}
+}
+
+func TestToYAMLInvalid(t *testing.T) {
+	tests := []struct {
+		input string
+		line  int
+		col   int
+	}{
+		{`{"a": }`, 1, 7},
+		{"{\n  \"a\": 1,\n  \"b\" 2\n}", 3, 7},
+		{`{"a": 1`, 1, 8},
+		{`[1] [2]`, 1, 5},
+		{``, 1, 1},
+	}
+	for _, tc := range tests {
+		_, err := ToYAML([]byte(tc.input), ToYAMLOptions{})
+		pe := requireParseError(t, err)
+		if pe.Line != tc.line || pe.Column != tc.col {
+			t.Errorf("%q: got line %d, column %d, want line %d, column %d (%s)", tc.input, pe.Line, pe.Column, tc.line, tc.col, pe.Message)
+		}
+	}
+}
+
+// requireYAMLRoundTrip checks that FromYAML reads yaml back as the compact
+// form of input.
+func requireYAMLRoundTrip(t *testing.T, input string, yaml []byte) {
+	t.Helper()
+	var want bytes.Buffer
+	if err := json.Compact(&want, []byte(input)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := FromYAML(yaml)
+	if err != nil {
+		t.Fatalf("FromYAML:\n%s\nerror: %v", yaml, err)
+	}
+	if string(got) != want.String() {
+		t.Errorf("round trip:\n%s\ngot  %s\nwant %s", yaml, got, want.String())
+	}
+}
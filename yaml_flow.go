@@ -47,10 +47,22 @@ func flowDepth(s []byte) int {
 // gatherFlowSrc builds a complete flow expression starting with first.
 // If brackets are unbalanced it reads additional rawLines to support multi-line
 // flow values. Returns the assembled bytes and the last rawLine index consumed.
+//
+// A flow that fits on one line is returned as first itself. When a source
+// map is being recorded, the position of each joined line is noted in
+// p.flowSegs.
 func (p *parser) gatherFlowSrc(first []byte, rawLineIdx int) ([]byte, int) {
+	depth := flowDepth(first)
+	if depth <= 0 {
+		return first, rawLineIdx
+	}
 	var sb bytes.Buffer
 	sb.Write(first)
-	depth := flowDepth(first)
+	p.flowSegs = p.flowSegs[:0]
+	if p.sm != nil {
+		off, _ := p.sm.offset(first)
+		p.flowSegs = append(p.flowSegs, yamlFlowSeg{at: 0, src: off})
+	}
 	last := rawLineIdx
 	for depth > 0 {
 		rawLineIdx++
@@ -58,47 +70,66 @@ func (p *parser) gatherFlowSrc(first []byte, rawLineIdx int) ([]byte, int) {
 			break
 		}
 		line := bytes.TrimRight(p.rawLines[rawLineIdx], " \t\r")
+		if isYAMLMarker(line) {
+			break // the document ends inside the flow collection
+		}
 		line = stripInlineComment(bytes.TrimSpace(line))
 		if len(line) == 0 {
 			continue
 		}
 		sb.WriteByte(' ')
+		if p.sm != nil {
+			off, _ := p.sm.offset(line)
+			p.flowSegs = append(p.flowSegs, yamlFlowSeg{at: sb.Len(), src: off})
+		}
 		sb.Write(line)
 		depth += flowDepth(line)
 		last = rawLineIdx
 	}
+	if p.sm != nil {
+		p.flow = sb.Bytes()
+	}
 	return sb.Bytes(), last
 }
 
 // parseFlowExpr parses a complete YAML flow expression (mapping, sequence, or
 // scalar) from s and writes its JSON representation to buf.
-func parseFlowExpr(s []byte, buf *bytes.Buffer) error {
+func (p *parser) parseFlowExpr(s []byte, buf *bytes.Buffer) error {
 	s = bytes.TrimSpace(s)
 	switch {
 	case len(s) == 0:
 		buf.WriteString("null")
 		return nil
 	case s[0] == '{':
-		_, err := parseFlowMapping(s, 0, buf)
+		_, err := p.parseFlowMapping(s, 0, buf)
 		return err
 	case s[0] == '[':
-		_, err := parseFlowSequence(s, 0, buf)
+		_, err := p.parseFlowSequence(s, 0, buf)
 		return err
 	default:
-		return writeScalar(s, buf)
+		return p.writeScalar(s, buf)
 	}
 }
 
 // parseFlowMapping parses a flow mapping starting at s[pos] (which must be '{').
-func parseFlowMapping(s []byte, pos int, buf *bytes.Buffer) (int, error) {
+func (p *parser) parseFlowMapping(s []byte, pos int, buf *bytes.Buffer) (int, error) {
+	tag, err := p.collectionTag("map")
+	if err != nil {
+		return pos, err
+	}
+	start := buf.Len()
+	p.markOpen(buf, s[pos:])
 	pos++ // consume '{'
 	buf.WriteByte('{')
+	p.keys.open()
 	pos = flowSkipWS(s, pos)
 	first := true
 	for pos < len(s) {
 		if s[pos] == '}' {
+			p.closeMapping(buf)
+			p.markClose(buf, s[pos:])
 			buf.WriteByte('}')
-			return pos + 1, nil
+			return pos + 1, p.localTag(tag, buf, start)
 		}
 		if !first {
 			if s[pos] != ',' {
@@ -106,25 +137,42 @@ func parseFlowMapping(s []byte, pos int, buf *bytes.Buffer) (int, error) {
 			}
 			pos = flowSkipWS(s, pos+1)
 			if pos < len(s) && s[pos] == '}' {
+				p.closeMapping(buf)
+				p.markClose(buf, s[pos:])
 				buf.WriteByte('}')
-				return pos + 1, nil
+				return pos + 1, p.localTag(tag, buf, start)
 			}
-			buf.WriteByte(',')
 		}
 		first = false
+		writeMemberComma(buf)
 
+		if pos < len(s) && isYAMLKeyProperty(s[pos]) {
+			return pos, errYAMLKeyProperties
+		}
 		key, newPos, err := flowParseKey(s, pos)
 		if err != nil {
 			return newPos, err
 		}
-		writeJSONString(key, buf)
+		merge := isYAMLMergeKey(s[pos:]) && bytes.Equal(key, []byte("<<"))
+		start := buf.Len()
+		if !merge {
+			p.markFlowKey(buf, bytes.TrimSpace(s[pos:newPos]))
+			writeJSONString(key, buf)
+			if err := p.keys.key(buf, start, p.sm); err != nil {
+				return pos, err
+			}
+			buf.WriteByte(':')
+		}
 		pos = flowSkipWS(s, newPos)
 		if pos < len(s) && s[pos] == ':' {
 			pos = flowSkipWS(s, pos+1)
 		}
-		buf.WriteByte(':')
 
-		pos, err = flowParseItem(s, pos, buf)
+		valStart := buf.Len()
+		pos, err = p.flowParseItem(s, pos, buf)
+		if err == nil && merge {
+			err = p.merge(buf, start, valStart)
+		}
 		if err != nil {
 			return pos, err
 		}
@@ -134,15 +182,22 @@ func parseFlowMapping(s []byte, pos int, buf *bytes.Buffer) (int, error) {
 }
 
 // parseFlowSequence parses a flow sequence starting at s[pos] (which must be '[').
-func parseFlowSequence(s []byte, pos int, buf *bytes.Buffer) (int, error) {
+func (p *parser) parseFlowSequence(s []byte, pos int, buf *bytes.Buffer) (int, error) {
+	tag, err := p.collectionTag("seq")
+	if err != nil {
+		return pos, err
+	}
+	start := buf.Len()
+	p.markOpen(buf, s[pos:])
 	pos++ // consume '['
 	buf.WriteByte('[')
 	pos = flowSkipWS(s, pos)
 	first := true
 	for pos < len(s) {
 		if s[pos] == ']' {
+			p.markClose(buf, s[pos:])
 			buf.WriteByte(']')
-			return pos + 1, nil
+			return pos + 1, p.localTag(tag, buf, start)
 		}
 		if !first {
 			if s[pos] != ',' {
@@ -150,15 +205,16 @@ func parseFlowSequence(s []byte, pos int, buf *bytes.Buffer) (int, error) {
 			}
 			pos = flowSkipWS(s, pos+1)
 			if pos < len(s) && s[pos] == ']' {
+				p.markClose(buf, s[pos:])
 				buf.WriteByte(']')
-				return pos + 1, nil
+				return pos + 1, p.localTag(tag, buf, start)
 			}
 			buf.WriteByte(',')
 		}
 		first = false
 
 		var err error
-		pos, err = flowParseItem(s, pos, buf)
+		pos, err = p.flowParseItem(s, pos, buf)
 		if err != nil {
 			return pos, err
 		}
@@ -168,26 +224,45 @@ func parseFlowSequence(s []byte, pos int, buf *bytes.Buffer) (int, error) {
 }
 
 // flowParseItem parses a single flow value (mapping, sequence, or scalar).
-func flowParseItem(s []byte, pos int, buf *bytes.Buffer) (int, error) {
+func (p *parser) flowParseItem(s []byte, pos int, buf *bytes.Buffer) (int, error) {
 	pos = flowSkipWS(s, pos)
 	if pos >= len(s) {
 		buf.WriteString("null")
 		return pos, nil
 	}
 	switch s[pos] {
+	case '&', '!':
+		props, rest, err := cutYAMLProps(s[pos:])
+		if err != nil {
+			return pos, err
+		}
+		p.tag = props.tag
+		start := buf.Len()
+		if pos, err = p.flowParseItem(s, len(s)-len(rest), buf); err != nil {
+			return pos, err
+		}
+		return pos, p.endProps(props, buf, start)
 	case '{':
-		return parseFlowMapping(s, pos, buf)
+		return p.parseFlowMapping(s, pos, buf)
 	case '[':
-		return parseFlowSequence(s, pos, buf)
+		return p.parseFlowSequence(s, pos, buf)
 	case '"':
 		str, newPos, err := flowParseDoubleQuoted(s, pos)
 		if err != nil {
 			return newPos, err
 		}
+		if p.tag != nil {
+			return newPos, p.writeScalar(s[pos:newPos], buf)
+		}
+		p.markValue(buf, s[pos:newPos])
 		writeJSONString(str, buf)
 		return newPos, nil
 	case '\'':
 		str, newPos := flowParseSingleQuoted(s, pos)
+		if p.tag != nil {
+			return newPos, p.writeScalar(s[pos:newPos], buf)
+		}
+		p.markValue(buf, s[pos:newPos])
 		writeJSONString(str, buf)
 		return newPos, nil
 	default:
@@ -195,7 +270,7 @@ func flowParseItem(s []byte, pos int, buf *bytes.Buffer) (int, error) {
 		for pos < len(s) && s[pos] != ',' && s[pos] != '}' && s[pos] != ']' {
 			pos++
 		}
-		return pos, writeScalar(bytes.TrimSpace(s[start:pos]), buf)
+		return pos, p.writeScalar(bytes.TrimSpace(s[start:pos]), buf)
 	}
 }
 
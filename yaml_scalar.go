@@ -10,27 +10,112 @@ import (
 // YAML parser options
 // --------------------------------------------------------------------------
 
-// yamlTabWidth is the number of spaces a tab character counts as when
-// measuring indentation. Set to <= 0 to forbid tabs in YAML input entirely.
-const yamlTabWidth = 2
+// YAMLOptions controls how FromYAMLWithOptions interprets its input.
+// The zero value matches the behavior of FromYAML.
+type YAMLOptions struct {
+	// TabWidth is the number of spaces a tab character counts as when
+	// measuring indentation. Zero selects the default of 2; a negative
+	// value forbids tabs in indentation entirely.
+	TabWidth int
 
-// yamlBoolAliases controls whether YAML 1.1 boolean aliases are recognised.
-// When true, yes/no/on/off (and their case variants) map to true/false.
-// When false, only true/false (and their case variants) are treated as booleans.
-const yamlBoolAliases = false
+	// BoolAliases enables the YAML 1.1 boolean aliases: yes/no/on/off
+	// (and their case variants) map to true/false. When false, only
+	// true/false (and their case variants) are treated as booleans.
+	BoolAliases bool
 
-// yamlTildeNull controls whether bare ~ is treated as null.
-const yamlTildeNull = false
+	// TildeNull treats a bare ~ as null instead of the string "~".
+	TildeNull bool
+
+	// DuplicateKeys selects what happens when a mapping repeats a key. The
+	// zero value, DuplicateKeyError, reports the repeat as an error at its
+	// position; DuplicateKeyFirst and DuplicateKeyLast keep one value.
+	DuplicateKeys DuplicateKeyPolicy
+
+	// NonFinite selects what .nan, .inf, and -.inf convert to, since JSON
+	// has no NaN or infinity. The zero value, NonFiniteDefault, writes them
+	// as the strings ".nan", ".inf", and "-.inf".
+	NonFinite NonFinitePolicy
+
+	// NonFiniteSentinel is the JSON text written for a NaN or infinity when
+	// NonFinite is NonFiniteSentinel, such as -1 or "n/a". Text that is not
+	// valid JSON is reported as an error where it would be written.
+	NonFiniteSentinel string
+
+	// MaxErrors, when greater than one, makes conversion continue past an
+	// error so that one pass reports up to MaxErrors problems. After an
+	// error in a mapping entry or sequence item, parsing resumes at the
+	// next line at the same indentation. The errors are returned as
+	// ParseErrors, and the output is discarded. Zero or one stops at the
+	// first error, which is returned as a *ParseError.
+	MaxErrors int
+
+	// MaxAliasBytes limits the total size of the JSON that aliases copy
+	// from their anchors, merge keys included, so that a small document
+	// cannot expand into an enormous one, as in the "billion laughs" attack.
+	// An alias that would exceed it is an error. Zero selects the default,
+	// 1 MiB or 16 times the size of the input, whichever is larger; a
+	// negative value makes every alias an error.
+	MaxAliasBytes int
+
+	// TagHandler converts values with a local tag, such as CloudFormation's
+	// !Ref and !GetAtt. It is called with the tag, "!Ref", and the JSON the
+	// value converts to without it, and returns the JSON to write in its
+	// place. Without a TagHandler a local tag is an error. Tags of the core
+	// schema, such as !!str and !!int, are always applied; any other tag is
+	// an error.
+	TagHandler func(tag string, value []byte) ([]byte, error)
+
+	// UnsafeIntegers selects what an integer beyond 2^53-1 in magnitude
+	// converts to, since JavaScript and other readers that hold numbers in a
+	// double would silently round it. The zero value, UnsafeIntegerKeep,
+	// writes it as a number; UnsafeIntegerString writes it as a string and
+	// UnsafeIntegerError reports it as an error.
+	UnsafeIntegers UnsafeIntegerPolicy
+
+	// Indent, when non-empty, formats the output with each object member
+	// and array element on its own line, indented by one copy of Indent per
+	// level of nesting, as json.Indent does. It has no effect on Unmarshal
+	// functions.
+	Indent string
+
+	// Canonical writes the output in the RFC 8785 JSON Canonicalization
+	// Scheme: compact, with object keys sorted, numbers formatted as
+	// ECMAScript doubles, and minimal string escaping. Equivalent documents
+	// then give identical bytes whatever their input format, for hashing or
+	// comparison. Integers beyond 2^53 lose precision, and a number too
+	// large for a double is an error. Canonical takes precedence over
+	// Indent and has no effect on Unmarshal functions.
+	Canonical bool
+}
+
+// yamlDefaultTabWidth is the tab width used when YAMLOptions.TabWidth is zero.
+const yamlDefaultTabWidth = 2
+
+// tabWidth returns the effective tab width, or a value <= 0 if tabs are
+// forbidden.
+func (o *YAMLOptions) tabWidth() int {
+	if o.TabWidth == 0 {
+		return yamlDefaultTabWidth
+	}
+	return o.TabWidth
+}
 
 // writeScalar converts a YAML scalar to its JSON representation.
-func writeScalar(s []byte, buf *bytes.Buffer) error {
+func (p *parser) writeScalar(s []byte, buf *bytes.Buffer) error {
 	s = bytes.TrimSpace(s)
+	if p.tag != nil {
+		return p.writeTaggedScalar(s, buf)
+	}
+	p.markValue(buf, s)
+	if len(s) > 0 && s[0] == '*' {
+		return p.writeAlias(s, buf)
+	}
 	switch string(s) {
 	case "", "null", "Null", "NULL":
 		buf.WriteString("null")
 		return nil
 	}
-	if yamlTildeNull && string(s) == "~" {
+	if p.opts.TildeNull && string(s) == "~" {
 		buf.WriteString("null")
 		return nil
 	}
@@ -42,7 +127,7 @@ func writeScalar(s []byte, buf *bytes.Buffer) error {
 		buf.WriteString("false")
 		return nil
 	}
-	if yamlBoolAliases {
+	if p.opts.BoolAliases {
 		switch string(s) {
 		case "yes", "Yes", "YES", "on", "On", "ON":
 			buf.WriteString("true")
@@ -53,6 +138,10 @@ func writeScalar(s []byte, buf *bytes.Buffer) error {
 		}
 	}
 
+	if nan, neg, ok := yamlNonFinite(s); ok {
+		return p.opts.nonFinite().write(buf, s, nan, neg)
+	}
+
 	if len(s) > 0 && s[0] == '"' {
 		// Decode using Go string literal rules, then re-encode as JSON.
 		// This handles \n \t \uNNNN \xNN etc.; YAML-specific escapes like
@@ -71,8 +160,9 @@ func writeScalar(s []byte, buf *bytes.Buffer) error {
 	}
 
 	if isYAMLNumber(s) {
+		start := buf.Len()
 		writeNormalizedNumber(buf, s)
-		return nil
+		return p.opts.ints().rewriteTail(buf, start)
 	}
 
 	writeJSONString(s, buf)
@@ -192,6 +282,26 @@ func splitMapKey(content []byte) (key, value []byte, err error) {
 	return content, nil, nil
 }
 
+// mapKeyLen returns the length of the key token at the start of content, a
+// line for which isMapKey is true: the quoted string including its quotes,
+// or the bare text before the ':' separator.
+func mapKeyLen(content []byte) int {
+	switch content[0] {
+	case '"':
+		if end := doubleQuotedEnd(content); end > 0 {
+			return end
+		}
+	case '\'':
+		if _, rest := parseSingleQuotedRaw(content); rest != nil {
+			return len(content) - len(rest)
+		}
+	}
+	if i := bytes.Index(content, []byte(": ")); i >= 0 {
+		return i
+	}
+	return len(bytes.TrimSuffix(content, []byte(":")))
+}
+
 // parseSingleQuotedRaw returns (unescaped bytes, remainder after closing quote).
 func parseSingleQuotedRaw(s []byte) ([]byte, []byte) {
 	if len(s) < 2 || s[0] != '\'' {
@@ -242,18 +352,18 @@ func leadingSpaces(s []byte) int {
 	return n
 }
 
-// yamlLeadingIndent counts the indentation of s using yamlTabWidth for tabs.
-// Returns an error if yamlTabWidth < 0 and s contains a leading tab.
-func yamlLeadingIndent(s []byte) (int, error) {
+// yamlLeadingIndent counts the indentation of s using tabWidth for tabs.
+// Returns an error if tabWidth <= 0 and s contains a leading tab.
+func yamlLeadingIndent(s []byte, tabWidth int) (int, error) {
 	n := 0
 	for _, c := range s {
 		if c == ' ' {
 			n++
 		} else if c == '\t' {
-			if yamlTabWidth <= 0 {
+			if tabWidth <= 0 {
 				return 0, fmt.Errorf("tab character not allowed in YAML indentation")
 			}
-			n += yamlTabWidth
+			n += tabWidth
 		} else {
 			break
 		}
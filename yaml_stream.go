@@ -0,0 +1,78 @@
+package tojson
+
+import (
+	"bytes"
+	"iter"
+)
+
+// FromYAMLStream converts a stream of YAML documents, separated by --- lines
+// as in a Kubernetes manifest, to one JSON value per document. A line of ...
+// ends a document without starting the next. Each document is converted on
+// its own, so anchors and duplicate keys do not carry from one to the next,
+// but the lines and columns of errors count from the start of src. Every ---
+// starts a document, and one with nothing in it, including at the end of
+// src, is null. A %YAML directive line before a --- is skipped; a %TAG
+// directive is an error.
+func FromYAMLStream(src []byte) ([][]byte, error) {
+	return FromYAMLStreamWithOptions(src, YAMLOptions{})
+}
+
+// FromYAMLStreamWithOptions is like FromYAMLStream but converts each document
+// according to opts.
+func FromYAMLStreamWithOptions(src []byte, opts YAMLOptions) ([][]byte, error) {
+	var docs [][]byte
+	for doc, err := range YAMLDocuments(src, opts) {
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// YAMLDocuments returns an iterator over the JSON of each document in the
+// YAML stream src, converted as FromYAMLStreamWithOptions does. The iteration
+// stops after the first error, which is yielded with a nil document. The
+// yielded JSON is not reused by later iterations.
+func YAMLDocuments(src []byte, opts YAMLOptions) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		p := parser{opts: opts}
+		p.errs = errorList{max: opts.MaxErrors}
+		if err := p.init(src); err != nil {
+			yield(nil, p.errs.result(err))
+			return
+		}
+		for i := range p.docs {
+			var buf bytes.Buffer
+			if err := p.errs.result(p.document(i, &buf)); err != nil {
+				yield(nil, err)
+				return
+			}
+			doc, err := formatJSON(src, buf.Bytes(), opts.output())
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(doc, nil) {
+				return
+			}
+		}
+	}
+}
+
+// cutYAMLMarker reports whether the line s is the document marker "---" or
+// "...", alone or followed by a space, and returns what follows it.
+func cutYAMLMarker(s []byte, marker string) (rest []byte, ok bool) {
+	rest, ok = bytes.CutPrefix(s, []byte(marker))
+	if !ok || len(rest) > 0 && rest[0] != ' ' && rest[0] != '\t' {
+		return nil, false
+	}
+	return bytes.TrimLeft(rest, " \t"), true
+}
+
+// isYAMLMarker reports whether the line s is a --- or ... document marker.
+func isYAMLMarker(s []byte) bool {
+	_, start := cutYAMLMarker(s, "---")
+	_, end := cutYAMLMarker(s, "...")
+	return start || end
+}
@@ -0,0 +1,135 @@
+package tojson
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFromYAMLStream(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"comments only", "# nothing\n", nil},
+		{"single", "a: 1\n", []string{`{"a":1}`}},
+		{"leading marker", "---\na: 1\n", []string{`{"a":1}`}},
+		{"implicit first", "a: 1\n---\nb: 2\n", []string{`{"a":1}`, `{"b":2}`}},
+		{"manifest", "# header\n---\nkind: A\n---\nkind: B\n", []string{`{"kind":"A"}`, `{"kind":"B"}`}},
+		{"empty documents", "---\n---\na: 1\n---\nb: 2\n", []string{`null`, `{"a":1}`, `{"b":2}`}},
+		{"trailing marker", "---\na: 1\n---\n", []string{`{"a":1}`, `null`}},
+		{"trailing markers", "a: 1\n---\n# end\n---\n...\n", []string{`{"a":1}`, `null`, `null`}},
+		{"marker only", "---\n", []string{`null`}},
+		{"markers only", "---\n---\n", []string{`null`, `null`}},
+		{"empty then content", "---\n---\nb: 2", []string{`null`, `{"b":2}`}},
+		{"directive", "%YAML 1.2\n---\na: 1\n...\n%YAML 1.2\n---\nb: 2\n", []string{`{"a":1}`, `{"b":2}`}},
+		{"alias bytes per document", "a: &x [1]\nb: *x\n---\nc: &y [2]\nd: *y\n", []string{`{"a":[1],"b":[1]}`, `{"c":[2],"d":[2]}`}},
+		{"document end", "a: 1\n...\nb: 2\n...\n", []string{`{"a":1}`, `{"b":2}`}},
+		{"document end then marker", "a: 1\n...\n---\nb: 2\n", []string{`{"a":1}`, `{"b":2}`}},
+		{"content on marker line", "--- {a: 1}\n--- text\n--- # comment\nc: 3\n", []string{`{"a":1}`, `"text"`, `{"c":3}`}},
+		{"block scalar on marker line", "--- |\n  one\n  two\n--- >\n  folded\n  text\n", []string{`"one\ntwo\n"`, `"folded text\n"`}},
+		{"marker in block scalar", "text: |\n  ---\n  x\n---\ny: 1\n", []string{`{"text":"---\nx\n"}`, `{"y":1}`}},
+		{"sequences", "- 1\n---\n- 2\n", []string{`[1]`, `[2]`}},
+		{"not a marker", "---x: 1\n", []string{`{"---x":1}`}},
+		{"same keys", "a: 1\n---\na: 2\n", []string{`{"a":1}`, `{"a":2}`}},
+		{"anchor per document", "a: &x 1\nb: *x\n---\nx: &x 2\ny: *x\n", []string{`{"a":1,"b":1}`, `{"x":2,"y":2}`}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			docs, err := FromYAMLStream([]byte(tc.in))
+			if err != nil {
+				t.Fatalf("error: %v", err)
+			}
+			var got []string
+			for _, d := range docs {
+				got = append(got, string(d))
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") || len(got) != len(tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFromYAMLStreamErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		line, col int
+		msg       string
+	}{
+		{"second document", "a: 1\n---\nb: \"x\n", 3, 4, "invalid double-quoted string"},
+		{"after end", "a: 1\n...\nb: [\n", 3, 4, "unterminated flow sequence"},
+		{"anchor from previous document", "a: &x 1\n---\nb: *x\n", 3, 4, "alias *x has no anchor"},
+		{"flow collection cut by marker", "a: 1\n---\nb: [\n---\n]\n", 3, 4, "unterminated flow sequence"},
+		{"tag directive", "%TAG ! tag:example.com,2000:\n---\na: 1\n", 1, 1, "%TAG directives are not supported"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			docs, err := FromYAMLStream([]byte(tc.in))
+			pe := requireParseError(t, err)
+			if docs != nil || pe.Line != tc.line || pe.Column != tc.col || !strings.Contains(pe.Message, tc.msg) {
+				t.Errorf("got %q, %d:%d %q, want %d:%d %q", docs, pe.Line, pe.Column, pe.Message, tc.line, tc.col, tc.msg)
+			}
+		})
+	}
+}
+
+func TestFromYAMLMultipleDocuments(t *testing.T) {
+	for _, in := range []string{"a: 1\n---\nb: 2\n", "a: 1\n...\nb: 2\n", "---\na: 1\n---\n]\n", "---\n---\nb: 2", "a: 1\n---\n---\nb: 2\n"} {
+		_, err := FromYAML([]byte(in))
+		pe := requireParseError(t, err)
+		if !strings.Contains(pe.Message, "a second document starts here") {
+			t.Errorf("FromYAML(%q): got %v", in, pe)
+		}
+	}
+	// A single document may still be marked at either end, and be followed
+	// by empty documents.
+	for _, in := range []string{"---\na: 1\n...\n", "---\na: 1\n---\n", "%YAML 1.2\n---\na: 1\n", "a: 1\n---\n---\n...\n"} {
+		got, err := FromYAML([]byte(in))
+		if err != nil || string(got) != `{"a":1}` {
+			t.Errorf("FromYAML(%q): got %s, %v", in, got, err)
+		}
+	}
+	for _, in := range []string{"---\n", "---\n---\n"} {
+		got, err := FromYAML([]byte(in))
+		if err != nil || string(got) != `null` {
+			t.Errorf("FromYAML(%q): got %s, %v", in, got, err)
+		}
+	}
+}
+
+func TestYAMLDocuments(t *testing.T) {
+	src := []byte("a: 1\n---\nb: 2\n---\nc: [\n")
+	var got []string
+	for doc, err := range YAMLDocuments(src, YAMLOptions{Indent: " "}) {
+		if err != nil {
+			got = append(got, err.Error())
+			continue
+		}
+		got = append(got, string(doc))
+	}
+	want := []string{"{\n \"a\": 1\n}", "{\n \"b\": 2\n}", "line 5, column 4: unterminated flow sequence"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Stopping early is allowed.
+	n := 0
+	for range YAMLDocuments(src, YAMLOptions{}) {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("got %d iterations, want 1", n)
+	}
+}
+
+func TestFromYAMLStreamMaxErrors(t *testing.T) {
+	_, err := FromYAMLStreamWithOptions([]byte("a: 1\n---\nb: \"x\nc: [\n"), YAMLOptions{MaxErrors: 10})
+	errs, ok := err.(ParseErrors)
+	if !ok || len(errs) != 2 || errs[0].Line != 3 || errs[1].Line != 4 {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,285 @@
+package tojson
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Tags.
+//
+// A tag travels from where it is cut off a value to the code that writes
+// the value in p.tag. A scalar takes it in writeScalar or writeBlockScalar
+// and is written as the tag says; a mapping or sequence takes it as it opens
+// so that none of its members sees it. A local tag, such as !Ref, is left to
+// YAMLOptions.TagHandler, which gets the JSON written for the value without
+// it.
+
+// yamlProps are the properties that may precede a value: an anchor name and
+// a tag, either of which may be empty.
+type yamlProps struct {
+	anchor, tag []byte
+}
+
+// cutYAMLProps splits the anchor &name and the tag, such as !!str or !Ref,
+// off the front of the value s, in either order, and returns them and the
+// rest of the value.
+func cutYAMLProps(s []byte) (props yamlProps, rest []byte, err error) {
+	rest = s
+	for len(rest) > 0 {
+		var n int
+		switch {
+		case rest[0] == '&' && props.anchor == nil:
+			n = 1 + yamlAnchorNameLen(rest[1:])
+			if n == 1 {
+				return props, rest, errors.New("expected an anchor name after '&'")
+			}
+			props.anchor = rest[1:n]
+		case rest[0] == '!' && props.tag == nil:
+			if n = yamlTagLen(rest); n < 0 {
+				return props, rest, errors.New("expected '>' to end the verbatim tag")
+			}
+			props.tag = rest[:n]
+		default:
+			return props, rest, nil
+		}
+		rest = bytes.TrimLeft(rest[n:], " \t")
+	}
+	return props, rest, nil
+}
+
+// yamlTagLen returns the length of the tag at the start of s, which runs to
+// a space or a flow indicator, or for a verbatim tag !<...> to its '>'. It
+// returns -1 for a verbatim tag without its '>'.
+func yamlTagLen(s []byte) int {
+	if len(s) > 1 && s[1] == '<' {
+		if i := bytes.IndexByte(s, '>'); i >= 0 {
+			return i + 1
+		}
+		return -1
+	}
+	return 1 + yamlAnchorNameLen(s[1:])
+}
+
+// yamlCoreTag returns the name of the YAML core schema tag, such as "str"
+// for !!str or !<tag:yaml.org,2002:str>, or "" for any other tag. The
+// non-specific tag ! is "str".
+func yamlCoreTag(tag []byte) string {
+	name := tag
+	switch {
+	case string(tag) == "!":
+		return "str"
+	case bytes.HasPrefix(tag, []byte("!!")):
+		name = tag[2:]
+	case bytes.HasPrefix(tag, []byte("!<tag:yaml.org,2002:")):
+		name = bytes.TrimSuffix(tag[len("!<tag:yaml.org,2002:"):], []byte(">"))
+	}
+	switch string(name) {
+	case "str", "int", "float", "bool", "null", "seq", "map", "binary":
+		return string(name)
+	}
+	return ""
+}
+
+// isYAMLLocalTag reports whether tag is a local tag, such as !Ref, with a
+// single ! and a name. A tag with a named handle, such as !e!tag, is not.
+func isYAMLLocalTag(tag []byte) bool {
+	return len(tag) > 1 && tag[1] != '<' && bytes.IndexByte(tag[1:], '!') < 0
+}
+
+// endProps finishes the value written to buf from offset start with props.
+// A tag still in p.tag belongs to a value left empty, which is written again
+// as an empty scalar with the tag. The anchor then records the value.
+func (p *parser) endProps(props yamlProps, buf *bytes.Buffer, start int) error {
+	if p.tag != nil {
+		buf.Truncate(start)
+		p.sm.truncate(start)
+		if err := p.writeScalar(nil, buf); err != nil {
+			return err
+		}
+	}
+	p.setAnchor(props.anchor, buf, start)
+	return nil
+}
+
+// writeTaggedScalar is writeScalar for the scalar s with the tag in p.tag.
+func (p *parser) writeTaggedScalar(s []byte, buf *bytes.Buffer) error {
+	tag := p.tag
+	p.tag = nil
+	if len(s) > 0 && s[0] == '*' {
+		return fmt.Errorf("alias %s cannot have a tag", s)
+	}
+	if isYAMLLocalTag(tag) {
+		start := buf.Len()
+		if err := p.writeScalar(s, buf); err != nil {
+			return err
+		}
+		return p.localTag(tag, buf, start)
+	}
+	p.markValue(buf, s)
+	str := s
+	switch {
+	case len(s) > 0 && s[0] == '"':
+		u, err := strconv.Unquote(string(s))
+		if err != nil {
+			return fmt.Errorf("invalid double-quoted string: %w", err)
+		}
+		str = []byte(u)
+	case len(s) > 0 && s[0] == '\'':
+		str = parseSingleQuoted(s)
+	}
+	return p.writeCoreScalar(tag, str, buf)
+}
+
+// writeBlockScalar writes the content s of a literal or folded block scalar,
+// applying the tag in p.tag if there is one.
+func (p *parser) writeBlockScalar(s []byte, buf *bytes.Buffer) error {
+	tag := p.tag
+	p.tag = nil
+	switch {
+	case tag == nil:
+		writeJSONString(s, buf)
+		return nil
+	case isYAMLLocalTag(tag):
+		start := buf.Len()
+		writeJSONString(s, buf)
+		return p.localTag(tag, buf, start)
+	}
+	return p.writeCoreScalar(tag, s, buf)
+}
+
+// writeCoreScalar writes the scalar whose text, unquoted, is str as the
+// core schema tag says: !!str 0012 is the string "0012" and !!int "42" the
+// number 42.
+func (p *parser) writeCoreScalar(tag, str []byte, buf *bytes.Buffer) error {
+	switch yamlCoreTag(tag) {
+	case "str":
+		writeJSONString(str, buf)
+		return nil
+	case "null":
+		switch string(str) {
+		case "", "~", "null", "Null", "NULL":
+			buf.WriteString("null")
+			return nil
+		}
+	case "bool":
+		switch string(str) {
+		case "true", "True", "TRUE":
+			buf.WriteString("true")
+			return nil
+		case "false", "False", "FALSE":
+			buf.WriteString("false")
+			return nil
+		}
+	case "int":
+		if b, ok := appendYAMLInt(buf.AvailableBuffer(), str); ok {
+			start := buf.Len()
+			buf.Write(b)
+			return p.opts.ints().rewriteTail(buf, start)
+		}
+	case "float":
+		if nan, neg, ok := yamlNonFinite(str); ok {
+			// The tag makes .inf a number, so the default does not keep
+			// it as text, as it does an untagged .inf.
+			nf := p.opts.nonFinite()
+			if p.opts.NonFinite == NonFiniteDefault {
+				nf.policy = NonFiniteError
+			}
+			return nf.write(buf, str, nan, neg)
+		}
+		if isYAMLNumber(str) {
+			writeNormalizedNumber(buf, str)
+			return nil
+		}
+	case "binary":
+		b := bytes.Join(bytes.Fields(str), nil)
+		if _, err := base64.StdEncoding.DecodeString(string(b)); err == nil {
+			writeJSONString(b, buf)
+			return nil
+		}
+	case "seq", "map":
+		return fmt.Errorf("tag %s cannot apply to a scalar", tag)
+	default:
+		return p.atTag(tag, fmt.Errorf("unsupported tag %s", tag))
+	}
+	return fmt.Errorf("%q is not a valid %s", str, tag)
+}
+
+// atTag positions err, an error about tag itself, at tag. A tag inside a
+// flow collection that spans several lines is read from a copy, so err is
+// then returned as it is, for the caller to position at the value.
+func (p *parser) atTag(tag []byte, err error) error {
+	for i, raw := range p.rawLines {
+		if off, ok := sliceOffset(raw, tag); ok {
+			return atLineCol(i, off, err)
+		}
+	}
+	return err
+}
+
+// appendYAMLInt appends the decimal form of s, an integer of the YAML core
+// schema in decimal, 0o octal, or 0x hexadecimal, and reports whether s is
+// one. Leading zeros are allowed, as in !!int 0012.
+func appendYAMLInt(dst, s []byte) ([]byte, bool) {
+	digits, neg := s, false
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+		digits, neg = digits[1:], digits[0] == '-'
+	}
+	base := 10
+	if len(digits) > 2 && digits[0] == '0' {
+		switch digits[1] {
+		case 'o':
+			digits, base = digits[2:], 8
+		case 'x':
+			digits, base = digits[2:], 16
+		}
+	}
+	b, err := appendRadixInt(dst, digits, base, neg)
+	return b, err == nil
+}
+
+// collectionTag takes the tag in p.tag as a mapping or sequence, kind "map"
+// or "seq", opens. It returns a local tag for localTag to apply once the
+// collection is written, and reports a core tag of another kind as an
+// error.
+func (p *parser) collectionTag(kind string) ([]byte, error) {
+	tag := p.tag
+	p.tag = nil
+	switch core := yamlCoreTag(tag); {
+	case tag == nil || core == kind || string(tag) == "!":
+		return nil, nil
+	case isYAMLLocalTag(tag):
+		return tag, nil
+	case core == "":
+		return nil, p.atTag(tag, fmt.Errorf("unsupported tag %s", tag))
+	}
+	if kind == "map" {
+		return nil, fmt.Errorf("tag %s cannot apply to a mapping", tag)
+	}
+	return nil, fmt.Errorf("tag %s cannot apply to a sequence", tag)
+}
+
+// localTag replaces the JSON written to buf from offset start with what
+// YAMLOptions.TagHandler returns for it and the local tag. A nil tag leaves
+// the JSON as it is.
+func (p *parser) localTag(tag []byte, buf *bytes.Buffer, start int) error {
+	if tag == nil {
+		return nil
+	}
+	if p.opts.TagHandler == nil {
+		return p.atTag(tag, fmt.Errorf("unsupported tag %s; set YAMLOptions.TagHandler to convert local tags", tag))
+	}
+	out, err := p.opts.TagHandler(string(tag), bytes.Clone(buf.Bytes()[start:]))
+	if err != nil {
+		return fmt.Errorf("tag %s: %w", tag, err)
+	}
+	buf.Truncate(start)
+	p.sm.truncate(start + 1)
+	if err := json.Compact(buf, out); err != nil {
+		return fmt.Errorf("tag %s: TagHandler returned invalid JSON %q", tag, out)
+	}
+	return nil
+}
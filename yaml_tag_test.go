@@ -0,0 +1,165 @@
+package tojson
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestYAMLCoreTags(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"str", "a: !!str 0012\nb: !!str true\nc: !!str null", `{"a":"0012","b":"true","c":"null"}`},
+		{"str block scalar", "a: !!str |\n  x\n", `{"a":"x\n"}`},
+		{"non-specific", "a: ! 12", `{"a":"12"}`},
+		{"verbatim", "a: !<tag:yaml.org,2002:str> 12", `{"a":"12"}`},
+		{"int", "a: !!int \"42\"\nb: !!int '-7'", `{"a":42,"b":-7}`},
+		{"int radix", "a: !!int 0x1F\nb: !!int 0o17\nc: !!int 0012", `{"a":31,"b":15,"c":12}`},
+		{"float", "a: !!float 1\nb: !!float '2.5e3'", `{"a":1,"b":2.5e3}`},
+		{"bool", "a: !!bool 'true'\nb: !!bool False", `{"a":true,"b":false}`},
+		{"null", "a: !!null ''\nb: !!null ~", `{"a":null,"b":null}`},
+		{"binary", "a: !!binary aGVsbG8=", `{"a":"aGVsbG8="}`},
+		{"binary block scalar", "a: !!binary |\n  aGVs\n  bG8=\n", `{"a":"aGVsbG8="}`},
+		{"empty str", "a: !!str\nb: !!str # comment", `{"a":"","b":""}`},
+		{"empty null", "a: !!null\nb: 1", `{"a":null,"b":1}`},
+		{"seq", "a: !!seq\n- 1\nb: !!seq [2]", `{"a":[1],"b":[2]}`},
+		{"map", "a: !!map\n  b: 1\nc: !!map {d: 2}", `{"a":{"b":1},"c":{"d":2}}`},
+		{"sequence items", "- !!str 1\n- !!int '2'\n- !!map\n  k: v", `["1",2,{"k":"v"}]`},
+		{"empty sequence items", "- !!str\n- x\n- !!null\n-\n  !!str\n- y", `["","x",null,"","y"]`},
+		{"inline map", "- a: !!str 1\n  b: !!int '2'", `[{"a":"1","b":2}]`},
+		{"flow", "[!!str 1, !!int \"2\", !!bool 'false', !!str]", `["1",2,false,""]`},
+		{"flow mapping", "{a: !!str 1, b: !!null}", `{"a":"1","b":null}`},
+		{"root", "!!str 1", `"1"`},
+		{"root block", "!!map\na: 1", `{"a":1}`},
+		{"anchor then tag", "a: &x !!str 1\nb: *x", `{"a":"1","b":"1"}`},
+		{"tag then anchor", "a: !!str &x 1\nb: [*x]", `{"a":"1","b":["1"]}`},
+		{"exclamation in plain scalar", "a: b!c\nd: 'e'", `{"a":"b!c","d":"e"}`},
+		{"quoted", "a: '!!str'\nb: \"!Ref x\"", `{"a":"!!str","b":"!Ref x"}`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := FromYAML([]byte(tc.in))
+			if err != nil || string(got) != tc.want {
+				t.Errorf("got %s, %v, want %s", got, err, tc.want)
+			}
+		})
+	}
+}
+
+// cfnTags converts CloudFormation's short form tags to their JSON form.
+func cfnTags(tag string, value []byte) ([]byte, error) {
+	switch tag {
+	case "!Ref":
+		return fmt.Appendf(nil, `{"Ref": %s}`, value), nil
+	case "!GetAtt", "!Sub", "!Join":
+		return fmt.Appendf(nil, `{"Fn::%s": %s}`, tag[1:], value), nil
+	}
+	return nil, errors.New("unknown tag")
+}
+
+func TestYAMLTagHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"scalar", "a: !Ref Bucket", `{"a":{"Ref":"Bucket"}}`},
+		{"flow sequence", "a: !GetAtt [Bucket, Arn]", `{"a":{"Fn::GetAtt":["Bucket","Arn"]}}`},
+		{"block sequence", "a: !Join\n  - ''\n  - [x, !Ref y]", `{"a":{"Fn::Join":["",["x",{"Ref":"y"}]]}}`},
+		{"block mapping", "a: !Sub\n  b: 1", `{"a":{"Fn::Sub":{"b":1}}}`},
+		{"block scalar", "a: !Sub |\n  ${x}\n", `{"a":{"Fn::Sub":"${x}\n"}}`},
+		{"empty", "a: !Ref\nb: 1", `{"a":{"Ref":null},"b":1}`},
+		{"sequence item", "- !Ref x\n- !!str 1", `[{"Ref":"x"},"1"]`},
+		{"empty sequence item", "- !Ref\n- x", `[{"Ref":null},"x"]`},
+		{"flow", "[!Ref 'x', !Ref y]", `[{"Ref":"x"},{"Ref":"y"}]`},
+		{"anchored", "a: &x !Ref y\nb: *x", `{"a":{"Ref":"y"},"b":{"Ref":"y"}}`},
+		{"root", "!Ref x", `{"Ref":"x"}`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := FromYAMLWithOptions([]byte(tc.in), YAMLOptions{TagHandler: cfnTags})
+			if err != nil || string(got) != tc.want {
+				t.Errorf("got %s, %v, want %s", got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestYAMLTagErrors(t *testing.T) {
+	invalidJSON := func(string, []byte) ([]byte, error) { return []byte("{"), nil }
+	tests := []struct {
+		name      string
+		in        string
+		opts      YAMLOptions
+		line, col int
+		msg       string
+	}{
+		{"invalid int", "a: !!int x", YAMLOptions{}, 1, 10, `"x" is not a valid !!int`},
+		{"invalid float", "a: !!float 1.2.3", YAMLOptions{}, 1, 12, `"1.2.3" is not a valid !!float`},
+		{"invalid bool", "a: !!bool yes", YAMLOptions{}, 1, 11, `"yes" is not a valid !!bool`},
+		{"invalid null", "a: !!null 0", YAMLOptions{}, 1, 11, `"0" is not a valid !!null`},
+		{"invalid binary", "a: !!binary '!'", YAMLOptions{}, 1, 13, `"!" is not a valid !!binary`},
+		{"map on scalar", "a: !!map 1", YAMLOptions{}, 1, 10, "tag !!map cannot apply to a scalar"},
+		{"map on sequence", "a: !!map [1]", YAMLOptions{}, 1, 10, "tag !!map cannot apply to a sequence"},
+		{"seq on mapping", "a: !!seq\n  b: 1", YAMLOptions{}, 2, 3, "tag !!seq cannot apply to a mapping"},
+		{"unsupported", "a: !!set {x}", YAMLOptions{}, 1, 4, "unsupported tag !!set"},
+		{"unsupported scalar", "a: !<tag:example.com,2026:x> 1", YAMLOptions{}, 1, 4, "unsupported tag !<tag:example.com,2026:x>"},
+		{"named handle", "a: !e!tag x", YAMLOptions{}, 1, 4, "unsupported tag !e!tag"},
+		{"named handle on mapping", "a: !e!tag\n  b: 1", YAMLOptions{TagHandler: cfnTags}, 1, 4, "unsupported tag !e!tag"},
+		{"unterminated verbatim", "a: !<x 1", YAMLOptions{}, 1, 4, "expected '>' to end the verbatim tag"},
+		{"alias", "a: &x 1\nb: !!str *x", YAMLOptions{}, 2, 10, "alias *x cannot have a tag"},
+		{"key", "!!str a: 1", YAMLOptions{}, 1, 1, "anchors, aliases, and tags are not supported on mapping keys"},
+		{"flow key", "{!!str a: 1}", YAMLOptions{}, 1, 1, "anchors, aliases, and tags are not supported on mapping keys"},
+		{"local without handler", "a: !Ref x", YAMLOptions{}, 1, 4, "unsupported tag !Ref; set YAMLOptions.TagHandler to convert local tags"},
+		{"local on sequence item", "- 1\n- !Ref x", YAMLOptions{}, 2, 3, "unsupported tag !Ref; set YAMLOptions.TagHandler to convert local tags"},
+		{"local on mapping", "a: !Ref\n  b: 1", YAMLOptions{}, 1, 4, "unsupported tag !Ref; set YAMLOptions.TagHandler to convert local tags"},
+		{"local in flow", "a: [1, !Ref x]", YAMLOptions{}, 1, 8, "unsupported tag !Ref; set YAMLOptions.TagHandler to convert local tags"},
+		{"local in multi-line flow", "a: [1,\n  !Ref x]", YAMLOptions{}, 1, 4, "unsupported tag !Ref; set YAMLOptions.TagHandler to convert local tags"},
+		{"non-finite float", "a: !!float -.inf", YAMLOptions{}, 1, 12, "-.inf is not representable in JSON"},
+		{"handler error", "a: !If [x]", YAMLOptions{TagHandler: cfnTags}, 1, 8, "tag !If: unknown tag"},
+		{"handler invalid JSON", "a: !Ref x", YAMLOptions{TagHandler: invalidJSON}, 1, 9, `tag !Ref: TagHandler returned invalid JSON "{"`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := FromYAMLWithOptions([]byte(tc.in), tc.opts)
+			pe := requireParseError(t, err)
+			if pe.Line != tc.line || pe.Column != tc.col || pe.Message != tc.msg {
+				t.Errorf("got %d:%d %q, want %d:%d %q", pe.Line, pe.Column, pe.Message, tc.line, tc.col, tc.msg)
+			}
+		})
+	}
+}
+
+func TestYAMLTagsSourceMap(t *testing.T) {
+	src := []byte("a: !!str 1\nb: !!int '2'\nc: [!!str x]\nd: !!map\n  e: 1\n")
+	out, sm, err := FromYAMLWithSourceMap(src)
+	if err != nil || string(out) != `{"a":"1","b":2,"c":["x"],"d":{"e":1}}` {
+		t.Fatalf("got %s, %v", out, err)
+	}
+	for _, tc := range []struct {
+		ptr       string
+		line, col int
+	}{
+		{"/a", 1, 10},
+		{"/b", 2, 10},
+		{"/c/0", 3, 11},
+		{"/d/e", 5, 6},
+	} {
+		s, ok := sm.Lookup(tc.ptr)
+		if !ok || s.Start.Line != tc.line || s.Start.Column != tc.col {
+			t.Errorf("%s: got %+v, %v, want %d:%d", tc.ptr, s, ok, tc.line, tc.col)
+		}
+	}
+}
+
+func TestYAMLTagsMaxErrors(t *testing.T) {
+	_, err := FromYAMLWithOptions([]byte("a: !!int x\nb: !Ref y\nc: 1\n"), YAMLOptions{MaxErrors: 10})
+	errs, ok := err.(ParseErrors)
+	if !ok || len(errs) != 2 || !strings.Contains(errs[1].Message, "!Ref") {
+		t.Errorf("got %v", err)
+	}
+}
@@ -51,20 +51,11 @@ func TestYAMLScalars(t *testing.T) {
 	roundtripYAML(t, `"hello world"`, `"hello world"`)
 	roundtripYAML(t, `'it''s fine'`, `"it's fine"`)
 	roundtripYAML(t, `null`, `null`)
-	if yamlTildeNull {
-		roundtripYAML(t, `~`, `null`)
-	} else {
-		roundtripYAML(t, `~`, `"~"`)
-	}
+	roundtripYAML(t, `~`, `"~"`)
 	roundtripYAML(t, `true`, `true`)
 	roundtripYAML(t, `false`, `false`)
-	if yamlBoolAliases {
-		roundtripYAML(t, `yes`, `true`)
-		roundtripYAML(t, `no`, `false`)
-	} else {
-		roundtripYAML(t, `yes`, `"yes"`)
-		roundtripYAML(t, `no`, `"no"`)
-	}
+	roundtripYAML(t, `yes`, `"yes"`)
+	roundtripYAML(t, `no`, `"no"`)
 	roundtripYAML(t, `42`, `42`)
 	roundtripYAML(t, `3.14`, `3.14`)
 	roundtripYAML(t, `-7`, `-7`)
@@ -193,15 +184,46 @@ func TestYAMLQuotedKeys(t *testing.T) {
 }
 
 func TestYAMLNullValues(t *testing.T) {
-	bVal := `"~"`
-	if yamlTildeNull {
-		bVal = `null`
-	}
 	roundtripYAML(t, `
 a: null
 b: ~
 c:
-`, `{"a":null,"b":`+bVal+`,"c":null}`)
+`, `{"a":null,"b":"~","c":null}`)
+}
+
+func TestYAMLOptions(t *testing.T) {
+	cases := []struct {
+		name  string
+		opts  YAMLOptions
+		input string
+		want  string
+	}{
+		{"tilde default", YAMLOptions{}, "a: ~\nb: [~]", `{"a":"~","b":["~"]}`},
+		{"tilde null", YAMLOptions{TildeNull: true}, "a: ~\nb: [~]", `{"a":null,"b":[null]}`},
+		{"bool aliases default", YAMLOptions{}, "a: yes\nb: Off", `{"a":"yes","b":"Off"}`},
+		{"bool aliases", YAMLOptions{BoolAliases: true}, "a: yes\nb: Off\nc: {d: ON}", `{"a":true,"b":false,"c":{"d":true}}`},
+		{"tab width default", YAMLOptions{}, "a:\n\tb: 1\n  c: 2", `{"a":{"b":1,"c":2}}`},
+		{"tab width 4", YAMLOptions{TabWidth: 4}, "a:\n\tb: 1\n    c: 2", `{"a":{"b":1,"c":2}}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := FromYAMLWithOptions([]byte(tc.input), tc.opts)
+			if err != nil {
+				t.Fatalf("FromYAMLWithOptions error: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestYAMLOptionsTabsForbidden(t *testing.T) {
+	_, err := FromYAMLWithOptions([]byte("a:\n\tb: 1"), YAMLOptions{TabWidth: -1})
+	pe := requireParseError(t, err)
+	if pe.Line != 2 {
+		t.Errorf("expected line 2, got %d (msg: %s)", pe.Line, pe.Message)
+	}
 }
 
 func TestYAMLMixedNested(t *testing.T) {
@@ -520,6 +542,7 @@ func TestYAMLBlockScalarEmptyBody(t *testing.T) {
 func TestYAMLSequenceEmptyDash(t *testing.T) {
 	roundtripYAML(t, "-\n  name: Alice", `[{"name":"Alice"}]`)
 	roundtripYAML(t, "-\n  nested: value\n-\n  nested: other", `[{"nested":"value"},{"nested":"other"}]`)
+	roundtripYAML(t, "-\n- x\n-", `[null,"x",null]`)
 }
 
 func TestYAMLQuotedMapKeys(t *testing.T) {
@@ -563,7 +586,8 @@ func TestParseFlowExprDirect(t *testing.T) {
 	}
 	for _, tc := range cases {
 		var buf bytes.Buffer
-		if err := parseFlowExpr([]byte(tc.in), &buf); err != nil {
+		var p parser
+		if err := p.parseFlowExpr([]byte(tc.in), &buf); err != nil {
 			t.Errorf("parseFlowExpr(%q): unexpected error: %v", tc.in, err)
 			continue
 		}